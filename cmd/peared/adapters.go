@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+)
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, so `discoverable on --timeout 3m` works as well as
+// `discoverable --timeout 3m on`.
+func parseInterspersed(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flagSet.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flagSet.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "yes", "true":
+		return true, nil
+	case "off", "no", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected on or off, got %q", value)
+	}
+}
+
+// adapterCommandSetup holds what every adapter property command needs.
+type adapterCommandSetup struct {
+	adapter daemon.Adapter
+	backend daemon.AdapterBackend
+}
+
+// setupAdapterCommand resolves identifier (ID, address or alias) to a
+// discovered adapter, falling back to the configured preferred adapter when it
+// is empty, and builds a bluetoothctl-backed AdapterBackend for it.
+func setupAdapterCommand(identifier string, noSudo bool, configPath string) (adapterCommandSetup, error) {
+	ctx := context.Background()
+
+	if strings.TrimSpace(identifier) == "" {
+		chosen, err := determineAdapter(ctx, "", configPath, "")
+		if err != nil {
+			return adapterCommandSetup{}, fmt.Errorf("determine adapter: %w", err)
+		}
+		identifier = chosen
+	}
+
+	adapters, err := daemon.DefaultAdapterProvider().ListAdapters(ctx)
+	if err != nil {
+		return adapterCommandSetup{}, fmt.Errorf("discover adapters: %w", err)
+	}
+
+	var adapter daemon.Adapter
+	found := false
+	for _, candidate := range adapters {
+		if candidate.Matches(identifier) {
+			adapter, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return adapterCommandSetup{}, fmt.Errorf("adapter %q is not present", identifier)
+	}
+
+	runner, _, err := newBluetoothRunner(noSudo, adapter.ID, configPath, "")
+	if err != nil {
+		return adapterCommandSetup{}, fmt.Errorf("set up bluetoothctl runner: %w", err)
+	}
+
+	return adapterCommandSetup{adapter: adapter, backend: daemon.NewBluetoothctlAdapterBackend(runner)}, nil
+}
+
+func adapterFlagSet(name string) (*flag.FlagSet, *bool, *string) {
+	flagSet := flag.NewFlagSet("adapters "+name, flag.ExitOnError)
+	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	return flagSet, noSudo, configPath
+}
+
+func setAdapterPower(args []string) {
+	flagSet, noSudo, configPath := adapterFlagSet("power")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(positional) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: peared adapters power on|off <adapter>\n")
+		os.Exit(2)
+	}
+
+	on, err := parseSwitch(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "power: %v\n", err)
+		os.Exit(2)
+	}
+
+	setup, err := setupAdapterCommand(positional[1], *noSudo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := setup.backend.SetPowered(context.Background(), setup.adapter.ID, on); err != nil {
+		handleDeviceCommandError(fmt.Sprintf("power %s %s", positional[0], setup.adapter.ID), err)
+		os.Exit(1)
+	}
+}
+
+func setAdapterAlias(args []string) {
+	flagSet, noSudo, configPath := adapterFlagSet("alias")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(positional) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: peared adapters alias <adapter> <name>\n")
+		os.Exit(2)
+	}
+
+	setup, err := setupAdapterCommand(positional[0], *noSudo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	alias := strings.Join(positional[1:], " ")
+	if err := setup.backend.SetAlias(context.Background(), setup.adapter.ID, alias); err != nil {
+		handleDeviceCommandError(fmt.Sprintf("alias %s", setup.adapter.ID), err)
+		os.Exit(1)
+	}
+}
+
+// setAdapterVisibility implements the discoverable and pairable commands,
+// which share their syntax.
+func setAdapterVisibility(property string, args []string) {
+	flagSet, noSudo, configPath := adapterFlagSet(property)
+	timeout := flagSet.Duration("timeout", 0, "Turn the property off again after this long (0 keeps the BlueZ setting)")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(positional) < 1 || len(positional) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: peared adapters %s on|off [<adapter>] [--timeout <duration>]\n", property)
+		os.Exit(2)
+	}
+
+	on, err := parseSwitch(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", property, err)
+		os.Exit(2)
+	}
+
+	identifier := ""
+	if len(positional) == 2 {
+		identifier = positional[1]
+	}
+
+	setup, err := setupAdapterCommand(identifier, *noSudo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if property == "discoverable" {
+		err = setup.backend.SetDiscoverable(ctx, setup.adapter.ID, on, *timeout)
+	} else {
+		err = setup.backend.SetPairable(ctx, setup.adapter.ID, on, *timeout)
+	}
+	if err != nil {
+		handleDeviceCommandError(fmt.Sprintf("%s %s %s", property, positional[0], setup.adapter.ID), err)
+		os.Exit(1)
+	}
+}
+
+func showAdapter(args []string) {
+	flagSet, noSudo, configPath := adapterFlagSet("show")
+	asJSON := flagSet.Bool("json", false, "Print the adapter as JSON")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if len(positional) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: peared adapters show [<adapter>]\n")
+		os.Exit(2)
+	}
+
+	identifier := ""
+	if len(positional) == 1 {
+		identifier = positional[0]
+	}
+
+	setup, err := setupAdapterCommand(identifier, *noSudo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	adapter, err := setup.backend.DescribeAdapter(context.Background(), setup.adapter)
+	if err != nil {
+		handleDeviceCommandError(fmt.Sprintf("show %s", setup.adapter.ID), err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(adapter); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode adapter: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	writeAdapter(os.Stdout, adapter)
+}
+
+// writeAdapterList prints one line per adapter. The long form appends the
+// hardware details read from sysfs.
+func writeAdapterList(out io.Writer, adapters []daemon.Adapter, long bool) {
+	for _, adapter := range adapters {
+		powered := "off"
+		if adapter.Powered {
+			powered = "on"
+		}
+
+		line := fmt.Sprintf("%s\t%s\t%s\t%s", adapter.ID, adapter.Address, valueOr(adapter.Alias, "(no alias)"), powered)
+		if long {
+			ids := "-"
+			if adapter.VendorID != "" {
+				ids = adapter.VendorID + ":" + adapter.ProductID
+			}
+			line += fmt.Sprintf("\t%s\t%s\t%s\t%s\t%s", valueOr(string(adapter.Transport), "unknown"), ids, valueOr(adapter.Chipset, "-"), valueOr(adapter.Driver, "-"), valueOr(adapter.Bus, "-"))
+		}
+
+		fmt.Fprintln(out, line)
+	}
+}
+
+// selectionPolicy builds the adapter selection policy from the configuration
+// exactly as pearedd does.
+func selectionPolicy(cfg *config.Config) (daemon.SelectionPolicy, error) {
+	selection := cfg.Daemon.AdapterSelection
+	policy, err := daemon.NewSelectionPolicy(selection.Prefer, selection.Exclude, selection.Tiebreak)
+	if err != nil {
+		return daemon.SelectionPolicy{}, fmt.Errorf("daemon.adapter_selection: %w", err)
+	}
+	return policy, nil
+}
+
+func explainAdapters(args []string) {
+	flagSet := flag.NewFlagSet("adapters explain", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	asJSON := flagSet.Bool("json", false, "Print the ranking as JSON")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	policy, err := selectionPolicy(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	adapters, err := daemon.DefaultAdapterProvider().ListAdapters(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list adapters: %v\n", err)
+		os.Exit(1)
+	}
+
+	ranked := policy.Explain(cfg.Daemon.PreferredAdapter, adapters)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ranked); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode ranking: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	writeExplanation(os.Stdout, policy, ranked)
+}
+
+func writeExplanation(out io.Writer, policy daemon.SelectionPolicy, ranked []daemon.AdapterScore) {
+	if len(ranked) == 0 {
+		fmt.Fprintf(out, "No adapters detected.\n")
+		return
+	}
+
+	fmt.Fprintf(out, "Tiebreak: %s\n", valueOr(string(policy.Tiebreak), string(daemon.TiebreakUSB)))
+	for i, entry := range ranked {
+		marker := " "
+		if i == 0 && !entry.Excluded {
+			marker = "*"
+		}
+
+		score := fmt.Sprintf("score %d", entry.Score)
+		if entry.Excluded {
+			score = "excluded"
+		}
+
+		fmt.Fprintf(out, "%s %s\t%s\t%s\t%s\n", marker, entry.Adapter.ID, valueOr(entry.Adapter.Alias, "(no alias)"), score, strings.Join(entry.Reasons, "; "))
+	}
+}
+
+func writeAdapter(out io.Writer, adapter daemon.Adapter) {
+	fmt.Fprintf(out, "Adapter %s (%s)\n", adapter.ID, valueOr(adapter.Address, "no address"))
+	fmt.Fprintf(out, "  Alias:         %s\n", valueOr(adapter.Alias, "(no alias)"))
+	fmt.Fprintf(out, "  Transport:     %s\n", valueOr(string(adapter.Transport), "unknown"))
+	if adapter.VendorID != "" {
+		fmt.Fprintf(out, "  Hardware:      %s:%s %s\n", adapter.VendorID, adapter.ProductID, adapter.Chipset)
+	}
+	if adapter.Driver != "" {
+		fmt.Fprintf(out, "  Driver:        %s\n", adapter.Driver)
+	}
+	fmt.Fprintf(out, "  Powered:       %s\n", yesNo(adapter.Powered))
+	fmt.Fprintf(out, "  Discoverable:  %s%s\n", yesNo(adapter.Discoverable), timeoutSuffix(adapter.DiscoverableTimeout))
+	fmt.Fprintf(out, "  Pairable:      %s%s\n", yesNo(adapter.Pairable), timeoutSuffix(adapter.PairableTimeout))
+	if adapter.SoftBlocked || adapter.HardBlocked {
+		fmt.Fprintf(out, "  Blocked:       soft=%s hard=%s\n", yesNo(adapter.SoftBlocked), yesNo(adapter.HardBlocked))
+	}
+	fmt.Fprintf(out, "  Class:         0x%06x\n", adapter.Class)
+	if adapter.Version != 0 {
+		fmt.Fprintf(out, "  HCI version:   %d\n", adapter.Version)
+	}
+	if adapter.Manufacturer != 0 {
+		fmt.Fprintf(out, "  Manufacturer:  %d\n", adapter.Manufacturer)
+	}
+	if len(adapter.Roles) > 0 {
+		fmt.Fprintf(out, "  Roles:         %s\n", strings.Join(adapter.Roles, ", "))
+	}
+	if len(adapter.UUIDs) > 0 {
+		fmt.Fprintf(out, "  UUIDs:\n")
+		for _, uuid := range adapter.UUIDs {
+			fmt.Fprintf(out, "    %s\n", uuid)
+		}
+	}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func timeoutSuffix(timeout time.Duration) string {
+	if timeout <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (timeout %s)", timeout)
+}
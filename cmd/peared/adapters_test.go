@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	timeout := flagSet.Duration("timeout", 0, "")
+
+	positional, err := parseInterspersed(flagSet, []string{"on", "--timeout", "3m", "hci1"})
+	if err != nil {
+		t.Fatalf("parseInterspersed returned error: %v", err)
+	}
+
+	if strings.Join(positional, " ") != "on hci1" {
+		t.Fatalf("unexpected positional arguments: %v", positional)
+	}
+	if *timeout != 3*time.Minute {
+		t.Fatalf("unexpected timeout: %s", *timeout)
+	}
+}
+
+func TestParseSwitch(t *testing.T) {
+	if on, err := parseSwitch("ON"); err != nil || !on {
+		t.Fatalf("expected on, got %v (%v)", on, err)
+	}
+	if on, err := parseSwitch("off"); err != nil || on {
+		t.Fatalf("expected off, got %v (%v)", on, err)
+	}
+	if _, err := parseSwitch("maybe"); err == nil {
+		t.Fatal("expected error for invalid switch")
+	}
+}
+
+func TestWriteAdapter(t *testing.T) {
+	var out bytes.Buffer
+	writeAdapter(&out, daemon.Adapter{
+		ID:                  "hci0",
+		Address:             "00:1A:7D:DA:71:13",
+		Alias:               "Desk",
+		Transport:           daemon.AdapterTransportUSB,
+		Powered:             true,
+		Discoverable:        true,
+		DiscoverableTimeout: 3 * time.Minute,
+		Class:               0x6c010c,
+		Version:             10,
+		Roles:               []string{"central", "peripheral"},
+		UUIDs:               []string{"0000110a-0000-1000-8000-00805f9b34fb"},
+	})
+
+	got := out.String()
+	for _, want := range []string{
+		"Adapter hci0 (00:1A:7D:DA:71:13)",
+		"Discoverable:  yes (timeout 3m0s)",
+		"Pairable:      no\n",
+		"Class:         0x6c010c",
+		"HCI version:   10",
+		"Roles:         central, peripheral",
+		"    0000110a-0000-1000-8000-00805f9b34fb",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Manufacturer") {
+		t.Errorf("unexpected manufacturer line:\n%s", got)
+	}
+}
+
+func TestWriteAdapterListLong(t *testing.T) {
+	adapters := []daemon.Adapter{
+		{ID: "hci0", Address: "AA:BB:CC:DD:EE:FF", Powered: true, Transport: daemon.AdapterTransportUSB, VendorID: "0bda", ProductID: "8771", Chipset: "Realtek RTL8761B", Driver: "btusb", Bus: "USB"},
+		{ID: "hci1", Address: "11:22:33:44:55:66", Alias: "Onboard"},
+	}
+
+	var short bytes.Buffer
+	writeAdapterList(&short, adapters, false)
+	if got := short.String(); got != "hci0\tAA:BB:CC:DD:EE:FF\t(no alias)\ton\nhci1\t11:22:33:44:55:66\tOnboard\toff\n" {
+		t.Fatalf("unexpected short output: %q", got)
+	}
+
+	var long bytes.Buffer
+	writeAdapterList(&long, adapters, true)
+	lines := strings.Split(strings.TrimSpace(long.String()), "\n")
+	if lines[0] != "hci0\tAA:BB:CC:DD:EE:FF\t(no alias)\ton\tusb\t0bda:8771\tRealtek RTL8761B\tbtusb\tUSB" {
+		t.Fatalf("unexpected long output: %q", lines[0])
+	}
+	if lines[1] != "hci1\t11:22:33:44:55:66\tOnboard\toff\tunknown\t-\t-\t-\t-" {
+		t.Fatalf("unexpected long output for bare adapter: %q", lines[1])
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	policy, err := daemon.NewSelectionPolicy(nil, []map[string]string{{"vendor_id": "8087"}}, "")
+	if err != nil {
+		t.Fatalf("NewSelectionPolicy returned error: %v", err)
+	}
+
+	ranked := policy.Explain("", []daemon.Adapter{
+		{ID: "hci0", Alias: "Onboard", VendorID: "8087"},
+		{ID: "hci1", Alias: "Dongle", VendorID: "0bda"},
+	})
+
+	var out bytes.Buffer
+	writeExplanation(&out, policy, ranked)
+
+	want := "Tiebreak: usb\n" +
+		"* hci1\tDongle\tscore 0\tmatches no prefer rule\n" +
+		"  hci0\tOnboard\texcluded\texcluded by rule vendor_id=8087\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected output:\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/peared/peared/internal/agent"
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func runAgent(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "pin":
+			runAgentPin(args[1:])
+			return
+		case "reply":
+			replyToPrompt(args[1:])
+			return
+		case "help", "-h", "--help":
+			agentUsage()
+			return
+		}
+	}
+
+	flagSet := flag.NewFlagSet("agent", flag.ExitOnError)
+	device := flagSet.String("device", "", "Only answer prompts for this device address")
+	socket := flagSet.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse agent flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Waiting for pairing requests. Press Ctrl+C to stop.\n")
+	if err := answerPrompts(ctx, *socket, *device, newLineReader(os.Stdin), os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			fmt.Fprintf(os.Stderr, "pearedd is not running; start it to answer pairing requests.\n")
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "pairing agent failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func agentUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: peared agent [<command>] [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Without a command, answer the daemon's pairing prompts interactively.\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  reply <id> yes|no|<value>    Answer a pending prompt non-interactively\n")
+	fmt.Fprintf(os.Stderr, "  pin set <addr> [<pin>]       Store a PIN or passkey (read from stdin when omitted)\n")
+	fmt.Fprintf(os.Stderr, "  pin remove <addr>            Forget a stored PIN\n")
+	fmt.Fprintf(os.Stderr, "  pin list                     List devices with a stored PIN\n")
+}
+
+func replyToPrompt(args []string) {
+	flagSet := flag.NewFlagSet("agent reply", flag.ExitOnError)
+	socket := flagSet.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse agent flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if flagSet.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "reply requires a prompt ID and an answer\n")
+		os.Exit(2)
+	}
+
+	accept, value := true, flagSet.Arg(1)
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		value = ""
+	case "no", "n":
+		accept, value = false, ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := sendReply(ctx, *socket, flagSet.Arg(0), accept, value); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to answer prompt %s: %v\n", flagSet.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func runAgentPin(args []string) {
+	if len(args) == 0 {
+		agentUsage()
+		os.Exit(2)
+	}
+
+	store, err := agent.NewPinStore("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open PIN store: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			fmt.Fprintf(os.Stderr, "pin set requires a device address and optionally a PIN\n")
+			os.Exit(2)
+		}
+		var pin string
+		if len(args) == 3 {
+			pin = args[2]
+		} else {
+			// Reading the PIN from stdin keeps it out of shell history.
+			if isInteractive(os.Stdin) {
+				fmt.Fprintf(os.Stderr, "PIN for %s: ", args[1])
+			}
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				fmt.Fprintf(os.Stderr, "failed to read PIN: %v\n", err)
+				os.Exit(1)
+			}
+			pin = strings.TrimSpace(line)
+		}
+		if err := store.Set(args[1], pin); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to store PIN: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "Stored PIN for %s in %s.\n", daemon.NormalizeAddress(args[1]), store.Dir())
+	case "remove":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "pin remove requires a device address\n")
+			os.Exit(2)
+		}
+		if err := store.Delete(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to remove PIN: %v\n", err)
+			os.Exit(1)
+		}
+	case "list":
+		addresses, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to list PINs: %v\n", err)
+			os.Exit(1)
+		}
+		if len(addresses) == 0 {
+			fmt.Fprintf(os.Stdout, "No PINs stored.\n")
+			return
+		}
+		for _, address := range addresses {
+			fmt.Fprintln(os.Stdout, address)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown pin command: %s\n\n", args[0])
+		agentUsage()
+		os.Exit(2)
+	}
+}
+
+// answerPrompts follows the daemon's pairing events and asks the user about
+// each prompt until ctx is cancelled. When device is set, other devices'
+// prompts are left for someone else to answer. It fails when the daemon's
+// pairing agent is not running.
+func answerPrompts(ctx context.Context, socket, device string, lines *lineReader, out io.Writer) error {
+	device = daemon.NormalizeAddress(device)
+	wanted := func(prompt daemon.PairingPrompt) bool {
+		return device == "" || prompt.Address == device
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	prompts := make(chan daemon.PairingPrompt, 16)
+	resolved := make(chan string, 16)
+	streamErr := make(chan error, 1)
+
+	// Subscribe before listing pending prompts so prompts raised meanwhile
+	// are not missed. A prompt seen both ways is only asked once.
+	go func() {
+		streamErr <- control.Events(ctx, socket, func(raw json.RawMessage) error {
+			var ev daemon.Event
+			if err := json.Unmarshal(raw, &ev); err != nil || ev.Prompt == nil || !wanted(*ev.Prompt) {
+				return nil
+			}
+			switch ev.Type {
+			case daemon.EventPairingRequest:
+				return deliver(ctx, prompts, *ev.Prompt)
+			case daemon.EventPairingDisplay:
+				fmt.Fprintf(out, "%s\n", ev.Message)
+			case daemon.EventPairingResolved:
+				if ev.Prompt.ID != "" {
+					return deliver(ctx, resolved, ev.Prompt.ID)
+				}
+			}
+			return nil
+		})
+	}()
+
+	pending, err := pendingPrompts(ctx, socket)
+	if err != nil {
+		return err
+	}
+	for _, prompt := range pending {
+		if wanted(prompt) {
+			if err := deliver(ctx, prompts, prompt); err != nil {
+				return err
+			}
+		}
+	}
+
+	done := make(map[string]bool)
+	for {
+		var prompt daemon.PairingPrompt
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-streamErr:
+			return err
+		case id := <-resolved:
+			done[id] = true
+			continue
+		case prompt = <-prompts:
+		}
+		if done[prompt.ID] {
+			continue
+		}
+
+		accept, value, err := askPrompt(ctx, prompt, lines, resolved, done, out)
+		if err != nil {
+			return err
+		}
+		if done[prompt.ID] {
+			fmt.Fprintf(out, "Prompt %s was answered elsewhere.\n", prompt.ID)
+			continue
+		}
+		done[prompt.ID] = true
+
+		if err := sendReply(ctx, socket, prompt.ID, accept, value); err != nil {
+			fmt.Fprintf(out, "Failed to answer prompt %s: %v\n", prompt.ID, err)
+		}
+	}
+}
+
+// deliver sends v on ch unless ctx is cancelled first.
+func deliver[T any](ctx context.Context, ch chan<- T, v T) error {
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lineReader reads lines on demand. Nothing is read from a shared terminal
+// until someone asks for a line, so bluetoothctl can read its own prompts'
+// answers in between.
+type lineReader struct {
+	want chan struct{}
+	c    chan string // closed once input ends
+}
+
+func newLineReader(in io.Reader) *lineReader {
+	r := &lineReader{want: make(chan struct{}, 1), c: make(chan string)}
+	go func() {
+		reader := bufio.NewReader(in)
+		for range r.want {
+			line, err := reader.ReadString('\n')
+			if line != "" || err == nil {
+				r.c <- line
+			}
+			if err != nil {
+				close(r.c)
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// request asks for the next line to be delivered on r.c. Repeated requests
+// before it arrives read only one line.
+func (r *lineReader) request() {
+	select {
+	case r.want <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the next line. It returns io.EOF once input ends.
+func (r *lineReader) next(ctx context.Context) (string, error) {
+	r.request()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-r.c:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+}
+
+// askPrompt asks until it gets a usable answer, the prompt is resolved
+// elsewhere (recorded in done) or input ends.
+func askPrompt(ctx context.Context, prompt daemon.PairingPrompt, lines *lineReader, resolved <-chan string, done map[string]bool, out io.Writer) (bool, string, error) {
+	for {
+		fmt.Fprintf(out, "[%s] %s", prompt.ID, promptQuestion(prompt))
+		lines.request()
+
+		select {
+		case <-ctx.Done():
+			return false, "", ctx.Err()
+		case id := <-resolved:
+			done[id] = true
+			if id == prompt.ID {
+				fmt.Fprintln(out)
+				return false, "", nil
+			}
+			fmt.Fprintln(out)
+			continue
+		case line, ok := <-lines.c:
+			if !ok {
+				return false, "", io.EOF
+			}
+			accept, value, err := parseAnswer(prompt, line)
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+				continue
+			}
+			return accept, value, nil
+		}
+	}
+}
+
+// promptQuestion renders the question asked for prompt.
+func promptQuestion(prompt daemon.PairingPrompt) string {
+	if prompt.NeedsValue() {
+		return prompt.Describe() + " (empty to reject): "
+	}
+	return prompt.Describe() + "? [y/N]: "
+}
+
+// parseAnswer interprets a line typed in reply to prompt.
+func parseAnswer(prompt daemon.PairingPrompt, line string) (bool, string, error) {
+	answer := strings.TrimSpace(line)
+
+	if prompt.NeedsValue() {
+		if answer == "" {
+			return false, "", nil
+		}
+		if prompt.Kind == agent.KindPasskey {
+			if _, err := agent.ParsePasskey(answer); err != nil {
+				return false, "", err
+			}
+		} else if err := agent.ValidatePinCode(answer); err != nil {
+			return false, "", err
+		}
+		return true, answer, nil
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, "", nil
+	case "", "n", "no":
+		return false, "", nil
+	default:
+		return false, "", fmt.Errorf("please answer y or n")
+	}
+}
+
+// pendingPrompts lists the prompts already waiting when the CLI starts.
+func pendingPrompts(ctx context.Context, socket string) ([]daemon.PairingPrompt, error) {
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := control.Call(callCtx, socket, control.Request{Command: "pairing.prompts"})
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+
+	var prompts []daemon.PairingPrompt
+	if err := resp.Decode(&prompts); err != nil {
+		return nil, fmt.Errorf("decode pairing prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+func sendReply(ctx context.Context, socket, id string, accept bool, value string) error {
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := control.Call(callCtx, socket, control.Request{
+		Command: "pairing.reply",
+		Args:    map[string]string{"id": id, "accept": strconv.FormatBool(accept), "value": value},
+	})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
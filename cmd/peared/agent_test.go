@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/agent"
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+// fakePairingDaemon serves pairing prompts over a control socket and records
+// the replies it receives.
+type fakePairingDaemon struct {
+	pending []daemon.PairingPrompt
+	stream  []daemon.Event
+
+	mu      sync.Mutex
+	replies []map[string]string
+	replied chan struct{}
+}
+
+func (f *fakePairingDaemon) HandleControl(_ context.Context, req control.Request) control.Response {
+	switch req.Command {
+	case "pairing.prompts":
+		return control.OK(f.pending)
+	case "pairing.reply":
+		f.mu.Lock()
+		f.replies = append(f.replies, req.Args)
+		f.mu.Unlock()
+		f.replied <- struct{}{}
+		return control.OK(nil)
+	default:
+		return control.Errorf("unknown command %q", req.Command)
+	}
+}
+
+func (f *fakePairingDaemon) HandleStream(ctx context.Context, _ control.Request, send func(any) error) error {
+	for _, ev := range f.stream {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestAnswerPrompts(t *testing.T) {
+	const keyboard = "33:33:33:33:33:33"
+	fake := &fakePairingDaemon{
+		pending: []daemon.PairingPrompt{{ID: "1", Kind: agent.KindConfirmation, Address: keyboard, Passkey: "123456"}},
+		stream: []daemon.Event{
+			{Type: daemon.EventPairingRequest, Prompt: &daemon.PairingPrompt{ID: "1", Kind: agent.KindConfirmation, Address: keyboard, Passkey: "123456"}},
+			{Type: daemon.EventPairingRequest, Prompt: &daemon.PairingPrompt{ID: "2", Kind: agent.KindPinCode, Address: "44:44:44:44:44:44"}},
+			{Type: daemon.EventPairingRequest, Prompt: &daemon.PairingPrompt{ID: "3", Kind: agent.KindPasskey, Address: keyboard}},
+		},
+		replied: make(chan struct{}, 4),
+	}
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	ln, err := control.Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go control.Serve(ctx, ln, fake)
+
+	// The first answer is retried after an invalid reply; the pin-code
+	// prompt belongs to another device and is never asked.
+	in := newLineReader(strings.NewReader("maybe\ny\n012345\n"))
+	var out bytes.Buffer
+
+	promptCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- answerPrompts(promptCtx, socket, strings.ToLower(keyboard), in, &out) }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fake.replied:
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for replies; output:\n%s", out.String())
+		}
+	}
+	stop()
+	<-done
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.replies) != 2 {
+		t.Fatalf("expected two replies, got %v", fake.replies)
+	}
+	if got := fake.replies[0]; got["id"] != "1" || got["accept"] != "true" {
+		t.Fatalf("unexpected confirmation reply %v", got)
+	}
+	if got := fake.replies[1]; got["id"] != "3" || got["accept"] != "true" || got["value"] != "012345" {
+		t.Fatalf("unexpected passkey reply %v", got)
+	}
+	if !strings.Contains(out.String(), "please answer y or n") {
+		t.Fatalf("expected the invalid answer to be reported:\n%s", out.String())
+	}
+}
+
+func TestParseAnswer(t *testing.T) {
+	confirm := daemon.PairingPrompt{Kind: agent.KindConfirmation}
+	if accept, _, err := parseAnswer(confirm, "Yes\n"); err != nil || !accept {
+		t.Fatalf("parseAnswer(yes) = %v, %v", accept, err)
+	}
+	if accept, _, err := parseAnswer(confirm, "\n"); err != nil || accept {
+		t.Fatalf("expected an empty answer to reject, got %v, %v", accept, err)
+	}
+
+	pin := daemon.PairingPrompt{Kind: agent.KindPinCode}
+	if accept, value, err := parseAnswer(pin, " 0000 \n"); err != nil || !accept || value != "0000" {
+		t.Fatalf("parseAnswer(pin) = %v, %q, %v", accept, value, err)
+	}
+
+	passkey := daemon.PairingPrompt{Kind: agent.KindPasskey}
+	if _, _, err := parseAnswer(passkey, "12ab"); err == nil {
+		t.Fatal("expected an invalid passkey to be refused")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/peared/peared/config/examples"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func runConfig(args []string) {
+	if len(args) == 0 {
+		configUsage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "check":
+		checkConfig(args[1:])
+	case "show":
+		showConfig(args[1:])
+	case "get":
+		getConfig(args[1:])
+	case "set":
+		setConfig(args[1:])
+	case "init":
+		initConfig(args[1:])
+	case "edit":
+		editConfig(args[1:])
+	case "help", "-h", "--help":
+		configUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n\n", args[0])
+		configUsage()
+		os.Exit(2)
+	}
+}
+
+func configUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: peared config <command> [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  check    Report every problem in the configuration\n")
+	fmt.Fprintf(os.Stderr, "  show     Print the effective value of every setting\n")
+	fmt.Fprintf(os.Stderr, "  get      Print the effective value of a setting, such as daemon.poll_interval\n")
+	fmt.Fprintf(os.Stderr, "  set      Change a setting in the user configuration file\n")
+	fmt.Fprintf(os.Stderr, "  init     Write a starter configuration file\n")
+	fmt.Fprintf(os.Stderr, "  edit     Open the configuration file in $VISUAL or $EDITOR\n\n")
+	fmt.Fprintf(os.Stderr, "Settings are read from /etc/peared/config.yaml, the user file, its config.d/*.yaml\n")
+	fmt.Fprintf(os.Stderr, "drop-ins and PEARED_* environment variables such as\n")
+	fmt.Fprintf(os.Stderr, "PEARED_DAEMON_POLL_INTERVAL, each overriding the ones before.\n")
+}
+
+func checkConfig(args []string) {
+	flagSet := flag.NewFlagSet("config check", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			if problem.Source == "" {
+				problem.Source = invalid.Source
+			}
+			fmt.Fprintln(os.Stdout, problem.Qualified())
+		}
+		fmt.Fprintf(os.Stdout, "%d %s found.\n", len(invalid.Problems), plural(len(invalid.Problems), "problem", "problems"))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	adapters, err := daemon.DefaultAdapterProvider().ListAdapters(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "warning: adapter references not checked: %v\n", err)
+	} else {
+		for _, warning := range adapterWarnings(cfg, adapters) {
+			fmt.Fprintf(os.Stdout, "warning: %s\n", warning.Qualified())
+		}
+	}
+
+	if !cfg.Loaded {
+		fmt.Fprintf(os.Stdout, "%s does not exist; the defaults apply.\n", cfg.Source)
+		return
+	}
+	for _, file := range cfg.Files {
+		fmt.Fprintf(os.Stdout, "%s is valid.\n", file)
+	}
+}
+
+func showConfig(args []string) {
+	flagSet := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	origin := flagSet.Bool("origin", false, "Show which file, environment variable or flag set each value")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	writeSettings(os.Stdout, cfg.Settings(), *origin)
+}
+
+// writeSettings prints one "key: value" line per setting, followed by its
+// origin when requested.
+func writeSettings(w io.Writer, settings []config.Setting, origin bool) {
+	if !origin {
+		for _, setting := range settings {
+			fmt.Fprintf(w, "%s: %s\n", setting.Path, setting.Value)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, setting := range settings {
+		fmt.Fprintf(tw, "%s: %s\t# %s\n", setting.Path, setting.Value, setting.Origin)
+	}
+	tw.Flush()
+}
+
+// adapterWarnings reports the adapters the configuration names that are not
+// currently present. They may just be unplugged, so these are not errors.
+func adapterWarnings(cfg *config.Config, adapters []daemon.Adapter) []config.Problem {
+	var ids []string
+	for _, adapter := range adapters {
+		ids = append(ids, adapter.ID)
+	}
+	detected := "no adapters are detected"
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		detected = "detected: " + strings.Join(ids, ", ")
+	}
+
+	var warnings []config.Problem
+	check := func(path, identifier string) {
+		identifier = strings.TrimSpace(identifier)
+		if identifier == "" {
+			return
+		}
+		for _, adapter := range adapters {
+			if adapter.Matches(identifier) {
+				return
+			}
+		}
+		origin := cfg.Origin(path)
+		warnings = append(warnings, config.Problem{
+			Source:  origin.Source,
+			Line:    origin.Line,
+			Column:  origin.Column,
+			Path:    path,
+			Message: fmt.Sprintf("%q matches none of the current adapters (%s)", identifier, detected),
+		})
+	}
+
+	check("daemon.preferred_adapter", cfg.Daemon.PreferredAdapter)
+	for _, key := range sortedConfigKeys(cfg.Adapters) {
+		check("adapters."+key, key)
+	}
+	for _, key := range sortedConfigKeys(cfg.Devices) {
+		check("devices."+key+".adapter", cfg.Devices[key].Adapter)
+	}
+	return warnings
+}
+
+func sortedConfigKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func getConfig(args []string) {
+	flagSet := flag.NewFlagSet("config get", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+	if len(positional) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: peared config get [--config path] <setting>\n")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	settings := settingsUnder(cfg.Settings(), positional[0])
+	switch {
+	case len(settings) == 0:
+		fmt.Fprintf(os.Stderr, "unknown setting %q (see peared config show)\n", positional[0])
+		os.Exit(1)
+	case len(settings) == 1 && settings[0].Path == positional[0]:
+		fmt.Fprintln(os.Stdout, settings[0].Value)
+	default:
+		writeSettings(os.Stdout, settings, false)
+	}
+}
+
+// settingsUnder returns the setting at path or, for a section such as
+// "daemon.reconnect", every setting inside it.
+func settingsUnder(settings []config.Setting, path string) []config.Setting {
+	var matched []config.Setting
+	for _, setting := range settings {
+		if setting.Path == path || strings.HasPrefix(setting.Path, path+".") {
+			matched = append(matched, setting)
+		}
+	}
+	return matched
+}
+
+func setConfig(args []string) {
+	flagSet := flag.NewFlagSet("config set", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	positional, err := parseInterspersed(flagSet, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+	if len(positional) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: peared config set [--config path] <setting> <value>\n")
+		os.Exit(2)
+	}
+	key, value := positional[0], positional[1]
+
+	path, err := config.ResolvePath(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to resolve config path: %v\n", err)
+		os.Exit(1)
+	}
+	doc, err := config.ReadDocument(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := doc.Set(key, value); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set %s: %v\n", key, err)
+		os.Exit(1)
+	}
+
+	cfg := saveConfig(path, doc)
+	if origin := cfg.Origin(key); origin.Layer != config.LayerUser {
+		fmt.Fprintf(os.Stdout, "note: %s is overridden by %s\n", key, origin)
+	}
+}
+
+func initConfig(args []string) {
+	flagSet := flag.NewFlagSet("config init", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	force := flagSet.Bool("force", false, "Replace an existing configuration file")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	path, err := config.ResolvePath(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to resolve config path: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "%s already exists; use --force to replace it\n", path)
+		os.Exit(1)
+	}
+
+	// Without adapters the template keeps its placeholder.
+	adapters, _ := daemon.DefaultAdapterProvider().ListAdapters(context.Background())
+	doc, err := starterConfig(adapters)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to prepare configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	saveConfig(path, doc)
+	fmt.Fprintf(os.Stdout, "Wrote %s.\n", path)
+}
+
+// starterConfig is the example configuration with the preferred adapter
+// filled in from the adapters present, when there are any.
+func starterConfig(adapters []daemon.Adapter) (*config.Document, error) {
+	doc, err := config.ParseDocument(examples.Minimal)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := daemon.SelectAdapter("", adapters)
+	if err != nil {
+		return doc, nil
+	}
+	identifier := adapter.Address
+	if identifier == "" {
+		identifier = adapter.ID
+	}
+	if err := doc.Set("daemon.preferred_adapter", identifier); err != nil {
+		return nil, err
+	}
+
+	var detected []string
+	for _, adapter := range adapters {
+		detected = append(detected, strings.TrimSpace(adapter.ID+" "+adapter.Address))
+	}
+	sort.Strings(detected)
+	doc.Comment("daemon.preferred_adapter", "detected: "+strings.Join(detected, ", "))
+	return doc, nil
+}
+
+// saveConfig validates doc and writes it to path, exiting with the
+// problems when it does not validate.
+func saveConfig(path string, doc *config.Document) *config.Config {
+	data, err := doc.Bytes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Save(path, data)
+	if err != nil {
+		reportConfigError(err)
+		fmt.Fprintf(os.Stderr, "%s was not changed.\n", path)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// reportConfigError prints each problem of a *config.ValidationError on its
+// own line, or err itself.
+func reportConfigError(err error) {
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	for _, problem := range invalid.Problems {
+		if problem.Source == "" {
+			problem.Source = invalid.Source
+		}
+		fmt.Fprintln(os.Stderr, problem.Qualified())
+	}
+}
+
+func editConfig(args []string) {
+	flagSet := flag.NewFlagSet("config edit", flag.ExitOnError)
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	path, err := config.ResolvePath(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to resolve config path: %v\n", err)
+		os.Exit(1)
+	}
+
+	original, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		original, err = examples.Minimal, nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if code := editCopy(path, original); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// editCopy lets the user edit a temporary copy of original until it saves
+// to path or they give up, and returns the exit code. The copy is removed
+// however the edit ends.
+func editCopy(path string, original []byte) int {
+	// The copy keeps the .yaml extension so editors highlight it.
+	tmp, err := os.CreateTemp("", "peared-config-*.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create a copy to edit: %v\n", err)
+		return 1
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create a copy to edit: %v\n", err)
+		return 1
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			fmt.Fprintf(os.Stderr, "editor failed: %v\n", err)
+			return 1
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read the edited copy: %v\n", err)
+			return 1
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Fprintf(os.Stdout, "%s was not changed.\n", path)
+			return 0
+		}
+
+		_, err = config.Save(path, edited)
+		if err == nil {
+			fmt.Fprintf(os.Stdout, "Saved %s.\n", path)
+			return 0
+		}
+		reportConfigError(err)
+
+		fmt.Fprintf(os.Stderr, "Edit again? [Y/n] ")
+		answer, readErr := reader.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); readErr != nil || answer == "n" || answer == "no" {
+			fmt.Fprintf(os.Stderr, "%s was not changed.\n", path)
+			return 1
+		}
+	}
+}
+
+// runEditor opens path in $VISUAL, $EDITOR or vi. The variables may carry
+// arguments, as in "code --wait".
+func runEditor(path string) error {
+	editor := strings.Fields(valueOr(os.Getenv("VISUAL"), valueOr(os.Getenv("EDITOR"), "vi")))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestAdapterWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `daemon:
+  preferred_adapter: hci3
+adapters:
+  "00:11:22:33:44:55":
+    powered: true
+  Dongle:
+    powered: false
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    adapter: hci0
+  "11:22:33:44:55:66":
+    adapter: hci9
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	adapters := []daemon.Adapter{
+		{ID: "hci1", Address: "00:11:22:33:44:55"},
+		{ID: "hci0", Address: "66:77:88:99:AA:BB", Alias: "Laptop"},
+	}
+
+	var got []string
+	for _, warning := range adapterWarnings(cfg, adapters) {
+		got = append(got, warning.String())
+	}
+	want := []string{
+		`2:3: daemon.preferred_adapter: "hci3" matches none of the current adapters (detected: hci0, hci1)`,
+		`6:3: adapters.Dongle: "Dongle" matches none of the current adapters (detected: hci0, hci1)`,
+		`12:5: devices.11:22:33:44:55:66.adapter: "hci9" matches none of the current adapters (detected: hci0, hci1)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected warnings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if warnings := adapterWarnings(&config.Config{Daemon: config.DaemonConfig{PreferredAdapter: "hci0"}}, nil); len(warnings) != 1 ||
+		!strings.Contains(warnings[0].Message, "no adapters are detected") {
+		t.Fatalf("unexpected warnings without adapters: %v", warnings)
+	}
+}
+
+func TestWriteSettings(t *testing.T) {
+	settings := []config.Setting{
+		{Path: "daemon.preferred_adapter", Value: "hci1", Origin: config.Origin{Layer: config.LayerFlag, Source: "--adapter"}},
+		{Path: "daemon.poll_interval", Value: "15s", Origin: config.Origin{Layer: config.LayerUser, Source: "config.yaml", Line: 2, Column: 3}},
+		{Path: "daemon.agent.enabled", Value: "~", Origin: config.Origin{Layer: config.LayerDefault}},
+	}
+
+	var plain strings.Builder
+	writeSettings(&plain, settings, false)
+	if want := "daemon.preferred_adapter: hci1\ndaemon.poll_interval: 15s\ndaemon.agent.enabled: ~\n"; plain.String() != want {
+		t.Fatalf("unexpected output:\n%s", plain.String())
+	}
+
+	var withOrigin strings.Builder
+	writeSettings(&withOrigin, settings, true)
+	want := "daemon.preferred_adapter: hci1  # flag --adapter\n" +
+		"daemon.poll_interval: 15s       # user config.yaml:2:3\n" +
+		"daemon.agent.enabled: ~         # default\n"
+	if withOrigin.String() != want {
+		t.Fatalf("unexpected output:\n%s", withOrigin.String())
+	}
+}
+
+func TestStarterConfig(t *testing.T) {
+	doc, err := starterConfig([]daemon.Adapter{
+		{ID: "hci1", Address: "00:11:22:33:44:55", Powered: true},
+		{ID: "hci0", Address: "66:77:88:99:AA:BB"},
+	})
+	if err != nil {
+		t.Fatalf("starterConfig: %v", err)
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if want := `preferred_adapter: "00:11:22:33:44:55" # detected: hci0 66:77:88:99:AA:BB, hci1 00:11:22:33:44:55`; !strings.Contains(string(data), want) {
+		t.Fatalf("expected %q in:\n%s", want, data)
+	}
+	if !strings.Contains(string(data), "# Choose a preferred adapter") {
+		t.Fatalf("template comments were lost:\n%s", data)
+	}
+
+	doc, err = starterConfig(nil)
+	if err != nil {
+		t.Fatalf("starterConfig without adapters: %v", err)
+	}
+	if data, _ := doc.Bytes(); !strings.Contains(string(data), `preferred_adapter: "AA:BB:CC:DD:EE:FF"`) {
+		t.Fatalf("expected the placeholder without adapters:\n%s", data)
+	}
+}
+
+func TestSettingsUnder(t *testing.T) {
+	settings := []config.Setting{
+		{Path: "daemon.poll_interval"},
+		{Path: "daemon.agent.enabled"},
+		{Path: "daemon.agent_extra"},
+		{Path: "devices"},
+	}
+	var got []string
+	for _, setting := range settingsUnder(settings, "daemon.agent") {
+		got = append(got, setting.Path)
+	}
+	if strings.Join(got, ",") != "daemon.agent.enabled" {
+		t.Fatalf("unexpected settings: %v", got)
+	}
+}
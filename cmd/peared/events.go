@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func runEvents(args []string) {
+	flagSet := flag.NewFlagSet("events", flag.ExitOnError)
+	asJSON := flagSet.Bool("json", false, "Print each event as a JSON object")
+	socket := flagSet.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse events flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := control.Events(ctx, *socket, func(raw json.RawMessage) error {
+		if *asJSON {
+			_, err := fmt.Fprintf(os.Stdout, "%s\n", raw)
+			return err
+		}
+
+		var ev daemon.Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		writeEvent(os.Stdout, ev)
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			fmt.Fprintf(os.Stderr, "pearedd is not running; start it to follow events.\n")
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "event stream failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeEvent(out io.Writer, ev daemon.Event) {
+	fmt.Fprintf(out, "%s\t%s\t%s\n", ev.Time.Local().Format(time.DateTime), ev.Type, ev.Message)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestWriteEvent(t *testing.T) {
+	var out bytes.Buffer
+	writeEvent(&out, daemon.Event{
+		Time:     time.Date(2024, 5, 6, 12, 0, 0, 0, time.Local),
+		Type:     daemon.EventAdapterSwitched,
+		Adapter:  "hci1",
+		Previous: "hci0",
+		Message:  "active adapter hci0 disappeared",
+	})
+
+	want := "2024-05-06 12:00:00\tadapter.switched\tactive adapter hci0 disappeared\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected output:\n%q\nwant\n%q", got, want)
+	}
+}
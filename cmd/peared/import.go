@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/peared/peared/internal/bluezstore"
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/registry"
+)
+
+func importBlueZ(args []string) {
+	flagSet := flag.NewFlagSet("devices import-bluez", flag.ExitOnError)
+	root := flagSet.String("root", bluezstore.DefaultRoot, "BlueZ storage directory to import from")
+	registryPath := flagSet.String("registry", "", "Path to the known-devices registry (defaults to $XDG_STATE_HOME/peared/devices.json)")
+	dryRun := flagSet.Bool("dry-run", false, "List the devices that would be imported without saving them")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse devices flags: %v\n", err)
+		os.Exit(2)
+	}
+	if flagSet.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: peared devices import-bluez [--root dir] [--registry path] [--dry-run]\n")
+		os.Exit(2)
+	}
+
+	stored, skipped, err := bluezstore.Read(*root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		if errors.Is(err, fs.ErrPermission) {
+			fmt.Fprintf(os.Stderr, "BlueZ keeps its store readable by root only; rerun with sudo.\n")
+		}
+		os.Exit(1)
+	}
+	for _, err := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	// Under sudo the registry belongs to the user who ran it, not to root.
+	invoker, err := sudoInvoker()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to look up the user running sudo: %v\n", err)
+		os.Exit(1)
+	}
+	path := *registryPath
+	if path == "" && invoker != nil {
+		path = registry.PathFor(invoker.HomeDir)
+	}
+
+	known, err := registry.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	devices := registryDevices(stored)
+	writeImported(os.Stdout, devices)
+	if len(devices) == 0 {
+		fmt.Fprintf(os.Stdout, "No paired devices found in %s.\n", *root)
+		return
+	}
+
+	added := 0
+	for _, device := range devices {
+		if known.Put(device) {
+			added++
+		}
+	}
+	summary := fmt.Sprintf("%d %s (%d new, %d updated)", len(devices), plural(len(devices), "device", "devices"), added, len(devices)-added)
+	if *dryRun {
+		fmt.Fprintf(os.Stdout, "Would import %s into %s.\n", summary, known.Path())
+		return
+	}
+
+	created := missingDirs(filepath.Dir(known.Path()))
+	if err := known.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if invoker != nil {
+		if err := chownAll(invoker, append(created, known.Path())); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stdout, "Imported %s into %s.\n", summary, known.Path())
+}
+
+// registryDevices combines the entries BlueZ keeps per adapter into one
+// registry entry per device.
+func registryDevices(stored []bluezstore.Device) []registry.Device {
+	var devices []registry.Device
+	index := make(map[string]int)
+	for _, s := range stored {
+		i, ok := index[s.Address]
+		if !ok {
+			index[s.Address] = len(devices)
+			devices = append(devices, registry.Device{Address: s.Address, Source: "bluez"})
+			i = len(devices) - 1
+		}
+		d := &devices[i]
+
+		d.Adapters = append(d.Adapters, s.Adapter)
+		d.AddressType = valueOr(d.AddressType, s.AddressType)
+		d.Name = valueOr(d.Name, s.Name)
+		d.Alias = valueOr(d.Alias, s.Alias)
+		if d.Class == 0 {
+			d.Class = s.Class
+		}
+		if d.Appearance == 0 {
+			d.Appearance = s.Appearance
+		}
+		for _, service := range s.Services {
+			if !containsString(d.Services, service) {
+				d.Services = append(d.Services, service)
+			}
+		}
+		d.Trusted = d.Trusted || s.Trusted
+		d.Blocked = d.Blocked || s.Blocked
+		d.LinkKey = d.LinkKey || s.LinkKey
+		d.LongTermKey = d.LongTermKey || s.LongTermKey
+		if s.LastSeen.After(d.LastSeen) {
+			d.LastSeen = s.LastSeen
+		}
+	}
+	return devices
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeImported lists devices with their kind, bonds and when BlueZ last
+// recorded them.
+func writeImported(w io.Writer, devices []registry.Device) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, device := range devices {
+		name := valueOr(device.Alias, valueOr(device.Name, unnamedLabel(device.Address, device.AddressType)))
+		kind := daemon.DetectKind(device.Class, device.Appearance, device.Services, "")
+
+		var bonds []string
+		if device.LinkKey {
+			bonds = append(bonds, "link key")
+		}
+		if device.LongTermKey {
+			bonds = append(bonds, "LE keys")
+		}
+		if len(bonds) == 0 {
+			bonds = append(bonds, "not paired")
+		}
+
+		seen := "-"
+		if !device.LastSeen.IsZero() {
+			seen = device.LastSeen.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", device.Address, name, kind, strings.Join(bonds, ", "), seen)
+	}
+	tw.Flush()
+}
+
+// sudoInvoker returns the user who ran peared through sudo, or nil when
+// peared was not started by sudo as root.
+func sudoInvoker() (*user.User, error) {
+	uid := os.Getenv("SUDO_UID")
+	if uid == "" || os.Geteuid() != 0 {
+		return nil, nil
+	}
+	return user.LookupId(uid)
+}
+
+// missingDirs lists dir and those of its parents that do not exist yet,
+// outermost first.
+func missingDirs(dir string) []string {
+	var missing []string
+	for ; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+	}
+	return missing
+}
+
+func chownAll(owner *user.User, paths []string) error {
+	uid, err := strconv.Atoi(owner.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %q", owner.Uid)
+	}
+	gid, err := strconv.Atoi(owner.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %q", owner.Gid)
+	}
+	for _, path := range paths {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("give %s to %s: %w", path, owner.Username, err)
+		}
+	}
+	return nil
+}
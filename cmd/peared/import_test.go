@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/bluezstore"
+)
+
+func TestRegistryDevices(t *testing.T) {
+	earlier := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	devices := registryDevices([]bluezstore.Device{
+		{Adapter: "00:1A:7D:DA:71:13", Address: "AA:BB:CC:DD:EE:FF", Name: "WH-1000XM4", Class: 0x240404,
+			Services: []string{"0000110b-0000-1000-8000-00805f9b34fb"}, LinkKey: true, LastSeen: earlier},
+		{Adapter: "00:1A:7D:DA:71:13", Address: "C8:3F:26:11:22:33", AddressType: "random", Name: "MX Master 3",
+			Appearance: 0x3c2, Trusted: true, LongTermKey: true, LastSeen: earlier},
+		{Adapter: "5C:F3:70:00:00:01", Address: "AA:BB:CC:DD:EE:FF", Alias: "Cans", Class: 0x200404, Trusted: true,
+			Services: []string{"0000110b-0000-1000-8000-00805f9b34fb", "0000111e-0000-1000-8000-00805f9b34fb"},
+			LastSeen: later},
+	})
+	if len(devices) != 2 {
+		t.Fatalf("expected two devices, got %+v", devices)
+	}
+
+	d := devices[0]
+	if d.Address != "AA:BB:CC:DD:EE:FF" || d.Name != "WH-1000XM4" || d.Alias != "Cans" || d.Class != 0x240404 ||
+		!d.Trusted || !d.LinkKey || d.LongTermKey || !d.LastSeen.Equal(later) || d.Source != "bluez" {
+		t.Fatalf("unexpected merged device %+v", d)
+	}
+	if strings.Join(d.Adapters, ",") != "00:1A:7D:DA:71:13,5C:F3:70:00:00:01" || len(d.Services) != 2 {
+		t.Fatalf("expected adapters and services from both records, got %+v", d)
+	}
+
+	var out bytes.Buffer
+	writeImported(&out, devices)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected listing:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[0]); fields[1] != "Cans" || fields[2] != "headset" || fields[3] != "link" {
+		t.Fatalf("unexpected line %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "MX Master 3") || !strings.Contains(lines[1], "mouse") || !strings.Contains(lines[1], "LE keys") {
+		t.Fatalf("unexpected line %q", lines[1])
+	}
+}
+
+func TestImportedStaticAddressIsNotGivenAVendor(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "00:1A:7D:DA:71:13", "F4:F5:D8:11:22:33")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	info := "[General]\nAddressType=static\nAppearance=0x03c2\n\n[PeripheralLongTermKey]\nKey=00112233445566778899AABBCCDDEEFF\n"
+	if err := os.WriteFile(filepath.Join(dir, "info"), []byte(info), 0o600); err != nil {
+		t.Fatalf("write info: %v", err)
+	}
+
+	stored, skipped, err := bluezstore.Read(root)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("Read: %v, skipped %v", err, skipped)
+	}
+	var out bytes.Buffer
+	writeImported(&out, registryDevices(stored))
+	if !strings.Contains(out.String(), "(unnamed, static random address)") {
+		t.Fatalf("expected a static random label, got %q", out.String())
+	}
+}
+
+func TestImportedBREDRDeviceIsGivenAVendor(t *testing.T) {
+	// BlueZ records no address type for BR/EDR devices, whose addresses
+	// are always public.
+	root := t.TempDir()
+	dir := filepath.Join(root, "00:1A:7D:DA:71:13", "DC:A6:32:11:22:33")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	info := "[General]\nClass=0x240404\n\n[LinkKey]\nKey=0123456789ABCDEF0123456789ABCDEF\n"
+	if err := os.WriteFile(filepath.Join(dir, "info"), []byte(info), 0o600); err != nil {
+		t.Fatalf("write info: %v", err)
+	}
+
+	stored, skipped, err := bluezstore.Read(root)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("Read: %v, skipped %v", err, skipped)
+	}
+	var out bytes.Buffer
+	writeImported(&out, registryDevices(stored))
+	if !strings.Contains(out.String(), "(unnamed, Raspberry Pi Trading Ltd)") {
+		t.Fatalf("expected the vendor label, got %q", out.String())
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/devclass"
+	"github.com/peared/peared/internal/registry"
+)
+
+// namedDevice is a device the CLI can look up by name: one BlueZ knows, one
+// with a nickname in the configuration, or both.
+type namedDevice struct {
+	daemon.Device
+	Nickname string
+
+	// AddressType is "public" or "random" when BlueZ reported it.
+	AddressType string
+
+	// Type describes the device class or appearance, when known.
+	Type string
+}
+
+// Matches extends daemon.Device.Matches with the configured nickname.
+func (d namedDevice) Matches(identifier string) bool {
+	if d.Device.Matches(identifier) {
+		return true
+	}
+	id := strings.TrimSpace(identifier)
+	return id != "" && strings.EqualFold(d.Nickname, id)
+}
+
+// hasPrefix reports whether the nickname, alias, name or address starts with
+// prefix, ignoring case.
+func (d namedDevice) hasPrefix(prefix string) bool {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return false
+	}
+	for _, label := range []string{d.Nickname, d.Alias, d.Name, d.Address} {
+		if label != "" && strings.HasPrefix(strings.ToLower(label), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// label returns the most specific name for the device.
+func (d namedDevice) label() string {
+	return valueOr(d.Nickname, valueOr(d.Alias, valueOr(d.Name, d.Address)))
+}
+
+// resolveDevice finds the address identifier refers to. Addresses are taken
+// as given. Otherwise an exact, case-insensitive match on a name, alias or
+// nickname wins over a unique prefix; several matches are an error listing
+// the candidates.
+func resolveDevice(identifier string, devices []namedDevice) (string, error) {
+	id := strings.TrimSpace(identifier)
+	if id == "" {
+		return "", fmt.Errorf("device address or name required")
+	}
+	if daemon.IsAddress(id) {
+		return id, nil
+	}
+
+	var exact, prefixed []namedDevice
+	for _, device := range devices {
+		switch {
+		case device.Matches(id):
+			exact = append(exact, device)
+		case device.hasPrefix(id):
+			prefixed = append(prefixed, device)
+		}
+	}
+
+	matches := exact
+	if len(matches) == 0 {
+		matches = prefixed
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no device matches %q; use the address or one of the names from `peared devices list`", id)
+	case 1:
+		return matches[0].Address, nil
+	default:
+		candidates := make([]string, 0, len(matches))
+		for _, device := range matches {
+			candidates = append(candidates, fmt.Sprintf("%s (%s)", device.label(), device.Address))
+		}
+		return "", fmt.Errorf("%q matches several devices: %s", id, strings.Join(candidates, ", "))
+	}
+}
+
+// lookupDevice resolves a device argument given by address, name, alias or
+// nickname. Names are only looked up when the argument is not an address.
+func lookupDevice(identifier string, noSudo bool, adapter, configPath string) (string, error) {
+	if daemon.IsAddress(identifier) {
+		return strings.TrimSpace(identifier), nil
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return "", fmt.Errorf("load config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	devices, err := knownDevices(ctx, noSudo, adapter, cfg)
+	if err != nil {
+		return "", fmt.Errorf("look up device %q: %w", identifier, err)
+	}
+
+	return resolveDevice(identifier, devices)
+}
+
+// knownDevices lists the devices BlueZ knows through the given adapter (or
+// the one chosen with `use` in the shell), or through every adapter when
+// none is given, together with the devices imported into the registry and
+// the nicknames from the configuration. Devices that only have a nickname
+// are included so they can be paired by name.
+func knownDevices(ctx context.Context, noSudo bool, adapter string, cfg *config.Config) ([]namedDevice, error) {
+	var opts []bluetoothctl.RunnerOption
+	if noSudo {
+		opts = append(opts, bluetoothctl.WithUseSudo(false))
+	}
+	runner, err := bluetoothctl.NewRunner(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listing must not prompt for an adapter, so without --adapter every
+	// detected adapter is asked in turn.
+	adapters := []string{strings.TrimSpace(adapter)}
+	if adapters[0] == "" {
+		adapters[0] = strings.TrimSpace(os.Getenv(adapterEnv))
+	}
+	if adapters[0] == "" {
+		if detected, err := daemon.DefaultAdapterProvider().ListAdapters(ctx); err == nil && len(detected) > 0 {
+			adapters = adapters[:0]
+			for _, a := range detected {
+				adapters = append(adapters, a.ID)
+			}
+		}
+	}
+
+	// A missing or unreadable registry only means fewer names to go by.
+	var known []registry.Device
+	if reg, err := registry.Open(""); err == nil {
+		known = reg.Devices()
+	}
+
+	return listNamedDevices(ctx, runner, adapters, known, cfg)
+}
+
+func listNamedDevices(ctx context.Context, runner *bluetoothctl.Runner, adapters []string, known []registry.Device, cfg *config.Config) ([]namedDevice, error) {
+	var devices []namedDevice
+	index := make(map[string]int)
+
+	for _, adapter := range adapters {
+		pinned := runner.ForAdapter(adapter)
+		entries, err := pinned.Devices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list devices: %w", err)
+		}
+
+		for _, entry := range entries {
+			address := daemon.NormalizeAddress(entry.Address)
+			if _, ok := index[address]; ok {
+				continue
+			}
+
+			device := namedDevice{Device: daemon.Device{Address: address, Alias: entry.Name, Adapter: adapter, Kind: daemon.DeviceKindUnknown}}
+			if info, err := pinned.Info(ctx, entry.Address); err == nil {
+				device.Name = info.Name
+				device.Alias = valueOr(info.Alias, entry.Name)
+				device.Kind = daemon.DetectKind(info.Class, info.Appearance, info.UUIDs, info.Icon)
+				device.Type = devclass.Describe(info.Class, info.Appearance)
+				device.AddressType = info.AddressType
+			}
+
+			index[address] = len(devices)
+			devices = append(devices, device)
+		}
+	}
+
+	for _, entry := range known {
+		if _, ok := index[entry.Address]; ok {
+			continue
+		}
+		index[entry.Address] = len(devices)
+		devices = append(devices, namedDevice{
+			Device: daemon.Device{
+				Address: entry.Address,
+				Name:    entry.Name,
+				Alias:   entry.Alias,
+				Kind:    daemon.DetectKind(entry.Class, entry.Appearance, entry.Services, ""),
+			},
+			AddressType: entry.AddressType,
+			Type:        devclass.Describe(entry.Class, entry.Appearance),
+		})
+	}
+
+	for key, settings := range cfg.Devices {
+		nickname := strings.TrimSpace(settings.Nickname)
+		if nickname == "" {
+			continue
+		}
+		address := daemon.NormalizeAddress(key)
+		if i, ok := index[address]; ok {
+			devices[i].Nickname = nickname
+			continue
+		}
+		index[address] = len(devices)
+		devices = append(devices, namedDevice{Device: daemon.Device{Address: address, Kind: daemon.DeviceKindUnknown}, Nickname: nickname})
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices, nil
+}
+
+func listDevices(args []string) {
+	flagSet := flag.NewFlagSet("devices list", flag.ExitOnError)
+	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
+	adapter := flagSet.String("adapter", "", "Adapter identifier (ID, address, or alias) to list devices for")
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	names := flagSet.Bool("names", false, "Print only the names devices can be addressed by, one per line")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse devices flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	devices, err := knownDevices(ctx, *noSudo, *adapter, cfg)
+	if err != nil {
+		handleDeviceCommandError("devices list", err)
+		os.Exit(1)
+	}
+
+	if *names {
+		for _, name := range deviceNames(devices) {
+			fmt.Fprintln(os.Stdout, name)
+		}
+		return
+	}
+
+	if len(devices) == 0 {
+		fmt.Fprintf(os.Stdout, "No devices known.\n")
+		return
+	}
+
+	for _, device := range devices {
+		name := device.Alias
+		if name == "" || name == strings.ReplaceAll(device.Address, ":", "-") {
+			name = valueOr(device.Name, unnamedLabel(device.Address, device.AddressType))
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%s\t%s\n", device.Address, name, device.Kind, valueOr(device.Type, "-"), valueOr(device.Nickname, "-"))
+	}
+}
+
+// deviceNames returns the distinct nicknames, aliases and names of devices
+// for shell completion. Unnamed devices, whose alias BlueZ sets to the dashed
+// address, are left out.
+func deviceNames(devices []namedDevice) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, device := range devices {
+		for _, name := range []string{device.Nickname, device.Alias, device.Name} {
+			if name == "" || seen[name] || name == strings.ReplaceAll(device.Address, ":", "-") {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
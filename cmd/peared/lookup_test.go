@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/registry"
+)
+
+func TestResolveDevice(t *testing.T) {
+	devices := []namedDevice{
+		{Device: daemon.Device{Address: "11:11:11:11:11:11", Name: "WH-1000XM4", Alias: "Work headphones"}},
+		{Device: daemon.Device{Address: "22:22:22:22:22:22", Name: "WH-1000XM5", Alias: "WH-1000XM5"}, Nickname: "cans"},
+		{Device: daemon.Device{Address: "33:33:33:33:33:33", Name: "MX Keys"}},
+	}
+
+	for identifier, want := range map[string]string{
+		"aa:bb:cc:dd:ee:ff": "aa:bb:cc:dd:ee:ff",
+		"wh-1000xm4":        "11:11:11:11:11:11",
+		"work HEADPHONES":   "11:11:11:11:11:11",
+		"Cans":              "22:22:22:22:22:22",
+		"mx":                "33:33:33:33:33:33",
+		"work":              "11:11:11:11:11:11",
+	} {
+		got, err := resolveDevice(identifier, devices)
+		if err != nil || got != want {
+			t.Errorf("resolveDevice(%q) = %q, %v; want %q", identifier, got, err, want)
+		}
+	}
+
+	_, err := resolveDevice("wh-1000", devices)
+	if err == nil || !strings.Contains(err.Error(), "Work headphones (11:11:11:11:11:11)") || !strings.Contains(err.Error(), "cans (22:22:22:22:22:22)") {
+		t.Fatalf("expected an ambiguity error listing both headphones, got %v", err)
+	}
+	if _, err := resolveDevice("speaker", devices); err == nil || !strings.Contains(err.Error(), "no device matches") {
+		t.Fatalf("expected a not-found error, got %v", err)
+	}
+}
+
+func TestListNamedDevices(t *testing.T) {
+	var selected []string
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "select":
+				selected = append(selected, args[1])
+			case "devices":
+				if selected[len(selected)-1] == "hci0" {
+					return []byte("Device 11:11:11:11:11:11 Work headphones\nDevice 44:44:44:44:44:44 44-44-44-44-44-44\n"), nil
+				}
+				return []byte("Device 11:11:11:11:11:11 Work headphones\n"), nil
+			case "info":
+				if args[1] == "11:11:11:11:11:11" {
+					return []byte("Device 11:11:11:11:11:11\n\tName: WH-1000XM4\n\tAlias: Work headphones\n\tClass: 0x00240418\n"), nil
+				}
+				return []byte("Device " + args[1] + "\n\tAlias: 44-44-44-44-44-44\n"), nil
+			}
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	cfg := &config.Config{Devices: map[string]config.DeviceConfig{
+		"11:11:11:11:11:11": {Nickname: "cans"},
+		"aa:bb:cc:dd:ee:ff": {Nickname: "speaker"},
+		"22:22:22:22:22:22": {Kind: "mouse"},
+	}}
+
+	known := []registry.Device{
+		{Address: "11:11:11:11:11:11", Name: "Old headphones"},
+		{Address: "55:55:55:55:55:55", Name: "Kitchen Speaker", Class: 0x240414},
+	}
+
+	devices, err := listNamedDevices(context.Background(), runner, []string{"hci0", "hci1"}, known, cfg)
+	if err != nil {
+		t.Fatalf("listNamedDevices: %v", err)
+	}
+	if len(devices) != 4 {
+		t.Fatalf("expected four devices, got %+v", devices)
+	}
+	if d := devices[0]; d.Address != "11:11:11:11:11:11" || d.Name != "WH-1000XM4" || d.Nickname != "cans" || d.Adapter != "hci0" ||
+		d.Kind != daemon.DeviceKindHeadset || d.Type != "Audio/Video: Headphones" {
+		t.Fatalf("unexpected known device %+v", d)
+	}
+	if d := devices[2]; d.Address != "55:55:55:55:55:55" || d.Name != "Kitchen Speaker" || d.Adapter != "" ||
+		d.Kind != daemon.DeviceKindSpeaker || d.Type != "Audio/Video: Loudspeaker" {
+		t.Fatalf("unexpected imported device %+v", d)
+	}
+	if d := devices[3]; d.Address != "AA:BB:CC:DD:EE:FF" || d.Nickname != "speaker" {
+		t.Fatalf("unexpected configured-only device %+v", d)
+	}
+
+	names := strings.Join(deviceNames(devices), ",")
+	if names != "Kitchen Speaker,WH-1000XM4,Work headphones,cans,speaker" {
+		t.Fatalf("unexpected completion names %q", names)
+	}
+}
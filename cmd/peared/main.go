@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -17,10 +18,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/peared/peared/internal/audio"
 	"github.com/peared/peared/internal/bluetoothctl"
-	"github.com/peared/peared/internal/cli"
 	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/control"
 	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/oui"
+	"github.com/peared/peared/internal/policy"
 )
 
 func main() {
@@ -36,6 +40,20 @@ func main() {
 		runAdapters(os.Args[2:])
 	case "devices":
 		runDevices(os.Args[2:])
+	case "status":
+		runStatus(os.Args[2:])
+	case "events":
+		runEvents(os.Args[2:])
+	case "reset":
+		runReset(os.Args[2:])
+	case "agent":
+		runAgent(os.Args[2:])
+	case "oui":
+		runOUI(os.Args[2:])
+	case "tui":
+		runTUI(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
 	case "help", "-h", "--help":
 		usage()
 	default:
@@ -45,36 +63,6 @@ func main() {
 	}
 }
 
-func runShell(args []string) {
-	fs := flag.NewFlagSet("shell", flag.ExitOnError)
-	logLevel := fs.String("log-level", "info", "Log level (debug, info, warn, error)")
-	prompt := fs.String("prompt", "peared> ", "Prompt to display for the interactive shell")
-	if err := fs.Parse(args); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
-		os.Exit(2)
-	}
-
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: parseLevel(*logLevel)}))
-	logger.Info("starting interactive shell")
-	defer logger.Info("shell session ended")
-
-	shell := cli.NewShell(os.Stdin, os.Stdout, cli.WithPrompt(*prompt))
-
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
-
-	if err := shell.Run(ctx); err != nil {
-		if errors.Is(err, context.Canceled) {
-			logger.Info("shell interrupted by context cancellation")
-			return
-		}
-		fmt.Fprintf(os.Stderr, "shell exited with error: %v\n", err)
-		os.Exit(1)
-	}
-
-	logger.Info("shell exited normally")
-}
-
 func usage() {
 	fmt.Fprintf(os.Stderr, "Peared CLI\n\n")
 	fmt.Fprintf(os.Stderr, "Usage:\n")
@@ -82,6 +70,13 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "Available Commands:\n")
 	fmt.Fprintf(os.Stderr, "  adapters  Inspect Bluetooth adapters available on the host\n")
 	fmt.Fprintf(os.Stderr, "  devices   Manage Bluetooth devices (scan, pair, connect, disconnect)\n")
+	fmt.Fprintf(os.Stderr, "  status    Show the daemon's view of adapters, devices and arbitration\n")
+	fmt.Fprintf(os.Stderr, "  events    Follow adapter and device events reported by the daemon\n")
+	fmt.Fprintf(os.Stderr, "  reset     Reset a wedged adapter, escalating from power-cycle to USB rebind\n")
+	fmt.Fprintf(os.Stderr, "  agent     Answer pairing prompts from the daemon and manage stored PINs\n")
+	fmt.Fprintf(os.Stderr, "  oui       Look up device manufacturers and update the vendor table\n")
+	fmt.Fprintf(os.Stderr, "  config    Check, show and edit the configuration\n")
+	fmt.Fprintf(os.Stderr, "  tui       Show a live dashboard of adapters and devices\n")
 	fmt.Fprintf(os.Stderr, "  shell     Start an interactive shell session\n")
 	fmt.Fprintf(os.Stderr, "  help      Show this message\n")
 }
@@ -112,6 +107,16 @@ func runAdapters(args []string) {
 	switch args[0] {
 	case "list":
 		listAdapters(args[1:])
+	case "show":
+		showAdapter(args[1:])
+	case "explain":
+		explainAdapters(args[1:])
+	case "power":
+		setAdapterPower(args[1:])
+	case "alias":
+		setAdapterAlias(args[1:])
+	case "discoverable", "pairable":
+		setAdapterVisibility(args[0], args[1:])
 	case "help", "-h", "--help":
 		adaptersUsage()
 	default:
@@ -124,7 +129,13 @@ func runAdapters(args []string) {
 func adaptersUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: peared adapters <command>\n\n")
 	fmt.Fprintf(os.Stderr, "Commands:\n")
-	fmt.Fprintf(os.Stderr, "  list   Discover Bluetooth adapters managed by the host\n")
+	fmt.Fprintf(os.Stderr, "  list                          Discover Bluetooth adapters managed by the host\n")
+	fmt.Fprintf(os.Stderr, "  explain                       Show how the selection policy ranks each adapter\n")
+	fmt.Fprintf(os.Stderr, "  show [<id>]                   Show class, version, roles and UUIDs for an adapter\n")
+	fmt.Fprintf(os.Stderr, "  power on|off <id>             Switch an adapter's radio on or off\n")
+	fmt.Fprintf(os.Stderr, "  alias <id> <name>             Change the name an adapter advertises\n")
+	fmt.Fprintf(os.Stderr, "  discoverable on|off [<id>]    Toggle visibility (--timeout to limit it)\n")
+	fmt.Fprintf(os.Stderr, "  pairable on|off [<id>]        Toggle acceptance of new pairings (--timeout)\n")
 }
 
 func runDevices(args []string) {
@@ -136,12 +147,16 @@ func runDevices(args []string) {
 	switch args[0] {
 	case "scan":
 		scanDevices(args[1:])
+	case "list":
+		listDevices(args[1:])
 	case "pair":
 		pairDevice(args[1:])
 	case "connect":
 		connectDevice(args[1:])
 	case "disconnect":
 		disconnectDevice(args[1:])
+	case "import-bluez":
+		importBlueZ(args[1:])
 	case "help", "-h", "--help":
 		devicesUsage()
 	default:
@@ -155,9 +170,14 @@ func devicesUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: peared devices <command> [options]\n\n")
 	fmt.Fprintf(os.Stderr, "Commands:\n")
 	fmt.Fprintf(os.Stderr, "  scan         Discover nearby devices using bluetoothctl\n")
+	fmt.Fprintf(os.Stderr, "  list         List known devices with their names and nicknames\n")
 	fmt.Fprintf(os.Stderr, "  pair <addr>  Pair with the specified device\n")
+	fmt.Fprintf(os.Stderr, "  pair --wizard    Scan and choose a device to pair, trust and connect\n")
 	fmt.Fprintf(os.Stderr, "  connect <addr>   Connect to the specified device\n")
 	fmt.Fprintf(os.Stderr, "  disconnect <addr> Disconnect the specified device\n")
+	fmt.Fprintf(os.Stderr, "  import-bluez     Record the devices paired through BlueZ as known devices\n\n")
+	fmt.Fprintf(os.Stderr, "Devices can be given by address, name, alias, configured nickname or a\n")
+	fmt.Fprintf(os.Stderr, "unique prefix of one of them.\n")
 }
 
 func scanDevices(args []string) {
@@ -171,7 +191,7 @@ func scanDevices(args []string) {
 		os.Exit(2)
 	}
 
-	runner, selectedAdapter, err := newBluetoothRunner(*noSudo, *adapter, *configPath)
+	runner, selectedAdapter, err := newBluetoothRunner(*noSudo, *adapter, *configPath, "")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
 		os.Exit(1)
@@ -228,6 +248,14 @@ func scanDevices(args []string) {
 		os.Exit(1)
 	}
 
+	// Name the manufacturer of devices that did not advertise a name. The
+	// address type decides whether the address carries an OUI at all.
+	vendors := oui.Default()
+	types := addressTypes(runner, unnamedAddresses(output))
+	output = annotateUnnamed(output, func(address string) string {
+		return vendors.Describe(address, types[address])
+	})
+
 	if output != "" {
 		fmt.Fprintf(os.Stdout, "%s\n", output)
 	}
@@ -238,24 +266,42 @@ func pairDevice(args []string) {
 	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
 	adapter := flagSet.String("adapter", "", "Adapter identifier (ID, address, or alias) to target")
 	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	wizard := flagSet.Bool("wizard", false, "Scan and choose the device to pair from a list")
 	if err := flagSet.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to parse devices flags: %v\n", err)
 		os.Exit(2)
 	}
 
+	if *wizard {
+		if flagSet.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "pair --wizard does not take a device address\n")
+			os.Exit(2)
+		}
+		runPairingWizard(*noSudo, *adapter, *configPath)
+		return
+	}
+
 	if flagSet.NArg() != 1 {
-		fmt.Fprintf(os.Stderr, "pair requires a device address\n")
+		fmt.Fprintf(os.Stderr, "pair requires a device address or name (or --wizard to choose one)\n")
 		os.Exit(2)
 	}
 
-	address := flagSet.Arg(0)
-	runner, _, err := newBluetoothRunner(*noSudo, *adapter, *configPath)
+	address, err := lookupDevice(flagSet.Arg(0), *noSudo, *adapter, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	runner, _, err := newBluetoothRunner(*noSudo, *adapter, *configPath, address)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
 		os.Exit(1)
 	}
 
-	output, err := runner.Pair(context.Background(), address)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	output, err := pairWithPrompts(ctx, runner, "", address, newLineReader(os.Stdin), os.Stderr)
 	if err != nil {
 		handleDeviceCommandError(fmt.Sprintf("pair %s", address), err)
 		os.Exit(1)
@@ -266,6 +312,30 @@ func pairDevice(args []string) {
 	}
 }
 
+// pairWithPrompts pairs with address. When pearedd's pairing agent is
+// running, bluetoothctl's agent is kept out of the way so BlueZ routes
+// prompts to the daemon, and they are answered here from lines.
+func pairWithPrompts(ctx context.Context, runner *bluetoothctl.Runner, socket, address string, lines *lineReader, out io.Writer) (string, error) {
+	if _, err := pendingPrompts(ctx, socket); err == nil {
+		runner.Agent = "off"
+		promptCtx, stopPrompts := context.WithCancel(ctx)
+		var prompts sync.WaitGroup
+		prompts.Add(1)
+		go func() {
+			defer prompts.Done()
+			if err := answerPrompts(promptCtx, socket, address, lines, out); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(out, "warning: pairing prompts unavailable: %v\n", err)
+			}
+		}()
+		defer func() {
+			stopPrompts()
+			prompts.Wait()
+		}()
+	}
+
+	return runner.Pair(ctx, address)
+}
+
 func connectDevice(args []string) {
 	flagSet := flag.NewFlagSet("devices connect", flag.ExitOnError)
 	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
@@ -277,17 +347,26 @@ func connectDevice(args []string) {
 	}
 
 	if flagSet.NArg() != 1 {
-		fmt.Fprintf(os.Stderr, "connect requires a device address\n")
+		fmt.Fprintf(os.Stderr, "connect requires a device address or name\n")
 		os.Exit(2)
 	}
 
-	address := flagSet.Arg(0)
-	runner, _, err := newBluetoothRunner(*noSudo, *adapter, *configPath)
+	address, err := lookupDevice(flagSet.Arg(0), *noSudo, *adapter, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	runner, selected, err := newBluetoothRunner(*noSudo, *adapter, *configPath, address)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Clear any hold left by a previous manual disconnect so the daemon
+	// resumes automatic reconnection for this device.
+	daemonRunning := notifyDaemon("device.release", address)
+
 	output, err := runner.Connect(context.Background(), address)
 	if err != nil {
 		handleDeviceCommandError(fmt.Sprintf("connect %s", address), err)
@@ -297,6 +376,10 @@ func connectDevice(args []string) {
 	if output != "" {
 		fmt.Fprintf(os.Stdout, "%s\n", output)
 	}
+
+	if !daemonRunning {
+		runDeviceActions(runner, *configPath, daemon.Device{Address: address, Adapter: selected, Connected: true})
+	}
 }
 
 func disconnectDevice(args []string) {
@@ -310,19 +393,29 @@ func disconnectDevice(args []string) {
 	}
 
 	if flagSet.NArg() != 1 {
-		fmt.Fprintf(os.Stderr, "disconnect requires a device address\n")
+		fmt.Fprintf(os.Stderr, "disconnect requires a device address or name\n")
 		os.Exit(2)
 	}
 
-	address := flagSet.Arg(0)
-	runner, _, err := newBluetoothRunner(*noSudo, *adapter, *configPath)
+	address, err := lookupDevice(flagSet.Arg(0), *noSudo, *adapter, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	runner, selected, err := newBluetoothRunner(*noSudo, *adapter, *configPath, address)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Ask the daemon to keep the device disconnected before dropping it so
+	// automatic reconnection does not immediately undo the request.
+	daemonRunning := notifyDaemon("device.hold", address)
+
 	output, err := runner.Disconnect(context.Background(), address)
 	if err != nil {
+		notifyDaemon("device.release", address)
 		handleDeviceCommandError(fmt.Sprintf("disconnect %s", address), err)
 		os.Exit(1)
 	}
@@ -330,6 +423,74 @@ func disconnectDevice(args []string) {
 	if output != "" {
 		fmt.Fprintf(os.Stdout, "%s\n", output)
 	}
+
+	if !daemonRunning {
+		runDeviceActions(runner, *configPath, daemon.Device{Address: address, Adapter: selected})
+	}
+}
+
+// notifyDaemon forwards a device request to pearedd when it is running and
+// reports whether it is. The daemon is optional for device commands, so an
+// absent daemon is ignored and other failures only produce a warning.
+func notifyDaemon(command, address string) bool {
+	err := callDaemon(command, address)
+	if err != nil && !errors.Is(err, control.ErrDaemonUnavailable) {
+		fmt.Fprintf(os.Stderr, "warning: failed to notify daemon (%s): %v\n", command, err)
+	}
+	return !errors.Is(err, control.ErrDaemonUnavailable)
+}
+
+// runDeviceActions applies the audio settings and runs the hooks configured
+// for dev, which has just connected or disconnected. pearedd does this for
+// the transitions it observes; the CLI only does it while pearedd is not
+// running.
+func runDeviceActions(runner *bluetoothctl.Runner, configPath string, dev daemon.Device) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: device settings not applied: %v\n", err)
+		return
+	}
+	device, ok := cfg.Device(dev.Address)
+	if !ok {
+		return
+	}
+	audioSettings, err := policy.NewAudioSettings(device.Audio.Profile, device.Audio.Codec, device.Audio.Volume)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: device settings not applied: %v\n", err)
+		return
+	}
+	settings := daemon.DeviceSettings{Nickname: device.Nickname, Audio: audioSettings, Hooks: daemon.DeviceHooks{Connect: device.Hooks.Connect, Disconnect: device.Hooks.Disconnect}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if info, err := runner.Info(ctx, dev.Address); err == nil {
+		dev.Name, dev.Alias = info.Name, info.Alias
+	}
+
+	actions := daemon.DeviceActions{Audio: audio.NewPactl(), Hooks: daemon.ShellHooks{}}
+	if dev.Connected {
+		err = actions.Connected(ctx, dev, settings)
+	} else {
+		err = actions.Disconnected(ctx, dev, settings)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+func callDaemon(command, address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := control.Call(ctx, "", control.Request{
+		Command: command,
+		Args:    map[string]string{"address": address},
+	})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 func formatDuration(d time.Duration) string {
@@ -345,13 +506,16 @@ func formatDuration(d time.Duration) string {
 	return rounded.String()
 }
 
-func newBluetoothRunner(disableSudo bool, adapterOverride, configPath string) (*bluetoothctl.Runner, string, error) {
+// newBluetoothRunner builds a runner targeting the adapter chosen by
+// determineAdapter. Device commands pass the device address so devices pinned
+// to a controller in the configuration are routed through it.
+func newBluetoothRunner(disableSudo bool, adapterOverride, configPath, device string) (*bluetoothctl.Runner, string, error) {
 	var opts []bluetoothctl.RunnerOption
 	if disableSudo {
 		opts = append(opts, bluetoothctl.WithUseSudo(false))
 	}
 
-	adapter, err := determineAdapter(context.Background(), adapterOverride, configPath)
+	adapter, err := determineAdapter(context.Background(), adapterOverride, configPath, device)
 	if err != nil {
 		return nil, "", fmt.Errorf("determine adapter: %w", err)
 	}
@@ -368,7 +532,15 @@ func newBluetoothRunner(disableSudo bool, adapterOverride, configPath string) (*
 	return runner, adapter, nil
 }
 
-func determineAdapter(ctx context.Context, override, configPath string) (string, error) {
+func determineAdapter(ctx context.Context, override, configPath, device string) (string, error) {
+	return resolveAdapter(ctx, override, os.Getenv(adapterEnv), configPath, device, isInteractive(os.Stdin))
+}
+
+// resolveAdapter picks the adapter a command runs against: override, then
+// the adapter device is pinned to, then shellAdapter, then the selection
+// policy. Only an interactive caller is asked to choose between several
+// adapters; the others get the policy's choice.
+func resolveAdapter(ctx context.Context, override, shellAdapter, configPath, device string, interactive bool) (string, error) {
 	if strings.TrimSpace(override) != "" {
 		return override, nil
 	}
@@ -378,6 +550,18 @@ func determineAdapter(ctx context.Context, override, configPath string) (string,
 		return "", fmt.Errorf("load config: %w", err)
 	}
 
+	// The adapter chosen with `use` in the shell gives way to a device pin,
+	// which names the only adapter the device is bonded with.
+	pin := ""
+	if device != "" {
+		if settings, ok := cfg.Device(device); ok {
+			pin = strings.TrimSpace(settings.Adapter)
+		}
+	}
+	if adapter := strings.TrimSpace(shellAdapter); adapter != "" && pin == "" {
+		return adapter, nil
+	}
+
 	provider := daemon.DefaultAdapterProvider()
 	adapters, err := provider.ListAdapters(ctx)
 	if err != nil {
@@ -388,7 +572,20 @@ func determineAdapter(ctx context.Context, override, configPath string) (string,
 		return "", errors.New("no adapters detected")
 	}
 
-	selected, err := daemon.SelectAdapter(cfg.Daemon.PreferredAdapter, adapters)
+	if pin != "" {
+		pinned, err := resolvePinnedAdapter(pin, adapters)
+		if err != nil {
+			return "", fmt.Errorf("device %s: %w", device, err)
+		}
+		return pinned, nil
+	}
+
+	policy, err := selectionPolicy(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	selected, err := policy.Select(cfg.Daemon.PreferredAdapter, adapters)
 	if err != nil {
 		return "", err
 	}
@@ -397,7 +594,7 @@ func determineAdapter(ctx context.Context, override, configPath string) (string,
 		return selected.ID, nil
 	}
 
-	if !isInteractive(os.Stdin) {
+	if !interactive {
 		fmt.Fprintf(os.Stderr, "Multiple adapters detected. Defaulting to %s. Use --adapter to override.\n", selected.ID)
 		return selected.ID, nil
 	}
@@ -410,6 +607,18 @@ func determineAdapter(ctx context.Context, override, configPath string) (string,
 	return chosen, nil
 }
 
+// resolvePinnedAdapter finds the adapter a device is pinned to in the
+// configuration, matching by ID, address or alias.
+func resolvePinnedAdapter(pin string, adapters []daemon.Adapter) (string, error) {
+	for _, adapter := range adapters {
+		if adapter.Matches(pin) {
+			return adapter.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("pinned adapter %q is not present", pin)
+}
+
 func promptAdapterSelection(in io.Reader, out io.Writer, adapters []daemon.Adapter, defaultID string) (string, error) {
 	if len(adapters) == 0 {
 		return "", errors.New("no adapters available for selection")
@@ -512,6 +721,8 @@ func handleDeviceCommandError(operation string, err error) {
 func listAdapters(args []string) {
 	flagSet := flag.NewFlagSet("adapters list", flag.ExitOnError)
 	sysfsPath := flagSet.String("sysfs", "", "Override the sysfs root used to discover adapters (advanced)")
+	long := flagSet.Bool("long", false, "Include vendor, product, chipset, driver and HCI bus details")
+	asJSON := flagSet.Bool("json", false, "Print adapters as JSON")
 	if err := flagSet.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to parse adapters flags: %v\n", err)
 		os.Exit(2)
@@ -534,22 +745,23 @@ func listAdapters(args []string) {
 		os.Exit(1)
 	}
 
+	if *asJSON {
+		if adapters == nil {
+			adapters = []daemon.Adapter{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(adapters); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode adapters: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(adapters) == 0 {
 		fmt.Fprintf(os.Stdout, "No adapters detected.\n")
 		return
 	}
 
-	for _, adapter := range adapters {
-		powered := "off"
-		if adapter.Powered {
-			powered = "on"
-		}
-
-		alias := adapter.Alias
-		if alias == "" {
-			alias = "(no alias)"
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%s\n", adapter.ID, adapter.Address, alias, powered)
-	}
+	writeAdapterList(os.Stdout, adapters, *long)
 }
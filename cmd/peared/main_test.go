@@ -76,3 +76,24 @@ func TestPromptAdapterSelection_InvalidThenValid(t *testing.T) {
 		t.Fatalf("expected invalid selection warning, got output: %s", out.String())
 	}
 }
+
+func TestResolvePinnedAdapter(t *testing.T) {
+	adapters := []daemon.Adapter{
+		{ID: "hci0", Alias: "Onboard", Address: "AA:BB"},
+		{ID: "hci1", Alias: "Gaming Dongle", Address: "CC:DD"},
+	}
+
+	for _, pin := range []string{"hci1", "cc:dd", "gaming dongle"} {
+		id, err := resolvePinnedAdapter(pin, adapters)
+		if err != nil {
+			t.Fatalf("resolvePinnedAdapter(%q) returned error: %v", pin, err)
+		}
+		if id != "hci1" {
+			t.Fatalf("resolvePinnedAdapter(%q) = %s, want hci1", pin, id)
+		}
+	}
+
+	if _, err := resolvePinnedAdapter("hci9", adapters); err == nil {
+		t.Fatal("expected error for missing pinned adapter")
+	}
+}
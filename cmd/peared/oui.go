@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/oui"
+)
+
+func runOUI(args []string) {
+	if len(args) == 0 {
+		ouiUsage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "update":
+		updateOUI(args[1:])
+	case "lookup":
+		lookupOUI(args[1:])
+	case "help", "-h", "--help":
+		ouiUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown oui command: %s\n\n", args[0])
+		ouiUsage()
+		os.Exit(2)
+	}
+}
+
+func ouiUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: peared oui <command> [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  update <oui.csv>    Install the vendor table from the IEEE registry's oui.csv\n")
+	fmt.Fprintf(os.Stderr, "  lookup <addr>...    Name the manufacturer of device addresses (--random for LE random addresses)\n")
+}
+
+func updateOUI(args []string) {
+	flagSet := flag.NewFlagSet("oui update", flag.ExitOnError)
+	output := flagSet.String("output", "", "Where to write the compressed table (defaults to $XDG_DATA_HOME/peared/oui.tsv.gz)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse oui flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if flagSet.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "update requires the path to oui.csv (download it from https://standards-oui.ieee.org/oui/oui.csv)\n")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flagSet.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", flagSet.Arg(0), err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	table, err := oui.ReadCSV(bufio.NewReader(f))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", flagSet.Arg(0), err)
+		os.Exit(1)
+	}
+
+	path := *output
+	if path == "" {
+		path, err = oui.DefaultPath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to determine table location: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := table.Install(path); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to install vendor table: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stdout, "Installed %d vendors in %s.\n", table.Len(), path)
+}
+
+func lookupOUI(args []string) {
+	flagSet := flag.NewFlagSet("oui lookup", flag.ExitOnError)
+	random := flagSet.Bool("random", false, "Treat the addresses as LE random addresses")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse oui flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	if flagSet.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "lookup requires at least one device address\n")
+		os.Exit(2)
+	}
+
+	addressType := "public"
+	if *random {
+		addressType = "random"
+	}
+
+	table := oui.Default()
+	failed := false
+	for _, address := range flagSet.Args() {
+		if oui.Classify(address, addressType) == oui.Unknown {
+			fmt.Fprintf(os.Stderr, "invalid device address %q\n", address)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", strings.ToUpper(address), valueOr(table.Describe(address, addressType), "(unknown vendor)"))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// annotateUnnamed appends the manufacturer or address kind to the lines of
+// bluetoothctl output that announce a device without a name, which
+// bluetoothctl prints as the address with dashes.
+func annotateUnnamed(output string, describe func(address string) string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		address, ok := unnamedAddress(line)
+		if !ok {
+			continue
+		}
+		if description := describe(address); description != "" {
+			lines[i] = line + " (" + description + ")"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unnamedAddresses returns the addresses annotateUnnamed describes.
+func unnamedAddresses(output string) []string {
+	var addresses []string
+	for _, line := range strings.Split(output, "\n") {
+		if address, ok := unnamedAddress(line); ok && !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func unnamedAddress(line string) (string, bool) {
+	fields := strings.Fields(line)
+	n := len(fields)
+	if n < 3 || fields[n-3] != "Device" || fields[n-1] != strings.ReplaceAll(fields[n-2], ":", "-") {
+		return "", false
+	}
+	return fields[n-2], true
+}
+
+// addressTypes asks bluetoothctl for the address type of each device, a few
+// at a time and under one shared timeout. Devices it cannot inspect are left
+// out, so they are not given a vendor.
+func addressTypes(runner *bluetoothctl.Runner, addresses []string) map[string]string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, 8)
+		types = make(map[string]string, len(addresses))
+	)
+	for _, address := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			info, err := runner.Info(ctx, address)
+			if err != nil || info.AddressType == "" {
+				return
+			}
+			mu.Lock()
+			types[address] = info.AddressType
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return types
+}
+
+// unnamedLabel stands in for the name of a device that has none, naming its
+// manufacturer or address kind when they are known.
+func unnamedLabel(address, addressType string) string {
+	if description := oui.Default().Describe(address, addressType); description != "" {
+		return "(unnamed, " + description + ")"
+	}
+	return "(unnamed)"
+}
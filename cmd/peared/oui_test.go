@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/oui"
+)
+
+func TestAnnotateUnnamed(t *testing.T) {
+	output := "Discovery started\n" +
+		"[NEW] Device 11:11:11:11:11:11 WH-1000XM4\n" +
+		"[NEW] Device 4C:22:33:44:55:66 4C-22-33-44-55-66\n" +
+		"[NEW] Device 00:11:22:33:44:55 00-11-22-33-44-55"
+
+	var asked []string
+	got := annotateUnnamed(output, func(address string) string {
+		asked = append(asked, address)
+		if address == "4C:22:33:44:55:66" {
+			return "resolvable private address"
+		}
+		return ""
+	})
+
+	want := "Discovery started\n" +
+		"[NEW] Device 11:11:11:11:11:11 WH-1000XM4\n" +
+		"[NEW] Device 4C:22:33:44:55:66 4C-22-33-44-55-66 (resolvable private address)\n" +
+		"[NEW] Device 00:11:22:33:44:55 00-11-22-33-44-55"
+	if got != want {
+		t.Fatalf("annotateUnnamed =\n%s\nwant\n%s", got, want)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("expected only unnamed devices to be described, got %v", asked)
+	}
+}
+
+func TestAddressTypesLeaveOutDevicesThatCannotBeInspected(t *testing.T) {
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			if args[0] != "info" {
+				return nil, nil
+			}
+			switch args[1] {
+			case "4C:22:33:44:55:66":
+				return []byte("Device 4C:22:33:44:55:66 (random)\n"), nil
+			case "00:1B:66:44:55:66":
+				return []byte("Device 00:1B:66:44:55:66 (public)\n"), nil
+			}
+			return []byte("Device " + args[1] + " not available\n"), errors.New("exit status 1")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	output := "[NEW] Device 4C:22:33:44:55:66 4C-22-33-44-55-66\n" +
+		"[NEW] Device 00:1B:66:44:55:66 00-1B-66-44-55-66\n" +
+		"[NEW] Device 4C:32:75:33:44:55 4C-32-75-33-44-55\n" +
+		"[CHG] Device 4C:22:33:44:55:66 4C-22-33-44-55-66"
+
+	types := addressTypes(runner, unnamedAddresses(output))
+	want := map[string]string{"4C:22:33:44:55:66": "random", "00:1B:66:44:55:66": "public"}
+	if !maps.Equal(types, want) {
+		t.Fatalf("addressTypes = %v, want %v", types, want)
+	}
+
+	// Without a type, an address that may well be random gets no vendor.
+	if got := oui.Default().Describe("4C:32:75:33:44:55", types["4C:32:75:33:44:55"]); got != "" {
+		t.Fatalf("device without an address type described as %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/policy"
+	"github.com/peared/peared/internal/recovery"
+)
+
+func runReset(args []string) {
+	flagSet := flag.NewFlagSet("reset", flag.ExitOnError)
+	levelName := flagSet.String("level", string(recovery.LevelService), "Highest reset level to escalate to (soft, service, module, usb)")
+	adapterFlag := flagSet.String("adapter", "", "Adapter identifier (ID, address, or alias) to reset")
+	attempts := flagSet.Int("attempts", 2, "Attempts per level before escalating")
+	modules := flagSet.String("modules", "", "Comma-separated kernel modules to reload (defaults to the adapter's driver)")
+	auditPath := flagSet.String("audit-log", "", "Path to the audit log (defaults to $XDG_STATE_HOME/peared/audit.log)")
+	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse reset flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	level, err := policy.ParseResetLevel(*levelName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	setup, err := setupAdapterCommand(*adapterFlag, *noSudo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	target := resetTarget(setup.adapter, daemon.DefaultSysfsPath(), *modules)
+
+	if level != recovery.LevelSoft && level != recovery.LevelService && os.Geteuid() != 0 {
+		fmt.Fprintf(os.Stderr, "warning: the %s level needs root; rerun with sudo if it fails with a permission error.\n", level)
+	}
+
+	if *auditPath == "" {
+		if resolved, err := recovery.DefaultAuditPath(); err == nil {
+			*auditPath = resolved
+		} else {
+			fmt.Fprintf(os.Stderr, "warning: audit log disabled: %v\n", err)
+		}
+	}
+
+	var audit recovery.AuditLog
+	if *auditPath != "" {
+		audit = recovery.NewFileAuditLog(*auditPath)
+	}
+
+	resetter := recovery.NewResetter(recovery.Options{
+		Power:    setup.backend,
+		Services: recovery.NewSystemdServices(),
+		Modules:  recovery.NewModprobe(),
+		USB:      recovery.NewSysfsUSB(""),
+		Health:   daemon.AdapterHealthCheck(daemon.DefaultAdapterProvider(), setup.backend),
+		Audit:    audit,
+		Attempts: *attempts,
+	})
+
+	fmt.Fprintf(os.Stderr, "Resetting %s, escalating up to the %s level...\n", target.Adapter, level)
+	result, err := resetter.Reset(ctx, target, level)
+	writeResetResult(os.Stdout, result)
+
+	if err != nil {
+		if errors.Is(err, recovery.ErrNotRecovered) {
+			fmt.Fprintf(os.Stderr, "%s did not recover; try a higher --level.\n", target.Adapter)
+		} else {
+			fmt.Fprintf(os.Stderr, "reset failed: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// resetTarget describes adapter for the recovery package, replacing the
+// kernel modules derived from its driver when modules is non-empty.
+func resetTarget(adapter daemon.Adapter, sysfsRoot, modules string) recovery.Target {
+	target := daemon.RecoveryTarget(adapter, sysfsRoot)
+
+	if strings.TrimSpace(modules) != "" {
+		target.Modules = nil
+		for _, module := range strings.Split(modules, ",") {
+			if module = strings.TrimSpace(module); module != "" {
+				target.Modules = append(target.Modules, module)
+			}
+		}
+	}
+
+	return target
+}
+
+func writeResetResult(out io.Writer, result recovery.Result) {
+	for _, step := range result.Steps {
+		outcome := "healthy"
+		if !step.Healthy {
+			outcome = "failed: " + step.Error
+		}
+		fmt.Fprintf(out, "%-8s attempt %d\t%s\n", step.Level, step.Attempt, outcome)
+	}
+
+	if result.Recovered {
+		fmt.Fprintf(out, "Recovered after the %s level.\n", result.Level)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/recovery"
+)
+
+func TestResetTarget(t *testing.T) {
+	dir := t.TempDir()
+	iface := filepath.Join(dir, "devices", "usb1", "1-4", "1-4:1.0")
+	if err := os.MkdirAll(iface, 0o755); err != nil {
+		t.Fatalf("failed to create interface dir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "hci0"), 0o755); err != nil {
+		t.Fatalf("failed to create adapter dir: %v", err)
+	}
+	if err := os.Symlink(iface, filepath.Join(dir, "hci0", "device")); err != nil {
+		t.Fatalf("failed to link device: %v", err)
+	}
+
+	adapter := daemon.Adapter{ID: "hci0", Address: "AA:BB:CC:DD:EE:FF", Transport: daemon.AdapterTransportUSB, Driver: "btusb", VendorID: "8087"}
+
+	target := resetTarget(adapter, dir, "")
+	if target.USBDevice != "1-4" || strings.Join(target.Modules, ",") != "btusb,btintel" {
+		t.Fatalf("unexpected target: %+v", target)
+	}
+
+	target = resetTarget(adapter, dir, "btusb, btmtk")
+	if strings.Join(target.Modules, ",") != "btusb,btmtk" {
+		t.Fatalf("expected module override, got %v", target.Modules)
+	}
+}
+
+func TestWriteResetResult(t *testing.T) {
+	var out bytes.Buffer
+	writeResetResult(&out, recovery.Result{
+		Recovered: true,
+		Level:     recovery.LevelService,
+		Steps: []recovery.Step{
+			{Level: recovery.LevelSoft, Attempt: 1, Error: "health check: adapter hci0 is not powered"},
+			{Level: recovery.LevelService, Attempt: 1, Healthy: true},
+		},
+	})
+
+	want := "soft     attempt 1\tfailed: health check: adapter hci0 is not powered\n" +
+		"service  attempt 1\thealthy\n" +
+		"Recovered after the service level.\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected output:\n%q\nwant\n%q", got, want)
+	}
+}
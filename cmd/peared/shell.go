@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/peared/peared/internal/cli"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+// adapterEnv passes the adapter chosen with `use` in the shell to the
+// commands it runs. determineAdapter treats it like --adapter, except that a
+// device pinned to an adapter in the configuration still goes there.
+const adapterEnv = "PEARED_ADAPTER"
+
+// eventRetryInterval is how long the shell waits before subscribing to the
+// daemon's events again after the stream ended or the daemon was absent.
+const eventRetryInterval = 5 * time.Second
+
+// eventBufferLines bounds how many event lines wait while a command writes.
+const eventBufferLines = 16
+
+// completionCacheTTL is how long tab completion reuses the device names it
+// looked up, since listing them runs bluetoothctl for every device.
+const completionCacheTTL = 30 * time.Second
+
+// shellSubcommands lists the subcommands the shell completes after each
+// command.
+var shellSubcommands = map[string][]string{
+	"adapters": {"list", "explain", "show", "power", "alias", "discoverable", "pairable", "help"},
+	"devices":  {"scan", "list", "pair", "connect", "disconnect", "import-bluez", "help"},
+	"agent":    {"reply", "pin", "help"},
+	"oui":      {"update", "lookup", "help"},
+	"config":   {"check", "show", "get", "set", "init", "edit", "help"},
+}
+
+func runShell(args []string) {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	logLevel := fs.String("log-level", "info", "Log level (debug, info, warn, error)")
+	prompt := fs.String("prompt", "peared> ", "Prompt to display for the interactive shell")
+	adapter := fs.String("adapter", "", "Adapter identifier (ID, address, or alias) to start the session with")
+	socket := fs.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	script := fs.String("script", "", "Run the commands in this file (- for standard input) and exit")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to locate the peared binary: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	commands := shellCommands(self)
+	opts := []cli.ShellOption{
+		cli.WithPrompt(*prompt),
+		cli.WithCommands(commands...),
+		cli.WithAdapterResolver(resolveShellAdapter),
+		cli.WithTerminal(isInteractive(os.Stdout)),
+	}
+	if *adapter != "" {
+		resolved, err := resolveShellAdapter(ctx, *adapter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, cli.WithAdapter(resolved))
+	}
+
+	// Piped input is a script too, so heredocs stop at the first failure
+	// instead of carrying on at a prompt nobody sees.
+	if *script == "" && !isInteractive(os.Stdin) {
+		*script = "-"
+	}
+	if *script != "" {
+		runScript(ctx, cancel, *script, opts)
+		return
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: parseLevel(*logLevel)}))
+	logger.Info("starting interactive shell")
+	defer logger.Info("shell session ended")
+
+	opts = append(opts, cli.WithEvents(followEvents(ctx, *socket)))
+
+	// On a terminal lines are edited in place, with history and completion;
+	// otherwise they are read as they come.
+	if isInteractive(os.Stdin) && isInteractive(os.Stdout) {
+		editor, err := newShellEditor(commands)
+		if err != nil {
+			logger.Warn("line editing unavailable", "error", err)
+		} else {
+			defer editor.Close()
+			opts = append(opts, cli.WithEditor(editor))
+		}
+	}
+
+	shell := cli.NewShell(os.Stdin, os.Stdout, opts...)
+
+	// At a plain prompt Ctrl-C leaves the shell; the editor reads it as a
+	// key and discards the line instead.
+	stop := forwardInterrupts(shell, cancel)
+	defer stop()
+
+	if err := shell.Run(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("shell interrupted by context cancellation")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "shell exited with error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger.Info("shell exited normally")
+}
+
+// runScript executes the script at path (- for standard input) and exits
+// with the status of the command that failed, 1 for other errors, or the
+// status given to `exit`.
+func runScript(ctx context.Context, cancel context.CancelFunc, path string, opts []cli.ShellOption) {
+	in, name := io.Reader(os.Stdin), "<stdin>"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open script: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in, name = f, path
+	}
+
+	shell := cli.NewShell(os.Stdin, os.Stdout, opts...)
+	stop := forwardInterrupts(shell, cancel)
+	defer stop()
+
+	err := shell.RunScript(ctx, in, name)
+	if err == nil {
+		return
+	}
+
+	var exit *cli.ExitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
+
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		os.Exit(exitErr.ExitCode())
+	}
+	os.Exit(1)
+}
+
+// forwardInterrupts makes Ctrl-C stop the command the shell is running, and
+// the shell itself when none is. SIGTERM always stops the shell.
+func forwardInterrupts(shell *cli.Shell, cancel context.CancelFunc) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == os.Interrupt && shell.Interrupt() {
+				continue
+			}
+			cancel()
+		}
+	}()
+	return func() { signal.Stop(signals) }
+}
+
+// shellCommands returns the commands the shell dispatches to. Each runs the
+// peared binary at self as a child process, so the commands behave exactly as
+// they do on the command line and their exits do not end the session.
+func shellCommands(self string) []cli.Command {
+	return []cli.Command{
+		{Name: "adapters", Summary: "inspect and configure adapters", Run: execCommand(self, "adapters")},
+		{Name: "devices", Summary: "scan, list, pair, connect and disconnect devices", Run: execCommand(self, "devices")},
+		{Name: "status", Summary: "show the daemon's view of adapters and devices", Run: execCommand(self, "status")},
+		{Name: "events", Summary: "follow daemon events until Ctrl-C", Run: execCommand(self, "events")},
+		{Name: "agent", Summary: "answer pairing prompts and manage stored PINs", Run: execCommand(self, "agent")},
+		{Name: "reset", Summary: "reset a wedged adapter", Run: execCommand(self, "reset")},
+		{Name: "oui", Summary: "look up device manufacturers", Run: execCommand(self, "oui")},
+		{Name: "config", Summary: "check, show and edit the configuration", Run: execCommand(self, "config")},
+		{Name: "tui", Summary: "show a live dashboard of adapters and devices", Run: execCommand(self, "tui")},
+		{Name: "wait-for", Summary: "wait for a device to connect, disconnect, pair or be trusted", Run: waitFor},
+	}
+}
+
+// execCommand runs `self name args...` with the shell's error output, and its
+// input when the shell is interactive, passing the adapter in use through
+// adapterEnv. A non-zero exit is reported as cli.ErrFailed, wrapping the
+// *exec.ExitError, because the command has printed its own error.
+func execCommand(self, name string) func(ctx context.Context, env cli.Env, args []string) error {
+	return func(ctx context.Context, env cli.Env, args []string) error {
+		cmd := exec.CommandContext(ctx, self, append([]string{name}, args...)...)
+		if env.Interactive {
+			cmd.Stdin = os.Stdin
+		}
+		cmd.Stdout = env.Out
+		cmd.Stderr = os.Stderr
+		cmd.Env = os.Environ()
+		if env.Adapter != "" {
+			cmd.Env = append(cmd.Env, adapterEnv+"="+env.Adapter)
+		}
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(os.Interrupt)
+		}
+		cmd.WaitDelay = 5 * time.Second
+
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%w: %w", cli.ErrFailed, exitErr)
+		}
+		return err
+	}
+}
+
+// resolveShellAdapter maps the argument of `use` to a detected adapter's ID.
+func resolveShellAdapter(ctx context.Context, identifier string) (string, error) {
+	adapters, err := daemon.DefaultAdapterProvider().ListAdapters(ctx)
+	if err != nil {
+		return "", fmt.Errorf("discover adapters: %w", err)
+	}
+	for _, adapter := range adapters {
+		if adapter.Matches(identifier) {
+			return adapter.ID, nil
+		}
+	}
+	return "", fmt.Errorf("adapter %q is not present", identifier)
+}
+
+// followEvents streams the daemon's events as printable lines until ctx is
+// cancelled, subscribing again whenever the daemon goes away so events
+// resume when it is restarted.
+func followEvents(ctx context.Context, socket string) <-chan string {
+	lines := make(chan string, eventBufferLines)
+	go func() {
+		defer close(lines)
+		for {
+			_ = control.Events(ctx, socket, func(raw json.RawMessage) error {
+				var ev daemon.Event
+				if err := json.Unmarshal(raw, &ev); err != nil {
+					return fmt.Errorf("decode event: %w", err)
+				}
+				var line strings.Builder
+				writeEvent(&line, ev)
+				select {
+				case lines <- strings.TrimRight(line.String(), "\n"):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				return nil
+			})
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(eventRetryInterval):
+			}
+		}
+	}()
+	return lines
+}
+
+// newShellEditor sets up line editing with the history kept in
+// $XDG_STATE_HOME/peared/history. A history that cannot be read is replaced
+// by one kept in memory for the session.
+func newShellEditor(commands []cli.Command) (*cli.Editor, error) {
+	history, err := openShellHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: shell history disabled: %v\n", err)
+		history, _ = cli.OpenHistory("")
+	}
+
+	names := []string{"use", "help", "exit", "quit"}
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+
+	var (
+		cached   []string
+		cachedAt time.Time
+	)
+	devices := func() []string {
+		if time.Since(cachedAt) < completionCacheTTL {
+			return cached
+		}
+		cached, cachedAt = completionDevices(), time.Now()
+		return cached
+	}
+
+	completer := func(words []string, _ string) []string {
+		return completeShell(words, names, completionAdapters, devices)
+	}
+	return cli.NewEditor(os.Stdin, os.Stdout, cli.WithHistory(history), cli.WithCompleter(completer))
+}
+
+func openShellHistory() (*cli.History, error) {
+	path, err := cli.DefaultHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	return cli.OpenHistory(path)
+}
+
+// completeShell returns the candidates for the word following words: command
+// names first, then each command's subcommands, switch values, adapter IDs
+// and device names or addresses where the command expects them.
+func completeShell(words, commands []string, adapters, devices func() []string) []string {
+	if len(words) == 0 {
+		return commands
+	}
+
+	switch words[len(words)-1] {
+	case "--adapter":
+		return adapters()
+	case "--level":
+		if words[0] == "reset" {
+			return []string{"soft", "service", "module", "usb"}
+		}
+	}
+
+	args := words[1:]
+	switch words[0] {
+	case "use":
+		if len(args) == 0 {
+			return append(adapters(), "none")
+		}
+	case "help":
+		if len(args) == 0 {
+			return commands
+		}
+	case "adapters":
+		if len(args) == 0 {
+			return shellSubcommands["adapters"]
+		}
+		switch args[0] {
+		case "show", "alias":
+			if len(args) == 1 {
+				return adapters()
+			}
+		case "power", "discoverable", "pairable":
+			if len(args) == 1 {
+				return []string{"on", "off"}
+			}
+			if len(args) == 2 {
+				return adapters()
+			}
+		}
+	case "devices":
+		if len(args) == 0 {
+			return shellSubcommands["devices"]
+		}
+		switch args[0] {
+		case "pair", "connect", "disconnect":
+			return devices()
+		}
+	case "agent":
+		if len(args) == 0 {
+			return shellSubcommands["agent"]
+		}
+		if args[0] == "pin" && len(args) == 1 {
+			return []string{"set", "remove", "list"}
+		}
+		if args[0] == "reply" && len(args) == 2 {
+			return []string{"yes", "no"}
+		}
+	case "oui":
+		if len(args) == 0 {
+			return shellSubcommands["oui"]
+		}
+	case "wait-for":
+		switch len(args) {
+		case 0:
+			return []string{"device"}
+		case 1:
+			return devices()
+		case 2:
+			return []string{"connected", "disconnected", "paired", "trusted"}
+		}
+	}
+	return nil
+}
+
+func completionAdapters() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	adapters, err := daemon.DefaultAdapterProvider().ListAdapters(ctx)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(adapters))
+	for _, adapter := range adapters {
+		ids = append(ids, adapter.ID)
+	}
+	return ids
+}
+
+// completionDevices returns the names and addresses of known devices. Like
+// the bash completion it never uses sudo, so it cannot prompt.
+func completionDevices() []string {
+	cfg, err := config.Load("")
+	if err != nil {
+		cfg = &config.Config{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	devices, err := knownDevices(ctx, true, "", cfg)
+	if err != nil {
+		return nil
+	}
+	candidates := deviceNames(devices)
+	for _, device := range devices {
+		candidates = append(candidates, device.Address)
+	}
+	return candidates
+}
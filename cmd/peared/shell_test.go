@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/cli"
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestExecCommandPassesAdapter(t *testing.T) {
+	run := execCommand("/bin/sh", "-c")
+
+	var out bytes.Buffer
+	if err := run(context.Background(), cli.Env{Adapter: "hci1", Out: &out}, []string{"echo adapter=$" + adapterEnv}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if out.String() != "adapter=hci1\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	err := run(context.Background(), cli.Env{Out: &out}, []string{"exit 3"})
+	if !errors.Is(err, cli.ErrFailed) {
+		t.Fatalf("expected ErrFailed for a failing command, got %v", err)
+	}
+}
+
+func TestDetermineAdapterUsesShellAdapter(t *testing.T) {
+	t.Setenv(adapterEnv, "hci1")
+
+	adapter, err := determineAdapter(context.Background(), "", "", "")
+	if err != nil || adapter != "hci1" {
+		t.Fatalf("determineAdapter = %q, %v; want hci1", adapter, err)
+	}
+
+	adapter, err = determineAdapter(context.Background(), "hci0", "", "")
+	if err != nil || adapter != "hci0" {
+		t.Fatalf("expected --adapter to win over the shell adapter, got %q, %v", adapter, err)
+	}
+	// A device pinned to an adapter is not routed through the shell adapter.
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("devices:\n  \"AA:BB:CC:DD:EE:FF\":\n    adapter: \"Gaming Dongle\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if adapter, err := determineAdapter(context.Background(), "", path, "AA:BB:CC:DD:EE:FF"); err == nil && adapter == "hci1" {
+		t.Fatalf("expected the device pin to win over the shell adapter, got %q", adapter)
+	}
+	if adapter, err := determineAdapter(context.Background(), "", path, "11:22:33:44:55:66"); err != nil || adapter != "hci1" {
+		t.Fatalf("determineAdapter for an unpinned device = %q, %v; want hci1", adapter, err)
+	}
+}
+
+func TestFollowEventsRendersDeviceEvents(t *testing.T) {
+	at := time.Date(2024, 5, 6, 12, 0, 0, 0, time.Local)
+	fake := &fakePairingDaemon{stream: []daemon.Event{
+		{Time: at, Type: daemon.EventDeviceConnected, Address: "AA:BB:CC:DD:EE:FF", Message: "WH-1000XM4 (AA:BB:CC:DD:EE:FF) connected"},
+		{Time: at, Type: daemon.EventBatteryLow, Address: "AA:BB:CC:DD:EE:FF", Message: "WH-1000XM4 (AA:BB:CC:DD:EE:FF) battery low: 15%"},
+		{Time: at, Type: daemon.EventDeviceDisconnected, Address: "AA:BB:CC:DD:EE:FF", Message: "WH-1000XM4 (AA:BB:CC:DD:EE:FF) disconnected"},
+	}}
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	ln, err := control.Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go control.Serve(ctx, ln, fake)
+
+	lines := followEvents(ctx, socket)
+	want := []string{
+		"2024-05-06 12:00:00\tdevice.connected\tWH-1000XM4 (AA:BB:CC:DD:EE:FF) connected",
+		"2024-05-06 12:00:00\tdevice.battery_low\tWH-1000XM4 (AA:BB:CC:DD:EE:FF) battery low: 15%",
+		"2024-05-06 12:00:00\tdevice.disconnected\tWH-1000XM4 (AA:BB:CC:DD:EE:FF) disconnected",
+	}
+	for i, line := range want {
+		select {
+		case got := <-lines:
+			if got != line {
+				t.Fatalf("line %d = %q, want %q", i, got, line)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for line %d", i)
+		}
+	}
+}
+
+func TestCompleteShell(t *testing.T) {
+	commands := []string{"use", "help", "adapters", "devices", "reset"}
+	adapters := func() []string { return []string{"hci0", "hci1"} }
+	devices := func() []string { return []string{"Work headphones", "11:11:11:11:11:11"} }
+
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{nil, "use,help,adapters,devices,reset"},
+		{[]string{"use"}, "hci0,hci1,none"},
+		{[]string{"adapters"}, strings.Join(shellSubcommands["adapters"], ",")},
+		{[]string{"adapters", "power"}, "on,off"},
+		{[]string{"adapters", "power", "on"}, "hci0,hci1"},
+		{[]string{"devices", "connect"}, "Work headphones,11:11:11:11:11:11"},
+		{[]string{"devices", "scan", "--adapter"}, "hci0,hci1"},
+		{[]string{"reset", "--level"}, "soft,service,module,usb"},
+		{[]string{"devices", "list"}, ""},
+		{[]string{"wait-for", "device", "cans"}, "connected,disconnected,paired,trusted"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(completeShell(tt.words, commands, adapters, devices), ",")
+		if got != tt.want {
+			t.Errorf("completeShell(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
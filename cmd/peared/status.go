@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func runStatus(args []string) {
+	flagSet := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := flagSet.Bool("json", false, "Print the daemon status as JSON")
+	socket := flagSet.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse status flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := control.Call(ctx, *socket, control.Request{Command: "status"})
+	if err != nil {
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			fmt.Fprintf(os.Stderr, "pearedd is not running; start it to see live status.\n")
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "failed to query daemon status: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := resp.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "daemon returned an error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var status daemon.Status
+	if err := resp.Decode(&status); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode daemon status: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(status); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode status: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	writeStatus(os.Stdout, status)
+}
+
+func writeStatus(out io.Writer, status daemon.Status) {
+	if adapter := status.ActiveAdapter; adapter != nil {
+		fmt.Fprintf(out, "Active adapter: %s (%s)\n", adapter.ID, valueOr(adapter.Address, "no address"))
+	} else {
+		fmt.Fprintf(out, "Active adapter: none\n")
+	}
+
+	if len(status.Limits) > 0 {
+		kinds := make([]string, 0, len(status.Limits))
+		for kind := range status.Limits {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+
+		parts := make([]string, 0, len(kinds))
+		for _, kind := range kinds {
+			parts = append(parts, fmt.Sprintf("%s=%d", kind, status.Limits[daemon.DeviceKind(kind)]))
+		}
+		fmt.Fprintf(out, "Connection limits: %s\n", strings.Join(parts, ", "))
+	}
+
+	fmt.Fprintln(out)
+	if len(status.Devices) == 0 {
+		fmt.Fprintf(out, "No devices known to the daemon.\n")
+	} else {
+		fmt.Fprintf(out, "Devices:\n")
+		for _, dev := range status.Devices {
+			state := "disconnected"
+			switch {
+			case dev.Connected:
+				state = "connected"
+			case dev.Held:
+				state = "held"
+			case dev.GaveUp:
+				state = fmt.Sprintf("gave up after %d attempts", dev.Attempts)
+			case dev.Attempts > 0:
+				state = fmt.Sprintf("reconnecting (attempt %d)", dev.Attempts)
+			}
+
+			if dev.Adapter != "" {
+				state += " via " + dev.Adapter
+			}
+
+			fmt.Fprintf(out, "  %s\t%s\t%s\tpriority %d\t%s\n", dev.Address, valueOr(dev.Name, "(no name)"), dev.Kind, dev.Priority, state)
+		}
+	}
+
+	if len(status.Decisions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Recent arbitration decisions:\n")
+	for _, decision := range status.Decisions {
+		line := fmt.Sprintf("  %s\t%s\t%s %s: %s", decision.Time.Local().Format(time.DateTime), decision.Kind, decision.Action, decision.Address, decision.Reason)
+		if decision.Error != "" {
+			line += fmt.Sprintf(" (failed: %s)", decision.Error)
+		}
+		fmt.Fprintln(out, line)
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
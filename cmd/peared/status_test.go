@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestWriteStatus(t *testing.T) {
+	status := daemon.Status{
+		ActiveAdapter: &daemon.Adapter{ID: "hci0", Address: "AA:BB:CC:DD:EE:FF"},
+		Limits:        map[daemon.DeviceKind]int{daemon.DeviceKindHeadset: 1},
+		Devices: []daemon.DeviceStatus{
+			{Address: "11:11:11:11:11:11", Name: "Studio", Kind: daemon.DeviceKindHeadset, Priority: 10, Connected: true, Adapter: "hci1"},
+			{Address: "22:22:22:22:22:22", Kind: daemon.DeviceKindHeadset, Priority: 1, Held: true},
+		},
+		Decisions: []daemon.ArbitrationDecision{{
+			Time:    time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC),
+			Kind:    daemon.DeviceKindHeadset,
+			Action:  "disconnect",
+			Address: "22:22:22:22:22:22",
+			Reason:  "headset limit of 1 reached",
+		}},
+	}
+
+	var out bytes.Buffer
+	writeStatus(&out, status)
+
+	for _, want := range []string{
+		"Active adapter: hci0 (AA:BB:CC:DD:EE:FF)",
+		"Connection limits: headset=1",
+		"11:11:11:11:11:11\tStudio\theadset\tpriority 10\tconnected via hci1",
+		"22:22:22:22:22:22\t(no name)\theadset\tpriority 1\theld",
+		"disconnect 22:22:22:22:22:22: headset limit of 1 reached",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
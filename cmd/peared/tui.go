@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/peared/peared/internal/audio"
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/devclass"
+	"github.com/peared/peared/internal/tui"
+)
+
+func runTUI(args []string) {
+	flagSet := flag.NewFlagSet("tui", flag.ExitOnError)
+	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+	socket := flagSet.String("socket", "", "Path to the daemon control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
+	refresh := flagSet.Duration("refresh", 5*time.Second, "How often to poll bluetoothctl between daemon events")
+	if err := flagSet.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse tui flags: %v\n", err)
+		os.Exit(2)
+	}
+	if *refresh <= 0 {
+		fmt.Fprintf(os.Stderr, "--refresh must be positive\n")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var opts []bluetoothctl.RunnerOption
+	if *noSudo {
+		opts = append(opts, bluetoothctl.WithUseSudo(false))
+	}
+	runner, err := bluetoothctl.NewRunner(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	backend := &dashboardBackend{
+		runner:   runner,
+		adapters: daemon.DefaultAdapterProvider(),
+		audio:    audio.NewPactl(),
+		cfg:      cfg,
+	}
+	dashboard := tui.New(backend, tui.WithRefresh(*refresh), tui.WithEvents(backend.expireOn(ctx, followEvents(ctx, *socket))))
+	if err := dashboard.Run(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+		handleDeviceCommandError("tui", err)
+		os.Exit(1)
+	}
+}
+
+// dashboardBackend feeds `peared tui` from sysfs and bluetoothctl. Device
+// actions tell the daemon, when it runs, the same way the devices commands
+// do, but quietly so warnings do not scribble over the screen.
+type dashboardBackend struct {
+	runner   *bluetoothctl.Runner
+	adapters daemon.AdapterProvider
+	cfg      *config.Config
+
+	// infos caches device information between refreshes; see deviceInfo.
+	mu         sync.Mutex
+	infos      map[string]cachedInfo
+	looked     map[string]bool
+	generation int
+
+	// audio reports the active audio routes. Without it, or without a
+	// sound server, the dashboard only shows the profiles devices offer.
+	audio interface {
+		ActiveProfiles(ctx context.Context) (map[string]string, error)
+	}
+}
+
+func (b *dashboardBackend) Snapshot(ctx context.Context) (tui.Snapshot, error) {
+	adapters, err := b.adapters.ListAdapters(ctx)
+	if err != nil {
+		return tui.Snapshot{}, fmt.Errorf("discover adapters: %w", err)
+	}
+
+	snapshot := tui.Snapshot{Adapters: adapters}
+	var addresses []string
+	listings := make(map[string][]deviceListing)
+	for _, adapter := range adapters {
+		// A powered-off adapter still lists the devices it knows. One that
+		// cannot list them is left out rather than hiding the others.
+		entries, err := b.runner.ForAdapter(adapter.ID).Devices(ctx)
+		if err != nil {
+			snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("list devices on %s: %v", adapter.ID, commandFailure(err)))
+			continue
+		}
+		for _, entry := range entries {
+			address := daemon.NormalizeAddress(entry.Address)
+			if _, ok := listings[address]; !ok {
+				addresses = append(addresses, address)
+			}
+			listings[address] = append(listings[address], deviceListing{adapter: adapter.ID, entry: entry})
+		}
+	}
+	if len(adapters) > 0 && len(snapshot.Warnings) == len(adapters) {
+		return tui.Snapshot{}, errors.New(strings.Join(snapshot.Warnings, "; "))
+	}
+
+	routes := b.routes(ctx)
+	b.startRefresh()
+	for _, address := range addresses {
+		listing := b.listingFor(address, listings[address], adapters)
+
+		device := tui.Device{Address: address, Name: listing.entry.Name, Adapter: listing.adapter, Kind: daemon.DeviceKindUnknown}
+		if info, ok := b.deviceInfo(ctx, listing.adapter, listing.entry.Address); ok {
+			device.Name = dashboardName(info, listing.entry.Name)
+			device.Kind = daemon.DetectKind(info.Class, info.Appearance, info.UUIDs, info.Icon)
+			device.Paired = info.Paired
+			device.Trusted = info.Trusted
+			device.Connected = info.Connected
+			device.RSSI, device.HasRSSI = info.RSSI, info.HasRSSI
+			device.Battery, device.HasBattery = info.Battery, info.HasBattery
+			device.Audio = devclass.AudioProfiles(info.UUIDs)
+		}
+		device.Route = routes[address]
+		if nickname := b.nickname(address); nickname != "" {
+			device.Name = nickname
+		}
+
+		snapshot.Devices = append(snapshot.Devices, device)
+	}
+	b.finishRefresh()
+
+	return snapshot, nil
+}
+
+// deviceListing is a device as one adapter lists it.
+type deviceListing struct {
+	adapter string
+	entry   bluetoothctl.DeviceEntry
+}
+
+// listingFor picks the adapter a device known to several is shown under:
+// the one it is pinned to when that adapter knows it, otherwise the first.
+func (b *dashboardBackend) listingFor(address string, listed []deviceListing, adapters []daemon.Adapter) deviceListing {
+	if pin := b.pin(address); pin != "" {
+		if id, err := resolvePinnedAdapter(pin, adapters); err == nil {
+			for _, listing := range listed {
+				if listing.adapter == id {
+					return listing
+				}
+			}
+		}
+	}
+	return listed[0]
+}
+
+// pin returns the adapter the configuration pins address to, if any.
+func (b *dashboardBackend) pin(address string) string {
+	device, _ := b.cfg.Device(address)
+	return strings.TrimSpace(device.Adapter)
+}
+
+// adapterFor returns the adapter to act on address through: the one it is
+// pinned to, as for the devices commands, or else the one it is listed
+// under.
+func (b *dashboardBackend) adapterFor(ctx context.Context, listed, address string) (string, error) {
+	pin := b.pin(address)
+	if pin == "" {
+		return listed, nil
+	}
+	adapters, err := b.adapters.ListAdapters(ctx)
+	if err != nil {
+		return "", fmt.Errorf("discover adapters: %w", err)
+	}
+	return resolvePinnedAdapter(pin, adapters)
+}
+
+// dashboardInfoMaxAge is how long the dashboard shows what bluetoothctl last
+// said about a device that is neither connected nor in range.
+const dashboardInfoMaxAge = time.Minute
+
+// cachedInfo is what bluetoothctl said about a device on one adapter.
+type cachedInfo struct {
+	info    bluetoothctl.DeviceInfo
+	fetched time.Time
+}
+
+// startRefresh begins a refresh; devices it does not look at are forgotten
+// by finishRefresh.
+func (b *dashboardBackend) startRefresh() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.looked = make(map[string]bool)
+}
+
+func (b *dashboardBackend) finishRefresh() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key := range b.infos {
+		if !b.looked[key] {
+			delete(b.infos, key)
+		}
+	}
+}
+
+// deviceInfo returns what bluetoothctl says about address on adapter. Each
+// answer costs a bluetoothctl run, so only connected devices, devices seen
+// in a scan and devices asked about more than dashboardInfoMaxAge ago are
+// asked again; expireInfo makes the next refresh ask about all of them.
+func (b *dashboardBackend) deviceInfo(ctx context.Context, adapter, address string) (bluetoothctl.DeviceInfo, bool) {
+	key := adapter + " " + address
+	now := time.Now()
+
+	b.mu.Lock()
+	b.looked[key] = true
+	cached, ok := b.infos[key]
+	generation := b.generation
+	b.mu.Unlock()
+	if ok && !cached.info.Connected && !cached.info.HasRSSI && now.Sub(cached.fetched) < dashboardInfoMaxAge {
+		return cached.info, true
+	}
+
+	info, err := b.runner.ForAdapter(adapter).Info(ctx, address)
+	if err != nil {
+		return bluetoothctl.DeviceInfo{}, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// An answer that predates an action or event may already be out of date.
+	if generation == b.generation {
+		if b.infos == nil {
+			b.infos = make(map[string]cachedInfo)
+		}
+		b.infos[key] = cachedInfo{info: info, fetched: now}
+	}
+	return info, true
+}
+
+// expireInfo forgets what bluetoothctl said about every device, after an
+// action or daemon event that may have changed it.
+func (b *dashboardBackend) expireInfo() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.infos = nil
+	b.generation++
+}
+
+// expireOn passes daemon event lines on to the dashboard, expiring device
+// information before each one so the refresh it triggers sees the change.
+func (b *dashboardBackend) expireOn(ctx context.Context, events <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for line := range events {
+			b.expireInfo()
+			select {
+			case out <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// routes asks the sound server which profile each device's audio is routed
+// through. A missing pactl or sound server only means no routes are shown.
+func (b *dashboardBackend) routes(ctx context.Context) map[string]string {
+	if b.audio == nil {
+		return nil
+	}
+	routes, err := b.audio.ActiveProfiles(ctx)
+	if err != nil {
+		return nil
+	}
+	return routes
+}
+
+// dashboardName prefers the alias, as `devices list` does, and labels
+// devices whose alias is only their dashed address.
+func dashboardName(info bluetoothctl.DeviceInfo, listed string) string {
+	name := valueOr(info.Alias, listed)
+	if name == "" || name == strings.ReplaceAll(info.Address, ":", "-") {
+		name = valueOr(info.Name, unnamedLabel(info.Address, info.AddressType))
+	}
+	return name
+}
+
+func (b *dashboardBackend) nickname(address string) string {
+	for key, settings := range b.cfg.Devices {
+		if daemon.NormalizeAddress(key) == address {
+			return strings.TrimSpace(settings.Nickname)
+		}
+	}
+	return ""
+}
+
+func (b *dashboardBackend) Connect(ctx context.Context, adapter, address string) error {
+	defer b.expireInfo()
+	adapter, err := b.adapterFor(ctx, adapter, address)
+	if err != nil {
+		return err
+	}
+	_ = callDaemon("device.release", address)
+	_, err = b.runner.ForAdapter(adapter).Connect(ctx, address)
+	return commandFailure(err)
+}
+
+func (b *dashboardBackend) Disconnect(ctx context.Context, adapter, address string) error {
+	defer b.expireInfo()
+	adapter, err := b.adapterFor(ctx, adapter, address)
+	if err != nil {
+		return err
+	}
+	_ = callDaemon("device.hold", address)
+	if _, err := b.runner.ForAdapter(adapter).Disconnect(ctx, address); err != nil {
+		_ = callDaemon("device.release", address)
+		return commandFailure(err)
+	}
+	return nil
+}
+
+func (b *dashboardBackend) Pair(ctx context.Context, adapter, address string) error {
+	defer b.expireInfo()
+	_, err := b.runner.ForAdapter(adapter).Pair(ctx, address)
+	return commandFailure(err)
+}
+
+func (b *dashboardBackend) Trust(ctx context.Context, adapter, address string) error {
+	defer b.expireInfo()
+	_, err := b.runner.ForAdapter(adapter).Trust(ctx, address)
+	return commandFailure(err)
+}
+
+func (b *dashboardBackend) Remove(ctx context.Context, adapter, address string) error {
+	defer b.expireInfo()
+	_, err := b.runner.ForAdapter(adapter).Remove(ctx, address)
+	return commandFailure(err)
+}
+
+func (b *dashboardBackend) SetPowered(ctx context.Context, adapter string, on bool) error {
+	_, err := b.runner.ForAdapter(adapter).SetPowered(ctx, on)
+	return commandFailure(err)
+}
+
+func (b *dashboardBackend) Scan(ctx context.Context, adapter string, duration time.Duration) error {
+	defer b.expireInfo()
+	_, err := b.runner.ForAdapter(adapter).Scan(ctx, duration)
+	return commandFailure(err)
+}
+
+// commandFailure replaces a bluetoothctl failure with the last line it
+// printed, which usually says why ("Failed to connect:
+// org.bluez.Error.Failed"), as the dashboard has one line to show it in.
+func commandFailure(err error) error {
+	var cmdErr *bluetoothctl.CommandError
+	if !errors.As(err, &cmdErr) {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(cmdErr.Output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return errors.New(last)
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+)
+
+func TestDashboardSnapshot(t *testing.T) {
+	var selected []string
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "select":
+				selected = append(selected, args[1])
+			case "devices":
+				if selected[len(selected)-1] == "hci0" {
+					return []byte("Device 11:11:11:11:11:11 Work headphones\nDevice 44:44:44:44:44:44 44-44-44-44-44-44\n"), nil
+				}
+				return []byte("Device 11:11:11:11:11:11 Work headphones\n"), nil
+			case "info":
+				if args[1] == "11:11:11:11:11:11" {
+					return []byte("Device 11:11:11:11:11:11 (public)\n\tName: WH-1000XM4\n\tAlias: Work headphones\n\tClass: 0x00240418\n" +
+						"\tPaired: yes\n\tConnected: yes\n\tUUID: Audio Sink (0000110b-0000-1000-8000-00805f9b34fb)\n" +
+						"\tRSSI: -58\n\tBattery Percentage: 0x46 (70)\n"), nil
+				}
+				return []byte("Device " + args[1] + " (random)\n\tAlias: 44-44-44-44-44-44\n\tRSSI: -80\n"), nil
+			}
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	backend := &dashboardBackend{
+		runner: runner,
+		adapters: daemon.AdapterProviderFunc(func(context.Context) ([]daemon.Adapter, error) {
+			return []daemon.Adapter{{ID: "hci0", Powered: true}, {ID: "hci1"}}, nil
+		}),
+		cfg:   &config.Config{Devices: map[string]config.DeviceConfig{"11:11:11:11:11:11": {Nickname: "cans"}}},
+		audio: fakeRoutes{"11:11:11:11:11:11": "a2dp-sink-aac"},
+	}
+
+	snapshot, err := backend.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Adapters) != 2 || len(snapshot.Devices) != 2 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	headphones := snapshot.Devices[0]
+	if headphones.Name != "cans" || headphones.Adapter != "hci0" || !headphones.Paired || !headphones.Connected ||
+		headphones.Kind != daemon.DeviceKindHeadset || headphones.RSSI != -58 || headphones.Battery != 70 ||
+		strings.Join(headphones.Audio, ",") != "A2DP" || headphones.Route != "a2dp-sink-aac" {
+		t.Fatalf("unexpected headphones %+v", headphones)
+	}
+	if unnamed := snapshot.Devices[1]; !strings.HasPrefix(unnamed.Name, "(unnamed") || unnamed.Paired || !unnamed.HasRSSI || unnamed.Route != "" {
+		t.Fatalf("unexpected unnamed device %+v", unnamed)
+	}
+}
+
+func TestDashboardSnapshotSkipsAdapterThatFails(t *testing.T) {
+	var selected string
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "select":
+				selected = args[1]
+			case "devices":
+				if selected == "hci1" {
+					return []byte("org.bluez.Error.NotReady\n"), errors.New("exit status 1")
+				}
+				return []byte("Device 11:11:11:11:11:11 Headphones\n"), nil
+			case "info":
+				return []byte("Device " + args[1] + " (public)\n\tPaired: yes\n"), nil
+			}
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	adapters := []daemon.Adapter{{ID: "hci0"}, {ID: "hci1"}}
+	backend := &dashboardBackend{
+		runner: runner,
+		adapters: daemon.AdapterProviderFunc(func(context.Context) ([]daemon.Adapter, error) {
+			return adapters, nil
+		}),
+		cfg: &config.Config{},
+	}
+
+	snapshot, err := backend.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Devices) != 1 || snapshot.Devices[0].Adapter != "hci0" {
+		t.Fatalf("unexpected devices %+v", snapshot.Devices)
+	}
+	if strings.Join(snapshot.Warnings, "|") != "list devices on hci1: org.bluez.Error.NotReady" {
+		t.Fatalf("unexpected warnings %q", snapshot.Warnings)
+	}
+
+	adapters = adapters[1:]
+	if _, err := backend.Snapshot(context.Background()); err == nil {
+		t.Fatalf("expected an error when no adapter lists its devices")
+	}
+}
+
+func TestDashboardHonoursDevicePins(t *testing.T) {
+	var selected string
+	var connected []string
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "select":
+				selected = args[1]
+			case "devices":
+				return []byte("Device 11:11:11:11:11:11 Headphones\n"), nil
+			case "info":
+				return []byte("Device " + args[1] + " (public)\n"), nil
+			case "connect":
+				connected = append(connected, selected+" "+args[1])
+			}
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	backend := &dashboardBackend{
+		runner: runner,
+		adapters: daemon.AdapterProviderFunc(func(context.Context) ([]daemon.Adapter, error) {
+			return []daemon.Adapter{{ID: "hci0"}, {ID: "hci1", Address: "00:1A:7D:DA:71:13"}}, nil
+		}),
+		cfg: &config.Config{Devices: map[string]config.DeviceConfig{"11:11:11:11:11:11": {Adapter: "00:1A:7D:DA:71:13"}}},
+	}
+
+	snapshot, err := backend.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Devices) != 1 || snapshot.Devices[0].Adapter != "hci1" {
+		t.Fatalf("pinned device not listed under its adapter: %+v", snapshot.Devices)
+	}
+
+	if err := backend.Connect(context.Background(), "hci0", "11:11:11:11:11:11"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if strings.Join(connected, "|") != "hci1 11:11:11:11:11:11" {
+		t.Fatalf("connected through %v, want the pinned adapter", connected)
+	}
+}
+
+func TestDashboardReusesDeviceInfo(t *testing.T) {
+	var asked []string
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "devices":
+				return []byte("Device 11:11:11:11:11:11 Headphones\nDevice 22:22:22:22:22:22 Speaker\nDevice 33:33:33:33:33:33 Keyboard\n"), nil
+			case "info":
+				asked = append(asked, args[1])
+				switch args[1] {
+				case "11:11:11:11:11:11":
+					return []byte("Device 11:11:11:11:11:11 (public)\n\tPaired: yes\n\tConnected: yes\n"), nil
+				case "22:22:22:22:22:22":
+					return []byte("Device 22:22:22:22:22:22 (public)\n\tRSSI: -70\n"), nil
+				}
+				return []byte("Device " + args[1] + " (public)\n\tPaired: yes\n"), nil
+			}
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	backend := &dashboardBackend{
+		runner: runner,
+		adapters: daemon.AdapterProviderFunc(func(context.Context) ([]daemon.Adapter, error) {
+			return []daemon.Adapter{{ID: "hci0"}}, nil
+		}),
+		cfg: &config.Config{},
+	}
+
+	refresh := func() []string {
+		t.Helper()
+		asked = nil
+		snapshot, err := backend.Snapshot(context.Background())
+		if err != nil {
+			t.Fatalf("Snapshot: %v", err)
+		}
+		if len(snapshot.Devices) != 3 || !snapshot.Devices[2].Paired {
+			t.Fatalf("unexpected devices %+v", snapshot.Devices)
+		}
+		return asked
+	}
+
+	if got := refresh(); len(got) != 3 {
+		t.Fatalf("first refresh asked about %v, want every device", got)
+	}
+	// The idle keyboard keeps its last answer; the connected headphones and
+	// the speaker in range are asked about again.
+	if got := strings.Join(refresh(), " "); got != "11:11:11:11:11:11 22:22:22:22:22:22" {
+		t.Fatalf("second refresh asked about %s", got)
+	}
+
+	backend.expireInfo()
+	if got := refresh(); len(got) != 3 {
+		t.Fatalf("refresh after an event asked about %v, want every device", got)
+	}
+}
+
+// fakeRoutes stands in for the sound server's active profiles.
+type fakeRoutes map[string]string
+
+func (f fakeRoutes) ActiveProfiles(context.Context) (map[string]string, error) {
+	return f, nil
+}
+
+func TestCommandFailureShowsLastLine(t *testing.T) {
+	err := commandFailure(&bluetoothctl.CommandError{
+		Args:   []string{"connect", "11:11:11:11:11:11"},
+		Output: "Attempting to connect to 11:11:11:11:11:11\nFailed to connect: org.bluez.Error.Failed\n",
+		Err:    errors.New("exit status 1"),
+	})
+	if err == nil || err.Error() != "Failed to connect: org.bluez.Error.Failed" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	plain := errors.New("boom")
+	if commandFailure(plain) != plain || commandFailure(nil) != nil {
+		t.Fatalf("commandFailure changed an error that did not come from bluetoothctl")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/cli"
+)
+
+// deviceStates are the states `wait-for device` can wait for.
+var deviceStates = map[string]func(bluetoothctl.DeviceInfo) bool{
+	"connected":    func(info bluetoothctl.DeviceInfo) bool { return info.Connected },
+	"disconnected": func(info bluetoothctl.DeviceInfo) bool { return !info.Connected },
+	"paired":       func(info bluetoothctl.DeviceInfo) bool { return info.Paired },
+	"trusted":      func(info bluetoothctl.DeviceInfo) bool { return info.Trusted },
+}
+
+const waitForUsage = "usage: wait-for device <addr> connected|disconnected|paired|trusted [--timeout 30s]"
+
+// waitFor is the shell's `wait-for device <addr> <state>` command. It polls
+// bluetoothctl until the device reaches the state and fails when --timeout
+// passes first, so scripts can wait for a connection before going on.
+func waitFor(ctx context.Context, env cli.Env, args []string) error {
+	flagSet := flag.NewFlagSet("wait-for", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	timeout := flagSet.Duration("timeout", 30*time.Second, "How long to wait before failing")
+	interval := flagSet.Duration("interval", time.Second, "How often to check the device")
+	noSudo := flagSet.Bool("no-sudo", false, "Disable automatic sudo escalation (advanced)")
+	configPath := flagSet.String("config", "", "Path to configuration file (defaults to XDG config directory)")
+
+	positional, err := parseInterspersed(flagSet, args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(env.Out, waitForUsage)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, waitForUsage)
+	}
+	if len(positional) != 3 || positional[0] != "device" {
+		return errors.New(waitForUsage)
+	}
+
+	state := strings.ToLower(positional[2])
+	reached, ok := deviceStates[state]
+	if !ok {
+		return fmt.Errorf("unknown device state %q\n%s", positional[2], waitForUsage)
+	}
+	if *timeout <= 0 || *interval <= 0 {
+		return errors.New("--timeout and --interval must be positive")
+	}
+
+	address, err := lookupDevice(positional[1], *noSudo, env.Adapter, *configPath)
+	if err != nil {
+		return err
+	}
+
+	// Scripts never stop to ask for an adapter, and a device pinned to one
+	// is waited for there rather than through the adapter chosen with `use`.
+	adapter, err := resolveAdapter(ctx, "", env.Adapter, *configPath, address, env.Interactive)
+	if err != nil {
+		return fmt.Errorf("determine adapter: %w", err)
+	}
+	runner, _, err := newBluetoothRunner(*noSudo, adapter, *configPath, address)
+	if err != nil {
+		return err
+	}
+
+	return waitForDevice(ctx, runner, address, state, reached, *timeout, *interval, env.Out)
+}
+
+func waitForDevice(ctx context.Context, runner *bluetoothctl.Runner, address, state string, reached func(bluetoothctl.DeviceInfo) bool, timeout, interval time.Duration, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// A device BlueZ does not know is disconnected, and neither paired
+		// nor trusted. Other failures are retried until the timeout.
+		info, err := runner.Info(ctx, address)
+		if bluetoothctl.IsUnknownDevice(err) {
+			info, err = bluetoothctl.DeviceInfo{Address: address}, nil
+		}
+		if err == nil && reached(info) {
+			fmt.Fprintf(out, "%s is %s.\n", address, state)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%s was not %s within %s", address, state, formatDuration(timeout))
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
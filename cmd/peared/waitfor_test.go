@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/cli"
+)
+
+func TestWaitForDevice(t *testing.T) {
+	checks := 0
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			if args[0] != "info" {
+				return nil, nil
+			}
+			checks++
+			if checks < 3 {
+				return []byte("Device 11:11:11:11:11:11 (public)\n\tConnected: no\n"), nil
+			}
+			return []byte("Device 11:11:11:11:11:11 (public)\n\tConnected: yes\n"), nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = waitForDevice(context.Background(), runner, "11:11:11:11:11:11", "connected", deviceStates["connected"], time.Second, time.Millisecond, &out)
+	if err != nil {
+		t.Fatalf("waitForDevice: %v", err)
+	}
+	if checks != 3 || out.String() != "11:11:11:11:11:11 is connected.\n" {
+		t.Fatalf("unexpected result after %d checks: %q", checks, out.String())
+	}
+
+	err = waitForDevice(context.Background(), runner, "11:11:11:11:11:11", "paired", deviceStates["paired"], 20*time.Millisecond, 5*time.Millisecond, &out)
+	if err == nil || !strings.Contains(err.Error(), "was not paired within") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestWaitForUnknownDevice(t *testing.T) {
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			return []byte("Device " + args[1] + " not available\n"), errors.New("exit status 1")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = waitForDevice(context.Background(), runner, "11:11:11:11:11:11", "disconnected", deviceStates["disconnected"], time.Second, time.Millisecond, &out)
+	if err != nil || out.String() != "11:11:11:11:11:11 is disconnected.\n" {
+		t.Fatalf("waitForDevice(disconnected) = %v, %q", err, out.String())
+	}
+
+	for _, state := range []string{"connected", "paired", "trusted"} {
+		err = waitForDevice(context.Background(), runner, "11:11:11:11:11:11", state, deviceStates[state], 20*time.Millisecond, 5*time.Millisecond, &out)
+		if err == nil || !strings.Contains(err.Error(), "was not "+state+" within") {
+			t.Errorf("waitForDevice(%s) = %v, want a timeout", state, err)
+		}
+	}
+}
+
+func TestWaitForUsage(t *testing.T) {
+	var out bytes.Buffer
+	env := cli.Env{Out: &out}
+
+	for _, args := range [][]string{
+		{"device", "11:11:11:11:11:11"},
+		{"adapter", "hci0", "powered"},
+		{"device", "11:11:11:11:11:11", "asleep"},
+	} {
+		if err := waitFor(context.Background(), env, args); err == nil || !strings.Contains(err.Error(), "usage: wait-for") {
+			t.Errorf("waitFor(%q) = %v, want a usage error", args, err)
+		}
+	}
+}
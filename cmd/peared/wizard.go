@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+	"github.com/peared/peared/internal/daemon"
+)
+
+const (
+	// wizardScanWindow is how long each discovery run lasts. Discovery is
+	// restarted until the user picks a device.
+	wizardScanWindow = 30 * time.Second
+
+	// wizardRefresh is how often the device list is refreshed while
+	// scanning.
+	wizardRefresh = 2 * time.Second
+)
+
+// deviceIcons maps the icon names BlueZ derives from the device class to
+// symbols shown in the wizard's device list.
+var deviceIcons = map[string]string{
+	"audio-headset":    "🎧",
+	"audio-headphones": "🎧",
+	"audio-speakers":   "🔊",
+	"audio-card":       "🔊",
+	"input-keyboard":   "⌨",
+	"input-mouse":      "🖱",
+	"input-tablet":     "✎",
+	"input-gaming":     "🎮",
+	"phone":            "📱",
+	"computer":         "💻",
+	"camera-video":     "📷",
+	"camera-photo":     "📷",
+	"printer":          "🖨",
+}
+
+// kindIcons covers devices whose icon name is missing or unknown but whose
+// class, appearance or services reveal what they are.
+var kindIcons = map[daemon.DeviceKind]string{
+	daemon.DeviceKindHeadset:  "🎧",
+	daemon.DeviceKindSpeaker:  "🔊",
+	daemon.DeviceKindKeyboard: "⌨",
+	daemon.DeviceKindMouse:    "🖱",
+	daemon.DeviceKindGamepad:  "🎮",
+	daemon.DeviceKindPhone:    "📱",
+	daemon.DeviceKindWatch:    "⌚",
+}
+
+func runPairingWizard(noSudo bool, adapter, configPath string) {
+	if !isInteractive(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "the pairing wizard needs an interactive terminal; pass a device address instead\n")
+		os.Exit(2)
+	}
+
+	runner, _, err := newBluetoothRunner(noSudo, adapter, configPath, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up bluetoothctl runner: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	wizard := &pairingWizard{
+		runner:  runner,
+		lines:   newLineReader(os.Stdin),
+		out:     os.Stderr,
+		redraw:  isInteractive(os.Stderr),
+		refresh: wizardRefresh,
+	}
+	if err := wizard.run(ctx); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
+			fmt.Fprintf(os.Stderr, "\nPairing wizard cancelled.\n")
+			os.Exit(1)
+		}
+		handleDeviceCommandError("pairing wizard", err)
+		os.Exit(1)
+	}
+}
+
+// pairingWizard scans for nearby devices, lets the user pick one and walks
+// through pairing, trusting and connecting it.
+type pairingWizard struct {
+	runner *bluetoothctl.Runner
+
+	// socket is the daemon control socket used to answer pairing prompts.
+	// Empty selects the default.
+	socket string
+
+	lines *lineReader
+	out   io.Writer
+
+	// redraw updates the device list in place instead of printing it again.
+	redraw  bool
+	refresh time.Duration
+}
+
+func (w *pairingWizard) run(ctx context.Context) error {
+	device, err := w.choose(ctx)
+	if err != nil {
+		return err
+	}
+	name := deviceLabel(device)
+
+	fmt.Fprintf(w.out, "Pairing with %s (%s). Confirm the passkey on both devices when asked.\n", name, device.Address)
+	if _, err := pairWithPrompts(ctx, w.runner, w.socket, device.Address, w.lines, w.out); err != nil {
+		return fmt.Errorf("pair %s: %w", device.Address, err)
+	}
+	fmt.Fprintf(w.out, "Paired with %s.\n", name)
+
+	if !device.Trusted {
+		trust, err := w.confirm(ctx, fmt.Sprintf("Trust %s so it can reconnect on its own?", name))
+		if err != nil {
+			return err
+		}
+		if trust {
+			if _, err := w.runner.Trust(ctx, device.Address); err != nil {
+				return fmt.Errorf("trust %s: %w", device.Address, err)
+			}
+			fmt.Fprintf(w.out, "Trusted %s.\n", name)
+		}
+	}
+
+	connect, err := w.confirm(ctx, fmt.Sprintf("Connect to %s now?", name))
+	if err != nil {
+		return err
+	}
+	if connect {
+		if _, err := w.runner.Connect(ctx, device.Address); err != nil {
+			return fmt.Errorf("connect %s: %w", device.Address, err)
+		}
+		fmt.Fprintf(w.out, "Connected to %s.\n", name)
+	}
+
+	return nil
+}
+
+// choose scans until the user picks one of the listed devices. The list is
+// refreshed while the user decides; a choice always refers to the list last
+// shown.
+func (w *pairingWizard) choose(ctx context.Context) (bluetoothctl.DeviceInfo, error) {
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+
+	scanErr := make(chan error, 1)
+	go func() { scanErr <- w.scan(scanCtx) }()
+
+	var shown []bluetoothctl.DeviceInfo
+	var rendered string
+	drawn := 0
+
+	update := func() {
+		devices, err := w.nearby(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(w.out, "\nwarning: failed to list devices: %v\n", err)
+				drawn = 0
+			}
+			return
+		}
+
+		text := renderWizardList(devices)
+		if text == rendered {
+			return
+		}
+		if w.redraw && drawn > 0 {
+			// Move back to the first line of the previous list and clear
+			// everything below it.
+			fmt.Fprintf(w.out, "\r\033[%dA\033[J", drawn)
+		}
+		fmt.Fprint(w.out, text)
+		shown, rendered, drawn = devices, text, strings.Count(text, "\n")
+	}
+
+	fmt.Fprintf(w.out, "Scanning for nearby devices. Press Ctrl+C to cancel.\n")
+	update()
+
+	ticker := time.NewTicker(w.refresh)
+	defer ticker.Stop()
+
+	w.lines.request()
+	for {
+		select {
+		case <-ctx.Done():
+			return bluetoothctl.DeviceInfo{}, ctx.Err()
+		case err := <-scanErr:
+			if err != nil {
+				return bluetoothctl.DeviceInfo{}, fmt.Errorf("scan: %w", err)
+			}
+			scanErr = nil
+		case <-ticker.C:
+			update()
+		case line, ok := <-w.lines.c:
+			if !ok {
+				return bluetoothctl.DeviceInfo{}, io.EOF
+			}
+			// The typed line moved the cursor, so the list is printed
+			// afresh from here on.
+			drawn = 0
+			choice := strings.TrimSpace(line)
+			if choice == "" {
+				rendered = ""
+				update()
+				w.lines.request()
+				continue
+			}
+			index, err := strconv.Atoi(choice)
+			if err != nil || index < 1 || index > len(shown) {
+				fmt.Fprintf(w.out, "Invalid selection. Please enter a number between 1 and %d or press Enter to refresh.\n", len(shown))
+				rendered = ""
+				update()
+				w.lines.request()
+				continue
+			}
+			return shown[index-1], nil
+		}
+	}
+}
+
+// scan keeps discovery running until ctx is cancelled.
+func (w *pairingWizard) scan(ctx context.Context) error {
+	for {
+		_, err := w.runner.Scan(ctx, wizardScanWindow)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nearby lists the unpaired devices seen during the current discovery,
+// strongest signal first.
+func (w *pairingWizard) nearby(ctx context.Context) ([]bluetoothctl.DeviceInfo, error) {
+	entries, err := w.runner.Devices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []bluetoothctl.DeviceInfo
+	for _, entry := range entries {
+		info, err := w.runner.Info(ctx, entry.Address)
+		if err != nil {
+			// The device may have been removed since it was listed.
+			continue
+		}
+		// Devices without an RSSI are cached from earlier scans rather
+		// than nearby now.
+		if info.Paired || !info.HasRSSI {
+			continue
+		}
+		if info.Name == "" && entry.Name != strings.ReplaceAll(entry.Address, ":", "-") {
+			info.Name = entry.Name
+		}
+		devices = append(devices, info)
+	}
+
+	sortBySignal(devices)
+	return devices, nil
+}
+
+// confirm asks a yes-or-no question that defaults to yes.
+func (w *pairingWizard) confirm(ctx context.Context, question string) (bool, error) {
+	for {
+		fmt.Fprintf(w.out, "%s [Y/n]: ", question)
+		line, err := w.lines.next(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		default:
+			fmt.Fprintf(w.out, "please answer y or n\n")
+		}
+	}
+}
+
+// sortBySignal orders devices by descending RSSI, breaking ties by address
+// so the list does not reshuffle between refreshes.
+func sortBySignal(devices []bluetoothctl.DeviceInfo) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		if devices[i].RSSI != devices[j].RSSI {
+			return devices[i].RSSI > devices[j].RSSI
+		}
+		return devices[i].Address < devices[j].Address
+	})
+}
+
+// renderWizardList renders the numbered device list followed by the
+// selection prompt.
+func renderWizardList(devices []bluetoothctl.DeviceInfo) string {
+	var b bytes.Buffer
+	if len(devices) == 0 {
+		b.WriteString("No unpaired devices found yet. Put the device into pairing mode.\n")
+		b.WriteString("Waiting for devices (press Enter to refresh): ")
+		return b.String()
+	}
+
+	b.WriteString("Nearby unpaired devices:\n")
+	for i, device := range devices {
+		fmt.Fprintf(&b, "  %2d  %4d dBm  %s  %s  %s\n", i+1, device.RSSI, device.Address, deviceIcon(device), deviceLabel(device))
+	}
+	fmt.Fprintf(&b, "Enter device number [1-%d] (Enter to refresh): ", len(devices))
+	return b.String()
+}
+
+// deviceIcon returns the symbol for the device's type.
+func deviceIcon(device bluetoothctl.DeviceInfo) string {
+	if icon, ok := deviceIcons[device.Icon]; ok {
+		return icon
+	}
+	if icon, ok := kindIcons[daemon.DetectKind(device.Class, device.Appearance, device.UUIDs, device.Icon)]; ok {
+		return icon
+	}
+	return "•"
+}
+
+// deviceLabel returns the name shown for a device.
+func deviceLabel(device bluetoothctl.DeviceInfo) string {
+	// BlueZ falls back to the dashed address as the alias of unnamed
+	// devices.
+	if device.Alias != "" && device.Alias != strings.ReplaceAll(device.Address, ":", "-") {
+		return device.Alias
+	}
+	if device.Name != "" {
+		return device.Name
+	}
+	return unnamedLabel(device.Address, device.AddressType)
+}
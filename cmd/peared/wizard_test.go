@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+)
+
+// fakeBluetoothctl answers bluetoothctl invocations from canned output and
+// records the device commands it receives.
+type fakeBluetoothctl struct {
+	info map[string]string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func (f *fakeBluetoothctl) run(ctx context.Context, _ string, args ...string) ([]byte, error) {
+	switch args[0] {
+	case "--timeout":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case "devices":
+		var out strings.Builder
+		for address := range f.info {
+			out.WriteString("Device " + address + " " + strings.ReplaceAll(address, ":", "-") + "\n")
+		}
+		return []byte(out.String()), nil
+	case "info":
+		return []byte(f.info[args[1]]), nil
+	}
+
+	f.mu.Lock()
+	f.commands = append(f.commands, strings.Join(args, " "))
+	f.mu.Unlock()
+	return nil, nil
+}
+
+func TestPairingWizard(t *testing.T) {
+	fake := &fakeBluetoothctl{info: map[string]string{
+		"11:11:11:11:11:11": "Device 11:11:11:11:11:11\n\tName: Far Speaker\n\tIcon: audio-speakers\n\tPaired: no\n\tRSSI: -80\n",
+		"22:22:22:22:22:22": "Device 22:22:22:22:22:22\n\tName: WH-1000XM4\n\tAlias: Headphones\n\tIcon: audio-headset\n\tPaired: no\n\tRSSI: 0xffffffd0 (-48)\n",
+		"33:33:33:33:33:33": "Device 33:33:33:33:33:33\n\tName: Old Mouse\n\tPaired: yes\n\tRSSI: -40\n",
+		"44:44:44:44:44:44": "Device 44:44:44:44:44:44\n\tName: Cached Keyboard\n\tPaired: no\n",
+	}}
+	runner, err := bluetoothctl.NewRunner(
+		bluetoothctl.WithBinary("bluetoothctl"),
+		bluetoothctl.WithUseSudo(false),
+		bluetoothctl.WithCommandRunner(fake.run),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner: %v", err)
+	}
+
+	var out bytes.Buffer
+	wizard := &pairingWizard{
+		runner: runner,
+		// No daemon listens here, so bluetoothctl's agent handles prompts.
+		socket:  filepath.Join(t.TempDir(), "control.sock"),
+		lines:   newLineReader(strings.NewReader("3\n1\nmaybe\ny\nn\n")),
+		out:     &out,
+		refresh: time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := wizard.run(ctx); err != nil {
+		t.Fatalf("run: %v\noutput:\n%s", err, out.String())
+	}
+
+	list := out.String()
+	if !strings.Contains(list, "   1   -48 dBm  22:22:22:22:22:22  🎧  Headphones\n   2   -80 dBm  11:11:11:11:11:11  🔊  Far Speaker\n") {
+		t.Fatalf("unexpected device list:\n%s", list)
+	}
+	if strings.Contains(list, "Old Mouse") || strings.Contains(list, "Cached Keyboard") {
+		t.Fatalf("expected paired and out-of-range devices to be hidden:\n%s", list)
+	}
+	if !strings.Contains(list, "Invalid selection") || !strings.Contains(list, "please answer y or n") {
+		t.Fatalf("expected invalid answers to be reported:\n%s", list)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	want := []string{"pair 22:22:22:22:22:22", "trust 22:22:22:22:22:22"}
+	if strings.Join(fake.commands, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected commands %v, want %v", fake.commands, want)
+	}
+}
+
+func TestRenderWizardListWhileEmpty(t *testing.T) {
+	if got := renderWizardList(nil); !strings.HasPrefix(got, "No unpaired devices found yet.") {
+		t.Fatalf("unexpected empty list %q", got)
+	}
+	unnamed := bluetoothctl.DeviceInfo{Address: "55:55:55:55:55:55", Alias: "55-55-55-55-55-55"}
+	if got := deviceLabel(unnamed); got != "(unnamed)" {
+		t.Fatalf("deviceLabel = %q", got)
+	}
+	private := bluetoothctl.DeviceInfo{Address: "4C:11:22:33:44:55", AddressType: "random"}
+	if got := deviceLabel(private); got != "(unnamed, resolvable private address)" {
+		t.Fatalf("deviceLabel(random) = %q", got)
+	}
+	if got := deviceIcon(bluetoothctl.DeviceInfo{Appearance: 0x03c4}); got != "🎮" {
+		t.Fatalf("deviceIcon(gamepad appearance) = %q", got)
+	}
+	if got := deviceIcon(unnamed); got != "•" {
+		t.Fatalf("deviceIcon(unknown) = %q", got)
+	}
+}
@@ -11,37 +11,138 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/peared/peared/internal/agent"
+	"github.com/peared/peared/internal/audio"
+	"github.com/peared/peared/internal/bluetoothctl"
 	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/control"
 	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/logind"
+	"github.com/peared/peared/internal/notify"
 )
 
 func main() {
 	var adapter string
 	var configPath string
 	var logLevel string
+	var socketPath string
 
 	flag.StringVar(&adapter, "adapter", "", "Preferred adapter name or MAC address to prioritize")
 	flag.StringVar(&configPath, "config", "", "Path to configuration file (defaults to XDG config directory)")
 	flag.StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&socketPath, "socket", "", "Path to the control socket (defaults to $XDG_RUNTIME_DIR/peared/control.sock)")
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: parseLevel(logLevel)}))
 
-	cfg, err := config.Load(configPath)
+	// Catch SIGHUP before anything else: its default action would end
+	// pearedd if `systemctl reload` came before the reloader started.
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var overrides []config.Override
+	if adapter != "" {
+		overrides = append(overrides, config.Override{Flag: "--adapter", Path: "daemon.preferred_adapter", Value: adapter})
+	}
+
+	cfg, err := config.Load(configPath, overrides...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	if adapter == "" {
-		adapter = cfg.Daemon.PreferredAdapter
+	settings, err := daemonSettings(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if socketPath == "" {
+		if resolved, err := control.DefaultSocketPath(); err == nil {
+			socketPath = resolved
+		} else {
+			logger.Warn("control API disabled", "error", err)
+		}
+	}
+
+	var backend daemon.DeviceBackend
+	var adapterBackend daemon.AdapterBackend
+	if runner, err := bluetoothctl.NewRunner(bluetoothctl.WithUseSudo(false)); err == nil {
+		backend = daemon.NewBluetoothctlBackend(runner)
+		adapterBackend = daemon.NewBluetoothctlAdapterBackend(runner)
+	} else {
+		logger.Warn("device management disabled", "error", err)
+	}
+
+	watchdog, err := watchdogSettings(cfg, adapterBackend)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	var sleepMonitor daemon.SleepMonitor
+	if enabled := cfg.Daemon.Suspend.Enabled; enabled == nil || *enabled {
+		if manager, err := logind.Connect(""); err == nil {
+			defer manager.Close()
+			sleepMonitor = manager
+		} else {
+			logger.Warn("suspend handling disabled", "error", err)
+		}
+	}
+
+	agentOpts, err := agentOptions(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	pairing := daemon.PairingSettings{Timeout: settings.PairingTimeout}
+	if enabled := cfg.Daemon.Agent.Enabled; enabled == nil || *enabled {
+		if pairingAgent, err := agent.Connect("", agentOpts); err == nil {
+			defer pairingAgent.Close()
+			pairing.Agent = pairingAgent
+		} else {
+			logger.Warn("pairing agent disabled", "error", err)
+		}
+
+		if pins, err := agent.NewPinStore(""); err == nil {
+			pairing.Pins = pins
+		} else {
+			logger.Warn("stored PINs disabled", "error", err)
+		}
+
+		if cfg.Daemon.Agent.Notifications {
+			if notifier, err := notify.Connect(""); err == nil {
+				defer notifier.Close()
+				pairing.Notifier = desktopNotifier{notifier: notifier}
+			} else {
+				logger.Warn("pairing notifications disabled", "error", err)
+			}
+		}
 	}
 
 	d, err := daemon.New(daemon.Options{
-		PreferredAdapter: adapter,
-		Logger:           logger,
-		ConfigSource:     cfg.Source,
-		ConfigLoaded:     cfg.Loaded,
+		PreferredAdapter:  settings.PreferredAdapter,
+		Logger:            logger,
+		ConfigSource:      cfg.Source,
+		ConfigLoaded:      cfg.Loaded,
+		DeviceBackend:     backend,
+		Devices:           settings.Devices,
+		DefaultReconnect:  settings.DefaultReconnect,
+		ConnectionLimits:  settings.ConnectionLimits,
+		PollInterval:      settings.PollInterval,
+		BatteryLow:        settings.BatteryLow,
+		FailoverReconnect: settings.FailoverReconnect,
+		SelectionPolicy:   settings.SelectionPolicy,
+		AdapterBackend:    adapterBackend,
+		AdapterSettings:   settings.AdapterSettings,
+		Watchdog:          watchdog,
+		SleepMonitor:      sleepMonitor,
+		SuspendAction:     settings.SuspendAction,
+		AudioBackend:      audio.NewPactl(),
+		Pairing:           pairing,
+		ControlSocket:     socketPath,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to configure daemon: %v\n", err)
@@ -51,6 +152,9 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	reloads := &reloader{daemon: d, log: logger, path: configPath, overrides: overrides, current: cfg}
+	go reloads.run(ctx, hangups)
+
 	if err := d.Run(ctx); err != nil {
 		if errors.Is(err, context.Canceled) {
 			return
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/notify"
+)
+
+// desktopNotifier offers pairing prompts as desktop notifications. Yes-or-no
+// prompts get Accept and Reject buttons; PINs and passkeys still have to be
+// typed through `peared agent`.
+type desktopNotifier struct {
+	notifier *notify.Notifier
+}
+
+func (n desktopNotifier) NotifyPairing(ctx context.Context, prompt daemon.PairingPrompt) (bool, bool, error) {
+	note := notify.Notification{Summary: "Bluetooth pairing", Body: prompt.Describe(), Icon: "bluetooth"}
+
+	switch {
+	case prompt.Display():
+		// BlueZ repeats passkey displays as digits are typed; only the
+		// first one is worth a notification.
+		if prompt.Entered > 0 {
+			return false, false, nil
+		}
+		_, err := n.notifier.Show(ctx, note)
+		return false, false, err
+	case prompt.NeedsValue():
+		note.Body += ". Answer with `peared agent`."
+		_, err := n.notifier.Show(ctx, note)
+		return false, false, err
+	}
+
+	note.Actions = []notify.Action{{Key: "accept", Label: "Accept"}, {Key: "reject", Label: "Reject"}}
+	key, err := n.notifier.Ask(ctx, note)
+	if err != nil {
+		return false, false, err
+	}
+	return key == "accept", key != "", nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+)
+
+// reloader re-reads the configuration on SIGHUP and whenever one of its
+// files changes, handing the new settings to the daemon. A configuration
+// that does not validate is rejected as a whole and the running one is kept.
+type reloader struct {
+	daemon    *daemon.Daemon
+	log       *slog.Logger
+	path      string
+	overrides []config.Override
+	current   *config.Config
+}
+
+// run reloads on every signal received from hangups, which main registers
+// for SIGHUP, and on file changes until ctx is done.
+func (r *reloader) run(ctx context.Context, hangups <-chan os.Signal) {
+	changes := make(chan struct{}, 1)
+	paths := r.current.WatchPaths()
+	go func() {
+		err := config.Watch(ctx, paths, func() {
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			r.log.Warn("configuration files not watched; send SIGHUP to reload", "error", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			r.reload("SIGHUP")
+		case <-changes:
+			r.reload("file changed")
+		}
+	}
+}
+
+func (r *reloader) reload(trigger string) {
+	logger := r.log.With("trigger", trigger, "config_source", r.current.Source)
+
+	cfg, err := config.Load(r.path, r.overrides...)
+	if err == nil {
+		var settings daemon.Settings
+		if settings, err = daemonSettings(cfg); err == nil {
+			r.daemon.Reload(settings)
+			if restart := restartRequired(r.current, cfg); len(restart) > 0 {
+				logger.Warn("some changes take effect after a restart", "settings", restart)
+			}
+			r.current = cfg
+			return
+		}
+	}
+
+	logger.Error("configuration reload failed; keeping the previous configuration", "error", err)
+}
+
+// restartRequired lists the changed settings that are only read when the
+// daemon starts.
+func restartRequired(previous, next *config.Config) []string {
+	var changed []string
+	if !reflect.DeepEqual(previous.Daemon.Watchdog, next.Daemon.Watchdog) {
+		changed = append(changed, "daemon.watchdog")
+	}
+
+	// The prompt timeout is reloaded; everything else configures the agent
+	// registered at startup.
+	previousAgent, nextAgent := previous.Daemon.Agent, next.Daemon.Agent
+	previousAgent.Timeout, nextAgent.Timeout = 0, 0
+	if !reflect.DeepEqual(previousAgent, nextAgent) {
+		changed = append(changed, "daemon.agent")
+	}
+
+	if !reflect.DeepEqual(previous.Daemon.Suspend.Enabled, next.Daemon.Suspend.Enabled) {
+		changed = append(changed, "daemon.suspend.enabled")
+	}
+	return changed
+}
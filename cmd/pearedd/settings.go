@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/peared/peared/internal/agent"
+	"github.com/peared/peared/internal/config"
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/devclass"
+	"github.com/peared/peared/internal/policy"
+	"github.com/peared/peared/internal/recovery"
+)
+
+// reconnectPolicy converts a reconnect block from the configuration file into
+// a daemon policy. Unset fields inherit from base.
+func reconnectPolicy(cfg config.ReconnectConfig, base daemon.ReconnectPolicy) (daemon.ReconnectPolicy, error) {
+	merged := base
+
+	if cfg.Policy != "" {
+		mode, err := policy.ParseReconnectMode(cfg.Policy)
+		if err != nil {
+			return daemon.ReconnectPolicy{}, err
+		}
+		merged.Mode = mode
+	}
+
+	if len(cfg.Windows) > 0 {
+		merged.Windows = nil
+		for _, raw := range cfg.Windows {
+			window, err := policy.ParseTimeWindow(raw)
+			if err != nil {
+				return daemon.ReconnectPolicy{}, err
+			}
+			merged.Windows = append(merged.Windows, window)
+		}
+	}
+
+	if cfg.InitialBackoff > 0 {
+		merged.InitialBackoff = cfg.InitialBackoff
+	}
+	if cfg.MaxBackoff > 0 {
+		merged.MaxBackoff = cfg.MaxBackoff
+	}
+	if cfg.MaxAttempts > 0 {
+		merged.MaxAttempts = cfg.MaxAttempts
+	}
+
+	return merged, nil
+}
+
+// deviceSettings builds the daemon's per-device settings and the default
+// reconnect policy for trusted devices from the loaded configuration.
+func deviceSettings(cfg *config.Config) (map[string]daemon.DeviceSettings, daemon.ReconnectPolicy, error) {
+	defaults, err := reconnectPolicy(cfg.Daemon.Reconnect, daemon.ReconnectPolicy{})
+	if err != nil {
+		return nil, daemon.ReconnectPolicy{}, fmt.Errorf("daemon.reconnect: %w", err)
+	}
+
+	settings := make(map[string]daemon.DeviceSettings, len(cfg.Devices))
+	for address, device := range cfg.Devices {
+		reconnect, err := reconnectPolicy(device.Reconnect, defaults)
+		if err != nil {
+			return nil, daemon.ReconnectPolicy{}, fmt.Errorf("devices.%s.reconnect: %w", address, err)
+		}
+
+		// config.Load has already rejected unknown kinds.
+		kind, _ := devclass.ParseKind(device.Kind)
+
+		// An unset action defers to daemon.suspend.devices.
+		var suspend daemon.SuspendAction
+		if device.Suspend != "" {
+			if suspend, err = policy.ParseSuspendAction(device.Suspend); err != nil {
+				return nil, daemon.ReconnectPolicy{}, fmt.Errorf("devices.%s.suspend: %w", address, err)
+			}
+		}
+
+		audio, err := policy.NewAudioSettings(device.Audio.Profile, device.Audio.Codec, device.Audio.Volume)
+		if err != nil {
+			return nil, daemon.ReconnectPolicy{}, fmt.Errorf("devices.%s.audio: %w", address, err)
+		}
+
+		settings[daemon.NormalizeAddress(address)] = daemon.DeviceSettings{
+			Reconnect:  reconnect,
+			Priority:   device.Priority,
+			Kind:       kind,
+			Adapter:    device.Adapter,
+			Suspend:    suspend,
+			AutoAccept: device.AutoAccept,
+			Nickname:   device.Nickname,
+			Audio:      audio,
+			Hooks:      daemon.DeviceHooks{Connect: device.Hooks.Connect, Disconnect: device.Hooks.Disconnect},
+		}
+	}
+
+	return settings, defaults, nil
+}
+
+// connectionLimits converts the per-kind connection limits from the
+// configuration file. config.Load has already rejected unknown kinds and
+// negative limits.
+func connectionLimits(cfg *config.Config) map[daemon.DeviceKind]int {
+	limits := make(map[daemon.DeviceKind]int, len(cfg.Daemon.ConnectionLimits))
+	for name, limit := range cfg.Daemon.ConnectionLimits {
+		kind, _ := devclass.ParseKind(name)
+		limits[kind] = limit
+	}
+
+	return limits
+}
+
+// adapterSettings converts the adapters section of the configuration file.
+func adapterSettings(cfg *config.Config) map[string]daemon.AdapterSettings {
+	settings := make(map[string]daemon.AdapterSettings, len(cfg.Adapters))
+	for identifier, adapter := range cfg.Adapters {
+		settings[identifier] = daemon.AdapterSettings{
+			Powered:             adapter.Powered,
+			Alias:               adapter.Alias,
+			Discoverable:        adapter.Discoverable,
+			Pairable:            adapter.Pairable,
+			DiscoverableTimeout: adapter.DiscoverableTimeout,
+			PairableTimeout:     adapter.PairableTimeout,
+		}
+	}
+
+	return settings
+}
+
+// watchdogSettings converts the daemon.watchdog block into daemon settings,
+// wiring a Resetter that drives the system through backend, systemd,
+// modprobe and sysfs. A disabled watchdog yields zero settings.
+func watchdogSettings(cfg *config.Config, backend daemon.AdapterBackend) (daemon.WatchdogSettings, error) {
+	watchdog := cfg.Daemon.Watchdog
+	if !watchdog.Enabled {
+		return daemon.WatchdogSettings{}, nil
+	}
+
+	levels, err := policy.ParseResetLevels(watchdog.Levels)
+	if err != nil {
+		return daemon.WatchdogSettings{}, fmt.Errorf("daemon.watchdog.levels: %w", err)
+	}
+
+	auditPath := watchdog.AuditLog
+	if auditPath == "" {
+		// Without a resolvable state directory recoveries still run; they
+		// are only reported through events and logs.
+		auditPath, _ = recovery.DefaultAuditPath()
+	}
+
+	var audit recovery.AuditLog
+	if auditPath != "" {
+		audit = recovery.NewFileAuditLog(auditPath)
+	}
+
+	opts := recovery.Options{
+		Services: recovery.NewSystemdServices(),
+		Modules:  recovery.NewModprobe(),
+		USB:      recovery.NewSysfsUSB(""),
+		Health:   daemon.AdapterHealthCheck(daemon.DefaultAdapterProvider(), backend),
+		Audit:    audit,
+	}
+	if backend != nil {
+		opts.Power = backend
+	}
+
+	return daemon.WatchdogSettings{
+		Recoverer:       recovery.NewResetter(opts),
+		Interval:        watchdog.Interval,
+		ProbeTimeout:    watchdog.ProbeTimeout,
+		Threshold:       watchdog.Threshold,
+		ConnectTimeouts: watchdog.ConnectTimeouts,
+		Levels:          levels,
+		MaxRecoveries:   watchdog.MaxRecoveries,
+		Window:          watchdog.Window,
+	}, nil
+}
+
+// suspendAction converts daemon.suspend.devices.
+func suspendAction(cfg *config.Config) (daemon.SuspendAction, error) {
+	action, err := policy.ParseSuspendAction(cfg.Daemon.Suspend.Devices)
+	if err != nil {
+		return "", fmt.Errorf("daemon.suspend.devices: %w", err)
+	}
+	return action, nil
+}
+
+// agentOptions converts daemon.agent into pairing agent options.
+func agentOptions(cfg *config.Config) (agent.Options, error) {
+	capability, err := policy.ParseCapability(cfg.Daemon.Agent.Capability)
+	if err != nil {
+		return agent.Options{}, fmt.Errorf("daemon.agent.capability: %w", err)
+	}
+
+	isDefault := cfg.Daemon.Agent.Default == nil || *cfg.Daemon.Agent.Default
+	return agent.Options{Capability: capability, Default: isDefault}, nil
+}
+
+// daemonSettings converts the parts of the configuration the daemon can
+// apply while it runs. The configuration has been checked by config.Load;
+// the errors returned here only come from converting values.
+func daemonSettings(cfg *config.Config) (daemon.Settings, error) {
+	devices, defaultReconnect, err := deviceSettings(cfg)
+	if err != nil {
+		return daemon.Settings{}, err
+	}
+
+	selection := cfg.Daemon.AdapterSelection
+	selectionPolicy, err := daemon.NewSelectionPolicy(selection.Prefer, selection.Exclude, selection.Tiebreak)
+	if err != nil {
+		return daemon.Settings{}, fmt.Errorf("daemon.adapter_selection: %w", err)
+	}
+
+	onSuspend, err := suspendAction(cfg)
+	if err != nil {
+		return daemon.Settings{}, err
+	}
+
+	return daemon.Settings{
+		PreferredAdapter:  cfg.Daemon.PreferredAdapter,
+		Devices:           devices,
+		DefaultReconnect:  defaultReconnect,
+		ConnectionLimits:  connectionLimits(cfg),
+		PollInterval:      cfg.Daemon.PollInterval,
+		FailoverReconnect: cfg.Daemon.Failover.ReconnectDevices,
+		SelectionPolicy:   selectionPolicy,
+		AdapterSettings:   adapterSettings(cfg),
+		SuspendAction:     onSuspend,
+		PairingTimeout:    cfg.Daemon.Agent.Timeout,
+		BatteryLow:        cfg.Daemon.BatteryLow,
+	}, nil
+}
@@ -0,0 +1,10 @@
+// Package examples embeds the example configuration files so the tools can
+// write them out, as `peared config init` does.
+package examples
+
+import _ "embed"
+
+// Minimal is the starter configuration, with placeholder values only.
+//
+//go:embed minimal.yaml
+var Minimal []byte
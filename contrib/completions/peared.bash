@@ -14,6 +14,32 @@ _peared_adapter_candidates() {
         peared adapters list 2>/dev/null | awk '{print $1}'
 }
 
+_peared_device_candidates() {
+        if ! command -v peared >/dev/null 2>&1; then
+                return
+        fi
+
+        # --no-sudo keeps completion from prompting for a password.
+        peared devices list --names --no-sudo 2>/dev/null
+}
+
+_peared_complete_devices() {
+        local cur_word="$1"
+        local IFS=$'\n'
+        local devices
+        mapfile -t devices < <(_peared_device_candidates)
+        if [ ${#devices[@]} -eq 0 ]; then
+                return
+        fi
+        COMPREPLY=( $(compgen -W "${devices[*]}" -- "$cur_word") )
+
+        # Names often contain spaces; quote them for the command line.
+        local i
+        for i in "${!COMPREPLY[@]}"; do
+                COMPREPLY[$i]=$(printf '%q' "${COMPREPLY[$i]}")
+        done
+}
+
 _peared_complete_files() {
         local cur_word="$1"
         _peared_compopt -o filenames 2>/dev/null
@@ -56,7 +82,7 @@ _peared()
         prev="${COMP_WORDS[COMP_CWORD-1]}"
 
         if [ $cword -le 1 ]; then
-                COMPREPLY=( $(compgen -W "adapters devices shell help" -- "$cur") )
+                COMPREPLY=( $(compgen -W "adapters devices status events reset agent oui tui config shell help" -- "$cur") )
                 return
         fi
 
@@ -70,15 +96,23 @@ _peared()
                 --prompt)
                         return
                         ;;
+                --adapter)
+                        _peared_complete_adapters "$cur"
+                        return
+                        ;;
+                --socket|--script)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
                 esac
 
                 if [[ "$cur" == -* ]]; then
-                        COMPREPLY=( $(compgen -W "--log-level --prompt --help -h" -- "$cur") )
+                        COMPREPLY=( $(compgen -W "--log-level --prompt --adapter --socket --script --help -h" -- "$cur") )
                 fi
                 ;;
         adapters)
                 if [ $cword -eq 2 ]; then
-                        COMPREPLY=( $(compgen -W "list help" -- "$cur") )
+                        COMPREPLY=( $(compgen -W "list explain show power alias discoverable pairable help" -- "$cur") )
                         return
                 fi
 
@@ -92,9 +126,58 @@ _peared()
                         esac
 
                         if [[ "$cur" == -* ]]; then
-                                COMPREPLY=( $(compgen -W "--sysfs --help -h" -- "$cur") )
+                                COMPREPLY=( $(compgen -W "--sysfs --long --json --help -h" -- "$cur") )
+                        fi
+                        ;;
+                explain)
+                        case "$prev" in
+                        --config)
+                                _peared_complete_files "$cur"
+                                return
+                                ;;
+                        esac
+
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--config --json --help -h" -- "$cur") )
                         fi
                         ;;
+                show|power|alias|discoverable|pairable)
+                        case "$prev" in
+                        --config)
+                                _peared_complete_files "$cur"
+                                return
+                                ;;
+                        --timeout)
+                                _peared_complete_duration "$cur"
+                                return
+                                ;;
+                        esac
+
+                        if [[ "$cur" == -* ]]; then
+                                local opts="--no-sudo --config --help -h"
+                                case "${words[2]}" in
+                                show) opts="--json $opts" ;;
+                                discoverable|pairable) opts="--timeout $opts" ;;
+                                esac
+                                COMPREPLY=( $(compgen -W "$opts" -- "$cur") )
+                                return
+                        fi
+
+                        case "${words[2]}" in
+                        power|discoverable|pairable)
+                                if [ $cword -eq 3 ]; then
+                                        COMPREPLY=( $(compgen -W "on off" -- "$cur") )
+                                else
+                                        _peared_complete_adapters "$cur"
+                                fi
+                                ;;
+                        show|alias)
+                                if [ $cword -eq 3 ]; then
+                                        _peared_complete_adapters "$cur"
+                                fi
+                                ;;
+                        esac
+                        ;;
                 help)
                         if [[ "$cur" == -* ]]; then
                                 COMPREPLY=( $(compgen -W "--help -h" -- "$cur") )
@@ -102,9 +185,138 @@ _peared()
                         ;;
                 esac
                 ;;
+        reset)
+                case "$prev" in
+                --level)
+                        COMPREPLY=( $(compgen -W "soft service module usb" -- "$cur") )
+                        return
+                        ;;
+                --adapter)
+                        _peared_complete_adapters "$cur"
+                        return
+                        ;;
+                --config|--audit-log)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
+                --attempts|--modules)
+                        return
+                        ;;
+                esac
+
+                if [[ "$cur" == -* ]]; then
+                        COMPREPLY=( $(compgen -W "--level --adapter --attempts --modules --audit-log --no-sudo --config --help -h" -- "$cur") )
+                fi
+                ;;
+        status|events)
+                case "$prev" in
+                --socket)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
+                esac
+
+                if [[ "$cur" == -* ]]; then
+                        COMPREPLY=( $(compgen -W "--json --socket --help -h" -- "$cur") )
+                fi
+                ;;
+        agent)
+                case "$prev" in
+                --socket)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
+                --device)
+                        return
+                        ;;
+                esac
+
+                if [ $cword -eq 2 ] && [[ "$cur" != -* ]]; then
+                        COMPREPLY=( $(compgen -W "reply pin help" -- "$cur") )
+                        return
+                fi
+
+                case "${words[2]}" in
+                pin)
+                        if [ $cword -eq 3 ]; then
+                                COMPREPLY=( $(compgen -W "set remove list" -- "$cur") )
+                        fi
+                        ;;
+                reply)
+                        if [ $cword -eq 4 ]; then
+                                COMPREPLY=( $(compgen -W "yes no" -- "$cur") )
+                        elif [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--socket --help -h" -- "$cur") )
+                        fi
+                        ;;
+                *)
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--device --socket --help -h" -- "$cur") )
+                        fi
+                        ;;
+                esac
+                ;;
+        config)
+                if [ $cword -eq 2 ]; then
+                        COMPREPLY=( $(compgen -W "check show get set init edit help" -- "$cur") )
+                        return
+                fi
+
+                case "$prev" in
+                --config)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
+                esac
+
+                if [[ "$cur" == -* ]]; then
+                        local opts="--config --help -h"
+                        case "${words[2]}" in
+                        show) opts="--origin $opts" ;;
+                        init) opts="--force $opts" ;;
+                        esac
+                        COMPREPLY=( $(compgen -W "$opts" -- "$cur") )
+                fi
+                ;;
+        tui)
+                case "$prev" in
+                --config|--socket)
+                        _peared_complete_files "$cur"
+                        return
+                        ;;
+                --refresh)
+                        return
+                        ;;
+                esac
+
+                if [[ "$cur" == -* ]]; then
+                        COMPREPLY=( $(compgen -W "--no-sudo --config --socket --refresh --help -h" -- "$cur") )
+                fi
+                ;;
+        oui)
+                if [ $cword -eq 2 ]; then
+                        COMPREPLY=( $(compgen -W "update lookup help" -- "$cur") )
+                        return
+                fi
+
+                case "${words[2]}" in
+                update)
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--output --help -h" -- "$cur") )
+                        else
+                                _peared_complete_files "$cur"
+                        fi
+                        ;;
+                lookup)
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--random --help -h" -- "$cur") )
+                        fi
+                        ;;
+                esac
+                ;;
         devices)
                 if [ $cword -eq 2 ]; then
-                        COMPREPLY=( $(compgen -W "scan pair connect disconnect help" -- "$cur") )
+                        COMPREPLY=( $(compgen -W "scan list pair connect disconnect import-bluez help" -- "$cur") )
                         return
                 fi
 
@@ -142,7 +354,45 @@ _peared()
                         esac
 
                         if [[ "$cur" == -* ]]; then
-                                COMPREPLY=( $(compgen -W "--no-sudo --adapter --config --help -h" -- "$cur") )
+                                local opts="--no-sudo --adapter --config --help -h"
+                                if [ "${words[2]}" = "pair" ]; then
+                                        opts="--wizard $opts"
+                                fi
+                                COMPREPLY=( $(compgen -W "$opts" -- "$cur") )
+                        else
+                                _peared_complete_devices "$cur"
+                        fi
+                        ;;
+                list)
+                        case "$prev" in
+                        --config)
+                                _peared_complete_files "$cur"
+                                return
+                                ;;
+                        --adapter)
+                                _peared_complete_adapters "$cur"
+                                return
+                                ;;
+                        esac
+
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--names --no-sudo --adapter --config --help -h" -- "$cur") )
+                        fi
+                        ;;
+                import-bluez)
+                        case "$prev" in
+                        --root)
+                                _peared_complete_dirs "$cur"
+                                return
+                                ;;
+                        --registry)
+                                _peared_complete_files "$cur"
+                                return
+                                ;;
+                        esac
+
+                        if [[ "$cur" == -* ]]; then
+                                COMPREPLY=( $(compgen -W "--root --registry --dry-run --help -h" -- "$cur") )
                         fi
                         ;;
                 help)
@@ -154,7 +404,7 @@ _peared()
                 ;;
         help)
                 if [ $cword -eq 2 ]; then
-                        COMPREPLY=( $(compgen -W "adapters devices shell" -- "$cur") )
+                        COMPREPLY=( $(compgen -W "adapters devices status events reset agent oui tui config shell" -- "$cur") )
                         return
                 fi
                 ;;
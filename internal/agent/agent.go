@@ -0,0 +1,408 @@
+// Package agent implements a BlueZ pairing agent (org.bluez.Agent1) so
+// pearedd can answer PIN, passkey and confirmation requests without relying
+// on bluetoothctl's interactive agent.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+	"github.com/peared/peared/internal/policy"
+)
+
+const (
+	// Service, ManagerPath and ManagerInterface address the BlueZ agent
+	// manager.
+	Service          = "org.bluez"
+	ManagerPath      = dbus.ObjectPath("/org/bluez")
+	ManagerInterface = "org.bluez.AgentManager1"
+
+	// Interface is the interface agents export.
+	Interface = "org.bluez.Agent1"
+
+	// DefaultPath is where the agent is exported unless Options.Path says
+	// otherwise.
+	DefaultPath = dbus.ObjectPath("/org/peared/agent")
+
+	// ErrorRejected and ErrorCanceled are the replies BlueZ expects when a
+	// request is refused or abandoned.
+	ErrorRejected = "org.bluez.Error.Rejected"
+	ErrorCanceled = "org.bluez.Error.Canceled"
+)
+
+// unregisterTimeout bounds the UnregisterAgent call made on shutdown.
+const unregisterTimeout = time.Second
+
+// Capability is the input and output capability the agent advertises.
+type Capability = policy.Capability
+
+const (
+	DisplayOnly     = policy.DisplayOnly
+	DisplayYesNo    = policy.DisplayYesNo
+	KeyboardOnly    = policy.KeyboardOnly
+	NoInputNoOutput = policy.NoInputNoOutput
+	KeyboardDisplay = policy.KeyboardDisplay
+)
+
+// Kind identifies the Agent1 method behind a Request.
+type Kind string
+
+const (
+	// KindPinCode asks for a legacy PIN code (Response.PinCode).
+	KindPinCode Kind = "pin-code"
+
+	// KindPasskey asks for the six-digit passkey shown by the device
+	// (Response.Passkey).
+	KindPasskey Kind = "passkey"
+
+	// KindConfirmation asks whether Request.Passkey matches the one shown
+	// by the device.
+	KindConfirmation Kind = "confirmation"
+
+	// KindAuthorization asks whether an incoming pairing may proceed.
+	KindAuthorization Kind = "authorization"
+
+	// KindService asks whether the device may use the profile
+	// Request.UUID.
+	KindService Kind = "service"
+
+	// KindDisplayPinCode and KindDisplayPasskey ask for Request.PinCode or
+	// Request.Passkey to be shown so the user can type it on the device.
+	// They need no answer.
+	KindDisplayPinCode Kind = "display-pin-code"
+	KindDisplayPasskey Kind = "display-passkey"
+)
+
+// Request is a pairing request from BlueZ.
+type Request struct {
+	Kind Kind
+
+	// Adapter and Address identify the device, parsed from its object path.
+	Adapter string
+	Address string
+
+	PinCode string
+	Passkey uint32
+
+	// Entered counts the passkey digits typed so far on the device. BlueZ
+	// repeats KindDisplayPasskey as it changes.
+	Entered uint16
+
+	UUID string
+}
+
+// Display reports whether the request only shows information and needs no
+// answer.
+func (r Request) Display() bool {
+	return r.Kind == KindDisplayPinCode || r.Kind == KindDisplayPasskey
+}
+
+// Response answers a Request. Requests that are not accepted are rejected.
+type Response struct {
+	Accept  bool
+	PinCode string
+	Passkey uint32
+}
+
+// Handler decides pairing requests. The context is cancelled when BlueZ
+// cancels the request, which is then reported as canceled regardless of
+// what the handler returns.
+type Handler interface {
+	HandlePairing(ctx context.Context, req Request) (Response, error)
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, req Request) (Response, error)
+
+// HandlePairing implements Handler.
+func (f HandlerFunc) HandlePairing(ctx context.Context, req Request) (Response, error) {
+	return f(ctx, req)
+}
+
+// Options configures an Agent.
+type Options struct {
+	// Path is the object path the agent is exported at. Empty selects
+	// DefaultPath.
+	Path dbus.ObjectPath
+
+	// Capability is advertised to BlueZ. Empty selects KeyboardDisplay.
+	Capability Capability
+
+	// Default asks BlueZ to use this agent for pairings started by clients
+	// without their own agent and for pairings initiated by devices.
+	Default bool
+}
+
+// Agent exports Agent1 on a bus connection and registers it with BlueZ.
+type Agent struct {
+	conn       *dbus.Conn
+	path       dbus.ObjectPath
+	capability Capability
+	isDefault  bool
+
+	mu       sync.Mutex
+	nextID   int
+	inflight map[int]context.CancelFunc
+}
+
+// Connect opens a connection to the bus at address for a new agent. An empty
+// address selects the system bus.
+func Connect(address string, opts Options) (*Agent, error) {
+	if address == "" {
+		address = dbus.SystemBusAddress()
+	}
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, opts), nil
+}
+
+// New builds an agent on an existing connection. The agent takes over the
+// connection's method call handling.
+func New(conn *dbus.Conn, opts Options) *Agent {
+	path := opts.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	capability := opts.Capability
+	if capability == "" {
+		capability = KeyboardDisplay
+	}
+
+	return &Agent{
+		conn:       conn,
+		path:       path,
+		capability: capability,
+		isDefault:  opts.Default,
+		inflight:   make(map[int]context.CancelFunc),
+	}
+}
+
+// Close closes the underlying bus connection, which also unregisters the
+// agent from BlueZ.
+func (a *Agent) Close() error {
+	return a.conn.Close()
+}
+
+// Serve registers the agent and routes requests to handler until ctx is
+// cancelled. The agent is registered again whenever bluetoothd restarts.
+func (a *Agent) Serve(ctx context.Context, handler Handler) error {
+	if ctx == nil {
+		return errors.New("nil context passed to Serve")
+	}
+
+	signals, stop := a.conn.Signals()
+	defer stop()
+
+	rule := fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='%s'", Service)
+	if err := a.conn.AddMatch(ctx, rule); err != nil {
+		return fmt.Errorf("watch %s: %w", Service, err)
+	}
+
+	a.conn.HandleCalls(func(call *dbus.Message) ([]any, error) {
+		return a.handle(ctx, call, handler)
+	})
+	defer a.conn.HandleCalls(nil)
+
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	defer a.unregister()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return dbus.ErrClosed
+			}
+			if sig.Member != "NameOwnerChanged" || len(sig.Body) != 3 || sig.Body[0] != Service {
+				continue
+			}
+			// Requests in flight died with the old bluetoothd.
+			a.cancelAll()
+			if owner, _ := sig.Body[2].(string); owner != "" {
+				if err := a.register(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (a *Agent) register(ctx context.Context) error {
+	if _, err := a.conn.Call(ctx, Service, ManagerPath, ManagerInterface, "RegisterAgent", a.path, string(a.capability)); err != nil {
+		return fmt.Errorf("register pairing agent: %w", err)
+	}
+	if a.isDefault {
+		if _, err := a.conn.Call(ctx, Service, ManagerPath, ManagerInterface, "RequestDefaultAgent", a.path); err != nil {
+			return fmt.Errorf("make pairing agent the default: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *Agent) unregister() {
+	a.cancelAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), unregisterTimeout)
+	defer cancel()
+	_, _ = a.conn.Call(ctx, Service, ManagerPath, ManagerInterface, "UnregisterAgent", a.path)
+}
+
+// handle answers a single Agent1 method call.
+func (a *Agent) handle(ctx context.Context, call *dbus.Message, handler Handler) ([]any, error) {
+	if call.Path != a.path || (call.Interface != "" && call.Interface != Interface) {
+		return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownObject", Message: fmt.Sprintf("no agent at %s", call.Path)}
+	}
+
+	switch call.Member {
+	case "Release":
+		a.cancelAll()
+		return nil, nil
+	case "Cancel":
+		a.cancelAll()
+		return nil, nil
+	}
+
+	req, err := parseRequest(call)
+	if err != nil {
+		return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Message: err.Error()}
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	id := a.track(cancel)
+	defer a.untrack(id)
+
+	resp, err := handler.HandlePairing(reqCtx, req)
+	if reqCtx.Err() != nil {
+		return nil, &dbus.Error{Name: ErrorCanceled, Message: "request canceled"}
+	}
+	if err != nil {
+		return nil, &dbus.Error{Name: ErrorRejected, Message: err.Error()}
+	}
+	if req.Display() {
+		return nil, nil
+	}
+	if !resp.Accept {
+		return nil, &dbus.Error{Name: ErrorRejected, Message: "request rejected"}
+	}
+
+	switch req.Kind {
+	case KindPinCode:
+		if err := ValidatePinCode(resp.PinCode); err != nil {
+			return nil, &dbus.Error{Name: ErrorRejected, Message: err.Error()}
+		}
+		return []any{resp.PinCode}, nil
+	case KindPasskey:
+		if resp.Passkey > MaxPasskey {
+			return nil, &dbus.Error{Name: ErrorRejected, Message: "passkey out of range"}
+		}
+		return []any{resp.Passkey}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseRequest maps an Agent1 method call to a Request.
+func parseRequest(call *dbus.Message) (Request, error) {
+	var req Request
+	var want string
+	switch call.Member {
+	case "RequestPinCode":
+		req.Kind, want = KindPinCode, "o"
+	case "DisplayPinCode":
+		req.Kind, want = KindDisplayPinCode, "os"
+	case "RequestPasskey":
+		req.Kind, want = KindPasskey, "o"
+	case "DisplayPasskey":
+		req.Kind, want = KindDisplayPasskey, "ouq"
+	case "RequestConfirmation":
+		req.Kind, want = KindConfirmation, "ou"
+	case "RequestAuthorization":
+		req.Kind, want = KindAuthorization, "o"
+	case "AuthorizeService":
+		req.Kind, want = KindService, "os"
+	default:
+		return Request{}, fmt.Errorf("unknown method %s", call.Member)
+	}
+
+	if len(call.Body) != len(want) {
+		return Request{}, fmt.Errorf("%s expects %d arguments", call.Member, len(want))
+	}
+
+	device, ok := call.Body[0].(dbus.ObjectPath)
+	if !ok {
+		return Request{}, fmt.Errorf("%s: first argument is not a device path", call.Member)
+	}
+	req.Adapter, req.Address = ParseDevicePath(device)
+
+	ok = true
+	switch req.Kind {
+	case KindDisplayPinCode:
+		req.PinCode, ok = call.Body[1].(string)
+	case KindService:
+		req.UUID, ok = call.Body[1].(string)
+	case KindConfirmation:
+		req.Passkey, ok = call.Body[1].(uint32)
+	case KindDisplayPasskey:
+		var entered bool
+		req.Passkey, ok = call.Body[1].(uint32)
+		req.Entered, entered = call.Body[2].(uint16)
+		ok = ok && entered
+	}
+	if !ok {
+		return Request{}, fmt.Errorf("%s: unexpected argument types", call.Member)
+	}
+
+	return req, nil
+}
+
+// ParseDevicePath extracts the adapter and device address from a BlueZ
+// device path such as /org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF. Unrecognised
+// paths yield empty strings.
+func ParseDevicePath(path dbus.ObjectPath) (adapter, address string) {
+	parts := strings.Split(strings.TrimPrefix(string(path), string(ManagerPath)+"/"), "/")
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "dev_") {
+		return "", ""
+	}
+	return parts[0], strings.ReplaceAll(strings.TrimPrefix(parts[1], "dev_"), "_", ":")
+}
+
+func (a *Agent) track(cancel context.CancelFunc) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := a.nextID
+	a.nextID++
+	a.inflight[id] = cancel
+	return id
+}
+
+func (a *Agent) untrack(id int) {
+	a.mu.Lock()
+	cancel := a.inflight[id]
+	delete(a.inflight, id)
+	a.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// cancelAll cancels every request in flight. BlueZ's Cancel does not say
+// which request it means, but it only ever has one outstanding per agent.
+func (a *Agent) cancelAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, cancel := range a.inflight {
+		cancel()
+	}
+}
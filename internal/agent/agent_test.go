@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+	"github.com/peared/peared/internal/dbus/dbustest"
+)
+
+// fakeBlueZ owns org.bluez on a private bus, records agent manager calls and
+// lets tests call the registered agent the way bluetoothd would.
+type fakeBlueZ struct {
+	conn  *dbus.Conn
+	calls chan *dbus.Message
+}
+
+func startFakeBlueZ(t *testing.T, address string) *fakeBlueZ {
+	t.Helper()
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.RequestName(context.Background(), Service); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+
+	fake := &fakeBlueZ{conn: conn, calls: make(chan *dbus.Message, 8)}
+	conn.HandleCalls(func(call *dbus.Message) ([]any, error) {
+		if call.Path != ManagerPath || call.Interface != ManagerInterface {
+			return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: call.Member}
+		}
+		fake.calls <- call
+		return nil, nil
+	})
+
+	return fake
+}
+
+func (f *fakeBlueZ) expect(t *testing.T, member string) *dbus.Message {
+	t.Helper()
+	select {
+	case call := <-f.calls:
+		if call.Member != member {
+			t.Fatalf("expected %s, got %s %v", member, call.Member, call.Body)
+		}
+		return call
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", member)
+		return nil
+	}
+}
+
+func (f *fakeBlueZ) callAgent(ctx context.Context, agent string, member string, args ...any) ([]any, error) {
+	return f.conn.Call(ctx, agent, DefaultPath, Interface, member, args...)
+}
+
+const device = dbus.ObjectPath("/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF")
+
+func TestAgentRegistersAndAnswersRequests(t *testing.T) {
+	address := dbustest.StartBus(t)
+	bluez := startFakeBlueZ(t, address)
+
+	agent, err := Connect(address, Options{Capability: DisplayYesNo, Default: true})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer agent.Close()
+
+	requests := make(chan Request, 8)
+	handler := HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+		requests <- req
+		switch req.Kind {
+		case KindPinCode:
+			return Response{Accept: true, PinCode: "0000"}, nil
+		case KindPasskey:
+			return Response{Accept: true, Passkey: 123456}, nil
+		case KindConfirmation:
+			return Response{Accept: req.Passkey == 42}, nil
+		case KindService:
+			return Response{}, errors.New("profile not allowed")
+		default:
+			return Response{Accept: true}, nil
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	served := make(chan error, 1)
+	go func() { served <- agent.Serve(ctx, handler) }()
+
+	register := bluez.expect(t, "RegisterAgent")
+	if len(register.Body) != 2 || register.Body[0] != DefaultPath || register.Body[1] != string(DisplayYesNo) {
+		t.Fatalf("unexpected RegisterAgent arguments %v", register.Body)
+	}
+	bluez.expect(t, "RequestDefaultAgent")
+	sender := register.Sender
+
+	body, err := bluez.callAgent(ctx, sender, "RequestPinCode", device)
+	if err != nil || len(body) != 1 || body[0] != "0000" {
+		t.Fatalf("RequestPinCode = %v, %v", body, err)
+	}
+	if req := <-requests; req.Adapter != "hci0" || req.Address != "AA:BB:CC:DD:EE:FF" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	body, err = bluez.callAgent(ctx, sender, "RequestPasskey", device)
+	if err != nil || len(body) != 1 || body[0] != uint32(123456) {
+		t.Fatalf("RequestPasskey = %v, %v", body, err)
+	}
+	<-requests
+
+	if _, err := bluez.callAgent(ctx, sender, "RequestConfirmation", device, uint32(42)); err != nil {
+		t.Fatalf("RequestConfirmation: %v", err)
+	}
+	<-requests
+
+	_, err = bluez.callAgent(ctx, sender, "RequestConfirmation", device, uint32(7))
+	var dbusErr *dbus.Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != ErrorRejected {
+		t.Fatalf("expected rejection, got %v", err)
+	}
+	<-requests
+
+	_, err = bluez.callAgent(ctx, sender, "AuthorizeService", device, "0000110b-0000-1000-8000-00805f9b34fb")
+	if !errors.As(err, &dbusErr) || dbusErr.Name != ErrorRejected {
+		t.Fatalf("expected rejection, got %v", err)
+	}
+	if req := <-requests; req.Kind != KindService || req.UUID != "0000110b-0000-1000-8000-00805f9b34fb" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	if _, err := bluez.callAgent(ctx, sender, "DisplayPasskey", device, uint32(654321), uint16(3)); err != nil {
+		t.Fatalf("DisplayPasskey: %v", err)
+	}
+	if req := <-requests; req.Kind != KindDisplayPasskey || req.Passkey != 654321 || req.Entered != 3 || !req.Display() {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	bluez.expect(t, "UnregisterAgent")
+}
+
+func TestAgentCancelAbortsPendingRequest(t *testing.T) {
+	address := dbustest.StartBus(t)
+	bluez := startFakeBlueZ(t, address)
+
+	agent, err := Connect(address, Options{})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer agent.Close()
+
+	waiting := make(chan struct{})
+	handler := HandlerFunc(func(ctx context.Context, req Request) (Response, error) {
+		close(waiting)
+		<-ctx.Done()
+		return Response{}, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go agent.Serve(ctx, handler)
+
+	register := bluez.expect(t, "RegisterAgent")
+	if register.Body[1] != string(KeyboardDisplay) {
+		t.Fatalf("expected KeyboardDisplay by default, got %v", register.Body)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bluez.callAgent(ctx, register.Sender, "RequestAuthorization", device)
+		result <- err
+	}()
+
+	<-waiting
+	if _, err := bluez.callAgent(ctx, register.Sender, "Cancel"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	var dbusErr *dbus.Error
+	if err := <-result; !errors.As(err, &dbusErr) || dbusErr.Name != ErrorCanceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestParseDevicePath(t *testing.T) {
+	adapter, address := ParseDevicePath(device)
+	if adapter != "hci0" || address != "AA:BB:CC:DD:EE:FF" {
+		t.Fatalf("ParseDevicePath = %q, %q", adapter, address)
+	}
+	if adapter, address := ParseDevicePath("/org/bluez/hci0"); adapter != "" || address != "" {
+		t.Fatalf("expected no match, got %q, %q", adapter, address)
+	}
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// MaxPasskey is the largest passkey Bluetooth allows (six digits).
+const MaxPasskey = 999999
+
+// ValidatePinCode checks that pin is a legacy PIN BlueZ accepts: one to
+// sixteen printable characters without spaces.
+func ValidatePinCode(pin string) error {
+	if pin == "" || len(pin) > 16 {
+		return errors.New("PIN must be 1 to 16 characters")
+	}
+	for _, r := range pin {
+		if r <= ' ' || r > '~' {
+			return errors.New("PIN must only contain printable ASCII characters without spaces")
+		}
+	}
+	return nil
+}
+
+// ParsePasskey parses a six-digit passkey.
+func ParsePasskey(value string) (uint32, error) {
+	trimmed := strings.TrimSpace(value)
+	passkey, err := strconv.ParseUint(trimmed, 10, 32)
+	if err != nil || len(trimmed) > 6 || passkey > MaxPasskey {
+		return 0, fmt.Errorf("invalid passkey %q (want up to six digits)", value)
+	}
+	return uint32(passkey), nil
+}
+
+// PinStore keeps PINs and passkeys for devices that cannot display or type
+// them, one file per device. The directory is only readable by its owner,
+// and stored secrets are ignored if their permissions were loosened.
+type PinStore struct {
+	dir string
+}
+
+// DefaultPinDir returns $XDG_RUNTIME_DIR/peared/pins. Keeping secrets in the
+// runtime directory means they never reach persistent storage.
+func DefaultPinDir() (string, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(runtimeDir, "peared", "pins"), nil
+}
+
+// NewPinStore returns a store rooted at dir. An empty dir resolves to
+// DefaultPinDir.
+func NewPinStore(dir string) (*PinStore, error) {
+	if dir == "" {
+		resolved, err := DefaultPinDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = resolved
+	}
+	return &PinStore{dir: dir}, nil
+}
+
+// Dir returns the directory secrets are stored in.
+func (s *PinStore) Dir() string {
+	return s.dir
+}
+
+// Get returns the secret stored for address. The boolean is false when none
+// is stored.
+func (s *PinStore) Get(address string) (string, bool, error) {
+	path, err := s.path(address)
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := checkPrivate(s.dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("read PIN for %s: %w", address, err)
+	}
+	defer file.Close()
+
+	if err := checkPrivateFile(file); err != nil {
+		return "", false, err
+	}
+
+	data := make([]byte, 64)
+	n, err := file.Read(data)
+	if err != nil {
+		return "", false, fmt.Errorf("read PIN for %s: %w", address, err)
+	}
+	return strings.TrimSpace(string(data[:n])), true, nil
+}
+
+// Set stores secret for address, replacing any previous one. The secret is
+// written to a private temporary file and renamed into place so readers
+// never see a partial value.
+func (s *PinStore) Set(address, secret string) error {
+	path, err := s.path(address)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePinCode(secret); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("create PIN directory: %w", err)
+	}
+	// MkdirAll leaves an existing directory's mode alone.
+	if err := os.Chmod(s.dir, 0o700); err != nil {
+		return fmt.Errorf("restrict PIN directory permissions: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".pin-*")
+	if err != nil {
+		return fmt.Errorf("store PIN for %s: %w", address, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store PIN for %s: %w", address, err)
+	}
+	if _, err := tmp.WriteString(secret + "\n"); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store PIN for %s: %w", address, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("store PIN for %s: %w", address, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("store PIN for %s: %w", address, err)
+	}
+	return nil
+}
+
+// Delete removes the secret stored for address. Removing a missing secret
+// is not an error.
+func (s *PinStore) Delete(address string) error {
+	path, err := s.path(address)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove PIN for %s: %w", address, err)
+	}
+	return nil
+}
+
+// List returns the addresses with a stored secret, sorted.
+func (s *PinStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list PINs: %w", err)
+	}
+
+	var addresses []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			addresses = append(addresses, strings.ReplaceAll(entry.Name(), "_", ":"))
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// path maps an address to its file, named like BlueZ names device objects.
+func (s *PinStore) path(address string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(address))
+	if normalized == "" || strings.ContainsAny(normalized, "/_.") {
+		return "", fmt.Errorf("invalid device address %q", address)
+	}
+	return filepath.Join(s.dir, strings.ReplaceAll(normalized, ":", "_")), nil
+}
+
+// checkPrivate fails unless path is owned by the current user and not
+// accessible to anyone else.
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return checkMode(path, info)
+}
+
+func checkPrivateFile(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return checkMode(file.Name(), info)
+}
+
+func checkMode(path string, info fs.FileInfo) error {
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible to other users (mode %v); refusing to use it", path, info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is owned by uid %d; refusing to use it", path, stat.Uid)
+	}
+	return nil
+}
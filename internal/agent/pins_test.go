@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPinStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pins")
+	store, err := NewPinStore(dir)
+	if err != nil {
+		t.Fatalf("NewPinStore: %v", err)
+	}
+
+	if _, ok, err := store.Get("aa:bb:cc:dd:ee:ff"); ok || err != nil {
+		t.Fatalf("expected no PIN before Set, got ok=%v err=%v", ok, err)
+	}
+
+	if err := store.Set("aa:bb:cc:dd:ee:ff", "1234"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	pin, ok, err := store.Get("AA:BB:CC:DD:EE:FF")
+	if err != nil || !ok || pin != "1234" {
+		t.Fatalf("Get = %q, %v, %v", pin, ok, err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected 0700 directory, got %v (%v)", info.Mode().Perm(), err)
+	}
+	info, err = os.Stat(filepath.Join(dir, "AA_BB_CC_DD_EE_FF"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 file, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	addresses, err := store.List()
+	if err != nil || len(addresses) != 1 || addresses[0] != "AA:BB:CC:DD:EE:FF" {
+		t.Fatalf("List = %v, %v", addresses, err)
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "AA_BB_CC_DD_EE_FF"), 0o644); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if _, _, err := store.Get("AA:BB:CC:DD:EE:FF"); err == nil {
+		t.Fatal("expected a world-readable PIN to be refused")
+	}
+
+	if err := store.Delete("AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete("AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Delete of a missing PIN: %v", err)
+	}
+
+	if err := store.Set("../escape", "1234"); err == nil {
+		t.Fatal("expected invalid address to be refused")
+	}
+	if err := store.Set("AA:BB:CC:DD:EE:FF", "has space"); err == nil {
+		t.Fatal("expected invalid PIN to be refused")
+	}
+}
+
+func TestParsePasskey(t *testing.T) {
+	if passkey, err := ParsePasskey(" 012345 "); err != nil || passkey != 12345 {
+		t.Fatalf("ParsePasskey = %d, %v", passkey, err)
+	}
+	for _, value := range []string{"", "1000000", "12a", "-1"} {
+		if _, err := ParsePasskey(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
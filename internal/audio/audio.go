@@ -0,0 +1,202 @@
+// Package audio applies Bluetooth audio preferences through the sound
+// server's pactl tool, which PipeWire (through pipewire-pulse) and
+// PulseAudio both provide.
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+const (
+	// cardWait bounds how long ApplyAudio waits for the sound server to create
+	// the card of a device that just connected.
+	cardWait     = 15 * time.Second
+	cardInterval = 500 * time.Millisecond
+)
+
+type commandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func defaultCommandRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// Pactl is a daemon.AudioBackend driving pactl.
+type Pactl struct {
+	run   commandRunner
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewPactl returns an AudioBackend backed by pactl.
+func NewPactl() *Pactl {
+	return &Pactl{}
+}
+
+// ApplyAudio implements daemon.AudioBackend. It waits for the device's card,
+// selects the profile (and with it the codec), then sets the volume of the
+// device's output.
+func (p *Pactl) ApplyAudio(ctx context.Context, address string, settings daemon.AudioSettings) error {
+	card := "bluez_card." + strings.ReplaceAll(daemon.NormalizeAddress(address), ":", "_")
+	if err := p.waitForCard(ctx, card); err != nil {
+		return err
+	}
+
+	if profile := settings.EffectiveProfile(); profile != "" {
+		if err := p.setProfile(ctx, card, profile, settings.Codec); err != nil {
+			return err
+		}
+	}
+
+	if settings.Volume != nil {
+		sink, err := p.sink(ctx, address)
+		if err != nil {
+			return err
+		}
+		if _, err := p.pactl(ctx, "set-sink-volume", sink, strconv.Itoa(*settings.Volume)+"%"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pactl) waitForCard(ctx context.Context, card string) error {
+	for attempt := 0; ; attempt++ {
+		out, err := p.pactl(ctx, "list", "short", "cards")
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 && fields[1] == card {
+				return nil
+			}
+		}
+		if time.Duration(attempt+1)*cardInterval >= cardWait {
+			return fmt.Errorf("sound server has no card %s", card)
+		}
+		if err := p.wait(ctx, cardInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// setProfile tries the profile names PipeWire and PulseAudio use, most
+// specific first. PipeWire names a profile per codec; PulseAudio switches
+// the codec with a message to the card once the profile is active.
+func (p *Pactl) setProfile(ctx context.Context, card string, profile daemon.AudioProfile, codec string) error {
+	var names []string
+	switch profile {
+	case daemon.AudioProfileA2DP:
+		if codec != "" {
+			names = append(names, "a2dp-sink-"+codec)
+		}
+		names = append(names, "a2dp-sink", "a2dp_sink")
+	case daemon.AudioProfileHeadset:
+		if codec != "" {
+			names = append(names, "headset-head-unit-"+codec)
+		}
+		names = append(names, "headset-head-unit", "headset_head_unit", "handsfree_head_unit")
+	default:
+		names = []string{string(profile)}
+	}
+
+	var errs []error
+	// With a codec, only the first name selects it.
+	for i, name := range names {
+		_, err := p.pactl(ctx, "set-card-profile", card, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if codec == "" || i == 0 {
+			return nil
+		}
+		_, err = p.pactl(ctx, "send-message", "/card/"+card+"/bluez", "switch-codec", strconv.Quote(codec))
+		return err
+	}
+	return fmt.Errorf("select %s profile: %w", profile, errors.Join(errs...))
+}
+
+// sink finds the output of the device, which PipeWire names
+// bluez_output.<address>.* and PulseAudio bluez_sink.<address>.*.
+func (p *Pactl) sink(ctx context.Context, address string) (string, error) {
+	id := strings.ReplaceAll(daemon.NormalizeAddress(address), ":", "_")
+	out, err := p.pactl(ctx, "list", "short", "sinks")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if strings.HasPrefix(fields[1], "bluez_output."+id) || strings.HasPrefix(fields[1], "bluez_sink."+id) {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("sound server has no output for %s", address)
+}
+
+// ActiveProfiles returns the profile the sound server routes each Bluetooth
+// device through, such as a2dp-sink-aac, keyed by device address. Devices
+// whose card is off are left out.
+func (p *Pactl) ActiveProfiles(ctx context.Context) (map[string]string, error) {
+	out, err := p.pactl(ctx, "list", "cards")
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]string)
+	address := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "Name: "); ok {
+			address = ""
+			if id, ok := strings.CutPrefix(name, "bluez_card."); ok && daemon.IsAddress(strings.ReplaceAll(id, "_", ":")) {
+				address = daemon.NormalizeAddress(strings.ReplaceAll(id, "_", ":"))
+			}
+			continue
+		}
+		if profile, ok := strings.CutPrefix(line, "Active Profile: "); ok && address != "" && profile != "off" {
+			profiles[address] = profile
+		}
+	}
+	return profiles, nil
+}
+
+func (p *Pactl) pactl(ctx context.Context, args ...string) ([]byte, error) {
+	run := p.run
+	if run == nil {
+		run = defaultCommandRunner
+	}
+
+	out, err := run(ctx, "pactl", args...)
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(out)); trimmed != "" {
+			return nil, fmt.Errorf("pactl %s: %w: %s", strings.Join(args, " "), err, trimmed)
+		}
+		return nil, fmt.Errorf("pactl %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+func (p *Pactl) wait(ctx context.Context, d time.Duration) error {
+	if p.sleep != nil {
+		return p.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+// fakePactl answers pactl commands from canned output. Profiles not listed
+// in profiles fail like an unknown profile does.
+type fakePactl struct {
+	cards    []string
+	sinks    string
+	profiles map[string]bool
+	calls    []string
+}
+
+func (f *fakePactl) run(_ context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(args, " ")
+	f.calls = append(f.calls, command)
+
+	switch {
+	case command == "list short cards":
+		if len(f.cards) == 0 {
+			return nil, nil
+		}
+		out := f.cards[0]
+		if len(f.cards) > 1 {
+			f.cards = f.cards[1:]
+		}
+		return []byte(out), nil
+	case command == "list short sinks":
+		return []byte(f.sinks), nil
+	case args[0] == "set-card-profile" && !f.profiles[args[2]]:
+		return []byte("Failure: No such entity"), errors.New("exit status 1")
+	}
+	return nil, nil
+}
+
+const card = "bluez_card.AA_BB_CC_DD_EE_FF"
+
+func TestApplyAudioPipeWire(t *testing.T) {
+	fake := &fakePactl{
+		cards:    []string{"", "49\t" + card + "\tmodule-bluez5-device.c\n"},
+		sinks:    "50\talsa_output.pci.analog-stereo\tPipeWire\n51\tbluez_output.AA_BB_CC_DD_EE_FF.1\tPipeWire\n",
+		profiles: map[string]bool{"a2dp-sink-aac": true},
+	}
+	var waited time.Duration
+	p := &Pactl{run: fake.run, sleep: func(_ context.Context, d time.Duration) error {
+		waited += d
+		return nil
+	}}
+
+	volume := 60
+	err := p.ApplyAudio(context.Background(), "aa:bb:cc:dd:ee:ff", daemon.AudioSettings{Codec: "aac", Volume: &volume})
+	if err != nil {
+		t.Fatalf("ApplyAudio: %v", err)
+	}
+
+	want := []string{
+		"list short cards",
+		"list short cards",
+		"set-card-profile " + card + " a2dp-sink-aac",
+		"list short sinks",
+		"set-sink-volume bluez_output.AA_BB_CC_DD_EE_FF.1 60%",
+	}
+	if strings.Join(fake.calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected commands:\n%s", strings.Join(fake.calls, "\n"))
+	}
+	if waited != cardInterval {
+		t.Fatalf("expected one wait for the card, waited %v", waited)
+	}
+}
+
+func TestApplyAudioPulseAudioSwitchesCodec(t *testing.T) {
+	fake := &fakePactl{
+		cards:    []string{"3\t" + card + "\tmodule-bluez5-device.c\n"},
+		profiles: map[string]bool{"headset_head_unit": true},
+	}
+	p := &Pactl{run: fake.run}
+
+	err := p.ApplyAudio(context.Background(), "AA:BB:CC:DD:EE:FF", daemon.AudioSettings{Profile: daemon.AudioProfileHeadset, Codec: "msbc"})
+	if err != nil {
+		t.Fatalf("ApplyAudio: %v", err)
+	}
+	if last := fake.calls[len(fake.calls)-1]; last != `send-message /card/`+card+`/bluez switch-codec "msbc"` {
+		t.Fatalf("expected a codec switch, got %v", fake.calls)
+	}
+}
+
+func TestApplyAudioGivesUpWithoutCard(t *testing.T) {
+	fake := &fakePactl{}
+	p := &Pactl{run: fake.run, sleep: func(context.Context, time.Duration) error { return nil }}
+
+	err := p.ApplyAudio(context.Background(), "AA:BB:CC:DD:EE:FF", daemon.AudioSettings{Profile: daemon.AudioProfileA2DP})
+	if err == nil || !strings.Contains(err.Error(), "no card "+card) {
+		t.Fatalf("expected a missing card error, got %v", err)
+	}
+	if len(fake.calls) != int(cardWait/cardInterval) {
+		t.Fatalf("unexpected number of attempts: %d", len(fake.calls))
+	}
+}
+
+func TestActiveProfiles(t *testing.T) {
+	listing := `Card #48
+	Name: alsa_card.pci-0000_00_1f.3
+	Active Profile: output:analog-stereo
+Card #49
+	Name: bluez_card.AA_BB_CC_DD_EE_FF
+	Driver: module-bluez5-device.c
+	Profiles:
+		a2dp-sink-aac: High Fidelity Playback (A2DP Sink, codec AAC) (sinks: 1, sources: 0, priority: 18, available: yes)
+		off: Off (sinks: 0, sources: 0, priority: 0, available: yes)
+	Active Profile: a2dp-sink-aac
+Card #50
+	Name: bluez_card.11_22_33_44_55_66
+	Active Profile: off
+`
+	p := &Pactl{run: func(_ context.Context, _ string, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") != "list cards" {
+			t.Fatalf("unexpected pactl %v", args)
+		}
+		return []byte(listing), nil
+	}}
+
+	profiles, err := p.ActiveProfiles(context.Background())
+	if err != nil {
+		t.Fatalf("ActiveProfiles: %v", err)
+	}
+	if len(profiles) != 1 || profiles["AA:BB:CC:DD:EE:FF"] != "a2dp-sink-aac" {
+		t.Fatalf("unexpected profiles %v", profiles)
+	}
+}
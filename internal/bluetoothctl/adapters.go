@@ -0,0 +1,259 @@
+package bluetoothctl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdapterInfo captures the `bluetoothctl show` output for a controller.
+// Properties that the installed BlueZ release does not report are left at
+// their zero values.
+type AdapterInfo struct {
+	Address      string
+	Name         string
+	Alias        string
+	Class        uint32
+	Powered      bool
+	Discoverable bool
+	Pairable     bool
+	Discovering  bool
+
+	DiscoverableTimeout time.Duration
+	PairableTimeout     time.Duration
+
+	// Manufacturer is the Bluetooth SIG company identifier and Version the
+	// HCI version number. Both are only reported by BlueZ 5.66 and later.
+	Manufacturer int
+	Version      int
+
+	Modalias string
+	Roles    []string
+	UUIDs    []string
+}
+
+// Show returns the properties of the selected adapter.
+func (r *Runner) Show(ctx context.Context) (AdapterInfo, error) {
+	if ctx == nil {
+		return AdapterInfo{}, errors.New("nil context passed to Show")
+	}
+
+	if _, err := r.selectAdapter(ctx); err != nil {
+		return AdapterInfo{}, fmt.Errorf("select adapter %s: %w", r.Adapter, err)
+	}
+
+	output, err := r.exec(ctx, "show")
+	if err != nil {
+		return AdapterInfo{}, err
+	}
+
+	return ParseShow(output), nil
+}
+
+// SetPowered switches the selected adapter's radio on or off.
+func (r *Runner) SetPowered(ctx context.Context, on bool) (string, error) {
+	return r.adapterCommand(ctx, "power", onOff(on))
+}
+
+// SetAlias changes the name the selected adapter advertises to other devices.
+func (r *Runner) SetAlias(ctx context.Context, alias string) (string, error) {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return "", errors.New("alias must not be empty")
+	}
+	return r.adapterCommand(ctx, "system-alias", alias)
+}
+
+// SetDiscoverable toggles whether the selected adapter is visible to nearby
+// devices. A positive timeout is applied before enabling discovery so BlueZ
+// turns it off again once the timeout elapses.
+func (r *Runner) SetDiscoverable(ctx context.Context, on bool, timeout time.Duration) (string, error) {
+	var outputs []string
+	if on && timeout > 0 {
+		output, err := r.adapterCommand(ctx, "discoverable-timeout", seconds(timeout))
+		if err != nil {
+			return "", err
+		}
+		outputs = append(outputs, output)
+	}
+
+	output, err := r.adapterCommand(ctx, "discoverable", onOff(on))
+	if err != nil {
+		return "", err
+	}
+
+	return combineOutputs(append(outputs, output)...), nil
+}
+
+// SetPairable toggles whether the selected adapter accepts new pairings. A
+// positive timeout is applied before enabling pairing. bluetoothctl has no
+// command for the pairable timeout, so it is written over D-Bus with busctl,
+// which requires the adapter to be addressed by its ID (hci0, hci1, ...).
+func (r *Runner) SetPairable(ctx context.Context, on bool, timeout time.Duration) (string, error) {
+	if on && timeout > 0 {
+		if err := r.setAdapterProperty(ctx, "PairableTimeout", "u", seconds(timeout)); err != nil {
+			return "", err
+		}
+	}
+
+	return r.adapterCommand(ctx, "pairable", onOff(on))
+}
+
+func (r *Runner) adapterCommand(ctx context.Context, args ...string) (string, error) {
+	if ctx == nil {
+		return "", fmt.Errorf("nil context passed to %s", args[0])
+	}
+
+	adapterOutput, err := r.selectAdapter(ctx)
+	if err != nil {
+		return "", fmt.Errorf("select adapter %s: %w", r.Adapter, err)
+	}
+
+	output, err := r.exec(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return combineOutputs(adapterOutput, output), nil
+}
+
+func (r *Runner) setAdapterProperty(ctx context.Context, property, signature, value string) error {
+	adapter := r.Adapter
+	if adapter == "" {
+		adapter = "hci0"
+	}
+	if !strings.HasPrefix(adapter, "hci") {
+		return fmt.Errorf("setting %s requires an adapter ID such as hci0, got %q", property, adapter)
+	}
+
+	busctl := r.BusctlPath
+	if busctl == "" {
+		path, err := exec.LookPath("busctl")
+		if err != nil {
+			return fmt.Errorf("locate busctl: %w", err)
+		}
+		busctl = path
+	}
+
+	args := []string{"--system", "set-property", "org.bluez", "/org/bluez/" + adapter, "org.bluez.Adapter1", property, signature, value}
+	name := busctl
+	if r.UseSudo {
+		name = r.SudoPath
+		args = append([]string{busctl}, args...)
+	}
+
+	if out, err := r.run(ctx, name, args...); err != nil {
+		return &CommandError{Args: []string{"set-property", property}, Output: string(out), Err: err}
+	}
+
+	return nil
+}
+
+// ParseShow decodes the key/value block printed by `bluetoothctl show`.
+func ParseShow(output string) AdapterInfo {
+	var info AdapterInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Controller ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				info.Address = fields[1]
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			info.Name = value
+		case "Alias":
+			info.Alias = value
+		case "Class":
+			if class, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32); err == nil {
+				info.Class = uint32(class)
+			}
+		case "Powered":
+			info.Powered = value == "yes"
+		case "Discoverable":
+			info.Discoverable = value == "yes"
+		case "Pairable":
+			info.Pairable = value == "yes"
+		case "Discovering":
+			info.Discovering = value == "yes"
+		case "DiscoverableTimeout":
+			if secs, ok := parseNumber(value); ok {
+				info.DiscoverableTimeout = time.Duration(secs) * time.Second
+			}
+		case "PairableTimeout":
+			if secs, ok := parseNumber(value); ok {
+				info.PairableTimeout = time.Duration(secs) * time.Second
+			}
+		case "Manufacturer":
+			if id, ok := parseNumber(value); ok {
+				info.Manufacturer = id
+			}
+		case "Version":
+			if version, ok := parseNumber(value); ok {
+				info.Version = version
+			}
+		case "Modalias":
+			info.Modalias = value
+		case "Roles":
+			info.Roles = append(info.Roles, value)
+		case "UUID":
+			if uuid := parseUUID(value); uuid != "" {
+				info.UUIDs = append(info.UUIDs, uuid)
+			}
+		}
+	}
+
+	return info
+}
+
+// parseNumber accepts the annotated ("0x0c (12)"), hexadecimal ("0x000000b4")
+// and decimal forms bluetoothctl uses for numeric properties.
+func parseNumber(value string) (int, bool) {
+	if open := strings.LastIndex(value, "("); open >= 0 {
+		if end := strings.LastIndex(value, ")"); end > open {
+			value = value[open+1 : end]
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 0, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(n), true
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func seconds(d time.Duration) string {
+	secs := int(d / time.Second)
+	if secs <= 0 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
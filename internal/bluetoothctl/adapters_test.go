@@ -0,0 +1,123 @@
+package bluetoothctl
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleShow = `Controller 00:1A:7D:DA:71:13 (public)
+	Manufacturer: 0x0002 (2)
+	Version: 0x0a (10)
+	Name: workstation
+	Alias: Desk
+	Class: 0x006c010c
+	Powered: yes
+	Discoverable: no
+	DiscoverableTimeout: 0x000000b4
+	Pairable: yes
+	UUID: Audio Source              (0000110a-0000-1000-8000-00805f9b34fb)
+	UUID: Generic Access Profile    (00001800-0000-1000-8000-00805f9b34fb)
+	Modalias: usb:v1D6Bp0246d0540
+	Discovering: no
+	Roles: central
+	Roles: peripheral
+`
+
+func TestParseShow(t *testing.T) {
+	info := ParseShow(sampleShow)
+
+	if info.Address != "00:1A:7D:DA:71:13" || info.Name != "workstation" || info.Alias != "Desk" {
+		t.Errorf("unexpected identity: %+v", info)
+	}
+	if info.Class != 0x6c010c {
+		t.Errorf("unexpected class: %#x", info.Class)
+	}
+	if !info.Powered || info.Discoverable || !info.Pairable || info.Discovering {
+		t.Errorf("unexpected flags: %+v", info)
+	}
+	if info.DiscoverableTimeout != 3*time.Minute {
+		t.Errorf("unexpected discoverable timeout: %s", info.DiscoverableTimeout)
+	}
+	if info.Manufacturer != 2 || info.Version != 10 {
+		t.Errorf("unexpected manufacturer/version: %d/%d", info.Manufacturer, info.Version)
+	}
+	if strings.Join(info.Roles, ",") != "central,peripheral" {
+		t.Errorf("unexpected roles: %v", info.Roles)
+	}
+	if len(info.UUIDs) != 2 || info.UUIDs[0] != "0000110a-0000-1000-8000-00805f9b34fb" {
+		t.Errorf("unexpected uuids: %v", info.UUIDs)
+	}
+}
+
+func TestSetDiscoverableAppliesTimeoutFirst(t *testing.T) {
+	var calls []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithCommandRunner(func(_ context.Context, name string, args ...string) ([]byte, error) {
+			calls = append(calls, strings.Join(args, " "))
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.SetDiscoverable(context.Background(), true, 90*time.Second); err != nil {
+		t.Fatalf("SetDiscoverable returned error: %v", err)
+	}
+
+	want := []string{"discoverable-timeout 90", "discoverable on"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected commands: %q", calls)
+	}
+}
+
+func TestSetPairableWritesTimeoutThroughBusctl(t *testing.T) {
+	var calls []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithBusctlPath("busctl"),
+		WithUseSudo(false),
+		WithAdapter("hci1"),
+		WithCommandRunner(func(_ context.Context, name string, args ...string) ([]byte, error) {
+			calls = append(calls, name+" "+strings.Join(args, " "))
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.SetPairable(context.Background(), true, time.Minute); err != nil {
+		t.Fatalf("SetPairable returned error: %v", err)
+	}
+
+	want := []string{
+		"busctl --system set-property org.bluez /org/bluez/hci1 org.bluez.Adapter1 PairableTimeout u 60",
+		"bluetoothctl select hci1",
+		"bluetoothctl pairable on",
+	}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected commands:\n%q\nwant\n%q", calls, want)
+	}
+}
+
+func TestSetPairableTimeoutRequiresAdapterID(t *testing.T) {
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithBusctlPath("busctl"),
+		WithUseSudo(false),
+		WithAdapter("00:1A:7D:DA:71:13"),
+		WithCommandRunner(func(context.Context, string, ...string) ([]byte, error) { return nil, nil }),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.SetPairable(context.Background(), true, time.Minute); err == nil {
+		t.Fatal("expected error when the adapter is addressed by MAC")
+	}
+}
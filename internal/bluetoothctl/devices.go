@@ -0,0 +1,230 @@
+package bluetoothctl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeviceEntry is a single line from `bluetoothctl devices`.
+type DeviceEntry struct {
+	Address string
+	Name    string
+}
+
+// DeviceInfo captures the subset of `bluetoothctl info` output that Peared
+// consumes. Fields that bluetoothctl omits are left at their zero values.
+type DeviceInfo struct {
+	Address string
+	// AddressType is "public" or "random", as printed after the address.
+	AddressType string
+
+	Name  string
+	Alias string
+	Class uint32
+	Icon  string
+
+	// Appearance is the GAP appearance LE devices advertise; zero when
+	// absent.
+	Appearance uint16
+
+	Paired    bool
+	Bonded    bool
+	Trusted   bool
+	Blocked   bool
+	Connected bool
+
+	// RSSI is only reported while the device has recently been seen during
+	// discovery. HasRSSI distinguishes a missing value from zero.
+	RSSI    int
+	HasRSSI bool
+
+	// Battery is the charge in percent reported by the Battery1 interface.
+	// HasBattery is false for devices that do not report one.
+	Battery    int
+	HasBattery bool
+
+	UUIDs []string
+}
+
+// Devices lists the devices known to the selected adapter.
+func (r *Runner) Devices(ctx context.Context) ([]DeviceEntry, error) {
+	if ctx == nil {
+		return nil, errors.New("nil context passed to Devices")
+	}
+
+	if _, err := r.selectAdapter(ctx); err != nil {
+		return nil, fmt.Errorf("select adapter %s: %w", r.Adapter, err)
+	}
+
+	output, err := r.exec(ctx, "devices")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDevices(output), nil
+}
+
+// Info returns the parsed `bluetoothctl info` output for the provided device.
+func (r *Runner) Info(ctx context.Context, address string) (DeviceInfo, error) {
+	if ctx == nil {
+		return DeviceInfo{}, errors.New("nil context passed to Info")
+	}
+
+	addr := strings.TrimSpace(address)
+	if addr == "" {
+		return DeviceInfo{}, errors.New("device address required for info")
+	}
+
+	if _, err := r.selectAdapter(ctx); err != nil {
+		return DeviceInfo{}, fmt.Errorf("select adapter %s: %w", r.Adapter, err)
+	}
+
+	output, err := r.exec(ctx, "info", addr)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+
+	info := ParseInfo(output)
+	if info.Address == "" {
+		info.Address = addr
+	}
+
+	return info, nil
+}
+
+// IsUnknownDevice reports whether err is bluetoothctl saying it does not
+// know a device, as Info does for devices that were never seen or have been
+// removed.
+func IsUnknownDevice(err error) bool {
+	var cmdErr *CommandError
+	return errors.As(err, &cmdErr) && strings.Contains(cmdErr.Output, "not available")
+}
+
+// ParseDevices extracts device entries from `bluetoothctl devices` output.
+// Lines that do not describe a device (controller banners, agent chatter) are
+// ignored.
+func ParseDevices(output string) []DeviceEntry {
+	var devices []DeviceEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "Device" {
+			continue
+		}
+
+		devices = append(devices, DeviceEntry{
+			Address: fields[1],
+			Name:    strings.Join(fields[2:], " "),
+		})
+	}
+
+	return devices
+}
+
+// ParseInfo decodes the key/value block printed by `bluetoothctl info`.
+func ParseInfo(output string) DeviceInfo {
+	var info DeviceInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Device ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				info.Address = fields[1]
+			}
+			if len(fields) >= 3 {
+				info.AddressType = strings.Trim(fields[2], "()")
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			info.Name = value
+		case "Alias":
+			info.Alias = value
+		case "Class":
+			if class, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32); err == nil {
+				info.Class = uint32(class)
+			}
+		case "Icon":
+			info.Icon = value
+		case "Appearance":
+			if appearance, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 16); err == nil {
+				info.Appearance = uint16(appearance)
+			}
+		case "Paired":
+			info.Paired = value == "yes"
+		case "Bonded":
+			info.Bonded = value == "yes"
+		case "Trusted":
+			info.Trusted = value == "yes"
+		case "Blocked":
+			info.Blocked = value == "yes"
+		case "Connected":
+			info.Connected = value == "yes"
+		case "RSSI":
+			if rssi, ok := parseRSSI(value); ok {
+				info.RSSI = rssi
+				info.HasRSSI = true
+			}
+		case "Battery Percentage":
+			// Printed like RSSI: "0x64 (100)".
+			if battery, ok := parseRSSI(value); ok {
+				info.Battery = battery
+				info.HasBattery = true
+			}
+		case "UUID":
+			if uuid := parseUUID(value); uuid != "" {
+				info.UUIDs = append(info.UUIDs, uuid)
+			}
+		}
+	}
+
+	return info
+}
+
+// parseRSSI accepts both the plain ("-60") and the annotated
+// ("0xffffffc4 (-60)") forms emitted by different BlueZ releases.
+func parseRSSI(value string) (int, bool) {
+	if open := strings.LastIndex(value, "("); open >= 0 {
+		if end := strings.LastIndex(value, ")"); end > open {
+			value = value[open+1 : end]
+		}
+	}
+
+	rssi, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+
+	return rssi, true
+}
+
+// parseUUID extracts the UUID from lines such as
+// "Audio Sink                (0000110b-0000-1000-8000-00805f9b34fb)".
+func parseUUID(value string) string {
+	open := strings.LastIndex(value, "(")
+	end := strings.LastIndex(value, ")")
+	if open >= 0 && end > open {
+		return strings.ToLower(value[open+1 : end])
+	}
+
+	return strings.ToLower(strings.TrimSpace(value))
+}
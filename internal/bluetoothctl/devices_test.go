@@ -0,0 +1,131 @@
+package bluetoothctl
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const sampleInfo = `Device AA:BB:CC:DD:EE:FF (public)
+	Name: Test Headset
+	Alias: Desk Headset
+	Class: 0x00240404
+	Icon: audio-headset
+	Appearance: 0x0941
+	Paired: yes
+	Bonded: yes
+	Trusted: yes
+	Blocked: no
+	Connected: no
+	LegacyPairing: no
+	UUID: Audio Sink                (0000110b-0000-1000-8000-00805f9b34fb)
+	UUID: Handsfree                 (0000111e-0000-1000-8000-00805f9b34fb)
+	RSSI: 0xffffffc4 (-60)
+	Battery Percentage: 0x50 (80)
+`
+
+func TestParseDevices(t *testing.T) {
+	output := "Agent registered\nDevice AA:BB:CC:DD:EE:FF Test Headset\nDevice 11:22:33:44:55:66 Keyboard K3\n"
+
+	devices := ParseDevices(output)
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(devices))
+	}
+
+	if devices[0].Address != "AA:BB:CC:DD:EE:FF" || devices[0].Name != "Test Headset" {
+		t.Fatalf("unexpected first device: %+v", devices[0])
+	}
+
+	if devices[1].Name != "Keyboard K3" {
+		t.Fatalf("unexpected second device name: %q", devices[1].Name)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info := ParseInfo(sampleInfo)
+
+	if info.Address != "AA:BB:CC:DD:EE:FF" || info.AddressType != "public" {
+		t.Errorf("unexpected address: %q (%s)", info.Address, info.AddressType)
+	}
+	if info.Name != "Test Headset" || info.Alias != "Desk Headset" {
+		t.Errorf("unexpected name/alias: %q/%q", info.Name, info.Alias)
+	}
+	if info.Class != 0x240404 || info.Appearance != 0x0941 {
+		t.Errorf("unexpected class/appearance: %#x/%#x", info.Class, info.Appearance)
+	}
+	if !info.Paired || !info.Bonded || !info.Trusted || info.Blocked || info.Connected {
+		t.Errorf("unexpected flags: %+v", info)
+	}
+	if !info.HasRSSI || info.RSSI != -60 {
+		t.Errorf("unexpected rssi: %d (present=%v)", info.RSSI, info.HasRSSI)
+	}
+	if !info.HasBattery || info.Battery != 80 {
+		t.Errorf("unexpected battery: %d (present=%v)", info.Battery, info.HasBattery)
+	}
+
+	want := []string{"0000110b-0000-1000-8000-00805f9b34fb", "0000111e-0000-1000-8000-00805f9b34fb"}
+	if !slicesEqual(info.UUIDs, want) {
+		t.Errorf("unexpected uuids: %v", info.UUIDs)
+	}
+}
+
+func TestParseInfoWithoutRSSI(t *testing.T) {
+	info := ParseInfo("Device AA:BB:CC:DD:EE:FF (public)\n\tConnected: yes\n")
+	if info.HasRSSI || info.HasBattery {
+		t.Fatalf("expected RSSI and battery to be absent")
+	}
+	if !info.Connected {
+		t.Fatalf("expected device to be connected")
+	}
+}
+
+func TestRunnerInfoInvokesBluetoothctl(t *testing.T) {
+	var gotArgs []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			gotArgs = append([]string(nil), args...)
+			return []byte(sampleInfo), nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	info, err := runner.Info(context.Background(), "AA:BB:CC:DD:EE:FF")
+	if err != nil {
+		t.Fatalf("Info returned error: %v", err)
+	}
+
+	if !slicesEqual(gotArgs, []string{"info", "AA:BB:CC:DD:EE:FF"}) {
+		t.Fatalf("unexpected arguments: %v", gotArgs)
+	}
+
+	if info.Alias != "Desk Headset" {
+		t.Fatalf("unexpected alias: %q", info.Alias)
+	}
+}
+
+func TestIsUnknownDevice(t *testing.T) {
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			if args[1] == "AA:BB:CC:DD:EE:FF" {
+				return []byte("Device AA:BB:CC:DD:EE:FF not available\n"), errors.New("exit status 1")
+			}
+			return []byte("No default controller available\n"), errors.New("exit status 1")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.Info(context.Background(), "AA:BB:CC:DD:EE:FF"); !IsUnknownDevice(err) {
+		t.Fatalf("expected an unknown device, got %v", err)
+	}
+	if _, err := runner.Info(context.Background(), "11:22:33:44:55:66"); err == nil || IsUnknownDevice(err) {
+		t.Fatalf("expected another failure, got %v", err)
+	}
+}
@@ -33,6 +33,15 @@ type Runner struct {
 	// default selection.
 	Adapter string
 
+	// BusctlPath is the busctl executable used for adapter properties that
+	// bluetoothctl cannot change. It is looked up on PATH when first needed.
+	BusctlPath string
+
+	// Agent is passed to bluetoothctl's --agent option when non-empty. "off"
+	// stops bluetoothctl from registering its own pairing agent so BlueZ
+	// routes prompts to the default agent instead.
+	Agent string
+
 	useSudoSet bool
 	sudoSet    bool
 
@@ -72,6 +81,21 @@ func WithAdapter(adapter string) RunnerOption {
 	}
 }
 
+// WithBusctlPath overrides the busctl binary path.
+func WithBusctlPath(path string) RunnerOption {
+	return func(r *Runner) {
+		r.BusctlPath = path
+	}
+}
+
+// WithAgent sets the capability bluetoothctl registers its pairing agent
+// with, or "off" to register none.
+func WithAgent(agent string) RunnerOption {
+	return func(r *Runner) {
+		r.Agent = strings.TrimSpace(agent)
+	}
+}
+
 // WithCommandRunner allows tests to replace the command execution primitive.
 func WithCommandRunner(run commandRunner) RunnerOption {
 	return func(r *Runner) {
@@ -124,6 +148,15 @@ func NewRunner(opts ...RunnerOption) (*Runner, error) {
 	return r, nil
 }
 
+// ForAdapter returns a copy of the runner that targets the provided adapter.
+// The receiver is left untouched so a single runner can serve several
+// controllers.
+func (r *Runner) ForAdapter(adapter string) *Runner {
+	clone := *r
+	clone.Adapter = strings.TrimSpace(adapter)
+	return &clone
+}
+
 // Scan enables adapter discovery for the provided duration and returns the raw
 // bluetoothctl output. A zero or negative duration falls back to a 15 second
 // scan window.
@@ -174,6 +207,18 @@ func (r *Runner) Disconnect(ctx context.Context, address string) (string, error)
 	return r.simpleDeviceCommand(ctx, "disconnect", address)
 }
 
+// Trust marks the provided device as trusted so it may reconnect without
+// authorization and returns the raw bluetoothctl output.
+func (r *Runner) Trust(ctx context.Context, address string) (string, error) {
+	return r.simpleDeviceCommand(ctx, "trust", address)
+}
+
+// Remove deletes the device and its pairing from the adapter and returns the
+// raw bluetoothctl output.
+func (r *Runner) Remove(ctx context.Context, address string) (string, error) {
+	return r.simpleDeviceCommand(ctx, "remove", address)
+}
+
 func (r *Runner) simpleDeviceCommand(ctx context.Context, command, address string) (string, error) {
 	if ctx == nil {
 		return "", fmt.Errorf("nil context passed to %s", command)
@@ -205,6 +250,9 @@ func (r *Runner) exec(ctx context.Context, args ...string) (string, error) {
 	var name string
 	var finalArgs []string
 
+	if r.Agent != "" {
+		finalArgs = append(finalArgs, "--agent", r.Agent)
+	}
 	finalArgs = append(finalArgs, args...)
 	if r.UseSudo {
 		name = r.SudoPath
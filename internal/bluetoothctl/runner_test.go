@@ -281,6 +281,105 @@ func TestRunnerExecSelectsAdapterBeforeCommands(t *testing.T) {
 	}
 }
 
+func TestRunnerForAdapterLeavesOriginalUntouched(t *testing.T) {
+	var calls [][]string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithAdapter("hci0"),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			calls = append(calls, append([]string(nil), args...))
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	pinned := runner.ForAdapter(" hci1 ")
+	if _, err := pinned.Connect(context.Background(), "AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Connect returned error: %v", err)
+	}
+
+	if runner.Adapter != "hci0" {
+		t.Fatalf("expected original runner to keep hci0, got %q", runner.Adapter)
+	}
+
+	if len(calls) != 2 || !slicesEqual(calls[0], []string{"select", "hci1"}) {
+		t.Fatalf("expected pinned runner to select hci1, got %v", calls)
+	}
+}
+
+func TestRunnerPassesAgentOption(t *testing.T) {
+	var gotArgs []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithAgent("off"),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			gotArgs = append([]string(nil), args...)
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.Pair(context.Background(), "AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Pair returned error: %v", err)
+	}
+
+	if !slicesEqual(gotArgs, []string{"--agent", "off", "pair", "AA:BB:CC:DD:EE:FF"}) {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+}
+
+func TestRunnerTrust(t *testing.T) {
+	var gotArgs []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			gotArgs = append([]string(nil), args...)
+			return []byte("Changing AA:BB:CC:DD:EE:FF trust succeeded\n"), nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.Trust(context.Background(), "AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Trust returned error: %v", err)
+	}
+
+	if !slicesEqual(gotArgs, []string{"trust", "AA:BB:CC:DD:EE:FF"}) {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+}
+
+func TestRunnerRemove(t *testing.T) {
+	var gotArgs []string
+	runner, err := NewRunner(
+		WithBinary("bluetoothctl"),
+		WithUseSudo(false),
+		WithCommandRunner(func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			gotArgs = append([]string(nil), args...)
+			return []byte("Device has been removed\n"), nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewRunner returned error: %v", err)
+	}
+
+	if _, err := runner.Remove(context.Background(), "AA:BB:CC:DD:EE:FF"); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+
+	if !slicesEqual(gotArgs, []string{"remove", "AA:BB:CC:DD:EE:FF"}) {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+}
+
 func slicesEqual[T comparable](a, b []T) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,337 @@
+// Package bluezstore reads the pairings BlueZ keeps under /var/lib/bluetooth:
+// one directory per adapter, named by its address, holding a directory per
+// paired device with an info file and a cache directory with a file per
+// device seen. Only what describes a device is read; for the keys BlueZ
+// stores the package reports whether they are present and never returns
+// the key material itself.
+package bluezstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+// DefaultRoot is where BlueZ keeps its store.
+const DefaultRoot = "/var/lib/bluetooth"
+
+const maxLine = 1 << 20
+
+// Device is a device BlueZ stored for an adapter.
+type Device struct {
+	// Adapter is the address of the adapter the device is stored for.
+	Adapter string
+
+	Address string
+
+	// AddressType is "public" or "random", as bluetoothctl prints it. BlueZ
+	// only records it for LE devices; the others use BR/EDR, whose
+	// addresses are always public.
+	AddressType string
+
+	Name  string
+	Alias string
+
+	Class      uint32
+	Appearance uint16
+
+	// Services are the service UUIDs the device advertised.
+	Services []string
+
+	Trusted bool
+	Blocked bool
+
+	// LinkKey reports a BR/EDR bond, LongTermKey an LE bond.
+	LinkKey     bool
+	LongTermKey bool
+
+	// LastSeen is when BlueZ last wrote the device's info or cache file.
+	// BlueZ keeps no timestamp of its own, so this is the closest record
+	// of the device's last connection or discovery.
+	LastSeen time.Time
+}
+
+// Paired reports whether BlueZ holds keys for the device.
+func (d Device) Paired() bool {
+	return d.LinkKey || d.LongTermKey
+}
+
+// keySections hold key material. Their values are never kept; only the
+// names of the entries in them are.
+var keySections = map[string]bool{
+	"LinkKey":               true,
+	"LongTermKey":           true,
+	"PeripheralLongTermKey": true,
+	"SlaveLongTermKey":      true,
+	"IdentityResolvingKey":  true,
+	"LocalSignatureKey":     true,
+	"RemoteSignatureKey":    true,
+}
+
+// Read returns the devices stored under root for every adapter, sorted by
+// adapter and address. Only devices with an info file are returned; BlueZ
+// also caches every device a scan turned up, and those only fill in names
+// and times. An empty root reads DefaultRoot.
+//
+// A device whose info file cannot be parsed is left out, and a cache file
+// that cannot be parsed is ignored; both are reported in skipped, naming the
+// file and line, so one damaged file does not stop the rest from being read.
+// err is only set when the store itself cannot be read.
+func Read(root string) (devices []Device, skipped []error, err error) {
+	if root == "" {
+		root = DefaultRoot
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read BlueZ store: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !daemon.IsAddress(entry.Name()) {
+			continue
+		}
+		adapterDevices, adapterSkipped, err := readAdapter(filepath.Join(root, entry.Name()), daemon.NormalizeAddress(entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		devices = append(devices, adapterDevices...)
+		skipped = append(skipped, adapterSkipped...)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Adapter != devices[j].Adapter {
+			return devices[i].Adapter < devices[j].Adapter
+		}
+		return devices[i].Address < devices[j].Address
+	})
+	return devices, skipped, nil
+}
+
+func readAdapter(dir, adapter string) ([]Device, []error, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read adapter %s: %w", adapter, err)
+	}
+
+	var skipped []error
+	devices := make(map[string]*Device)
+	for _, entry := range entries {
+		if !entry.IsDir() || !daemon.IsAddress(entry.Name()) {
+			continue
+		}
+		device, err := readInfo(filepath.Join(dir, entry.Name(), "info"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("skipped device %s: %w", daemon.NormalizeAddress(entry.Name()), err))
+			continue
+		}
+		device.Adapter = adapter
+		device.Address = daemon.NormalizeAddress(entry.Name())
+		devices[device.Address] = device
+	}
+
+	cached, err := os.ReadDir(filepath.Join(dir, "cache"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("read adapter %s cache: %w", adapter, err)
+	}
+	for _, entry := range cached {
+		if !entry.Type().IsRegular() || !daemon.IsAddress(entry.Name()) {
+			continue
+		}
+		device, ok := devices[daemon.NormalizeAddress(entry.Name())]
+		if !ok {
+			continue
+		}
+		if err := readCache(filepath.Join(dir, "cache", entry.Name()), device); err != nil {
+			skipped = append(skipped, fmt.Errorf("ignored cache of device %s: %w", device.Address, err))
+		}
+	}
+
+	list := make([]Device, 0, len(devices))
+	for _, device := range devices {
+		list = append(list, *device)
+	}
+	return list, skipped, nil
+}
+
+func readInfo(path string) (*Device, error) {
+	file, err := readINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	device := &Device{
+		AddressType: addressType(file.get("General", "AddressType")),
+		Name:        file.get("General", "Name"),
+		Alias:       file.get("General", "Alias"),
+		Services:    splitList(file.get("General", "Services")),
+		Trusted:     file.get("General", "Trusted") == "true",
+		Blocked:     file.get("General", "Blocked") == "true",
+		LastSeen:    file.modified,
+	}
+	if device.Class, err = parseHex(file.get("General", "Class"), 32); err != nil {
+		return nil, file.errorAt("General", "Class", err)
+	}
+	appearance, err := parseHex(file.get("General", "Appearance"), 16)
+	if err != nil {
+		return nil, file.errorAt("General", "Appearance", err)
+	}
+	device.Appearance = uint16(appearance)
+
+	device.LinkKey = file.has("LinkKey", "Key")
+	for _, section := range []string{"LongTermKey", "PeripheralLongTermKey", "SlaveLongTermKey"} {
+		if file.has(section, "Key") {
+			device.LongTermKey = true
+		}
+	}
+	return device, nil
+}
+
+// addressType translates the type BlueZ stores, which names LE random
+// static addresses "static" and leaves it out for BR/EDR devices, to the
+// public/random pair bluetoothctl and the rest of peared use.
+func addressType(value string) string {
+	switch value {
+	case "static":
+		return "random"
+	case "":
+		return "public"
+	}
+	return value
+}
+
+// readCache fills in what the info file lacks from the device's cache file.
+func readCache(path string, device *Device) error {
+	file, err := readINI(path)
+	if err != nil {
+		return err
+	}
+
+	if device.Name == "" {
+		device.Name = file.get("General", "Name")
+	}
+	if device.Name == "" {
+		device.Name = file.get("General", "ShortName")
+	}
+	if file.modified.After(device.LastSeen) {
+		device.LastSeen = file.modified
+	}
+	return nil
+}
+
+// iniFile is a parsed BlueZ key file.
+type iniFile struct {
+	path     string
+	modified time.Time
+
+	// sections maps section names to their entries, lines maps them to the
+	// line each entry was read from.
+	sections map[string]map[string]string
+	lines    map[string]map[string]int
+}
+
+func (f *iniFile) get(section, key string) string {
+	return f.sections[section][key]
+}
+
+func (f *iniFile) has(section, key string) bool {
+	_, ok := f.sections[section][key]
+	return ok
+}
+
+// errorAt reports err as the fault of the entry's line.
+func (f *iniFile) errorAt(section, key string, err error) error {
+	return fmt.Errorf("%s:%d: %s: %w", f.path, f.lines[section][key], key, err)
+}
+
+// readINI parses a BlueZ key file. Entries in keySections are recorded
+// with empty values.
+func readINI(path string) (*iniFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &iniFile{
+		path:     path,
+		modified: info.ModTime(),
+		sections: make(map[string]map[string]string),
+		lines:    make(map[string]map[string]int),
+	}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	// Cached service records are written as one long hex line each.
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	line := 1
+	for ; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "["):
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed section header", path, line)
+			}
+			section = text[1 : len(text)-1]
+			if file.sections[section] == nil {
+				file.sections[section] = make(map[string]string)
+				file.lines[section] = make(map[string]int)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || section == "" {
+			return nil, fmt.Errorf("%s:%d: expected key=value in a section", path, line)
+		}
+		if keySections[section] {
+			value = ""
+		}
+		key = strings.TrimSpace(key)
+		file.sections[section][key] = strings.TrimSpace(value)
+		file.lines[section][key] = line
+	}
+	if err := scanner.Err(); err != nil {
+		// The scanner stopped on the line after the last one it returned.
+		return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+	}
+	return file, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.ToLower(item))
+		}
+	}
+	return items
+}
+
+func parseHex(value string, bits int) (uint32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(value), "0x"), 16, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return uint32(parsed), nil
+}
@@ -0,0 +1,153 @@
+package bluezstore
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureStore copies testdata/store into a temporary directory. Module
+// paths may not contain colons, so the fixture spells addresses with
+// underscores and the copy restores the names BlueZ uses. Every file gets
+// the modification time modified.
+func fixtureStore(t *testing.T, modified time.Time) string {
+	t.Helper()
+	root := t.TempDir()
+	err := filepath.WalkDir("testdata/store", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel("testdata/store", path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, strings.ReplaceAll(rel, "_", ":"))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0o600); err != nil {
+			return err
+		}
+		return os.Chtimes(target, modified, modified)
+	})
+	if err != nil {
+		t.Fatalf("copy fixture: %v", err)
+	}
+	return root
+}
+
+func TestRead(t *testing.T) {
+	modified := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	root := fixtureStore(t, modified)
+
+	cached := modified.Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "00:1A:7D:DA:71:13", "cache", "C8:3F:26:11:22:33"), cached, cached); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	devices, skipped, err := Read(root)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("Read: %v, skipped %v", err, skipped)
+	}
+
+	var got []string
+	for _, d := range devices {
+		got = append(got, fmt.Sprintf("%s %s %q alias=%q class=%#x appearance=%#x type=%s services=%d trusted=%t blocked=%t link=%t ltk=%t seen=%s",
+			d.Adapter, d.Address, d.Name, d.Alias, d.Class, d.Appearance, d.AddressType, len(d.Services),
+			d.Trusted, d.Blocked, d.LinkKey, d.LongTermKey, d.LastSeen.UTC().Format(time.RFC3339)))
+	}
+	want := []string{
+		`00:1A:7D:DA:71:13 11:22:33:44:55:66 "Kitchen Speaker" alias="" class=0x240414 appearance=0x0 type=public services=0 trusted=false blocked=true link=false ltk=false seen=2026-03-14T09:30:00Z`,
+		`00:1A:7D:DA:71:13 AA:BB:CC:DD:EE:FF "WH-1000XM4" alias="Cans" class=0x240404 appearance=0x0 type=public services=3 trusted=true blocked=false link=true ltk=false seen=2026-03-14T09:30:00Z`,
+		`00:1A:7D:DA:71:13 C8:3F:26:11:22:33 "MX Master 3" alias="" class=0x0 appearance=0x3c2 type=random services=2 trusted=true blocked=false link=false ltk=true seen=2026-03-14T10:30:00Z`,
+		`5C:F3:70:00:00:01 AA:BB:CC:DD:EE:FF "WH-1000XM4" alias="" class=0x240404 appearance=0x0 type=public services=1 trusted=false blocked=false link=true ltk=false seen=2026-03-14T09:30:00Z`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected devices:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if services := devices[1].Services; services[0] != "0000110b-0000-1000-8000-00805f9b34fb" {
+		t.Fatalf("expected lower-case service UUIDs, got %v", services)
+	}
+	if !devices[1].Paired() || devices[0].Paired() {
+		t.Fatalf("unexpected pairing state: %t %t", devices[1].Paired(), devices[0].Paired())
+	}
+
+	dump := fmt.Sprintf("%#v", devices)
+	for _, key := range []string{"0123456789ABCDEF", "FEDCBA9876543210", "00112233445566778899", "AAAABBBBCCCC", "12345", "6789"} {
+		if strings.Contains(dump, key) {
+			t.Fatalf("key material %q leaked into %s", key, dump)
+		}
+	}
+}
+
+func TestReadSkipsMalformedFiles(t *testing.T) {
+	root := fixtureStore(t, time.Now())
+	adapter := filepath.Join(root, "00:1A:7D:DA:71:13")
+	info := filepath.Join(adapter, "11:22:33:44:55:66", "info")
+	cache := filepath.Join(adapter, "cache", "C8:3F:26:11:22:33")
+
+	read := func() ([]string, string) {
+		t.Helper()
+		devices, skipped, err := Read(root)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		var addresses []string
+		for _, d := range devices {
+			addresses = append(addresses, d.Adapter+" "+d.Address+" "+d.Name)
+		}
+		if len(skipped) != 1 {
+			t.Fatalf("expected one skipped file, got %v", skipped)
+		}
+		return addresses, skipped[0].Error()
+	}
+
+	if err := os.WriteFile(info, []byte("[General]\nName=Kitchen Speaker\nClass=loud\n"), 0o600); err != nil {
+		t.Fatalf("write info: %v", err)
+	}
+	devices, warning := read()
+	if len(devices) != 3 || strings.Contains(strings.Join(devices, "|"), "11:22:33:44:55:66") {
+		t.Fatalf("expected only the damaged device to be left out, got %v", devices)
+	}
+	if !strings.Contains(warning, "11:22:33:44:55:66") || !strings.Contains(warning, info+`:3: Class: invalid value "loud"`) {
+		t.Fatalf("expected the warning to point at the class line, got %q", warning)
+	}
+
+	if err := os.WriteFile(info, []byte("[LinkKey]\nKey 0123456789ABCDEF\n"), 0o600); err != nil {
+		t.Fatalf("write info: %v", err)
+	}
+	if _, warning := read(); !strings.Contains(warning, info+":2: expected key=value") || strings.Contains(warning, "0123") {
+		t.Fatalf("expected a malformed line warning without its content, got %q", warning)
+	}
+	if err := os.Remove(info); err != nil {
+		t.Fatalf("remove info: %v", err)
+	}
+
+	// A service record longer than any BlueZ writes only costs the device
+	// what its cache would have added.
+	record := "[ServiceRecords]\n0x00010000=" + strings.Repeat("35", maxLine) + "\n"
+	if err := os.WriteFile(cache, []byte("[General]\nName=Cached name\n"+record), 0o600); err != nil {
+		t.Fatalf("write cache: %v", err)
+	}
+	devices, warning = read()
+	if !slices.Contains(devices, "00:1A:7D:DA:71:13 C8:3F:26:11:22:33 ") {
+		t.Fatalf("expected the device to be kept without its cached name, got %v", devices)
+	}
+	if !strings.Contains(warning, cache+":4: ") {
+		t.Fatalf("expected the warning to point at the long line, got %q", warning)
+	}
+
+	if _, _, err := Read(filepath.Join(root, "missing")); err == nil {
+		t.Fatal("expected an error for a missing store")
+	}
+}
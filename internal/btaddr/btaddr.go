@@ -0,0 +1,30 @@
+// Package btaddr checks and normalises Bluetooth device addresses as users
+// write them in configuration and on the command line.
+package btaddr
+
+import "strings"
+
+// Valid reports whether value is a MAC address written as six
+// colon-separated pairs of hex digits.
+func Valid(value string) bool {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 6 {
+		return false
+	}
+	for _, part := range parts {
+		if len(part) != 2 || !isHex(part[0]) || !isHex(part[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// Normalize upper-cases and trims a MAC address so lookups are insensitive
+// to how users typed it.
+func Normalize(address string) string {
+	return strings.ToUpper(strings.TrimSpace(address))
+}
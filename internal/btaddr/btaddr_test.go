@@ -0,0 +1,19 @@
+package btaddr
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	for value, want := range map[string]bool{
+		"AA:BB:CC:DD:EE:FF":  true,
+		"aa:bb:cc:dd:ee:0f":  true,
+		"AA:BB:CC:DD:EE":     false,
+		"AA-BB-CC-DD-EE-FF":  false,
+		"AA:BB:CC:DD:EE:FG":  false,
+		"WH-1000XM4":         false,
+		"AA:BB:CC:DD:EE:FFF": false,
+	} {
+		if got := Valid(value); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
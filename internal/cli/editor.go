@@ -0,0 +1,562 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/peared/peared/internal/term"
+)
+
+// Completer returns the candidates for the word being typed. words are the
+// complete words before it and partial is what has been typed of it so far;
+// candidates need not be filtered by partial.
+type Completer func(words []string, partial string) []string
+
+// Editor reads lines from a terminal in raw mode, with cursor movement,
+// history navigation, reverse search (Ctrl-R) and tab completion. The
+// terminal is only in raw mode while ReadLine waits for a line, so commands
+// run in between see it as usual.
+type Editor struct {
+	in        *bufio.Reader
+	out       io.Writer
+	raw       func() (func() error, error)
+	history   *History
+	completer Completer
+
+	// mu serializes drawing with PrintAbove and guards the line state.
+	mu       sync.Mutex
+	active   bool
+	prompt   string
+	buf      []rune
+	pos      int
+	restore  func() error
+	listNext bool
+
+	// History navigation: index is the entry shown, len(entries) for the
+	// line being typed, which is kept in draft meanwhile.
+	entries []string
+	index   int
+	draft   []rune
+
+	// Reverse search: the line before the search started is kept in saved.
+	searching bool
+	query     []rune
+	match     int
+	saved     []rune
+}
+
+// EditorOption customizes an Editor.
+type EditorOption func(*Editor)
+
+// WithHistory recalls and records lines in h.
+func WithHistory(h *History) EditorOption {
+	return func(e *Editor) {
+		e.history = h
+	}
+}
+
+// WithCompleter completes words with c when Tab is pressed.
+func WithCompleter(c Completer) EditorOption {
+	return func(e *Editor) {
+		e.completer = c
+	}
+}
+
+// NewEditor creates an editor reading keys from the terminal in and drawing
+// on out. It fails when in is not a terminal, in which case callers keep
+// reading plain lines.
+func NewEditor(in *os.File, out io.Writer, opts ...EditorOption) (*Editor, error) {
+	if !term.IsTerminal(in) {
+		return nil, errors.New("input is not a terminal")
+	}
+	return newEditor(in, out, func() (func() error, error) { return term.MakeRaw(in) }, opts...), nil
+}
+
+func newEditor(in io.Reader, out io.Writer, raw func() (func() error, error), opts ...EditorOption) *Editor {
+	e := &Editor{in: bufio.NewReader(in), out: out, raw: raw}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(e)
+		}
+	}
+	return e
+}
+
+// Close restores the terminal if a ReadLine was interrupted.
+func (e *Editor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.restore == nil {
+		return nil
+	}
+	err := e.restore()
+	e.restore = nil
+	return err
+}
+
+// ReadLine shows prompt and returns the line entered, without the newline.
+// Ctrl-C discards the line and returns an empty one; Ctrl-D on an empty line
+// returns io.EOF.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := e.raw()
+	if err != nil {
+		return "", fmt.Errorf("enter raw mode: %w", err)
+	}
+
+	e.mu.Lock()
+	e.restore = restore
+	e.active = true
+	e.prompt = prompt
+	e.buf, e.pos = nil, 0
+	e.entries = nil
+	if e.history != nil {
+		e.entries = e.history.Entries()
+	}
+	e.index, e.draft = len(e.entries), nil
+	e.searching, e.listNext = false, false
+	e.render()
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.active = false
+		e.mu.Unlock()
+		e.Close()
+	}()
+
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		e.mu.Lock()
+		line, done, err := e.handle(k)
+		e.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		if done {
+			if e.history != nil {
+				_ = e.history.Add(line)
+			}
+			return line, nil
+		}
+	}
+}
+
+// PrintAbove prints line above the line being edited and draws the prompt
+// and the input typed so far again below it.
+func (e *Editor) PrintAbove(line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.active {
+		fmt.Fprintf(e.out, "%s\n", line)
+		return
+	}
+	fmt.Fprintf(e.out, "\r\x1b[K%s\n", line)
+	e.render()
+}
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyTab
+	keyInterrupt
+	keyEOF
+	keyKillEnd
+	keyKillStart
+	keyKillWord
+	keySearch
+	keyCancel
+	keyClear
+	keyIgnored
+)
+
+type key struct {
+	code keyCode
+	r    rune
+}
+
+var controlKeys = map[rune]keyCode{
+	0x01: keyHome,      // Ctrl-A
+	0x02: keyLeft,      // Ctrl-B
+	0x03: keyInterrupt, // Ctrl-C
+	0x04: keyEOF,       // Ctrl-D
+	0x05: keyEnd,       // Ctrl-E
+	0x06: keyRight,     // Ctrl-F
+	0x07: keyCancel,    // Ctrl-G
+	0x08: keyBackspace, // Ctrl-H
+	0x09: keyTab,
+	0x0a: keyEnter,
+	0x0b: keyKillEnd, // Ctrl-K
+	0x0c: keyClear,   // Ctrl-L
+	0x0d: keyEnter,
+	0x0e: keyDown,      // Ctrl-N
+	0x10: keyUp,        // Ctrl-P
+	0x12: keySearch,    // Ctrl-R
+	0x15: keyKillStart, // Ctrl-U
+	0x17: keyKillWord,  // Ctrl-W
+	0x7f: keyBackspace,
+}
+
+// escapeKeys maps the keys term.ReadKey decodes from escape sequences.
+var escapeKeys = map[term.KeyCode]keyCode{
+	term.KeyUp:     keyUp,
+	term.KeyDown:   keyDown,
+	term.KeyRight:  keyRight,
+	term.KeyLeft:   keyLeft,
+	term.KeyHome:   keyHome,
+	term.KeyEnd:    keyEnd,
+	term.KeyDelete: keyDelete,
+}
+
+// readKey decodes the next key. Control characters map to editing keys and
+// other unprintable characters are ignored.
+func (e *Editor) readKey() (key, error) {
+	k, err := term.ReadKey(e.in)
+	if err != nil {
+		return key{}, err
+	}
+
+	if k.Code != term.KeyRune {
+		if code, ok := escapeKeys[k.Code]; ok {
+			return key{code: code}, nil
+		}
+		return key{code: keyIgnored}, nil
+	}
+	if code, ok := controlKeys[k.Rune]; ok {
+		return key{code: code}, nil
+	}
+	if unicode.IsPrint(k.Rune) {
+		return key{code: keyRune, r: k.Rune}, nil
+	}
+	return key{code: keyIgnored}, nil
+}
+
+// handle applies k to the line. It reports the line once it is complete.
+func (e *Editor) handle(k key) (string, bool, error) {
+	if e.searching && e.handleSearch(k) {
+		if k.code == keyEnter {
+			return e.finish()
+		}
+		return "", false, nil
+	}
+
+	listing := e.listNext
+	e.listNext = false
+
+	switch k.code {
+	case keyRune:
+		e.insert([]rune{k.r})
+	case keyEnter:
+		return e.finish()
+	case keyBackspace:
+		if e.pos > 0 {
+			e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+			e.pos--
+		}
+	case keyDelete:
+		if e.pos < len(e.buf) {
+			e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+		}
+	case keyLeft:
+		if e.pos > 0 {
+			e.pos--
+		}
+	case keyRight:
+		if e.pos < len(e.buf) {
+			e.pos++
+		}
+	case keyHome:
+		e.pos = 0
+	case keyEnd:
+		e.pos = len(e.buf)
+	case keyUp:
+		e.recall(e.index - 1)
+	case keyDown:
+		e.recall(e.index + 1)
+	case keyKillEnd:
+		e.buf = e.buf[:e.pos]
+	case keyKillStart:
+		e.buf = append([]rune(nil), e.buf[e.pos:]...)
+		e.pos = 0
+	case keyKillWord:
+		start := e.pos
+		for start > 0 && e.buf[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && e.buf[start-1] != ' ' {
+			start--
+		}
+		e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+		e.pos = start
+	case keyTab:
+		e.complete(listing)
+	case keySearch:
+		e.searching, e.query, e.match = true, nil, -1
+		e.saved = append([]rune(nil), e.buf...)
+	case keyClear:
+		fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+	case keyInterrupt:
+		fmt.Fprint(e.out, "^C\n")
+		e.buf, e.pos = nil, 0
+		return "", true, nil
+	case keyEOF:
+		if len(e.buf) == 0 {
+			return "", false, io.EOF
+		}
+		if e.pos < len(e.buf) {
+			e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+		}
+	case keyCancel, keyIgnored:
+	}
+
+	e.render()
+	return "", false, nil
+}
+
+func (e *Editor) finish() (string, bool, error) {
+	e.searching = false
+	e.pos = len(e.buf)
+	e.render()
+	fmt.Fprint(e.out, "\n")
+	return string(e.buf), true, nil
+}
+
+func (e *Editor) insert(runes []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	buf = append(buf, e.buf[e.pos:]...)
+	e.buf = buf
+	e.pos += len(runes)
+}
+
+// recall shows history entry i, or the line being typed past the newest.
+func (e *Editor) recall(i int) {
+	if i < 0 || i > len(e.entries) || i == e.index {
+		return
+	}
+	if e.index == len(e.entries) {
+		e.draft = append([]rune(nil), e.buf...)
+	}
+	e.index = i
+	if i == len(e.entries) {
+		e.buf = append([]rune(nil), e.draft...)
+	} else {
+		e.buf = []rune(e.entries[i])
+	}
+	e.pos = len(e.buf)
+}
+
+// handleSearch applies k during reverse search and reports whether it was
+// consumed. Keys that do not refine the search accept the match and are
+// then handled as usual.
+func (e *Editor) handleSearch(k key) bool {
+	switch k.code {
+	case keyRune:
+		e.query = append(e.query, k.r)
+		e.search(e.match)
+	case keyBackspace:
+		if len(e.query) > 0 {
+			e.query = e.query[:len(e.query)-1]
+		}
+		e.search(len(e.entries) - 1)
+	case keySearch:
+		e.search(e.match - 1)
+	case keyCancel, keyInterrupt:
+		e.searching = false
+		e.buf = append([]rune(nil), e.saved...)
+		e.pos = len(e.buf)
+	case keyEnter:
+		e.accept()
+		return true
+	default:
+		e.accept()
+		return false
+	}
+	e.render()
+	return true
+}
+
+// search finds the newest entry at or before from containing the query.
+func (e *Editor) search(from int) {
+	if from < 0 || from >= len(e.entries) {
+		from = len(e.entries) - 1
+	}
+	query := string(e.query)
+	for i := from; i >= 0; i-- {
+		if strings.Contains(e.entries[i], query) {
+			e.match = i
+			return
+		}
+	}
+}
+
+func (e *Editor) accept() {
+	e.searching = false
+	if e.match >= 0 && e.match < len(e.entries) {
+		e.buf = []rune(e.entries[e.match])
+		e.index = e.match
+	}
+	e.pos = len(e.buf)
+}
+
+// complete completes the word before the cursor: a single candidate is
+// inserted in full, several extend the word to their common prefix, and a
+// second Tab lists them.
+func (e *Editor) complete(listing bool) {
+	if e.completer == nil {
+		return
+	}
+
+	head := string(e.buf[:e.pos])
+	start, quote := wordStart(head)
+	words, err := SplitLine(head[:start])
+	if err != nil {
+		return
+	}
+	partial := head[start:]
+	if quote != 0 {
+		partial = partial[1:]
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, candidate := range e.completer(words, partial) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) && !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Strings(candidates)
+
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return
+	case 1:
+		e.replaceWord(start, quoteWord(candidates[0], quote, true)+" ")
+		return
+	}
+
+	common := commonPrefix(candidates)
+	if len(common) > len(partial) {
+		e.replaceWord(start, quoteWord(common, quote, false))
+		return
+	}
+	if !listing {
+		e.listNext = true
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+}
+
+func (e *Editor) replaceWord(start int, word string) {
+	head := []rune(string(e.buf[:e.pos])[:start])
+	tail := e.buf[e.pos:]
+	e.buf = append(append(head, []rune(word)...), tail...)
+	e.pos = len(head) + len([]rune(word))
+}
+
+// wordStart returns the byte offset where the last word of head starts and
+// the quote it opens with, if that quote is still open.
+func wordStart(head string) (int, rune) {
+	start, opened, quote, escaped := 0, 0, rune(0), false
+	for i, r := range head {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			}
+		case r == '\\':
+			escaped = true
+		case r == '"' || r == '\'':
+			quote, opened = r, i
+		case r == ' ' || r == '\t':
+			start = i + 1
+		}
+	}
+	if quote == 0 || opened != start {
+		return start, 0
+	}
+	return start, quote
+}
+
+// quoteWord prepares a completion for the command line. Words that need
+// quoting are wrapped in double quotes unless the user opened a quote; the
+// quote is closed once the word is complete.
+func quoteWord(word string, quote rune, complete bool) string {
+	if quote == 0 {
+		if !strings.ContainsAny(word, " \t\"'\\#") {
+			return word
+		}
+		quote = '"'
+	}
+	if quote == '"' {
+		word = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word)
+	}
+	if complete {
+		return string(quote) + word + string(quote)
+	}
+	return string(quote) + word
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// render draws the prompt and the line, and puts the cursor in place.
+func (e *Editor) render() {
+	var b strings.Builder
+	b.WriteString("\r")
+	if e.searching {
+		match := ""
+		if e.match >= 0 && e.match < len(e.entries) {
+			match = e.entries[e.match]
+		}
+		fmt.Fprintf(&b, "(reverse-i-search)`%s': %s\x1b[K", string(e.query), match)
+		io.WriteString(e.out, b.String())
+		return
+	}
+
+	b.WriteString(e.prompt)
+	b.WriteString(string(e.buf))
+	b.WriteString("\x1b[K")
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", n)
+	}
+	io.WriteString(e.out, b.String())
+}
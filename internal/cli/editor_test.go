@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func noRawMode() (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func readLines(t *testing.T, e *Editor, n int) []string {
+	t.Helper()
+	var lines []string
+	for i := 0; i < n; i++ {
+		line, err := e.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("ReadLine %d: %v", i, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestEditorEditsLine(t *testing.T) {
+	// "stauts": fix the typo with Left, Delete, Right and an insertion, then
+	// type at the start and the end of the line.
+	input := "stauts\x1b[D\x1b[D\x1b[D\x1b[3~\x1b[Cu" + "\x1b[Hx\x01\x1b[3~\x05 --json\x7fn\r"
+	var out bytes.Buffer
+
+	e := newEditor(strings.NewReader(input), &out, noRawMode)
+	lines := readLines(t, e, 1)
+	if lines[0] != "status --json" {
+		t.Fatalf("unexpected line %q", lines[0])
+	}
+	if !strings.HasPrefix(out.String(), "\r> ") {
+		t.Fatalf("expected the prompt to be drawn, got %q", out.String())
+	}
+}
+
+func TestEditorKillsAndInterrupts(t *testing.T) {
+	input := "devices connect cans\x17speaker\r" + "garbage\x03" + "status\x01\x0b\r"
+	var out bytes.Buffer
+
+	e := newEditor(strings.NewReader(input), &out, noRawMode)
+	lines := readLines(t, e, 3)
+	want := []string{"devices connect speaker", "", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected lines %q, want %q", lines, want)
+	}
+	if !strings.Contains(out.String(), "^C\n") {
+		t.Fatalf("expected Ctrl-C to be echoed, got %q", out.String())
+	}
+
+	e = newEditor(strings.NewReader("\x04"), &out, noRawMode)
+	if _, err := e.ReadLine("> "); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF for Ctrl-D on an empty line, got %v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peared", "history")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatalf("OpenHistory: %v", err)
+	}
+
+	// Two lines, then Up Up recalls the first, Down returns to the second;
+	// Ctrl-R "ad" finds the adapters line; Up past the oldest entry stays.
+	input := "adapters list\r" + "status\r" +
+		"st\x1b[A\x1b[A\x1b[B\r" +
+		"\x12ad\r" +
+		"\x1b[A\x1b[A\x1b[A\x1b[A\r"
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader(input), &out, noRawMode, WithHistory(history))
+	lines := readLines(t, e, 5)
+	want := []string{"adapters list", "status", "status", "adapters list", "adapters list"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected lines %q, want %q", lines, want)
+	}
+	if !strings.Contains(out.String(), "(reverse-i-search)`ad': adapters list") {
+		t.Fatalf("expected the search to be shown, got %q", out.String())
+	}
+
+	reopened, err := OpenHistory(path)
+	if err != nil {
+		t.Fatalf("OpenHistory: %v", err)
+	}
+	entries := reopened.Entries()
+	if strings.Join(entries, "|") != "adapters list|status|adapters list" {
+		t.Fatalf("unexpected persisted history %q", entries)
+	}
+}
+
+func TestEditorReverseSearchCancel(t *testing.T) {
+	history, _ := OpenHistory("")
+	_ = history.Add("devices connect cans")
+	_ = history.Add("devices disconnect cans")
+
+	// Ctrl-R twice steps to the older match; Ctrl-G restores the typed
+	// line; a second search is accepted with End and edited further.
+	input := "sta\x12dev\x12\x07tus\r" + "\x12disc\x05 --adapter hci1\r"
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader(input), &out, noRawMode, WithHistory(history))
+	lines := readLines(t, e, 2)
+	want := []string{"status", "devices disconnect cans --adapter hci1"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected lines %q, want %q", lines, want)
+	}
+	if !strings.Contains(out.String(), "(reverse-i-search)`dev': devices connect cans") {
+		t.Fatalf("expected Ctrl-R to step to the older match, got %q", out.String())
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	completer := func(words []string, partial string) []string {
+		switch {
+		case len(words) == 0:
+			return []string{"adapters", "devices", "status"}
+		case len(words) == 1 && words[0] == "devices":
+			return []string{"connect", "disconnect", "list"}
+		case len(words) == 2:
+			return []string{"Work headphones", "Work keyboard", "MX Keys"}
+		}
+		return nil
+	}
+
+	// "dev<Tab>" completes the command, "c<Tab>" the subcommand, and "w<Tab>"
+	// extends to the quoted common prefix; "h<Tab>" finishes the name.
+	input := "dev\tc\tw\th\t\r" + "de\tdis\t\"MX\t\r"
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader(input), &out, noRawMode, WithCompleter(completer))
+	lines := readLines(t, e, 2)
+	want := []string{`devices connect "Work headphones" `, `devices disconnect "MX Keys" `}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected lines %q, want %q", lines, want)
+	}
+
+	words, err := SplitLine(lines[0])
+	if err != nil || len(words) != 3 || words[2] != "Work headphones" {
+		t.Fatalf("completed line splits into %q, %v", words, err)
+	}
+
+	// Two Tabs with nothing to extend list the candidates.
+	out.Reset()
+	e = newEditor(strings.NewReader("devices \t\t\r"), &out, noRawMode, WithCompleter(completer))
+	readLines(t, e, 1)
+	if !strings.Contains(out.String(), "\nconnect  disconnect  list\n") {
+		t.Fatalf("expected the candidates to be listed, got %q", out.String())
+	}
+}
+
+func TestEditorPrintAbove(t *testing.T) {
+	r, w := io.Pipe()
+	var out syncBuffer
+
+	e := newEditor(r, &out, noRawMode)
+	done := make(chan string)
+	go func() {
+		line, _ := e.ReadLine("> ")
+		done <- line
+	}()
+
+	io.WriteString(w, "sta")
+	waitFor(t, &out, "> sta")
+	e.PrintAbove("adapter.added\tadapter hci1 appeared")
+	waitFor(t, &out, "\r\x1b[Kadapter.added\tadapter hci1 appeared\n\r> sta\x1b[K")
+
+	io.WriteString(w, "tus\r")
+	if line := <-done; line != "status" {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
@@ -6,18 +6,86 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+	"unicode"
 )
 
-// Shell provides a minimal interactive prompt for controlling the daemon. It is
-// intentionally small for now so we can iterate quickly while we flesh out the
-// underlying control plane.
+// ErrFailed marks a command error whose message the command has already
+// printed, so the shell does not repeat it.
+var ErrFailed = errors.New("command failed")
+
+// ExitError is returned by RunScript when a script ends with `exit <code>`.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// unknownCommandError reports a command the shell does not know.
+type unknownCommandError string
+
+func (e unknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command %q", string(e))
+}
+
+// Command is a command the shell dispatches to by name.
+type Command struct {
+	Name    string
+	Summary string
+
+	// Run executes the command with the words that followed its name.
+	Run func(ctx context.Context, env Env, args []string) error
+}
+
+// Env describes the session a command runs in.
+type Env struct {
+	// Adapter is the adapter chosen with `use`, or empty for the default.
+	Adapter string
+	// Out receives the command's output. Writes are serialized with event
+	// lines.
+	Out io.Writer
+	// Interactive is set when the command runs from the prompt, where it may
+	// read the terminal. Script commands must not read input, which may be
+	// the script itself.
+	Interactive bool
+}
+
+// Shell provides an interactive prompt for controlling the daemon. Besides
+// its built-in commands it dispatches to the commands registered with
+// WithCommands, keeps the adapter chosen with `use` for the rest of the
+// session, and prints event lines as they arrive.
 type Shell struct {
 	reader *bufio.Reader
 	writer io.Writer
 	prompt string
 
+	commands []Command
+	editor   *Editor
+	events   <-chan string
+	resolve  func(ctx context.Context, adapter string) (string, error)
+	terminal bool
+
+	// mu serializes writes and guards the fields below.
+	mu          sync.Mutex
+	adapter     string
+	atPrompt    bool
+	cancel      context.CancelFunc
+	interactive bool
+
+	// Set with the `set` builtin: variables, stopping scripts at the first
+	// failure (-e, the default) and tracing commands (-x).
+	vars    map[string]string
+	errexit bool
+	xtrace  bool
+
 	closed atomic.Bool
 }
 
@@ -31,13 +99,62 @@ func WithPrompt(prompt string) ShellOption {
 	}
 }
 
+// WithCommands registers commands the shell dispatches to. Later commands
+// replace earlier ones with the same name.
+func WithCommands(commands ...Command) ShellOption {
+	return func(s *Shell) {
+		s.commands = append(s.commands, commands...)
+	}
+}
+
+// WithEditor reads lines with e instead of plainly, for line editing,
+// history and completion on a terminal.
+func WithEditor(e *Editor) ShellOption {
+	return func(s *Shell) {
+		s.editor = e
+	}
+}
+
+// WithEvents prints every line received from events above the prompt while
+// the shell runs.
+func WithEvents(events <-chan string) ShellOption {
+	return func(s *Shell) {
+		s.events = events
+	}
+}
+
+// WithAdapter sets the adapter commands run against until `use` changes it.
+func WithAdapter(adapter string) ShellOption {
+	return func(s *Shell) {
+		s.adapter = strings.TrimSpace(adapter)
+	}
+}
+
+// WithAdapterResolver checks the argument of `use` and returns the adapter
+// ID it refers to, so aliases and addresses are accepted and typos rejected.
+func WithAdapterResolver(resolve func(ctx context.Context, adapter string) (string, error)) ShellOption {
+	return func(s *Shell) {
+		s.resolve = resolve
+	}
+}
+
+// WithTerminal tells the shell that its output is a terminal, so event lines
+// can clear the prompt before they are printed instead of following it.
+func WithTerminal(terminal bool) ShellOption {
+	return func(s *Shell) {
+		s.terminal = terminal
+	}
+}
+
 // NewShell creates a Shell instance that reads commands from r and writes
 // output to w. Callers may provide additional options to tweak defaults.
 func NewShell(r io.Reader, w io.Writer, opts ...ShellOption) *Shell {
 	shell := &Shell{
-		reader: bufio.NewReader(r),
-		writer: w,
-		prompt: "peared> ",
+		reader:  bufio.NewReader(r),
+		writer:  w,
+		prompt:  "peared> ",
+		vars:    make(map[string]string),
+		errexit: true,
 	}
 
 	for _, opt := range opts {
@@ -49,9 +166,32 @@ func NewShell(r io.Reader, w io.Writer, opts ...ShellOption) *Shell {
 	return shell
 }
 
-// Run executes the interactive loop until the user exits or the context is
-// cancelled. Unknown commands result in a helpful message so early adopters can
-// understand the current surface area.
+// Adapter returns the adapter chosen with `use`, or empty.
+func (s *Shell) Adapter() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.adapter
+}
+
+// Interrupt cancels the command that is running, if any, and reports whether
+// there was one. Callers forward Ctrl-C here so it stops the command rather
+// than the shell.
+func (s *Shell) Interrupt() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// Run reads and executes commands until the user exits, input ends or ctx is
+// cancelled. Each line runs a built-in such as use, which picks the adapter
+// later commands act on and shows it in the prompt, or the matching peared
+// command; failures are reported and the loop carries on. Daemon events are
+// printed above the prompt while it waits. Run returns nil on exit or end of
+// input, the context's cause when cancelled and otherwise the read error.
 func (s *Shell) Run(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("nil context passed to Shell.Run")
@@ -61,6 +201,18 @@ func (s *Shell) Run(ctx context.Context) error {
 		return errors.New("shell already closed")
 	}
 
+	// The event printer stops when ctx is cancelled, so wait for it after
+	// the cancel below has run.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	s.interactive = true
+	s.mu.Unlock()
+
 	fmt.Fprintf(s.writer, "Welcome to the Peared shell! Type 'help' to see available commands.\n")
 
 	type input struct {
@@ -68,11 +220,28 @@ func (s *Shell) Run(ctx context.Context) error {
 		err  error
 	}
 
-	inputCh := make(chan input, 1)
+	// Lines are read on request only, so commands that prompt can read the
+	// same input while they run.
+	requests := make(chan struct{})
+	inputCh := make(chan input)
 	go func() {
 		defer close(inputCh)
 		for {
-			line, err := s.reader.ReadString('\n')
+			select {
+			case <-ctx.Done():
+				return
+			case <-requests:
+			}
+
+			var (
+				line string
+				err  error
+			)
+			if s.editor != nil {
+				line, err = s.editor.ReadLine(s.currentPrompt())
+			} else {
+				line, err = s.reader.ReadString('\n')
+			}
 			select {
 			case <-ctx.Done():
 				return
@@ -85,53 +254,548 @@ func (s *Shell) Run(ctx context.Context) error {
 		}
 	}()
 
+	if s.events != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.printEvents(ctx)
+		}()
+	}
+
 	for {
-		fmt.Fprint(s.writer, s.prompt)
+		s.showPrompt()
 
 		select {
 		case <-ctx.Done():
-			s.closed.Store(true)
-			if cause := context.Cause(ctx); cause != nil {
-				return cause
-			}
-			return context.Canceled
-		case in, ok := <-inputCh:
+			return s.stop(ctx)
+		case requests <- struct{}{}:
+		}
+
+		var in input
+		select {
+		case <-ctx.Done():
+			return s.stop(ctx)
+		case received, ok := <-inputCh:
 			if !ok {
-				fmt.Fprintln(s.writer)
+				s.leavePrompt()
+				s.printf("\n")
 				s.closed.Store(true)
 				return nil
 			}
+			in = received
+		}
+		s.leavePrompt()
 
-			if in.err != nil {
-				if errors.Is(in.err, io.EOF) {
-					fmt.Fprintln(s.writer)
-					s.closed.Store(true)
-					return nil
-				}
-				s.closed.Store(true)
-				return in.err
-			}
+		if in.err != nil && !errors.Is(in.err, io.EOF) {
+			s.closed.Store(true)
+			return in.err
+		}
 
-			cmd := strings.TrimSpace(in.line)
-			switch strings.ToLower(cmd) {
-			case "", "#":
-				continue
-			case "exit", "quit":
-				fmt.Fprintln(s.writer, "Goodbye!")
-				s.closed.Store(true)
+		err := s.execute(ctx, in.line)
+		var exit *ExitError
+		if errors.As(err, &exit) {
+			s.printf("Goodbye!\n")
+			s.closed.Store(true)
+			return nil
+		}
+		s.report(err)
+
+		if in.err != nil {
+			s.printf("\n")
+			s.closed.Store(true)
+			return nil
+		}
+	}
+}
+
+func (s *Shell) stop(ctx context.Context) error {
+	s.leavePrompt()
+	s.closed.Store(true)
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	return context.Canceled
+}
+
+// RunScript executes the commands read from r without prompting, as
+// `peared shell --script` does. It stops at the first command that fails,
+// unless `set +e` turned that off, and returns the error labelled with name
+// and the line number. `exit <code>` ends the script with an *ExitError.
+func (s *Shell) RunScript(ctx context.Context, r io.Reader, name string) error {
+	if ctx == nil {
+		return errors.New("nil context passed to Shell.RunScript")
+	}
+
+	if s.closed.Swap(true) {
+		return errors.New("shell already closed")
+	}
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := s.execute(ctx, scanner.Text())
+		var exit *ExitError
+		if errors.As(err, &exit) {
+			if exit.Code == 0 {
 				return nil
-			case "help":
-				s.writeHelp()
-			default:
-				fmt.Fprintf(s.writer, "Unknown command: %s\n", cmd)
 			}
+			return exit
+		}
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("%s:%d: %w", name, number, err)
+		if s.errexit {
+			return err
+		}
+		s.printf("%v\n", err)
+	}
+	return scanner.Err()
+}
+
+// report prints the error of an interactive command.
+func (s *Shell) report(err error) {
+	var unknown unknownCommandError
+	switch {
+	case err == nil, errors.Is(err, ErrFailed):
+	case errors.As(err, &unknown):
+		s.printf("Unknown command: %s\n", string(unknown))
+	default:
+		s.printf("%v\n", err)
+	}
+}
+
+// execute runs one input line. `exit` is reported as an *ExitError.
+func (s *Shell) execute(ctx context.Context, line string) error {
+	args, err := splitWords(strings.TrimRight(line, "\r\n"), s.lookup)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	if s.xtrace {
+		s.printf("+ %s\n", strings.Join(args, " "))
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "exit", "quit":
+		return exitStatus(args[1:])
+	case "help":
+		return s.help(ctx, args[1:])
+	case "use":
+		return s.use(ctx, args[1:])
+	case "set":
+		return s.set(args[1:])
+	case "echo":
+		s.printf("%s\n", strings.Join(args[1:], " "))
+		return nil
+	case "sleep":
+		return sleep(ctx, args[1:])
+	}
+
+	command, ok := s.command(args[0])
+	if !ok {
+		return unknownCommandError(args[0])
+	}
+
+	if err := s.runCommand(ctx, command, args[1:]); err != nil {
+		return fmt.Errorf("%s: %w", command.Name, err)
+	}
+	return nil
+}
+
+func exitStatus(args []string) error {
+	if len(args) == 0 {
+		return &ExitError{}
+	}
+	code, err := strconv.Atoi(args[0])
+	if err != nil || len(args) > 1 || code < 0 || code > 255 {
+		return errors.New("usage: exit [<status 0-255>]")
+	}
+	return &ExitError{Code: code}
+}
+
+// sleep waits for a duration such as 500ms or 2s; a bare number is seconds.
+func sleep(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: sleep <duration>")
+	}
+	d, err := time.ParseDuration(args[0])
+	if seconds, convErr := strconv.ParseFloat(args[0], 64); err != nil && convErr == nil {
+		d, err = time.Duration(seconds*float64(time.Second)), nil
+	}
+	if err != nil || d < 0 {
+		return fmt.Errorf("sleep: invalid duration %q", args[0])
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// set assigns a variable (set <name> <value>...), lists them (set), or
+// toggles the -e and -x options.
+func (s *Shell) set(args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.vars))
+		for name := range s.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s.printf("%s=%s\n", name, s.vars[name])
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "-e", "+e":
+		s.errexit = args[0] == "-e"
+		return nil
+	case "-x", "+x":
+		s.xtrace = args[0] == "-x"
+		return nil
+	}
+
+	if !validName(args[0]) {
+		return fmt.Errorf("set: invalid variable name %q", args[0])
+	}
+	s.vars[args[0]] = strings.Join(args[1:], " ")
+	return nil
+}
+
+// lookup resolves $name from the shell's variables, then the environment.
+func (s *Shell) lookup(name string) (string, error) {
+	if value, ok := s.vars[name]; ok {
+		return value, nil
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("undefined variable $%s", name)
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Shell) runCommand(ctx context.Context, command Command, args []string) error {
+	cmdCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	s.cancel = cancel
+	env := Env{Adapter: s.adapter, Out: lockedWriter{s}, Interactive: s.interactive}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+	}()
+
+	return command.Run(cmdCtx, env, args)
+}
+
+func (s *Shell) command(name string) (Command, bool) {
+	for i := len(s.commands) - 1; i >= 0; i-- {
+		if s.commands[i].Name == name {
+			return s.commands[i], true
+		}
+	}
+	return Command{}, false
+}
+
+// use prints or changes the adapter commands run against. "none" goes back
+// to the default adapter selection.
+func (s *Shell) use(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: use [<adapter>|none]")
+	}
+
+	if len(args) == 0 {
+		if adapter := s.Adapter(); adapter != "" {
+			s.printf("Using adapter %s.\n", adapter)
+		} else {
+			s.printf("Using the default adapter.\n")
+		}
+		return nil
+	}
+
+	adapter := strings.TrimSpace(args[0])
+	if strings.EqualFold(adapter, "none") || adapter == "-" {
+		adapter = ""
+	} else if s.resolve != nil {
+		resolved, err := s.resolve(ctx, adapter)
+		if err != nil {
+			return fmt.Errorf("use: %w", err)
+		}
+		adapter = resolved
+	}
+
+	s.mu.Lock()
+	s.adapter = adapter
+	s.mu.Unlock()
+
+	if adapter == "" {
+		s.printf("Using the default adapter.\n")
+	} else {
+		s.printf("Using adapter %s.\n", adapter)
+	}
+	return nil
+}
+
+func (s *Shell) help(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		if command, ok := s.command(args[0]); ok {
+			if err := s.runCommand(ctx, command, []string{"--help"}); err != nil {
+				return fmt.Errorf("%s: %w", command.Name, err)
+			}
+			return nil
 		}
 	}
+	s.writeHelp()
+	return nil
 }
 
 func (s *Shell) writeHelp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	fmt.Fprintf(s.writer, "Available commands:\n")
-	fmt.Fprintf(s.writer, "  help  - show this message\n")
-	fmt.Fprintf(s.writer, "  exit  - leave the shell\n")
-	fmt.Fprintf(s.writer, "  quit  - alias for exit\n")
+	for _, command := range s.listed() {
+		fmt.Fprintf(s.writer, "  %-10s - %s\n", command.Name, command.Summary)
+	}
+	fmt.Fprintf(s.writer, "  %-10s - run commands against an adapter (none for the default)\n", "use [<id>]")
+	fmt.Fprintf(s.writer, "  %-10s - set a variable used as $name, or the -e and -x options\n", "set")
+	fmt.Fprintf(s.writer, "  %-10s - print the arguments\n", "echo")
+	fmt.Fprintf(s.writer, "  %-10s - wait for a duration such as 2s\n", "sleep")
+	fmt.Fprintf(s.writer, "  %-10s - show this message, or a command's usage\n", "help")
+	fmt.Fprintf(s.writer, "  %-10s - leave the shell\n", "exit")
+	fmt.Fprintf(s.writer, "  %-10s - alias for exit\n", "quit")
+}
+
+// listed returns the registered commands in registration order, skipping
+// ones replaced by a later registration.
+func (s *Shell) listed() []Command {
+	seen := make(map[string]bool)
+	var listed []Command
+	for _, command := range s.commands {
+		if seen[command.Name] {
+			continue
+		}
+		seen[command.Name] = true
+		latest, _ := s.command(command.Name)
+		listed = append(listed, latest)
+	}
+	return listed
+}
+
+func (s *Shell) printEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-s.events:
+			if !ok {
+				return
+			}
+			s.printEvent(line)
+		}
+	}
+}
+
+// printEvent prints line above the prompt. On a terminal the prompt is
+// cleared and drawn again below the line; input typed so far stays with the
+// terminal and is still submitted with the next Enter.
+func (s *Shell) printEvent(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line = strings.TrimRight(line, "\n")
+	switch {
+	case s.atPrompt && s.editor != nil:
+		s.editor.PrintAbove(line)
+	case !s.atPrompt:
+		fmt.Fprintf(s.writer, "%s\n", line)
+	case s.terminal:
+		fmt.Fprintf(s.writer, "\r\x1b[K%s\n%s", line, s.promptLocked())
+	default:
+		fmt.Fprintf(s.writer, "\n%s\n%s", line, s.promptLocked())
+	}
+}
+
+// showPrompt prints the prompt, unless the editor draws it.
+func (s *Shell) showPrompt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.editor == nil {
+		fmt.Fprint(s.writer, s.promptLocked())
+	}
+	s.atPrompt = true
+}
+
+func (s *Shell) currentPrompt() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.promptLocked()
+}
+
+func (s *Shell) leavePrompt() {
+	s.mu.Lock()
+	s.atPrompt = false
+	s.mu.Unlock()
+}
+
+// promptLocked returns the prompt, naming the adapter in use when the prompt
+// has the default "> " shape.
+func (s *Shell) promptLocked() string {
+	if s.adapter != "" && strings.HasSuffix(s.prompt, "> ") {
+		return strings.TrimSuffix(s.prompt, "> ") + "[" + s.adapter + "]> "
+	}
+	return s.prompt
+}
+
+func (s *Shell) printf(format string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.writer, format, args...)
+}
+
+// lockedWriter serializes command output with event lines.
+type lockedWriter struct {
+	s *Shell
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	return w.s.writer.Write(p)
+}
+
+// SplitLine splits a command line into words. Words are separated by
+// whitespace; single quotes keep their contents literally, double quotes
+// allow backslash escapes, and a backslash outside quotes escapes the next
+// character. A # at the start of a word begins a comment.
+func SplitLine(line string) ([]string, error) {
+	return splitWords(line, nil)
+}
+
+// splitWords is SplitLine with $name and ${name} outside single quotes
+// replaced by lookup, when it is not nil. Expanded values are not split.
+func splitWords(line string, lookup func(name string) (string, error)) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '$' && lookup != nil:
+			name, next := variableName(runes, i+1)
+			if name == "" {
+				word.WriteRune(r)
+				inWord = true
+				continue
+			}
+			value, err := lookup(name)
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(value)
+			inWord = true
+			i = next - 1
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord:
+			return words, nil
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// variableName reads the variable name at runes[start:], written as name or
+// {name}, and returns it with the index after it. The name is empty when
+// none follows.
+func variableName(runes []rune, start int) (string, int) {
+	braced := start < len(runes) && runes[start] == '{'
+	i := start
+	if braced {
+		i++
+	}
+	begin := i
+	for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || (i > begin && unicode.IsDigit(runes[i]))) {
+		i++
+	}
+	name := string(runes[begin:i])
+	if braced {
+		if name == "" || i >= len(runes) || runes[i] != '}' {
+			return "", start
+		}
+		i++
+	}
+	return name, i
 }
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -87,3 +89,269 @@ func TestShellRunTwice(t *testing.T) {
 		t.Fatal("expected error on second run but got nil")
 	}
 }
+
+func TestShellDispatchesCommands(t *testing.T) {
+	input := strings.NewReader("devices connect \"My Headphones\"\nuse hci1\ndevices list\nstatus\nuse none\nexit\n")
+	var output bytes.Buffer
+
+	var calls []string
+	record := func(_ context.Context, env Env, args []string) error {
+		calls = append(calls, env.Adapter+":"+strings.Join(args, "|"))
+		return nil
+	}
+	shell := NewShell(input, &output,
+		WithCommands(
+			Command{Name: "devices", Summary: "manage devices", Run: record},
+			Command{Name: "status", Summary: "show status", Run: func(context.Context, Env, []string) error {
+				return errors.New("daemon unavailable")
+			}},
+		),
+		WithAdapterResolver(func(_ context.Context, adapter string) (string, error) {
+			return adapter, nil
+		}),
+	)
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatalf("shell.Run returned error: %v", err)
+	}
+
+	want := []string{":connect|My Headphones", "hci1:list"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected calls %q, want %q", calls, want)
+	}
+
+	out := output.String()
+	for _, expected := range []string{"Using adapter hci1.", "peared[hci1]> ", "status: daemon unavailable", "Using the default adapter."} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output, got: %q", expected, out)
+		}
+	}
+}
+
+func TestShellUseRejectsUnknownAdapter(t *testing.T) {
+	input := strings.NewReader("use hci9\nuse\nexit\n")
+	var output bytes.Buffer
+
+	shell := NewShell(input, &output, WithAdapterResolver(func(_ context.Context, adapter string) (string, error) {
+		return "", errors.New("adapter \"" + adapter + "\" is not present")
+	}))
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatalf("shell.Run returned error: %v", err)
+	}
+
+	out := output.String()
+	if !strings.Contains(out, "use: adapter \"hci9\" is not present") || !strings.Contains(out, "Using the default adapter.") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if shell.Adapter() != "" {
+		t.Fatalf("expected no adapter, got %q", shell.Adapter())
+	}
+}
+
+func TestShellPrintsEventsAbovePrompt(t *testing.T) {
+	r, w := io.Pipe()
+	var output syncBuffer
+
+	events := make(chan string, 1)
+	shell := NewShell(r, &output, WithEvents(events), WithTerminal(true))
+
+	done := make(chan error)
+	go func() {
+		done <- shell.Run(context.Background())
+	}()
+
+	waitFor(t, &output, "peared> ")
+	events <- "device 11:11:11:11:11:11 connected"
+	waitFor(t, &output, "\r\x1b[Kdevice 11:11:11:11:11:11 connected\npeared> ")
+
+	if _, err := io.WriteString(w, "exit\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("shell.Run returned error: %v", err)
+	}
+}
+
+func TestShellInterruptCancelsCommand(t *testing.T) {
+	r, w := io.Pipe()
+	var output syncBuffer
+
+	started := make(chan struct{})
+	shell := NewShell(r, &output, WithCommands(Command{Name: "events", Run: func(ctx context.Context, _ Env, _ []string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}))
+
+	if shell.Interrupt() {
+		t.Fatal("expected nothing to interrupt before a command runs")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- shell.Run(context.Background())
+	}()
+
+	go io.WriteString(w, "events\n")
+	<-started
+	if !shell.Interrupt() {
+		t.Fatal("expected the running command to be interrupted")
+	}
+
+	waitFor(t, &output, "events: context canceled\npeared> ")
+	if _, err := io.WriteString(w, "quit\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("shell.Run returned error: %v", err)
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := map[string][]string{
+		"devices connect AA:BB":               {"devices", "connect", "AA:BB"},
+		`devices connect "My Headphones"`:     {"devices", "connect", "My Headphones"},
+		`adapters alias hci0 'Desk "dongle"'`: {"adapters", "alias", "hci0", `Desk "dongle"`},
+		`say My\ Headphones "a\"b"`:           {"say", "My Headphones", `a"b`},
+		"status # show the daemon":            {"status"},
+		"  # just a comment":                  nil,
+		`empty ""`:                            {"empty", ""},
+	}
+	for line, want := range tests {
+		got, err := SplitLine(line)
+		if err != nil {
+			t.Errorf("SplitLine(%q) returned error: %v", line, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") || len(got) != len(want) {
+			t.Errorf("SplitLine(%q) = %q, want %q", line, got, want)
+		}
+	}
+
+	if _, err := SplitLine(`devices connect "unterminated`); err == nil {
+		t.Fatal("expected an error for an unterminated quote")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the shell's concurrent writers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, b *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(b.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, got: %q", want, b.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShellReadsWithEditor(t *testing.T) {
+	var output bytes.Buffer
+	var calls []string
+
+	editor := newEditor(strings.NewReader("stat\t\rexit\r"), &output, noRawMode, WithCompleter(func([]string, string) []string {
+		return []string{"status"}
+	}))
+	shell := NewShell(strings.NewReader(""), &output, WithEditor(editor), WithCommands(Command{Name: "status", Run: func(_ context.Context, _ Env, args []string) error {
+		calls = append(calls, "status")
+		return nil
+	}}))
+	if err := shell.Run(context.Background()); err != nil {
+		t.Fatalf("shell.Run returned error: %v", err)
+	}
+
+	if len(calls) != 1 {
+		t.Fatalf("expected the completed command to run once, got %v", calls)
+	}
+	if !strings.Contains(output.String(), "Goodbye!") {
+		t.Fatalf("expected goodbye message, got: %q", output.String())
+	}
+}
+
+func TestShellRunScript(t *testing.T) {
+	script := `# pair the desk speaker
+set speaker "Desk speaker"
+set attempts 2
+devices connect "$speaker" --retries ${attempts}
+echo connected $speaker at '$HOME'
+sleep 10ms
+devices fail
+echo not reached
+`
+	var output bytes.Buffer
+	var calls []string
+	shell := NewShell(strings.NewReader(""), &output, WithCommands(Command{Name: "devices", Run: func(_ context.Context, env Env, args []string) error {
+		if env.Interactive {
+			t.Errorf("script commands must not be interactive")
+		}
+		calls = append(calls, strings.Join(args, "|"))
+		if args[0] == "fail" {
+			return fmt.Errorf("%w: exit status 1", ErrFailed)
+		}
+		return nil
+	}}))
+
+	err := shell.RunScript(context.Background(), strings.NewReader(script), "pair.peared")
+	if err == nil || !errors.Is(err, ErrFailed) || !strings.HasPrefix(err.Error(), "pair.peared:7: devices: ") {
+		t.Fatalf("expected the failure on line 7, got %v", err)
+	}
+	if strings.Join(calls, ",") != "connect|Desk speaker|--retries|2,fail" {
+		t.Fatalf("unexpected calls %q", calls)
+	}
+	out := output.String()
+	if !strings.Contains(out, "connected Desk speaker at $HOME\n") || strings.Contains(out, "not reached") || strings.Contains(out, "Welcome") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestShellRunScriptOptions(t *testing.T) {
+	script := "set +e\nbogus\nset -x\necho $UNDEFINED_PEARED_VARIABLE\necho still running\nexit 3\necho not reached\n"
+	var output bytes.Buffer
+
+	shell := NewShell(strings.NewReader(""), &output)
+	err := shell.RunScript(context.Background(), strings.NewReader(script), "test.peared")
+
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	out := output.String()
+	for _, expected := range []string{
+		"test.peared:2: unknown command \"bogus\"\n",
+		"test.peared:4: undefined variable $UNDEFINED_PEARED_VARIABLE\n",
+		"+ echo still running\nstill running\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output, got %q", expected, out)
+		}
+	}
+	if strings.Contains(out, "not reached") {
+		t.Fatalf("script continued after exit: %q", out)
+	}
+}
+
+func TestShellRunScriptStopsOnSleepCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	shell := NewShell(strings.NewReader(""), io.Discard)
+	if err := shell.RunScript(ctx, strings.NewReader("sleep 1m\n"), "-"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
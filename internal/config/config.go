@@ -1,29 +1,253 @@
 package config
 
 import (
-	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the on-disk configuration for the daemon and ancillary tools.
 type Config struct {
-	// Source tracks the path used to load the configuration. It is informational only.
+	// Source tracks the path of the user configuration file, whether or not
+	// it exists. It is informational only.
 	Source string `yaml:"-"`
 
-	// Loaded reports whether the configuration file existed and was decoded.
+	// Loaded reports whether any configuration file existed and was decoded.
 	Loaded bool `yaml:"-"`
 
+	// Files lists the configuration files that were read, in the order
+	// they were applied.
+	Files []string `yaml:"-"`
+
 	Daemon DaemonConfig `yaml:"daemon"`
+
+	// Adapters holds properties applied to adapters when they appear, keyed
+	// by adapter ID, address or alias.
+	Adapters map[string]AdapterConfig `yaml:"adapters"`
+
+	// Devices holds per-device settings keyed by MAC address.
+	Devices map[string]DeviceConfig `yaml:"devices"`
+
+	// origins records which layer set each setting.
+	origins map[string]Origin
 }
 
 // DaemonConfig holds daemon-specific options from the configuration file.
 type DaemonConfig struct {
 	PreferredAdapter string `yaml:"preferred_adapter"`
+
+	// PollInterval controls how often the daemon refreshes device state.
+	PollInterval time.Duration `yaml:"poll_interval"`
+
+	// BatteryLow is the charge in percent at or below which pearedd reports
+	// a device's battery as low. Zero selects 20.
+	BatteryLow int `yaml:"battery_low"`
+
+	// Reconnect is the default reconnection policy applied to trusted devices
+	// that have no entry under devices.
+	Reconnect ReconnectConfig `yaml:"reconnect"`
+
+	// ConnectionLimits caps simultaneous connections per device kind
+	// (headset, speaker, keyboard, mouse). Kinds without an entry are
+	// unlimited.
+	ConnectionLimits map[string]int `yaml:"connection_limits"`
+
+	// Failover controls what happens when the active adapter disappears.
+	Failover FailoverConfig `yaml:"failover"`
+
+	// AdapterSelection ranks adapters when preferred_adapter is unset or
+	// absent. The CLI applies the same policy when choosing an adapter.
+	AdapterSelection SelectionConfig `yaml:"adapter_selection"`
+
+	// Watchdog probes the active adapter and resets it when it wedges.
+	Watchdog WatchdogConfig `yaml:"watchdog"`
+
+	// Suspend controls how devices are handled around system suspend.
+	Suspend SuspendConfig `yaml:"suspend"`
+
+	// Agent configures the pairing agent pearedd registers with BlueZ.
+	Agent AgentConfig `yaml:"agent"`
+}
+
+// AgentConfig holds pairing agent settings.
+type AgentConfig struct {
+	// Enabled registers the agent. It defaults to on.
+	Enabled *bool `yaml:"enabled"`
+
+	// Capability is DisplayOnly, DisplayYesNo, KeyboardOnly,
+	// NoInputNoOutput or KeyboardDisplay (default).
+	Capability string `yaml:"capability"`
+
+	// Default makes pearedd BlueZ's default agent, answering pairings
+	// started without an agent of their own. It defaults to on.
+	Default *bool `yaml:"default"`
+
+	// Timeout bounds how long a prompt waits for an answer.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Notifications also offers prompts as desktop notifications.
+	Notifications bool `yaml:"notifications"`
+}
+
+// SuspendConfig holds suspend and resume settings.
+type SuspendConfig struct {
+	// Enabled turns suspend handling on or off. It defaults to on.
+	Enabled *bool `yaml:"enabled"`
+
+	// Devices is what happens to connected devices before suspend: leave
+	// (default) or disconnect. Devices can override it individually.
+	Devices string `yaml:"devices"`
+}
+
+// WatchdogConfig holds the adapter health watchdog settings. Zero values
+// select the daemon defaults.
+type WatchdogConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval is the time between health probes.
+	Interval time.Duration `yaml:"interval"`
+
+	// ProbeTimeout bounds how long BlueZ may take to answer a probe.
+	ProbeTimeout time.Duration `yaml:"probe_timeout"`
+
+	// Threshold is how many probes in a row must fail before recovering.
+	Threshold int `yaml:"threshold"`
+
+	// ConnectTimeouts is how many consecutive device connections may time
+	// out before the adapter is considered wedged.
+	ConnectTimeouts int `yaml:"connect_timeouts"`
+
+	// Levels is the escalation sequence (soft, service, module, usb).
+	Levels []string `yaml:"levels"`
+
+	// MaxRecoveries caps how many recoveries may start within Window.
+	MaxRecoveries int           `yaml:"max_recoveries"`
+	Window        time.Duration `yaml:"window"`
+
+	// AuditLog overrides where recovery attempts are recorded.
+	AuditLog string `yaml:"audit_log"`
+}
+
+// SelectionConfig describes the adapter selection policy. Each rule is a set
+// of fields (id, address, alias, transport, vendor_id, driver) that must all
+// match; id, alias and driver accept globs.
+type SelectionConfig struct {
+	// Prefer lists rules in order of preference.
+	Prefer []map[string]string `yaml:"prefer"`
+
+	// Exclude lists adapters that must never be selected.
+	Exclude []map[string]string `yaml:"exclude"`
+
+	// Tiebreak orders adapters that match equally: usb (default), first,
+	// powered or id.
+	Tiebreak string `yaml:"tiebreak"`
+}
+
+// FailoverConfig holds adapter failover settings.
+type FailoverConfig struct {
+	// ReconnectDevices reconnects devices that were connected through the
+	// lost adapter when they are also bonded with the replacement.
+	ReconnectDevices bool `yaml:"reconnect_devices"`
+}
+
+// AdapterConfig holds the properties pearedd applies to an adapter. Unset
+// switches leave the adapter as BlueZ configured it.
+type AdapterConfig struct {
+	Powered      *bool  `yaml:"powered"`
+	Alias        string `yaml:"alias"`
+	Discoverable *bool  `yaml:"discoverable"`
+	Pairable     *bool  `yaml:"pairable"`
+
+	DiscoverableTimeout time.Duration `yaml:"discoverable_timeout"`
+	PairableTimeout     time.Duration `yaml:"pairable_timeout"`
+}
+
+// DeviceConfig holds settings for a single device.
+type DeviceConfig struct {
+	// Nickname is a short name the CLI accepts in place of the address.
+	Nickname string `yaml:"nickname"`
+
+	// Kind overrides the detected device kind used for arbitration.
+	Kind string `yaml:"kind"`
+
+	// Priority ranks the device against others of the same kind; higher
+	// values win.
+	Priority int `yaml:"priority"`
+
+	// Adapter pins the device to a controller identified by ID, address or
+	// alias. Pairing and connections are routed through it.
+	Adapter string `yaml:"adapter"`
+
+	// Suspend overrides daemon.suspend.devices for this device.
+	Suspend string `yaml:"suspend"`
+
+	// AutoAccept answers pairing confirmations and authorizations from the
+	// device without prompting.
+	AutoAccept bool `yaml:"auto_accept"`
+
+	// Reconnect is the auto-connect policy: whether and when pearedd
+	// connects the device while it is disconnected.
+	Reconnect ReconnectConfig `yaml:"reconnect"`
+
+	Audio AudioConfig `yaml:"audio"`
+	Hooks HooksConfig `yaml:"hooks"`
+}
+
+// AudioConfig holds the audio preferences applied when the device
+// connects. Unset fields leave the sound server's choice alone.
+type AudioConfig struct {
+	// Profile is one of a2dp, headset or off.
+	Profile string `yaml:"profile"`
+
+	// Codec is a codec such as aac or ldac. Without a profile it selects
+	// the profile it belongs to.
+	Codec string `yaml:"codec"`
+
+	// Volume is the output volume in percent.
+	Volume *int `yaml:"volume"`
+}
+
+// HooksConfig holds shell commands run when the device connects or
+// disconnects.
+type HooksConfig struct {
+	Connect    string `yaml:"connect"`
+	Disconnect string `yaml:"disconnect"`
+}
+
+// ReconnectConfig describes when the daemon reconnects a dropped device. Zero
+// values inherit from the daemon-level reconnect block and then from built-in
+// defaults.
+type ReconnectConfig struct {
+	// Policy is one of always, when-in-range or never.
+	Policy string `yaml:"policy"`
+
+	// Windows limits reconnection to times such as "mon-fri 08:00-18:00".
+	Windows []string `yaml:"windows"`
+
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+}
+
+// Device returns the settings for the device with the given address. Keys are
+// matched case-insensitively because MAC addresses are written both ways.
+func (c *Config) Device(address string) (DeviceConfig, bool) {
+	address = strings.TrimSpace(address)
+	if device, ok := c.Devices[address]; ok {
+		return device, true
+	}
+
+	for key, device := range c.Devices {
+		if strings.EqualFold(strings.TrimSpace(key), address) {
+			return device, true
+		}
+	}
+
+	return DeviceConfig{}, false
 }
 
 // ResolvePath determines the configuration path to use. Explicit paths are honored first,
@@ -45,8 +269,20 @@ func ResolvePath(explicit string) (string, error) {
 	return filepath.Join(configDir, "peared", "config.yaml"), nil
 }
 
-// Load reads configuration from disk, returning default values if the file does not exist.
-func Load(path string) (*Config, error) {
+// Load reads the configuration layers, lowest precedence first: the system
+// file, the user file at path (see ResolvePath), the *.yaml drop-ins in the
+// config.d directory beside it in lexical order, PEARED_* environment
+// variables and finally overrides. Mappings are merged key by key; any other
+// value replaces the one below it. Defaults apply when no file exists.
+// Unknown fields, values of the wrong type and settings Validate rejects are
+// reported together as a *ValidationError.
+func Load(path string, overrides ...Override) (*Config, error) {
+	return load(path, nil, overrides)
+}
+
+// load is Load with the user file's content replaced by user when it is not
+// nil, so changes can be checked before they are written.
+func load(path string, user []byte, overrides []Override) (*Config, error) {
 	resolved, err := ResolvePath(path)
 	if err != nil {
 		return nil, err
@@ -54,19 +290,35 @@ func Load(path string) (*Config, error) {
 
 	cfg := &Config{Source: resolved}
 
-	data, err := os.ReadFile(resolved)
+	layers, err := readLayers(cfg, user, overrides)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return cfg, nil
-		}
-		return nil, fmt.Errorf("read config %q: %w", resolved, err)
+		return nil, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("decode config %q: %w", resolved, err)
+	var problems []Problem
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	cfg.origins = make(map[string]Origin)
+	for _, l := range layers {
+		if l.problem != nil {
+			problems = append(problems, *l.problem)
+			continue
+		}
+		origins, layerProblems := check(l)
+		problems = append(problems, layerProblems...)
+		merge(merged, l.root, "", origins, cfg.origins)
+	}
+	if len(problems) == 0 {
+		if err := merged.Decode(cfg); err != nil {
+			problems = append(problems, syntaxProblem(resolved, err))
+		}
+	}
+	if len(problems) == 0 {
+		problems = cfg.Validate()
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Source: resolved, Problems: problems}
 	}
 
-	cfg.Source = resolved
-	cfg.Loaded = true
+	cfg.Loaded = len(cfg.Files) > 0
 	return cfg, nil
 }
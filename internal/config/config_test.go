@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/peared/peared/config/examples"
 )
 
 func TestResolvePath(t *testing.T) {
@@ -67,3 +71,160 @@ func TestLoadExistingFile(t *testing.T) {
 		t.Fatalf("unexpected PreferredAdapter: %q", cfg.Daemon.PreferredAdapter)
 	}
 }
+
+func TestLoadDeviceSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := `daemon:
+  reconnect:
+    policy: when-in-range
+    max_attempts: 5
+  connection_limits:
+    headset: 1
+  failover:
+    reconnect_devices: true
+  adapter_selection:
+    prefer:
+      - alias: "Gaming*"
+        transport: usb
+    exclude:
+      - vendor_id: "8087"
+    tiebreak: powered
+  watchdog:
+    enabled: true
+    interval: 1m
+    levels: [soft, module]
+    max_recoveries: 2
+  suspend:
+    devices: disconnect
+  agent:
+    capability: DisplayYesNo
+    default: false
+    timeout: 45s
+    notifications: true
+adapters:
+  hci0:
+    alias: Desk
+    discoverable: false
+    pairable: true
+    pairable_timeout: 2m
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+    kind: headset
+    priority: 10
+    adapter: hci1
+    suspend: leave
+    auto_accept: true
+    reconnect:
+      policy: always
+      windows: ["mon-fri 08:00-18:00"]
+      initial_backoff: 3s
+      max_backoff: 1m
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Daemon.Reconnect.Policy != "when-in-range" || cfg.Daemon.Reconnect.MaxAttempts != 5 {
+		t.Fatalf("unexpected daemon reconnect config: %+v", cfg.Daemon.Reconnect)
+	}
+
+	device, ok := cfg.Device("aa:bb:cc:dd:ee:ff")
+	if !ok {
+		t.Fatalf("expected device entry, got %v", cfg.Devices)
+	}
+
+	if device.Nickname != "cans" {
+		t.Fatalf("unexpected nickname %q", device.Nickname)
+	}
+
+	if cfg.Daemon.ConnectionLimits["headset"] != 1 {
+		t.Fatalf("unexpected connection limits: %v", cfg.Daemon.ConnectionLimits)
+	}
+
+	if !cfg.Daemon.Failover.ReconnectDevices {
+		t.Fatalf("expected failover device reconnection to be enabled")
+	}
+
+	selection := cfg.Daemon.AdapterSelection
+	if len(selection.Prefer) != 1 || selection.Prefer[0]["alias"] != "Gaming*" || selection.Prefer[0]["transport"] != "usb" {
+		t.Fatalf("unexpected prefer rules: %v", selection.Prefer)
+	}
+	if len(selection.Exclude) != 1 || selection.Exclude[0]["vendor_id"] != "8087" || selection.Tiebreak != "powered" {
+		t.Fatalf("unexpected selection config: %+v", selection)
+	}
+
+	if cfg.Daemon.Suspend.Enabled != nil || cfg.Daemon.Suspend.Devices != "disconnect" || device.Suspend != "leave" {
+		t.Fatalf("unexpected suspend config: %+v, device %q", cfg.Daemon.Suspend, device.Suspend)
+	}
+
+	agent := cfg.Daemon.Agent
+	if agent.Enabled != nil || agent.Capability != "DisplayYesNo" || agent.Default == nil || *agent.Default || agent.Timeout != 45*time.Second || !agent.Notifications || !device.AutoAccept {
+		t.Fatalf("unexpected agent config: %+v, device auto_accept %v", agent, device.AutoAccept)
+	}
+
+	watchdog := cfg.Daemon.Watchdog
+	if !watchdog.Enabled || watchdog.Interval != time.Minute || len(watchdog.Levels) != 2 || watchdog.MaxRecoveries != 2 {
+		t.Fatalf("unexpected watchdog config: %+v", watchdog)
+	}
+
+	adapter := cfg.Adapters["hci0"]
+	if adapter.Alias != "Desk" || adapter.Powered != nil || adapter.Discoverable == nil || *adapter.Discoverable {
+		t.Fatalf("unexpected adapter config: %+v", adapter)
+	}
+	if adapter.Pairable == nil || !*adapter.Pairable || adapter.PairableTimeout != 2*time.Minute {
+		t.Fatalf("unexpected pairable config: %+v", adapter)
+	}
+
+	if device.Kind != "headset" || device.Priority != 10 || device.Adapter != "hci1" {
+		t.Fatalf("unexpected kind/priority/adapter: %q/%d/%q", device.Kind, device.Priority, device.Adapter)
+	}
+
+	if device.Reconnect.Policy != "always" {
+		t.Fatalf("unexpected policy: %q", device.Reconnect.Policy)
+	}
+	if device.Reconnect.InitialBackoff != 3*time.Second || device.Reconnect.MaxBackoff != time.Minute {
+		t.Fatalf("unexpected backoff: %+v", device.Reconnect)
+	}
+	if len(device.Reconnect.Windows) != 1 || device.Reconnect.Windows[0] != "mon-fri 08:00-18:00" {
+		t.Fatalf("unexpected windows: %v", device.Reconnect.Windows)
+	}
+}
+
+func TestExampleDevicesBlock(t *testing.T) {
+	// The devices block of the example is commented out; uncomment it the
+	// way the example asks to.
+	lines := strings.Split(string(examples.Minimal), "\n")
+	uncommenting := false
+	for i, line := range lines {
+		if line == "#devices:" {
+			uncommenting = true
+		} else if !strings.HasPrefix(line, "#  ") {
+			uncommenting = false
+		}
+		if uncommenting {
+			lines[i] = strings.TrimPrefix(line, "#")
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	device, ok := cfg.Device("11:22:33:44:55:66")
+	if !ok || device.Nickname != "headphones" || device.Audio.Codec != "aac" || *device.Audio.Volume != 50 ||
+		device.Hooks.Connect == "" || device.Reconnect.Policy != "when-in-range" {
+		t.Fatalf("unexpected example device %+v", device)
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a configuration file held as YAML nodes, so settings can be
+// changed without losing the comments and key order around them.
+type Document struct {
+	root yaml.Node
+}
+
+// ParseDocument parses the content of a configuration file.
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.Unmarshal(data, &doc.root); err != nil {
+		return nil, err
+	}
+	if len(doc.root.Content) == 0 {
+		doc.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the configuration must be a mapping")
+	}
+	return doc, nil
+}
+
+// ReadDocument reads the configuration file at path. A missing file reads as
+// an empty document.
+func ReadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read config %q: %w", path, err)
+	}
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %q: %w", path, err)
+	}
+	return doc, nil
+}
+
+// Set changes the setting at path, such as "daemon.poll_interval", to value,
+// which is parsed as YAML like an environment variable. Missing mappings on
+// the way are added at the end of their parent. The comments and, for
+// strings, the quoting of the value replaced are kept.
+func (d *Document) Set(path, value string) error {
+	node, err := valueNode(value)
+	if err != nil {
+		return err
+	}
+
+	keys := strings.Split(path, ".")
+	parent := d.root.Content[0]
+	for i, key := range keys {
+		if key == "" {
+			return fmt.Errorf("invalid setting %q", path)
+		}
+
+		index := -1
+		for j := 0; j+1 < len(parent.Content); j += 2 {
+			if parent.Content[j].Value == key {
+				index = j + 1
+				break
+			}
+		}
+
+		if i == len(keys)-1 {
+			if index < 0 {
+				parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+				return nil
+			}
+			previous := parent.Content[index]
+			node.HeadComment, node.LineComment, node.FootComment = previous.HeadComment, previous.LineComment, previous.FootComment
+			if node.Kind == yaml.ScalarNode && previous.Kind == yaml.ScalarNode && node.Tag == "!!str" && previous.Tag == "!!str" {
+				node.Style = previous.Style
+			}
+			parent.Content[index] = node
+			return nil
+		}
+
+		if index < 0 {
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+			parent = child
+			continue
+		}
+		child := parent.Content[index]
+		if child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: child.LineComment}
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(keys[:i+1], "."))
+		}
+		parent = child
+	}
+	return nil
+}
+
+// Comment sets the comment written after the setting at path. It does
+// nothing when the setting is not in the document.
+func (d *Document) Comment(path, comment string) {
+	node := d.root.Content[0]
+	for _, key := range strings.Split(path, ".") {
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			return
+		}
+		node = next
+	}
+	node.LineComment = comment
+}
+
+// Bytes writes the document out as YAML.
+func (d *Document) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&d.root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Save replaces the user configuration file at path (see ResolvePath) with
+// data once the configuration it results in, together with the other
+// layers, loads without problems; otherwise nothing is written and the
+// problems are returned as a *ValidationError. The file is replaced
+// atomically and keeps its permissions; new files are private to the user.
+func Save(path string, data []byte) (*Config, error) {
+	cfg, err := load(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(cfg.Source)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create config directory: %w", err)
+	}
+
+	perm := fs.FileMode(0o600)
+	if info, err := os.Stat(cfg.Source); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, ".config-*")
+	if err != nil {
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+
+	if err := os.Rename(tmp.Name(), cfg.Source); err != nil {
+		return nil, fmt.Errorf("write config %q: %w", cfg.Source, err)
+	}
+	return cfg, nil
+}
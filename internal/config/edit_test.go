@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/config/examples"
+)
+
+func TestDocumentSetKeepsCommentsAndOrder(t *testing.T) {
+	doc, err := ParseDocument([]byte(`# Peared configuration.
+
+daemon:
+  # The adapter to use first.
+  preferred_adapter: "hci0" # the laptop's own
+  poll_interval: 5s
+
+# Headphones and friends.
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+`))
+	if err != nil {
+		t.Fatalf("ParseDocument: %v", err)
+	}
+
+	for _, change := range [][2]string{
+		{"daemon.preferred_adapter", "hci1"},
+		{"daemon.reconnect.policy", "always"},
+		{"devices.AA:BB:CC:DD:EE:FF.priority", "5"},
+		{"daemon.watchdog.levels", "[soft, service]"},
+	} {
+		if err := doc.Set(change[0], change[1]); err != nil {
+			t.Fatalf("Set %s: %v", change[0], err)
+		}
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	want := `# Peared configuration.
+
+daemon:
+  # The adapter to use first.
+  preferred_adapter: "hci1" # the laptop's own
+  poll_interval: 5s
+  reconnect:
+    policy: always
+  watchdog:
+    levels: [soft, service]
+# Headphones and friends.
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+    priority: 5
+`
+	if string(data) != want {
+		t.Fatalf("unexpected document:\n%s\nwant:\n%s", data, want)
+	}
+
+	if err := doc.Set("daemon.poll_interval.seconds", "5"); err == nil || !strings.Contains(err.Error(), "daemon.poll_interval is not a mapping") {
+		t.Fatalf("expected an error setting below a value, got %v", err)
+	}
+}
+
+func TestSaveValidatesBeforeWriting(t *testing.T) {
+	path := layeredFiles(t, "", "daemon:\n  poll_interval: 5s\n", nil)
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	_, err := Save(path, []byte("daemon:\n  poll_interval: 5\n"))
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Problems) != 1 || invalid.Problems[0].Line != 2 {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "daemon:\n  poll_interval: 5s\n" {
+		t.Fatalf("invalid configuration was written: %q", data)
+	}
+
+	cfg, err := Save(path, []byte("daemon:\n  poll_interval: 10s\n"))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if cfg.Daemon.PollInterval != 10*time.Second {
+		t.Fatalf("unexpected config %+v", cfg.Daemon)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("expected permissions to be kept, got %v (%v)", info.Mode(), err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+
+	fresh := filepath.Join(t.TempDir(), "new", "config.yaml")
+	if _, err := Save(fresh, examples.Minimal); err != nil {
+		t.Fatalf("Save new file: %v", err)
+	}
+	if info, err := os.Stat(fresh); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private new file, got %v (%v)", info, err)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Setting is the effective value of one configuration key.
+type Setting struct {
+	// Path names the key, such as "daemon.poll_interval".
+	Path string
+
+	// Value is written as it would be in the file. Unset optional values
+	// are "~".
+	Value string
+
+	Origin Origin
+}
+
+// Settings lists every key with its effective value and origin, in the
+// order the keys are declared. Map entries are sorted; lists are single
+// settings.
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	c.collect(reflect.ValueOf(c).Elem(), "", &settings)
+	return settings
+}
+
+func (c *Config) collect(v reflect.Value, path string, settings *[]Setting) {
+	leaf := func(value string) {
+		*settings = append(*settings, Setting{Path: path, Value: value, Origin: c.Origin(path)})
+	}
+
+	switch {
+	case v.Type() == durationType:
+		leaf(time.Duration(v.Int()).String())
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			leaf("~")
+			return
+		}
+		c.collect(v.Elem(), path, settings)
+	case v.Kind() == reflect.Struct:
+		for _, field := range orderedFields(v.Type()) {
+			c.collect(v.FieldByIndex(field.Index), join(path, field.name), settings)
+		}
+	case v.Kind() == reflect.Map:
+		if v.Len() == 0 {
+			leaf("{}")
+			return
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			c.collect(v.MapIndex(reflect.ValueOf(key)), join(path, key), settings)
+		}
+	case v.Kind() == reflect.Slice:
+		leaf(flow(v.Interface()))
+	case v.Kind() == reflect.String && v.String() == "":
+		leaf(`""`)
+	default:
+		leaf(fmt.Sprint(v.Interface()))
+	}
+}
+
+// flow writes value as single-line YAML.
+func flow(value any) string {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	var setFlow func(*yaml.Node)
+	setFlow = func(n *yaml.Node) {
+		n.Style = yaml.FlowStyle
+		for _, child := range n.Content {
+			setFlow(child)
+		}
+	}
+	setFlow(&node)
+
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(string(data))
+}
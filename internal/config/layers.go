@@ -0,0 +1,308 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layer names, from lowest to highest precedence.
+const (
+	LayerDefault     = "default"
+	LayerSystem      = "system"
+	LayerUser        = "user"
+	LayerDropIn      = "drop-in"
+	LayerEnvironment = "environment"
+	LayerFlag        = "flag"
+)
+
+// systemPath is the system-wide configuration file. It is a variable so
+// tests do not depend on the machine they run on.
+var systemPath = "/etc/peared/config.yaml"
+
+// dropInDir is the directory beside the user configuration file whose *.yaml
+// files are applied after it.
+const dropInDir = "config.d"
+
+// envPrefix starts the environment variables that override settings, such
+// as PEARED_DAEMON_POLL_INTERVAL for daemon.poll_interval.
+const envPrefix = "PEARED_"
+
+// reservedEnv are PEARED_ variables the tools read themselves, or pass to
+// device hooks, rather than settings.
+var reservedEnv = map[string]bool{
+	"PEARED_CONFIG":       true,
+	"PEARED_SOCKET":       true,
+	"PEARED_ADAPTER":      true,
+	"PEARED_HOOK":         true,
+	"PEARED_HOOK_ADAPTER": true,
+	"PEARED_DEVICE":       true,
+	"PEARED_DEVICE_NAME":  true,
+	"PEARED_NICKNAME":     true,
+}
+
+// Origin tells where the effective value of a setting comes from.
+type Origin struct {
+	// Layer is one of the Layer names.
+	Layer string
+
+	// Source is the file, environment variable or flag that set the value.
+	// It is empty for defaults.
+	Source string
+
+	// Line and Column locate the setting in a file and are zero otherwise.
+	Line   int
+	Column int
+}
+
+func (o Origin) String() string {
+	switch {
+	case o.Source == "":
+		return o.Layer
+	case o.Line > 0:
+		return fmt.Sprintf("%s %s:%d:%d", o.Layer, o.Source, o.Line, o.Column)
+	default:
+		return o.Layer + " " + o.Source
+	}
+}
+
+// Override sets a single setting from the command line, above every other
+// layer.
+type Override struct {
+	// Flag names the command-line flag, such as "--adapter", in origins
+	// and problems.
+	Flag string
+
+	// Path is the setting, such as "daemon.preferred_adapter".
+	Path string
+
+	// Value is parsed as YAML, like environment variables.
+	Value string
+}
+
+// layer is one parsed source of settings. Sources that could not be parsed
+// carry the problem instead of a root.
+type layer struct {
+	origin  Origin
+	root    *yaml.Node
+	problem *Problem
+}
+
+// readLayers parses every layer that exists, in order of precedence, and
+// records the files it read in cfg.Files. A non-nil user replaces the user
+// file's content.
+func readLayers(cfg *Config, user []byte, overrides []Override) ([]layer, error) {
+	files := []Origin{
+		{Layer: LayerSystem, Source: systemPath},
+		{Layer: LayerUser, Source: cfg.Source},
+	}
+	dropIns, err := filepath.Glob(filepath.Join(filepath.Dir(cfg.Source), dropInDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("list drop-ins: %w", err)
+	}
+	for _, dropIn := range dropIns {
+		files = append(files, Origin{Layer: LayerDropIn, Source: dropIn})
+	}
+
+	var layers []layer
+	for _, origin := range files {
+		data, err := os.ReadFile(origin.Source)
+		if origin.Layer == LayerUser && user != nil {
+			data, err = user, nil
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("read config %q: %w", origin.Source, err)
+		}
+		cfg.Files = append(cfg.Files, origin.Source)
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			problem := syntaxProblem(origin.Source, err)
+			layers = append(layers, layer{origin: origin, problem: &problem})
+			continue
+		}
+		if len(doc.Content) > 0 {
+			layers = append(layers, layer{origin: origin, root: doc.Content[0]})
+		}
+	}
+
+	layers = append(layers, environmentLayers(os.Environ())...)
+
+	for _, override := range overrides {
+		origin := Origin{Layer: LayerFlag, Source: override.Flag}
+		value, err := valueNode(override.Value)
+		if err != nil {
+			layers = append(layers, layer{origin: origin, problem: &Problem{Source: override.Flag, Path: override.Path, Message: err.Error()}})
+			continue
+		}
+		layers = append(layers, layer{origin: origin, root: nest(strings.Split(override.Path, "."), value)})
+	}
+
+	return layers, nil
+}
+
+// environmentLayers turns each PEARED_ variable into a layer. Variables are
+// applied in name order, so PEARED_DAEMON_RECONNECT_POLICY overrides the
+// policy set by PEARED_DAEMON_RECONNECT.
+func environmentLayers(environ []string) []layer {
+	sort.Strings(environ)
+
+	var layers []layer
+	for _, entry := range environ {
+		name, raw, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, envPrefix) || reservedEnv[name] {
+			continue
+		}
+
+		origin := Origin{Layer: LayerEnvironment, Source: name}
+		path, ok := envPath(configType, strings.Split(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "_"))
+		if !ok {
+			layers = append(layers, layer{origin: origin, problem: &Problem{Source: name, Message: "environment variable matches no setting"}})
+			continue
+		}
+		value, err := valueNode(raw)
+		if err != nil {
+			layers = append(layers, layer{origin: origin, problem: &Problem{Source: name, Path: path, Message: err.Error()}})
+			continue
+		}
+		layers = append(layers, layer{origin: origin, root: nest(strings.Split(path, "."), value)})
+	}
+	return layers
+}
+
+// envPath finds the setting named by the lower-cased words of an
+// environment variable. Field names contain underscores themselves, so the
+// longest field name matching the leading words wins. Only struct fields can
+// be reached; map keys such as device addresses cannot be spelt in a
+// variable name.
+func envPath(t reflect.Type, words []string) (string, bool) {
+	if len(words) == 0 {
+		return "", true
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == durationType {
+		return "", false
+	}
+
+	fields := yamlFields(t)
+	for n := len(words); n > 0; n-- {
+		name := strings.Join(words[:n], "_")
+		field, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if rest, ok := envPath(field.Type, words[n:]); ok {
+			if rest == "" {
+				return name, true
+			}
+			return name + "." + rest, true
+		}
+	}
+	return "", false
+}
+
+// valueNode parses an environment variable or flag value as YAML, so lists
+// and mappings can be given in flow style. An empty value resets the setting
+// to its default.
+func valueNode(raw string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, fmt.Errorf("invalid value %q: %s", raw, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+
+	// Lines would refer to the value rather than a file.
+	var clear func(*yaml.Node)
+	clear = func(node *yaml.Node) {
+		node.Line, node.Column = 0, 0
+		for _, child := range node.Content {
+			clear(child)
+		}
+	}
+	clear(doc.Content[0])
+	return doc.Content[0], nil
+}
+
+// nest wraps value in mappings so it sits at keys.
+func nest(keys []string, value *yaml.Node) *yaml.Node {
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[i]}
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}
+	}
+	return value
+}
+
+// merge applies the mapping src over dst. Mappings on both sides are merged
+// key by key; anything else in src replaces what dst had. The origins of
+// replaced settings are taken from from.
+func merge(dst, src *yaml.Node, path string, from, into map[string]Origin) {
+	if src.Kind == yaml.AliasNode {
+		src = src.Alias
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		child := join(path, key.Value)
+
+		index := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				index = j
+				break
+			}
+		}
+
+		if index >= 0 && dst.Content[index+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			into[child] = from[child]
+			merge(dst.Content[index+1], value, child, from, into)
+			continue
+		}
+
+		if index >= 0 {
+			dst.Content[index+1] = value
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+		for p := range into {
+			if within(p, child) {
+				delete(into, p)
+			}
+		}
+		for p, origin := range from {
+			if within(p, child) {
+				into[p] = origin
+			}
+		}
+	}
+}
+
+// within reports whether the setting at path is parent or inside it.
+func within(path, parent string) bool {
+	if !strings.HasPrefix(path, parent) {
+		return false
+	}
+	rest := path[len(parent):]
+	return rest == "" || rest[0] == '.' || rest[0] == '['
+}
+
+// WatchPaths lists what to watch for configuration changes: the system and
+// user files and the drop-in directory, whether or not they exist yet.
+func (c *Config) WatchPaths() []string {
+	return []string{systemPath, c.Source, filepath.Join(filepath.Dir(c.Source), dropInDir)}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// layeredFiles writes a system file, a user file and drop-ins into a
+// temporary directory and returns the user file's path.
+func layeredFiles(t *testing.T, system, user string, dropIns map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	previous := systemPath
+	systemPath = filepath.Join(dir, "system.yaml")
+	t.Cleanup(func() { systemPath = previous })
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	if system != "" {
+		write(systemPath, system)
+	}
+	userPath := filepath.Join(dir, "user", "config.yaml")
+	if user != "" {
+		write(userPath, user)
+	}
+	for name, content := range dropIns {
+		write(filepath.Join(dir, "user", dropInDir, name), content)
+	}
+	return userPath
+}
+
+func TestLoadMergesLayers(t *testing.T) {
+	path := layeredFiles(t, `daemon:
+  preferred_adapter: hci0
+  poll_interval: 10s
+  reconnect:
+    policy: always
+    max_attempts: 3
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+    priority: 1
+`, `daemon:
+  reconnect:
+    policy: when-in-range
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    priority: 5
+`, map[string]string{
+		"20-late.yaml":  "daemon:\n  poll_interval: 30s\n",
+		"10-early.yaml": "daemon:\n  poll_interval: 20s\n  watchdog:\n    levels: [soft, service]\n",
+		"notes.txt":     "not: yaml: at all",
+	})
+	t.Setenv("PEARED_DAEMON_WATCHDOG_LEVELS", "[soft]")
+	t.Setenv("PEARED_DAEMON_AGENT_ENABLED", "false")
+
+	cfg, err := Load(path, Override{Flag: "--adapter", Path: "daemon.preferred_adapter", Value: "hci1"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(cfg.Files) != 4 || !cfg.Loaded {
+		t.Fatalf("unexpected files %v", cfg.Files)
+	}
+	daemonCfg := cfg.Daemon
+	if daemonCfg.PreferredAdapter != "hci1" || daemonCfg.PollInterval != 30*time.Second ||
+		daemonCfg.Reconnect.Policy != "when-in-range" || daemonCfg.Reconnect.MaxAttempts != 3 ||
+		strings.Join(daemonCfg.Watchdog.Levels, ",") != "soft" || daemonCfg.Agent.Enabled == nil || *daemonCfg.Agent.Enabled {
+		t.Fatalf("unexpected daemon config %+v", daemonCfg)
+	}
+	if device := cfg.Devices["AA:BB:CC:DD:EE:FF"]; device.Nickname != "cans" || device.Priority != 5 {
+		t.Fatalf("unexpected device %+v", device)
+	}
+
+	for path, want := range map[string]string{
+		"daemon.preferred_adapter":           "flag --adapter",
+		"daemon.poll_interval":               "drop-in " + filepath.Join(filepath.Dir(cfg.Source), dropInDir, "20-late.yaml") + ":2:3",
+		"daemon.reconnect.policy":            "user " + cfg.Source + ":3:5",
+		"daemon.reconnect.max_attempts":      "system " + systemPath + ":6:5",
+		"daemon.watchdog.levels":             "environment PEARED_DAEMON_WATCHDOG_LEVELS",
+		"devices.AA:BB:CC:DD:EE:FF.nickname": "system " + systemPath + ":9:5",
+		"devices.AA:BB:CC:DD:EE:FF.priority": "user " + cfg.Source + ":6:5",
+		"daemon.suspend.devices":             "default",
+	} {
+		if got := cfg.Origin(path).String(); got != want {
+			t.Errorf("origin of %s: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLoadReportsProblemsInEachLayer(t *testing.T) {
+	path := layeredFiles(t, "daemon:\n  poll_intervall: 5s\n", "daemon:\n  agent:\n    capability: Telepathy\n", map[string]string{
+		"10-dup.yaml": "devices:\n  \"AA:BB:CC:DD:EE:FF\": {}\n  \"AA:BB:CC:DD:EE:FF\": {}\n",
+	})
+	t.Setenv("PEARED_DAEMON_POLL", "5s")
+	t.Setenv("PEARED_DAEMON_RECONNECT_MAX_ATTEMPTS", "many")
+	t.Setenv("PEARED_CONFIG", "ignored because it names the file")
+
+	_, err := Load(path)
+	problems := problemsOf(t, err)
+	dropIn := filepath.Join(filepath.Dir(path), dropInDir, "10-dup.yaml")
+	want := []string{
+		systemPath + `:2:3: daemon: unknown field "poll_intervall" (did you mean "poll_interval"?)`,
+		dropIn + `:3:3: devices: "AA:BB:CC:DD:EE:FF" is already set on line 2`,
+		`PEARED_DAEMON_POLL: environment variable matches no setting`,
+		`PEARED_DAEMON_RECONNECT_MAX_ATTEMPTS: daemon.reconnect.max_attempts: expected a whole number, got "many"`,
+	}
+	var invalid []string
+	for _, problem := range err.(*ValidationError).Problems {
+		invalid = append(invalid, problem.Qualified())
+	}
+	if strings.Join(invalid, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected problems (%d):\n%s\nwant:\n%s", len(problems), strings.Join(invalid, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Settings are validated once merged, against the layer that set them.
+	t.Setenv("PEARED_DAEMON_POLL", "")
+	os.Unsetenv("PEARED_DAEMON_POLL")
+	t.Setenv("PEARED_DAEMON_RECONNECT_MAX_ATTEMPTS", "-1")
+	path = layeredFiles(t, "", "daemon:\n  agent:\n    capability: Telepathy\n", nil)
+	_, err = Load(path)
+	if got := err.Error(); got != "2 problems:\n"+
+		path+`:3:5: daemon.agent.capability: unknown agent capability "Telepathy" (want DisplayOnly, DisplayYesNo, KeyboardOnly, NoInputNoOutput or KeyboardDisplay)`+"\n"+
+		`PEARED_DAEMON_RECONNECT_MAX_ATTEMPTS: daemon.reconnect.max_attempts: must not be negative` {
+		t.Fatalf("unexpected error:\n%s", got)
+	}
+}
+
+func TestEnvPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"DAEMON_PREFERRED_ADAPTER":          "daemon.preferred_adapter",
+		"DAEMON_RECONNECT":                  "daemon.reconnect",
+		"DAEMON_RECONNECT_INITIAL_BACKOFF":  "daemon.reconnect.initial_backoff",
+		"DAEMON_FAILOVER_RECONNECT_DEVICES": "daemon.failover.reconnect_devices",
+		"DAEMON_AGENT_TIMEOUT":              "daemon.agent.timeout",
+		"DAEMON_POLL_INTERVAL_EXTRA":        "",
+		"DEVICES_AA":                        "",
+		"":                                  "",
+	} {
+		got, ok := envPath(configType, strings.Split(strings.ToLower(name), "_"))
+		if want == "" && ok && name != "" {
+			t.Errorf("%s: expected no setting, got %q", name, got)
+		}
+		if want != "" && (!ok || got != want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSettingsListsEveryKey(t *testing.T) {
+	path := layeredFiles(t, "", `daemon:
+  poll_interval: 15s
+  adapter_selection:
+    prefer:
+      - transport: usb
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+    reconnect:
+      windows: ["mon-fri 08:00-18:00"]
+`, nil)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	settings := make(map[string]Setting)
+	var order []string
+	for _, setting := range cfg.Settings() {
+		settings[setting.Path] = setting
+		order = append(order, setting.Path)
+	}
+	if order[0] != "daemon.preferred_adapter" || order[1] != "daemon.poll_interval" {
+		t.Fatalf("unexpected order %v", order[:2])
+	}
+
+	for path, want := range map[string]string{
+		"daemon.preferred_adapter":                    `""`,
+		"daemon.poll_interval":                        "15s",
+		"daemon.reconnect.max_attempts":               "0",
+		"daemon.agent.enabled":                        "~",
+		"daemon.adapter_selection.prefer":             "[{transport: usb}]",
+		"daemon.connection_limits":                    "{}",
+		"adapters":                                    "{}",
+		"devices.AA:BB:CC:DD:EE:FF.nickname":          "cans",
+		"devices.AA:BB:CC:DD:EE:FF.reconnect.windows": "['mon-fri 08:00-18:00']",
+	} {
+		if got := settings[path].Value; got != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+	if origin := settings["daemon.poll_interval"].Origin; origin.Layer != LayerUser || origin.Line != 2 {
+		t.Errorf("unexpected origin %v", origin)
+	}
+	if origin := settings["daemon.reconnect.max_attempts"].Origin; origin.Layer != LayerDefault {
+		t.Errorf("unexpected origin %v", origin)
+	}
+}
@@ -0,0 +1,522 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/peared/peared/internal/btaddr"
+	"github.com/peared/peared/internal/devclass"
+	"github.com/peared/peared/internal/policy"
+)
+
+// Problem is a mistake in a configuration file. Line and Column locate it
+// when it was found in a file and are zero otherwise.
+type Problem struct {
+	// Source is the file, environment variable or flag the problem was
+	// found in. It is empty for settings no layer set.
+	Source string
+
+	Line   int
+	Column int
+
+	// Path names the setting, such as "daemon.reconnect.policy".
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", p.Line, p.Column)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// Qualified prefixes the problem with its source, as in
+// "config.yaml:3:5: daemon.poll_interval: ...".
+func (p Problem) Qualified() string {
+	switch {
+	case p.Source == "":
+		return p.String()
+	case p.Line > 0:
+		return p.Source + ":" + p.String()
+	default:
+		return p.Source + ": " + p.String()
+	}
+}
+
+// ValidationError lists every problem found in the configuration. Source
+// is the user configuration file, which problems without a source of their
+// own are reported against.
+type ValidationError struct {
+	Source   string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		if problem.Source == "" {
+			problem.Source = e.Source
+		}
+		lines[i] = problem.Qualified()
+	}
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return fmt.Sprintf("%d problems:\n%s", len(lines), strings.Join(lines, "\n"))
+}
+
+// check strictly checks a layer against the Config schema. Unknown fields
+// and values of the wrong type are all reported, located in the layer's
+// source, rather than stopping at the first. It returns where each setting
+// is written.
+func check(l layer) (map[string]Origin, []Problem) {
+	d := &decoder{origin: l.origin, origins: make(map[string]Origin)}
+	d.walk(l.root, configType, "")
+	return d.origins, d.problems
+}
+
+// syntaxProblem turns a yaml.v3 error, which starts with "yaml: line N:",
+// into a Problem on that line of source.
+func syntaxProblem(source string, err error) Problem {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	var line int
+	if n, _ := fmt.Sscanf(message, "line %d:", &line); n == 1 {
+		_, message, _ = strings.Cut(message, ": ")
+		return Problem{Source: source, Line: line, Column: 1, Message: message}
+	}
+	return Problem{Source: source, Message: message}
+}
+
+type decoder struct {
+	origin   Origin
+	origins  map[string]Origin
+	problems []Problem
+}
+
+var (
+	configType   = reflect.TypeOf(Config{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func (d *decoder) problem(node *yaml.Node, path, format string, args ...any) {
+	d.problems = append(d.problems, Problem{Source: d.origin.Source, Line: node.Line, Column: node.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// record notes that key sets the setting at path.
+func (d *decoder) record(path string, key *yaml.Node) {
+	origin := d.origin
+	origin.Line, origin.Column = key.Line, key.Column
+	d.origins[path] = origin
+}
+
+// walk checks that node fits t, descending into mappings and sequences.
+func (d *decoder) walk(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		// yaml.v3 would read a bare number as nanoseconds.
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			d.problem(node, path, "expected a duration with a unit, such as 30s or 5m, got %s", describe(node))
+		} else if _, err := time.ParseDuration(node.Value); err != nil {
+			d.problem(node, path, "invalid duration %q (use a unit, such as 30s or 5m)", node.Value)
+		}
+	case t.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			d.problem(node, path, "expected a mapping, got %s", describe(node))
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := join(path, key.Value)
+			if d.duplicate(node, i, path) {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				d.problem(key, path, "unknown field %q%s", key.Value, suggest(key.Value, fields))
+				continue
+			}
+			d.record(child, key)
+			d.walk(value, field.Type, child)
+		}
+	case t.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			d.problem(node, path, "expected a mapping, got %s", describe(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := join(path, key.Value)
+			if d.duplicate(node, i, path) {
+				continue
+			}
+			d.record(child, key)
+			d.walk(value, t.Elem(), child)
+		}
+	case t.Kind() == reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			d.problem(node, path, "expected a list, got %s", describe(node))
+			return
+		}
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			d.record(child, item)
+			d.walk(item, t.Elem(), child)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			d.problem(node, path, "expected %s, got %s", kindName(t), describe(node))
+			return
+		}
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			d.problem(node, path, "expected %s, got %s", kindName(t), describe(node))
+		}
+	}
+}
+
+// duplicate reports the key at index i of mapping if an earlier key has the
+// same name. Merging layers would otherwise silently keep the last one.
+func (d *decoder) duplicate(mapping *yaml.Node, i int, path string) bool {
+	key := mapping.Content[i]
+	for j := 0; j < i; j += 2 {
+		if earlier := mapping.Content[j]; earlier.Value == key.Value {
+			d.problem(key, path, "%q is already set on line %d", key.Value, earlier.Line)
+			return true
+		}
+	}
+	return false
+}
+
+// yamlFields maps the keys a struct accepts to its fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, field := range orderedFields(t) {
+		fields[field.name] = field.StructField
+	}
+	return fields
+}
+
+type namedField struct {
+	reflect.StructField
+	name string
+}
+
+// orderedFields lists the keys a struct accepts in declaration order.
+func orderedFields(t reflect.Type) []namedField {
+	var fields []namedField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields = append(fields, namedField{field, name})
+	}
+	return fields
+}
+
+// suggest offers the known field closest to a misspelt one.
+func suggest(name string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		if distance := editDistance(name, candidate); distance < bestDistance || distance == bestDistance && candidate < best {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.String:
+		return "a string"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
+
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Validate checks the settings for values pearedd would reject or
+// misread: addresses, enumerations, time windows, negative durations and
+// counts, and nicknames used twice. Problems are sorted by where they occur.
+func (c *Config) Validate() []Problem {
+	v := &validator{origins: c.origins}
+
+	daemonCfg := c.Daemon
+	v.nonNegative("daemon.poll_interval", daemonCfg.PollInterval)
+	if daemonCfg.BatteryLow < 0 || daemonCfg.BatteryLow > 100 {
+		v.add("daemon.battery_low", "must be a percentage between 0 and 100")
+	}
+	v.reconnect("daemon.reconnect", daemonCfg.Reconnect)
+
+	for _, name := range sortedKeys(daemonCfg.ConnectionLimits) {
+		path := "daemon.connection_limits." + name
+		if kind, err := devclass.ParseKind(name); err != nil || kind == devclass.Unknown {
+			v.add(path, "unsupported device kind %q", name)
+		}
+		if daemonCfg.ConnectionLimits[name] < 0 {
+			v.add(path, "limit must not be negative")
+		}
+	}
+
+	selection := daemonCfg.AdapterSelection
+	if _, err := policy.ParseSelection(selection.Prefer, selection.Exclude, selection.Tiebreak); err != nil {
+		v.add("daemon.adapter_selection", "%v", err)
+	}
+
+	watchdog := daemonCfg.Watchdog
+	if _, err := policy.ParseResetLevels(watchdog.Levels); err != nil {
+		v.add("daemon.watchdog.levels", "%v", err)
+	}
+	v.nonNegative("daemon.watchdog.interval", watchdog.Interval)
+	v.nonNegative("daemon.watchdog.probe_timeout", watchdog.ProbeTimeout)
+	v.nonNegative("daemon.watchdog.window", watchdog.Window)
+	if watchdog.Threshold < 0 {
+		v.add("daemon.watchdog.threshold", "must not be negative")
+	}
+	if watchdog.ConnectTimeouts < 0 {
+		v.add("daemon.watchdog.connect_timeouts", "must not be negative")
+	}
+	if watchdog.MaxRecoveries < 0 {
+		v.add("daemon.watchdog.max_recoveries", "must not be negative")
+	}
+
+	if _, err := policy.ParseSuspendAction(daemonCfg.Suspend.Devices); err != nil {
+		v.add("daemon.suspend.devices", "%v", err)
+	}
+	if _, err := policy.ParseCapability(daemonCfg.Agent.Capability); err != nil {
+		v.add("daemon.agent.capability", "%v", err)
+	}
+	v.nonNegative("daemon.agent.timeout", daemonCfg.Agent.Timeout)
+
+	for _, identifier := range sortedKeys(c.Adapters) {
+		adapter := c.Adapters[identifier]
+		path := "adapters." + identifier
+		if strings.TrimSpace(identifier) == "" {
+			v.add(path, "adapter identifier must not be empty")
+		}
+		v.nonNegative(path+".discoverable_timeout", adapter.DiscoverableTimeout)
+		v.nonNegative(path+".pairable_timeout", adapter.PairableTimeout)
+	}
+
+	addresses := make(map[string]string)
+	nicknames := make(map[string]string)
+	// Devices are checked in file order so duplicates are reported where
+	// they are repeated.
+	keys := sortedKeys(c.Devices)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return locate(c.origins, "devices."+keys[i]).Line < locate(c.origins, "devices."+keys[j]).Line
+	})
+	for _, key := range keys {
+		device := c.Devices[key]
+		path := "devices." + key
+
+		if !btaddr.Valid(key) {
+			v.add(path, "invalid MAC address %q (want six pairs of hex digits such as AA:BB:CC:DD:EE:FF)", key)
+		} else if other, ok := addresses[btaddr.Normalize(key)]; ok {
+			v.add(path, "same device as devices.%s", other)
+		} else {
+			addresses[btaddr.Normalize(key)] = key
+		}
+
+		if nickname := strings.ToLower(strings.TrimSpace(device.Nickname)); nickname != "" {
+			if other, ok := nicknames[nickname]; ok {
+				v.add(path+".nickname", "nickname %q is also used by devices.%s", device.Nickname, other)
+			} else {
+				nicknames[nickname] = key
+			}
+		}
+		if _, err := devclass.ParseKind(device.Kind); err != nil {
+			v.add(path+".kind", "%v", err)
+		}
+		if _, err := policy.ParseSuspendAction(device.Suspend); err != nil {
+			v.add(path+".suspend", "%v", err)
+		}
+		v.reconnect(path+".reconnect", device.Reconnect)
+		v.audio(path+".audio", device.Audio)
+	}
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return v.problems
+}
+
+type validator struct {
+	origins  map[string]Origin
+	problems []Problem
+}
+
+// add records a problem with path, located at the closest enclosing setting
+// a layer wrote.
+func (v *validator) add(path, format string, args ...any) {
+	origin := locate(v.origins, path)
+	v.problems = append(v.problems, Problem{
+		Source:  origin.Source,
+		Line:    origin.Line,
+		Column:  origin.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Position returns the line and column where the setting at path, such as
+// "daemon.preferred_adapter", or its closest enclosing setting is written.
+// Both are zero for settings that did not come from a file.
+func (c *Config) Position(path string) (line, column int) {
+	origin := c.Origin(path)
+	return origin.Line, origin.Column
+}
+
+// Origin returns the layer that set the setting at path. Settings no layer
+// set have the default origin.
+func (c *Config) Origin(path string) Origin {
+	if origin, ok := c.origins[path]; ok {
+		return origin
+	}
+	return Origin{Layer: LayerDefault}
+}
+
+func locate(origins map[string]Origin, path string) Origin {
+	for p := path; p != ""; {
+		if origin, ok := origins[p]; ok {
+			return origin
+		}
+		cut := strings.LastIndexAny(p, ".[")
+		if cut < 0 {
+			break
+		}
+		p = p[:cut]
+	}
+	return Origin{Layer: LayerDefault}
+}
+
+func (v *validator) nonNegative(path string, d time.Duration) {
+	if d < 0 {
+		v.add(path, "must not be negative")
+	}
+}
+
+func (v *validator) reconnect(path string, cfg ReconnectConfig) {
+	if _, err := policy.ParseReconnectMode(cfg.Policy); err != nil {
+		v.add(path+".policy", "%v", err)
+	}
+	for i, window := range cfg.Windows {
+		if _, err := policy.ParseTimeWindow(window); err != nil {
+			v.add(fmt.Sprintf("%s.windows[%d]", path, i), "%v", err)
+		}
+	}
+	v.nonNegative(path+".initial_backoff", cfg.InitialBackoff)
+	v.nonNegative(path+".max_backoff", cfg.MaxBackoff)
+	if cfg.InitialBackoff > 0 && cfg.MaxBackoff > 0 && cfg.MaxBackoff < cfg.InitialBackoff {
+		v.add(path+".max_backoff", "must not be shorter than initial_backoff")
+	}
+	if cfg.MaxAttempts < 0 {
+		v.add(path+".max_attempts", "must not be negative")
+	}
+}
+
+// audio reports each audio problem at the setting that causes it.
+func (v *validator) audio(path string, audio AudioConfig) {
+	_, profileErr := policy.ParseAudioProfile(audio.Profile)
+	if profileErr != nil {
+		v.add(path+".profile", "%v", profileErr)
+	}
+	_, codecErr := policy.ParseAudioCodec(audio.Codec)
+	if codecErr != nil {
+		v.add(path+".codec", "%v", codecErr)
+	}
+	if profileErr == nil && codecErr == nil {
+		if _, err := policy.NewAudioSettings(audio.Profile, audio.Codec, nil); err != nil {
+			v.add(path+".codec", "%v", err)
+		}
+	}
+	if audio.Volume != nil && (*audio.Volume < 0 || *audio.Volume > 100) {
+		v.add(path+".volume", "volume %d is out of range (want 0 to 100)", *audio.Volume)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
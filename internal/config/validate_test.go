@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+func loadString(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return Load(path)
+}
+
+func problemsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var problems []string
+	for _, problem := range invalid.Problems {
+		problems = append(problems, problem.String())
+	}
+	return problems
+}
+
+func TestLoadReportsUnknownFieldsAndTypeErrors(t *testing.T) {
+	_, err := loadString(t, `daemon:
+  prefered_adapter: hci1
+  poll_interval: 30
+  watchdog:
+    enabled: true
+    threshold: three
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+    reconect:
+      policy: always
+    reconnect:
+      windows: mon-fri 08:00-18:00
+`)
+
+	want := []string{
+		`2:3: daemon: unknown field "prefered_adapter" (did you mean "preferred_adapter"?)`,
+		`3:18: daemon.poll_interval: expected a duration with a unit, such as 30s or 5m, got "30"`,
+		`6:16: daemon.watchdog.threshold: expected a whole number, got "three"`,
+		`10:5: devices.AA:BB:CC:DD:EE:FF: unknown field "reconect" (did you mean "reconnect"?)`,
+		`13:16: devices.AA:BB:CC:DD:EE:FF.reconnect.windows: expected a list, got "mon-fri 08:00-18:00"`,
+	}
+	if got := problemsOf(t, err); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadReportsInvalidSettings(t *testing.T) {
+	_, err := loadString(t, `daemon:
+  reconnect:
+    policy: sometimes
+    initial_backoff: 1m
+    max_backoff: 10s
+  connection_limits:
+    toaster: 1
+  watchdog:
+    levels: [soft, reboot]
+  suspend:
+    devices: hibernate
+  agent:
+    capability: Telepathy
+  battery_low: 120
+devices:
+  "AA:BB:CC:DD:EE:FF":
+    nickname: cans
+  "aa:bb:cc:dd:ee:ff":
+    kind: fridge
+  "11:22:33:44:55:66":
+    nickname: Cans
+    reconnect:
+      windows: ["mon-fri 08:00-18:00", "someday"]
+  "not-an-address":
+    suspend: leave
+  "22:33:44:55:66:77":
+    audio:
+      profile: headset
+      codec: ldac
+      volume: 120
+  "33:44:55:66:77:88":
+    audio:
+      profile: stereo
+`)
+
+	problems := problemsOf(t, err)
+	for _, want := range []string{
+		`3:5: daemon.reconnect.policy: unknown reconnect policy "sometimes"`,
+		`5:5: daemon.reconnect.max_backoff: must not be shorter than initial_backoff`,
+		`7:5: daemon.connection_limits.toaster: unsupported device kind "toaster"`,
+		`9:5: daemon.watchdog.levels: unknown reset level "reboot"`,
+		`11:5: daemon.suspend.devices: unknown suspend action "hibernate"`,
+		`13:5: daemon.agent.capability: unknown agent capability "Telepathy"`,
+		`14:3: daemon.battery_low: must be a percentage between 0 and 100`,
+		`18:3: devices.aa:bb:cc:dd:ee:ff: same device as devices.AA:BB:CC:DD:EE:FF`,
+		`19:5: devices.aa:bb:cc:dd:ee:ff.kind: `,
+		`21:5: devices.11:22:33:44:55:66.nickname: nickname "Cans" is also used by devices.AA:BB:CC:DD:EE:FF`,
+		`23:40: devices.11:22:33:44:55:66.reconnect.windows[1]: `,
+		`24:3: devices.not-an-address: invalid MAC address "not-an-address"`,
+		`29:7: devices.22:33:44:55:66:77.audio.codec: codec ldac is not available with the headset profile`,
+		`30:7: devices.22:33:44:55:66:77.audio.volume: volume 120 is out of range`,
+		`33:7: devices.33:44:55:66:77:88.audio.profile: unknown audio profile "stereo"`,
+	} {
+		found := false
+		for _, problem := range problems {
+			if strings.HasPrefix(problem, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing problem %q in:\n%s", want, strings.Join(problems, "\n"))
+		}
+	}
+	if len(problems) != 15 {
+		t.Errorf("expected 15 problems, got %d:\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+}
+
+func TestLoadReportsSyntaxErrors(t *testing.T) {
+	_, err := loadString(t, "daemon:\n\tpreferred_adapter: hci0\n")
+	problems := problemsOf(t, err)
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "2:1: ") {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+}
+
+func TestLoadAcceptsValidConfig(t *testing.T) {
+	cfg, err := loadString(t, `daemon:
+  preferred_adapter: hci0
+  poll_interval: 15s
+  agent:
+    enabled: false
+  adapter_selection:
+    prefer:
+      - transport: usb
+adapters:
+  hci0:
+    powered: true
+    discoverable_timeout: 3m
+devices:
+  "aa:bb:cc:dd:ee:ff":
+    nickname: cans
+    reconnect:
+      policy: when-in-range
+      windows: ["mon-fri 08:00-18:00"]
+    audio:
+      codec: aac
+      volume: 40
+    hooks:
+      connect: notify-send "$PEARED_NICKNAME connected"
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Daemon.PollInterval != 15*time.Second || cfg.Adapters["hci0"].DiscoverableTimeout != 3*time.Minute {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	if line, column := cfg.Position("devices.aa:bb:cc:dd:ee:ff.nickname"); line != 15 || column != 5 {
+		t.Fatalf("unexpected position %d:%d", line, column)
+	}
+	audio := cfg.Devices["aa:bb:cc:dd:ee:ff"].Audio
+	settings, err := policy.NewAudioSettings(audio.Profile, audio.Codec, audio.Volume)
+	if err != nil || settings.EffectiveProfile() != policy.AudioProfileA2DP || *settings.Volume != 40 {
+		t.Fatalf("unexpected audio settings %+v (%v)", settings, err)
+	}
+}
+
+func TestValidateWithoutFile(t *testing.T) {
+	cfg := &Config{Devices: map[string]DeviceConfig{"AA:BB": {}}}
+	problems := cfg.Validate()
+	if len(problems) != 1 || !strings.HasPrefix(problems[0].String(), `devices.AA:BB: invalid MAC address`) {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+}
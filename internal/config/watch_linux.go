@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// watchSettle is how long Watch waits for a burst of writes to end before
+// reporting a change, so editors that save in several steps trigger one
+// reload.
+const watchSettle = 200 * time.Millisecond
+
+// Watch calls changed whenever one of paths is written, replaced, created
+// or removed, until ctx is cancelled. A path that is a directory stands for
+// the *.yaml files in it. Files are watched through their directory so
+// editors that save by renaming a new file over the old one are noticed too.
+// Paths whose directory does not exist are skipped; Watch fails when none
+// can be watched. A path that appears later as a directory is watched from
+// then on.
+func Watch(ctx context.Context, paths []string, changed func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("watch configuration: %w", err)
+	}
+	events := os.NewFile(uintptr(fd), "inotify")
+	defer events.Close()
+
+	// Each watched directory matches either some names in it or, for
+	// drop-in directories, every *.yaml file.
+	type target struct {
+		dir   string
+		names map[string]bool
+		yaml  bool
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_CREATE | syscall.IN_DELETE
+	targets := make(map[int32]*target)
+	watch := func(dir, name string) error {
+		wd, err := syscall.InotifyAddWatch(fd, dir, mask)
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", dir, err)
+		}
+		t, ok := targets[int32(wd)]
+		if !ok {
+			t = &target{dir: dir, names: make(map[string]bool)}
+			targets[int32(wd)] = t
+		}
+		if name == "" {
+			t.yaml = true
+		} else {
+			t.names[name] = true
+		}
+		return nil
+	}
+
+	var firstErr error
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		dir, name := filepath.Dir(path), filepath.Base(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dir, name = path, ""
+		}
+		if err := watch(dir, name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(targets) == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("watch configuration: nothing to watch")
+		}
+		return firstErr
+	}
+
+	var settle *time.Timer
+	defer func() {
+		if settle != nil {
+			settle.Stop()
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		events.Close()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := events.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("watch configuration: %w", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			if offset > n || int(event.Len) == 0 {
+				continue
+			}
+
+			t, ok := targets[event.Wd]
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+			if !ok || !t.names[name] && !(t.yaml && strings.HasSuffix(name, ".yaml")) {
+				continue
+			}
+
+			// A drop-in directory created after Watch started is watched
+			// from then on; the reload its creation triggers reads any
+			// files already in it.
+			if t.names[name] && event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				_ = watch(filepath.Join(t.dir, name), "")
+			}
+
+			if settle == nil {
+				settle = time.AfterFunc(watchSettle, changed)
+			} else {
+				settle.Reset(watchSettle)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchReportsWritesRenamesAndDropIns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	dropIns := filepath.Join(dir, "config.d")
+	if err := os.Mkdir(dropIns, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	changes := make(chan struct{}, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, []string{path, dropIns}, func() { changes <- struct{}{} })
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch returned error: %v", err)
+		}
+	}()
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported after %s", what)
+		}
+	}
+
+	// Give the watch a moment to be registered before touching the file.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("daemon: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expect("writing the file")
+
+	replacement := filepath.Join(dir, ".config.yaml.swp")
+	if err := os.WriteFile(replacement, []byte("devices: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	expect("renaming over the file")
+
+	if err := os.WriteFile(filepath.Join(dropIns, "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dropIns, "10-work.yaml"), []byte("devices: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expect("adding a drop-in")
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected extra change")
+	case <-time.After(2 * watchSettle):
+	}
+}
+
+func TestWatchPicksUpDropInDirectoryCreatedLater(t *testing.T) {
+	dir := t.TempDir()
+	dropIns := filepath.Join(dir, "config.d")
+
+	changes := make(chan struct{}, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, []string{filepath.Join(dir, "config.yaml"), dropIns}, func() { changes <- struct{}{} })
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch returned error: %v", err)
+		}
+	}()
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported after %s", what)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Mkdir(dropIns, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	expect("creating the drop-in directory")
+
+	if err := os.WriteFile(filepath.Join(dropIns, "10-work.yaml"), []byte("devices: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expect("adding a drop-in to the new directory")
+}
+
+func TestWatchMissingDirectory(t *testing.T) {
+	err := Watch(context.Background(), []string{filepath.Join(t.TempDir(), "missing", "config.yaml")}, func() {})
+	if err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package config
+
+import (
+	"context"
+	"errors"
+)
+
+// Watch is not supported outside Linux; configuration is reloaded on SIGHUP
+// only.
+func Watch(context.Context, []string, func()) error {
+	return errors.New("watching the configuration file is only supported on Linux")
+}
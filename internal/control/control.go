@@ -0,0 +1,328 @@
+// Package control implements the local API the peared CLI uses to talk to a
+// running pearedd instance. Messages are newline-delimited JSON exchanged over
+// a Unix socket in the user's runtime directory.
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrDaemonUnavailable indicates that no daemon is listening on the control
+// socket. CLI commands treat this as a soft failure because the daemon is
+// optional for most operations.
+var ErrDaemonUnavailable = errors.New("peared daemon is not running")
+
+// Request is a single command sent from a client to the daemon.
+type Request struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args,omitempty"`
+}
+
+// Arg returns the named argument or an empty string when absent.
+func (r Request) Arg(name string) string {
+	if r.Args == nil {
+		return ""
+	}
+	return r.Args[name]
+}
+
+// Response is the daemon's reply to a Request.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the response payload into v.
+func (r Response) Decode(v any) error {
+	if len(r.Data) == 0 {
+		return errors.New("response carries no data")
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// Err converts a failed response into an error.
+func (r Response) Err() error {
+	if r.OK {
+		return nil
+	}
+	if r.Error == "" {
+		return errors.New("daemon reported an unspecified error")
+	}
+	return errors.New(r.Error)
+}
+
+// OK builds a successful response carrying the JSON encoding of data. A nil
+// data value produces an empty payload.
+func OK(data any) Response {
+	if data == nil {
+		return Response{OK: true}
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Errorf("encode response: %v", err)
+	}
+
+	return Response{OK: true, Data: raw}
+}
+
+// Errorf builds a failed response with a formatted message.
+func Errorf(format string, args ...any) Response {
+	return Response{Error: fmt.Sprintf(format, args...)}
+}
+
+// Handler processes requests received by the server.
+type Handler interface {
+	HandleControl(ctx context.Context, req Request) Response
+}
+
+// EventsCommand is the request that subscribes a client to the daemon's event
+// stream. The server answers with a Response header followed by one JSON
+// message per event until either side closes the connection.
+const EventsCommand = "events"
+
+// StreamHandler is implemented by handlers that can stream messages in
+// response to EventsCommand. HandleStream blocks until the context is
+// cancelled or send fails.
+type StreamHandler interface {
+	HandleStream(ctx context.Context, req Request, send func(v any) error) error
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, req Request) Response
+
+// HandleControl implements Handler.
+func (f HandlerFunc) HandleControl(ctx context.Context, req Request) Response {
+	return f(ctx, req)
+}
+
+// DefaultSocketPath returns the control socket location inside
+// $XDG_RUNTIME_DIR. The PEARED_SOCKET environment variable overrides it.
+func DefaultSocketPath() (string, error) {
+	if env := os.Getenv("PEARED_SOCKET"); env != "" {
+		return env, nil
+	}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+
+	return filepath.Join(runtimeDir, "peared", "control.sock"), nil
+}
+
+// dial connects to the control socket at path, resolving an empty path to
+// DefaultSocketPath. A missing or refused socket maps to ErrDaemonUnavailable.
+func dial(ctx context.Context, path string) (net.Conn, error) {
+	if path == "" {
+		resolved, err := DefaultSocketPath()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDaemonUnavailable, err)
+		}
+		path = resolved
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, ErrDaemonUnavailable
+		}
+		return nil, fmt.Errorf("dial control socket %s: %w", path, err)
+	}
+
+	return conn, nil
+}
+
+// Call sends a single request to the daemon listening on path and waits for
+// its response. A zero path resolves to DefaultSocketPath.
+func Call(ctx context.Context, path string, req Request) (Response, error) {
+	if ctx == nil {
+		return Response{}, errors.New("nil context passed to Call")
+	}
+
+	conn, err := dial(ctx, path)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("send %s request: %w", req.Command, err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("read %s response: %w", req.Command, err)
+	}
+
+	return resp, nil
+}
+
+// Events subscribes to the daemon's event stream and invokes fn for every
+// message until the context is cancelled, the daemon closes the stream or fn
+// returns an error.
+func Events(ctx context.Context, path string, fn func(json.RawMessage) error) error {
+	if ctx == nil {
+		return errors.New("nil context passed to Events")
+	}
+
+	conn, err := dial(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(Request{Command: EventsCommand}); err != nil {
+		return fmt.Errorf("send events request: %w", err)
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+
+	var header Response
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("read events response: %w", err)
+	}
+	if err := header.Err(); err != nil {
+		return err
+	}
+
+	for {
+		var msg json.RawMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read event: %w", err)
+		}
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Listen creates the control socket at path, replacing stale sockets left
+// behind by a previous daemon. The parent directory is created with 0700
+// permissions so other users cannot reach the daemon.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create control directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, callErr := Call(ctx, path, Request{Command: "ping"})
+		cancel()
+		if callErr == nil {
+			return nil, fmt.Errorf("control socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale control socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on control socket: %w", err)
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restrict control socket permissions: %w", err)
+	}
+
+	return ln, nil
+}
+
+// Serve accepts connections from ln and dispatches each request to h until the
+// context is cancelled. Every connection carries exactly one request.
+func Serve(ctx context.Context, ln net.Listener, h Handler) error {
+	if ctx == nil {
+		return errors.New("nil context passed to Serve")
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept control connection: %w", err)
+		}
+
+		go serveConn(ctx, conn, h)
+	}
+}
+
+func serveConn(ctx context.Context, conn net.Conn, h Handler) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		_ = json.NewEncoder(conn).Encode(Errorf("decode request: %v", err))
+		return
+	}
+
+	encoder := json.NewEncoder(conn)
+
+	if req.Command == EventsCommand {
+		streamer, ok := h.(StreamHandler)
+		if !ok {
+			_ = encoder.Encode(Errorf("event streaming is not supported"))
+			return
+		}
+
+		if err := encoder.Encode(OK(nil)); err != nil {
+			return
+		}
+
+		// Closing the connection from the client side is the only way a
+		// subscriber says goodbye, so watch for it to end the stream.
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			_, _ = io.Copy(io.Discard, conn)
+			cancel()
+		}()
+
+		_ = streamer.HandleStream(streamCtx, req, encoder.Encode)
+		return
+	}
+
+	var resp Response
+	if req.Command == "ping" {
+		resp = OK(nil)
+	} else {
+		resp = h.HandleControl(ctx, req)
+	}
+
+	_ = encoder.Encode(resp)
+}
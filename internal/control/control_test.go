@@ -0,0 +1,150 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCallRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Serve(ctx, ln, HandlerFunc(func(_ context.Context, req Request) Response {
+		if req.Command != "echo" {
+			return Errorf("unknown command %q", req.Command)
+		}
+		return OK(map[string]string{"address": req.Arg("address")})
+	}))
+
+	callCtx, callCancel := context.WithTimeout(ctx, time.Second)
+	defer callCancel()
+
+	resp, err := Call(callCtx, path, Request{Command: "echo", Args: map[string]string{"address": "AA:BB"}})
+	if err != nil {
+		t.Fatalf("Call returned error: %v", err)
+	}
+
+	var payload map[string]string
+	if err := resp.Decode(&payload); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+
+	if payload["address"] != "AA:BB" {
+		t.Fatalf("unexpected payload: %v", payload)
+	}
+
+	resp, err = Call(callCtx, path, Request{Command: "nope"})
+	if err != nil {
+		t.Fatalf("Call returned error: %v", err)
+	}
+	if resp.Err() == nil {
+		t.Fatalf("expected error response for unknown command")
+	}
+}
+
+func TestCallReportsUnavailableDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+
+	_, err := Call(context.Background(), path, Request{Command: "ping"})
+	if !errors.Is(err, ErrDaemonUnavailable) {
+		t.Fatalf("expected ErrDaemonUnavailable, got %v", err)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("first Listen returned error: %v", err)
+	}
+	// Closing a unix listener removes the socket; recreate a stale file to
+	// mimic a daemon that crashed without cleaning up.
+	ln.(interface{ SetUnlinkOnClose(bool) }).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = Listen(path)
+	if err != nil {
+		t.Fatalf("second Listen returned error: %v", err)
+	}
+	ln.Close()
+}
+
+type streamingHandler struct {
+	HandlerFunc
+}
+
+func (streamingHandler) HandleStream(ctx context.Context, _ Request, send func(any) error) error {
+	for i := 1; i <= 3; i++ {
+		if err := send(map[string]int{"seq": i}); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestEventsStreamsMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Serve(ctx, ln, streamingHandler{})
+
+	errStop := errors.New("stop")
+	var seen []int
+	err = Events(ctx, path, func(raw json.RawMessage) error {
+		var msg map[string]int
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return err
+		}
+		seen = append(seen, msg["seq"])
+		if len(seen) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+
+	if len(seen) != 3 || seen[0] != 1 || seen[2] != 3 {
+		t.Fatalf("unexpected events: %v", seen)
+	}
+}
+
+func TestEventsRequiresStreamHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Serve(ctx, ln, HandlerFunc(func(context.Context, Request) Response { return OK(nil) }))
+
+	err = Events(ctx, path, func(json.RawMessage) error { return nil })
+	if err == nil {
+		t.Fatal("expected error when handler cannot stream")
+	}
+}
@@ -2,8 +2,8 @@ package daemon
 
 import (
 	"context"
-	"fmt"
 	"strings"
+	"time"
 )
 
 // Adapter represents a Bluetooth controller the daemon can manage. The fields
@@ -12,21 +12,57 @@ import (
 type Adapter struct {
 	// ID is a stable identifier for the adapter (e.g. D-Bus object path or
 	// kernel name like hci0).
-	ID string
+	ID string `json:"id"`
 
 	// Address is the MAC address associated with the adapter.
-	Address string
+	Address string `json:"address"`
 
 	// Alias is a human-friendly label surfaced by BlueZ.
-	Alias string
+	Alias string `json:"alias"`
 
 	// Powered indicates whether the adapter radio is currently powered on.
-	Powered bool
+	Powered bool `json:"powered"`
 
 	// Transport attempts to describe the bus used by the adapter (usb, pci,
 	// platform, etc.). Selection logic can prefer specific transports when a
 	// preferred adapter is not explicitly configured.
-	Transport AdapterTransport
+	Transport AdapterTransport `json:"transport"`
+
+	// VendorID and ProductID are the four-digit hex IDs decoded from the USB
+	// or PCI modalias. Chipset is a friendly name looked up from them.
+	VendorID  string `json:"vendor_id,omitempty"`
+	ProductID string `json:"product_id,omitempty"`
+	Chipset   string `json:"chipset,omitempty"`
+
+	// Driver is the kernel driver bound to the controller (btusb, hci_uart,
+	// ...). Bus and HCIType are the HCI bus and controller type reported by
+	// the kernel, when it exposes them.
+	Driver  string `json:"driver,omitempty"`
+	Bus     string `json:"bus,omitempty"`
+	HCIType string `json:"hci_type,omitempty"`
+
+	// SoftBlocked and HardBlocked mirror the adapter's rfkill state. A hard
+	// block (physical switch or firmware) cannot be lifted from software.
+	SoftBlocked bool `json:"soft_blocked"`
+	HardBlocked bool `json:"hard_blocked"`
+
+	// The remaining fields come from BlueZ rather than sysfs and are only
+	// populated once an AdapterBackend has described the adapter.
+	Discoverable        bool          `json:"discoverable,omitempty"`
+	Pairable            bool          `json:"pairable,omitempty"`
+	DiscoverableTimeout time.Duration `json:"discoverable_timeout,omitempty"`
+	PairableTimeout     time.Duration `json:"pairable_timeout,omitempty"`
+	Class               uint32        `json:"class,omitempty"`
+	Manufacturer        int           `json:"manufacturer,omitempty"`
+	Version             int           `json:"version,omitempty"`
+	Roles               []string      `json:"roles,omitempty"`
+	UUIDs               []string      `json:"uuids,omitempty"`
+}
+
+// Usable reports whether the adapter can be used at all. Soft blocks are
+// recoverable from software, so only hard-blocked adapters are excluded.
+func (a Adapter) Usable() bool {
+	return !a.HardBlocked
 }
 
 // AdapterTransport identifies the bus type used by an adapter. Values are best
@@ -68,27 +104,9 @@ func (a Adapter) Matches(identifier string) bool {
 // SelectAdapter chooses the most appropriate adapter from the supplied list.
 // A preferred adapter identifier is honoured when provided; otherwise adapters
 // attached via USB are prioritised. If no USB adapter is present, the first
-// entry is returned.
+// entry is returned. It is the default SelectionPolicy.
 func SelectAdapter(preferred string, adapters []Adapter) (Adapter, error) {
-	if len(adapters) == 0 {
-		return Adapter{}, fmt.Errorf("select adapter: no adapters supplied")
-	}
-
-	if preferred = strings.TrimSpace(preferred); preferred != "" {
-		for _, adapter := range adapters {
-			if adapter.Matches(preferred) {
-				return adapter, nil
-			}
-		}
-	}
-
-	for _, adapter := range adapters {
-		if adapter.Transport == AdapterTransportUSB {
-			return adapter, nil
-		}
-	}
-
-	return adapters[0], nil
+	return SelectionPolicy{Tiebreak: TiebreakUSB}.Select(preferred, adapters)
 }
 
 // AdapterProvider knows how to discover adapters that are currently available
@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"bufio"
+	_ "embed"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//go:embed chipsets.txt
+var chipsetTable string
+
+var (
+	chipsetOnce  sync.Once
+	chipsetNames map[string]string
+)
+
+// adapterHardware is the hardware description sysfs exposes for an adapter.
+type adapterHardware struct {
+	vendorID  string
+	productID string
+	chipset   string
+	driver    string
+	bus       string
+	hciType   string
+}
+
+// readHardware collects the vendor and product IDs, chipset name, kernel driver and HCI
+// bus/type of the adapter at adapterPath. Missing attributes are left empty;
+// virtual controllers such as hci_vhci have no device modalias at all.
+func readHardware(adapterPath string) adapterHardware {
+	modalias := readTrimmedFile(filepath.Join(adapterPath, "device", "modalias"))
+	bus, vendor, product := parseModalias(modalias)
+
+	return adapterHardware{
+		vendorID:  vendor,
+		productID: product,
+		chipset:   chipsetName(bus, vendor, product),
+		driver:    readDriver(filepath.Join(adapterPath, "device", "driver")),
+		bus:       readTrimmedFile(filepath.Join(adapterPath, "bus")),
+		hciType:   readTrimmedFile(filepath.Join(adapterPath, "type")),
+	}
+}
+
+// parseModalias extracts the bus and the vendor and product IDs from a kernel
+// modalias such as "usb:v8087p0029d0001dcE0dsc01dp01..." or
+// "pci:v00008086d00002723sv...". IDs are returned as four lower-case hex
+// digits.
+func parseModalias(modalias string) (bus, vendor, product string) {
+	bus, rest, ok := strings.Cut(strings.TrimSpace(modalias), ":")
+	if !ok {
+		return "", "", ""
+	}
+	bus = strings.ToLower(bus)
+
+	switch bus {
+	case "usb":
+		// usb:vVVVVpPPPP...
+		if len(rest) >= 10 && rest[0] == 'v' && rest[5] == 'p' {
+			return bus, strings.ToLower(rest[1:5]), strings.ToLower(rest[6:10])
+		}
+	case "pci":
+		// pci:v0000VVVVd0000DDDD...
+		if len(rest) >= 18 && rest[0] == 'v' && rest[9] == 'd' {
+			return bus, strings.ToLower(rest[5:9]), strings.ToLower(rest[14:18])
+		}
+	}
+
+	return bus, "", ""
+}
+
+// readDriver resolves the kernel driver bound to the adapter from the driver
+// symlink, e.g. btusb or hci_uart.
+func readDriver(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// chipsetName maps bus and IDs to a friendly name from the embedded table,
+// falling back to the vendor name when the product is unknown.
+func chipsetName(bus, vendor, product string) string {
+	if vendor == "" {
+		return ""
+	}
+
+	chipsetOnce.Do(loadChipsets)
+
+	if name, ok := chipsetNames[bus+" "+vendor+":"+product]; ok {
+		return name
+	}
+	return chipsetNames[bus+" "+vendor]
+}
+
+func loadChipsets() {
+	chipsetNames = make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(chipsetTable))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		chipsetNames[strings.ToLower(key)] = strings.TrimSpace(name)
+	}
+}
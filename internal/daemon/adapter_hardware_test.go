@@ -0,0 +1,37 @@
+package daemon
+
+import "testing"
+
+func TestParseModalias(t *testing.T) {
+	tests := []struct {
+		modalias             string
+		bus, vendor, product string
+	}{
+		{"usb:v0BDAp8771d0200dcE0dsc01dp01icE0isc01ip01in00", "usb", "0bda", "8771"},
+		{"pci:v00008086d00002723sv00008086sd00000084bc02sc80i00", "pci", "8086", "2723"},
+		{"of:NbluetoothT(null)Cbrcm,bcm43438-bt", "of", "", ""},
+		{"", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		bus, vendor, product := parseModalias(tt.modalias)
+		if bus != tt.bus || vendor != tt.vendor || product != tt.product {
+			t.Errorf("parseModalias(%q) = %q, %q, %q; want %q, %q, %q", tt.modalias, bus, vendor, product, tt.bus, tt.vendor, tt.product)
+		}
+	}
+}
+
+func TestChipsetName(t *testing.T) {
+	if got := chipsetName("usb", "0bda", "8771"); got != "Realtek RTL8761B" {
+		t.Errorf("unexpected exact match: %q", got)
+	}
+	if got := chipsetName("usb", "0bda", "ffff"); got != "Realtek" {
+		t.Errorf("expected vendor fallback, got %q", got)
+	}
+	if got := chipsetName("pci", "8086", "2723"); got != "Intel" {
+		t.Errorf("expected PCI vendor name, got %q", got)
+	}
+	if got := chipsetName("usb", "ffff", "0001"); got != "" {
+		t.Errorf("expected no match, got %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"time"
+)
+
+// AdapterBackend reads and changes adapter properties that sysfs does not
+// expose. The default implementation shells out to bluetoothctl.
+type AdapterBackend interface {
+	// DescribeAdapter returns adapter with its BlueZ properties filled in.
+	DescribeAdapter(ctx context.Context, adapter Adapter) (Adapter, error)
+	SetPowered(ctx context.Context, adapter string, on bool) error
+	SetAlias(ctx context.Context, adapter, alias string) error
+	SetDiscoverable(ctx context.Context, adapter string, on bool, timeout time.Duration) error
+	SetPairable(ctx context.Context, adapter string, on bool, timeout time.Duration) error
+}
+
+// AdapterSettings are the properties the daemon applies to an adapter when it
+// appears. Nil switches and empty values leave the adapter untouched.
+type AdapterSettings struct {
+	Powered      *bool
+	Alias        string
+	Discoverable *bool
+	Pairable     *bool
+
+	// DiscoverableTimeout and PairableTimeout only apply when the matching
+	// switch turns the property on.
+	DiscoverableTimeout time.Duration
+	PairableTimeout     time.Duration
+}
+
+// adapterSettingsFor returns the settings whose key matches adapter by ID,
+// address or alias.
+func (d *Daemon) adapterSettingsFor(adapter Adapter) (AdapterSettings, bool) {
+	for identifier, settings := range d.settings().adapterSettings {
+		if adapter.Matches(identifier) {
+			return settings, true
+		}
+	}
+	return AdapterSettings{}, false
+}
+
+// configureAdapter applies the configured settings to a newly discovered
+// adapter. Failures are logged; a misconfigured property must not keep the
+// daemon from managing the adapter.
+func (d *Daemon) configureAdapter(ctx context.Context, adapter Adapter) {
+	if d.adapterBackend == nil {
+		return
+	}
+
+	settings, ok := d.adapterSettingsFor(adapter)
+	if !ok {
+		return
+	}
+
+	logger := d.log.With("adapter", adapter.ID)
+
+	if settings.Powered != nil {
+		if err := d.adapterBackend.SetPowered(ctx, adapter.ID, *settings.Powered); err != nil {
+			logger.Warn("failed to apply adapter power setting", "error", err)
+		}
+	}
+
+	if settings.Alias != "" && settings.Alias != adapter.Alias {
+		if err := d.adapterBackend.SetAlias(ctx, adapter.ID, settings.Alias); err != nil {
+			logger.Warn("failed to apply adapter alias", "error", err)
+		}
+	}
+
+	if settings.Discoverable != nil {
+		if err := d.adapterBackend.SetDiscoverable(ctx, adapter.ID, *settings.Discoverable, settings.DiscoverableTimeout); err != nil {
+			logger.Warn("failed to apply adapter discoverable setting", "error", err)
+		}
+	}
+
+	if settings.Pairable != nil {
+		if err := d.adapterBackend.SetPairable(ctx, adapter.ID, *settings.Pairable, settings.PairableTimeout); err != nil {
+			logger.Warn("failed to apply adapter pairable setting", "error", err)
+		}
+	}
+
+	logger.Info("applied adapter settings")
+}
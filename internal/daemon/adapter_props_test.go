@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordingAdapterBackend records every property change it is asked to make.
+type recordingAdapterBackend struct {
+	calls []string
+}
+
+func (b *recordingAdapterBackend) DescribeAdapter(_ context.Context, adapter Adapter) (Adapter, error) {
+	return adapter, nil
+}
+
+func (b *recordingAdapterBackend) SetPowered(_ context.Context, adapter string, on bool) error {
+	b.calls = append(b.calls, fmt.Sprintf("%s power %v", adapter, on))
+	return nil
+}
+
+func (b *recordingAdapterBackend) SetAlias(_ context.Context, adapter, alias string) error {
+	b.calls = append(b.calls, fmt.Sprintf("%s alias %s", adapter, alias))
+	return nil
+}
+
+func (b *recordingAdapterBackend) SetDiscoverable(_ context.Context, adapter string, on bool, timeout time.Duration) error {
+	b.calls = append(b.calls, fmt.Sprintf("%s discoverable %v %s", adapter, on, timeout))
+	return nil
+}
+
+func (b *recordingAdapterBackend) SetPairable(_ context.Context, adapter string, on bool, timeout time.Duration) error {
+	b.calls = append(b.calls, fmt.Sprintf("%s pairable %v %s", adapter, on, timeout))
+	return nil
+}
+
+func TestAdapterSettingsAppliedWhenAdapterAppears(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard)
+
+	on, off := true, false
+	backend := &recordingAdapterBackend{}
+	d := newTestDaemon(t, Options{
+		AdapterProvider: provider,
+		AdapterBackend:  backend,
+		AdapterSettings: map[string]AdapterSettings{
+			"00:00:00:00:00:02": {
+				Powered:             &on,
+				Alias:               "Dongle",
+				Discoverable:        &off,
+				Pairable:            &on,
+				PairableTimeout:     time.Minute,
+				DiscoverableTimeout: time.Hour,
+			},
+		},
+	})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if len(backend.calls) != 0 {
+		t.Fatalf("expected no changes for unconfigured adapter, got %v", backend.calls)
+	}
+
+	provider.set(onboard, dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	want := []string{
+		"hci1 power true",
+		"hci1 alias Dongle",
+		"hci1 discoverable false 1h0m0s",
+		"hci1 pairable true 1m0s",
+	}
+	if fmt.Sprint(backend.calls) != fmt.Sprint(want) {
+		t.Fatalf("unexpected calls:\n%v\nwant\n%v", backend.calls, want)
+	}
+
+	// Settings are only applied once per appearance.
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if len(backend.calls) != len(want) {
+		t.Fatalf("expected settings to be applied once, got %v", backend.calls)
+	}
+}
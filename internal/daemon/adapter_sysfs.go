@@ -78,13 +78,23 @@ func (p *sysfsAdapterProvider) ListAdapters(ctx context.Context) ([]Adapter, err
 		alias := readTrimmedFile(filepath.Join(adapterPath, "name"))
 		powered := parseBool(readTrimmedFile(filepath.Join(adapterPath, "powered")))
 		transport := detectTransport(adapterPath)
+		softBlocked, hardBlocked := readRfkill(adapterPath)
+		hardware := readHardware(adapterPath)
 
 		adapters = append(adapters, Adapter{
-			ID:        name,
-			Address:   address,
-			Alias:     alias,
-			Powered:   powered,
-			Transport: transport,
+			ID:          name,
+			Address:     address,
+			Alias:       alias,
+			Powered:     powered,
+			Transport:   transport,
+			VendorID:    hardware.vendorID,
+			ProductID:   hardware.productID,
+			Chipset:     hardware.chipset,
+			Driver:      hardware.driver,
+			Bus:         hardware.bus,
+			HCIType:     hardware.hciType,
+			SoftBlocked: softBlocked,
+			HardBlocked: hardBlocked,
 		})
 	}
 
@@ -122,6 +132,23 @@ func detectTransport(adapterPath string) AdapterTransport {
 	return AdapterTransportUnknown
 }
 
+// readRfkill reports the rfkill block state of the adapter. The kernel exposes
+// an rfkillN child directory for each controller; adapters without one are
+// treated as unblocked.
+func readRfkill(adapterPath string) (soft, hard bool) {
+	matches, err := filepath.Glob(filepath.Join(adapterPath, "rfkill*"))
+	if err != nil {
+		return false, false
+	}
+
+	for _, match := range matches {
+		soft = soft || parseBool(readTrimmedFile(filepath.Join(match, "soft")))
+		hard = hard || parseBool(readTrimmedFile(filepath.Join(match, "hard")))
+	}
+
+	return soft, hard
+}
+
 func readTrimmedFile(path string) string {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -189,3 +189,102 @@ func TestSysfsAdapterProviderExposesPermissionErrors(t *testing.T) {
 		t.Fatalf("expected path to be populated")
 	}
 }
+
+func TestSysfsAdapterProviderReadsRfkillState(t *testing.T) {
+	dir := t.TempDir()
+
+	rfkillDir := filepath.Join(dir, "hci0", "rfkill3")
+	if err := os.MkdirAll(rfkillDir, 0o755); err != nil {
+		t.Fatalf("failed to create rfkill dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(rfkillDir, "soft"), []byte("0\n"), 0o644); err != nil {
+		t.Fatalf("failed to write soft: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(rfkillDir, "hard"), []byte("1\n"), 0o644); err != nil {
+		t.Fatalf("failed to write hard: %v", err)
+	}
+
+	provider := NewSysfsAdapterProvider(dir)
+	adapters, err := provider.ListAdapters(context.Background())
+	if err != nil {
+		t.Fatalf("ListAdapters returned error: %v", err)
+	}
+
+	if len(adapters) != 1 {
+		t.Fatalf("expected 1 adapter, got %d", len(adapters))
+	}
+
+	if adapters[0].SoftBlocked {
+		t.Errorf("expected adapter not to be soft blocked")
+	}
+
+	if !adapters[0].HardBlocked {
+		t.Errorf("expected adapter to be hard blocked")
+	}
+
+	if adapters[0].Usable() {
+		t.Errorf("expected hard-blocked adapter to be unusable")
+	}
+}
+
+func TestSysfsAdapterProviderReadsHardwareMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	deviceDir := filepath.Join(dir, "devices", "usb1", "1-4", "1-4:1.0")
+	if err := os.MkdirAll(deviceDir, 0o755); err != nil {
+		t.Fatalf("failed to create device dir: %v", err)
+	}
+	driverDir := filepath.Join(dir, "bus", "usb", "drivers", "btusb")
+	if err := os.MkdirAll(driverDir, 0o755); err != nil {
+		t.Fatalf("failed to create driver dir: %v", err)
+	}
+	if err := os.Symlink(driverDir, filepath.Join(deviceDir, "driver")); err != nil {
+		t.Fatalf("failed to link driver: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(deviceDir, "modalias"), []byte("usb:v8087p0029d0001dcE0dsc01dp01icE0isc01ip01in00\n"), 0o644); err != nil {
+		t.Fatalf("failed to write modalias: %v", err)
+	}
+
+	adapterDir := filepath.Join(dir, "class", "hci0")
+	if err := os.MkdirAll(adapterDir, 0o755); err != nil {
+		t.Fatalf("failed to create adapter dir: %v", err)
+	}
+	if err := os.Symlink(deviceDir, filepath.Join(adapterDir, "device")); err != nil {
+		t.Fatalf("failed to link device: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(adapterDir, "bus"), []byte("USB\n"), 0o644); err != nil {
+		t.Fatalf("failed to write bus: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(adapterDir, "type"), []byte("Primary\n"), 0o644); err != nil {
+		t.Fatalf("failed to write type: %v", err)
+	}
+
+	provider := NewSysfsAdapterProvider(filepath.Join(dir, "class"))
+	adapters, err := provider.ListAdapters(context.Background())
+	if err != nil {
+		t.Fatalf("ListAdapters returned error: %v", err)
+	}
+
+	if len(adapters) != 1 {
+		t.Fatalf("expected 1 adapter, got %d", len(adapters))
+	}
+
+	adapter := adapters[0]
+	if adapter.VendorID != "8087" || adapter.ProductID != "0029" {
+		t.Errorf("unexpected IDs: %s:%s", adapter.VendorID, adapter.ProductID)
+	}
+	if adapter.Chipset != "Intel Wi-Fi 6 AX200 Bluetooth" {
+		t.Errorf("unexpected chipset: %q", adapter.Chipset)
+	}
+	if adapter.Driver != "btusb" {
+		t.Errorf("unexpected driver: %q", adapter.Driver)
+	}
+	if adapter.Bus != "USB" || adapter.HCIType != "Primary" {
+		t.Errorf("unexpected bus/type: %q/%q", adapter.Bus, adapter.HCIType)
+	}
+	if adapter.Transport != AdapterTransportUSB {
+		t.Errorf("expected usb transport, got %s", adapter.Transport)
+	}
+}
@@ -0,0 +1,227 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/devclass"
+)
+
+// DeviceKind is a coarse device category used to group devices that compete
+// for the same role, such as two headsets fighting over the audio sink.
+type DeviceKind = devclass.Kind
+
+const (
+	DeviceKindUnknown  = devclass.Unknown
+	DeviceKindHeadset  = devclass.Headset
+	DeviceKindSpeaker  = devclass.Speaker
+	DeviceKindKeyboard = devclass.Keyboard
+	DeviceKindMouse    = devclass.Mouse
+	DeviceKindGamepad  = devclass.Gamepad
+	DeviceKindPhone    = devclass.Phone
+	DeviceKindWatch    = devclass.Watch
+)
+
+// KindFromIcon maps the freedesktop icon name BlueZ derives from a device's
+// class to a DeviceKind.
+func KindFromIcon(icon string) DeviceKind {
+	switch icon {
+	case "audio-headset", "audio-headphones":
+		return DeviceKindHeadset
+	case "audio-speakers", "audio-card":
+		return DeviceKindSpeaker
+	case "input-keyboard":
+		return DeviceKindKeyboard
+	case "input-mouse", "input-tablet":
+		return DeviceKindMouse
+	case "input-gaming":
+		return DeviceKindGamepad
+	case "phone":
+		return DeviceKindPhone
+	default:
+		return DeviceKindUnknown
+	}
+}
+
+// DetectKind decodes the class of device, then the appearance and then the
+// service UUIDs, falling back to the BlueZ icon name when none of them names
+// a kind.
+func DetectKind(class uint32, appearance uint16, uuids []string, icon string) DeviceKind {
+	if kind := devclass.Detect(class, appearance, uuids); kind != devclass.Unknown {
+		return kind
+	}
+	return KindFromIcon(icon)
+}
+
+// ArbitrationDecision records a connection change the daemon made to enforce
+// per-kind connection limits.
+type ArbitrationDecision struct {
+	Time    time.Time  `json:"time"`
+	Kind    DeviceKind `json:"kind"`
+	Action  string     `json:"action"`
+	Address string     `json:"address"`
+	Adapter string     `json:"adapter,omitempty"`
+	Reason  string     `json:"reason"`
+	Error   string     `json:"error,omitempty"`
+}
+
+const maxRecordedDecisions = 32
+
+type arbitrationPlan struct {
+	connect []Device
+	evict   []ArbitrationDecision
+}
+
+// kindFor resolves the kind of dev, preferring configured settings over
+// detection. Callers must hold devMu.
+func (d *Daemon) kindFor(dev Device) DeviceKind {
+	if settings, ok := d.settings().devices[dev.Address]; ok && settings.Kind != "" && settings.Kind != DeviceKindUnknown {
+		return settings.Kind
+	}
+	if dev.Kind == "" {
+		return DeviceKindUnknown
+	}
+	return dev.Kind
+}
+
+func (d *Daemon) priorityFor(address string) int {
+	return d.settings().devices[address].Priority
+}
+
+// planArbitration decides which candidates to connect and which connected
+// devices to evict. For kinds with a connection limit, the highest-priority
+// devices among those connected or eligible for connection win the available
+// slots. Lower-priority devices are only disconnected once the limit is
+// actually exceeded, so a preferred device that fails to connect never costs
+// the user a working one. Callers must hold devMu.
+func (d *Daemon) planArbitration(devices []Device, candidates []Device, now time.Time) arbitrationPlan {
+	var plan arbitrationPlan
+	limits := d.settings().limits
+
+	byKind := make(map[DeviceKind][]Device)
+	for _, dev := range candidates {
+		if limits[dev.Kind] <= 0 {
+			plan.connect = append(plan.connect, dev)
+			continue
+		}
+		byKind[dev.Kind] = append(byKind[dev.Kind], dev)
+	}
+
+	connectedByKind := make(map[DeviceKind][]Device)
+	for _, dev := range devices {
+		if dev.Connected && limits[dev.Kind] > 0 {
+			connectedByKind[dev.Kind] = append(connectedByKind[dev.Kind], dev)
+		}
+	}
+
+	kinds := make([]DeviceKind, 0, len(limits))
+	for kind := range limits {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	for _, kind := range kinds {
+		limit := limits[kind]
+		if limit <= 0 {
+			continue
+		}
+
+		connected := connectedByKind[kind]
+		d.rankDevices(connected)
+
+		pool := append(append([]Device(nil), connected...), byKind[kind]...)
+		d.rankDevices(pool)
+		if len(pool) > limit {
+			pool = pool[:limit]
+		}
+
+		for _, dev := range pool {
+			if dev.Connected {
+				continue
+			}
+
+			plan.connect = append(plan.connect, dev)
+			if len(connected) >= limit {
+				weakest := connected[len(connected)-1]
+				d.recordDecision(ArbitrationDecision{
+					Time:    now,
+					Kind:    kind,
+					Action:  "connect",
+					Address: dev.Address,
+					Adapter: dev.Adapter,
+					Reason:  fmt.Sprintf("priority %d outranks %s (priority %d)", d.priorityFor(dev.Address), weakest.Address, d.priorityFor(weakest.Address)),
+				}, nil)
+			}
+		}
+
+		if len(connected) <= limit {
+			continue
+		}
+
+		keep := connected[:limit]
+		for _, dev := range connected[limit:] {
+			plan.evict = append(plan.evict, ArbitrationDecision{
+				Time:    now,
+				Kind:    kind,
+				Action:  "disconnect",
+				Address: dev.Address,
+				Adapter: dev.Adapter,
+				Reason:  fmt.Sprintf("%s limit of %d reached; keeping %s", kind, limit, d.describePriorities(keep)),
+			})
+		}
+	}
+
+	return plan
+}
+
+// rankDevices sorts devices by descending priority. Ties favour devices that
+// are already connected, then the one that connected first, so equal-priority
+// devices never flap. Callers must hold devMu.
+func (d *Daemon) rankDevices(devices []Device) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		a, b := devices[i], devices[j]
+		if pa, pb := d.priorityFor(a.Address), d.priorityFor(b.Address); pa != pb {
+			return pa > pb
+		}
+		if a.Connected != b.Connected {
+			return a.Connected
+		}
+		if a.Connected {
+			ta, tb := d.deviceState(a.Address).connectedAt, d.deviceState(b.Address).connectedAt
+			if !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+		}
+		return a.Address < b.Address
+	})
+}
+
+func (d *Daemon) describePriorities(devices []Device) string {
+	parts := make([]string, 0, len(devices))
+	for _, dev := range devices {
+		parts = append(parts, fmt.Sprintf("%s (priority %d)", dev.Address, d.priorityFor(dev.Address)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// recordDecision logs an arbitration decision and keeps it for status
+// queries. Decisions are guarded by their own lock so this is safe to call
+// with or without devMu held.
+func (d *Daemon) recordDecision(decision ArbitrationDecision, err error) {
+	if err != nil {
+		decision.Error = err.Error()
+		d.log.Warn("arbitration action failed", "kind", decision.Kind, "action", decision.Action, "address", decision.Address, "reason", decision.Reason, "error", err)
+	} else {
+		d.log.Info("arbitration decision", "kind", decision.Kind, "action", decision.Action, "address", decision.Address, "reason", decision.Reason)
+	}
+
+	d.decisionMu.Lock()
+	defer d.decisionMu.Unlock()
+
+	d.decisions = append(d.decisions, decision)
+	if len(d.decisions) > maxRecordedDecisions {
+		d.decisions = d.decisions[len(d.decisions)-maxRecordedDecisions:]
+	}
+}
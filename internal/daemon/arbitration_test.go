@@ -0,0 +1,203 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+)
+
+const (
+	primaryHeadset   = "11:11:11:11:11:11"
+	secondaryHeadset = "22:22:22:22:22:22"
+)
+
+// arbitrationOptions configures two headsets of different priority competing
+// for a single headset slot.
+func arbitrationOptions(backend DeviceBackend, clock *fakeClock) Options {
+	always := ReconnectPolicy{Mode: ReconnectAlways}
+	return Options{
+		DeviceBackend: backend,
+		Devices: map[string]DeviceSettings{
+			primaryHeadset:   {Reconnect: always, Priority: 10},
+			secondaryHeadset: {Reconnect: always, Priority: 1},
+		},
+		ConnectionLimits: map[DeviceKind]int{DeviceKindHeadset: 1},
+		Clock:            clock.Now,
+	}
+}
+
+func TestArbitrationDisconnectsLowerPriority(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(
+		Device{Address: primaryHeadset, Kind: DeviceKindHeadset},
+		Device{Address: secondaryHeadset, Kind: DeviceKindHeadset, Connected: true},
+	)
+	d := newTestDaemon(t, arbitrationOptions(backend, clock))
+
+	// The preferred headset appears while the secondary holds the only slot.
+	backend.setConnected(primaryHeadset, true)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	backend.mu.Lock()
+	secondaryConnected := backend.devices[secondaryHeadset].Connected
+	backend.mu.Unlock()
+	if secondaryConnected {
+		t.Fatalf("expected lower-priority headset to be disconnected")
+	}
+
+	status := d.Status()
+	if len(status.Decisions) != 1 {
+		t.Fatalf("expected one decision, got %+v", status.Decisions)
+	}
+	decision := status.Decisions[0]
+	if decision.Action != "disconnect" || decision.Address != secondaryHeadset || decision.Kind != DeviceKindHeadset {
+		t.Fatalf("unexpected decision: %+v", decision)
+	}
+
+	// The evicted headset must not be reconnected while the slot is taken.
+	clock.Advance(time.Minute)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no reconnect attempts, got %v", backend.connects)
+	}
+}
+
+func TestArbitrationPrefersHigherPriorityWhenReconnecting(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(
+		Device{Address: primaryHeadset, Kind: DeviceKindHeadset},
+		Device{Address: secondaryHeadset, Kind: DeviceKindHeadset},
+	)
+	d := newTestDaemon(t, arbitrationOptions(backend, clock))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if len(backend.connects) != 1 || backend.connects[0] != primaryHeadset {
+		t.Fatalf("expected only the primary headset to be connected, got %v", backend.connects)
+	}
+}
+
+func TestArbitrationFallsBackWhilePreferredIsBackingOff(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := &failingBackend{
+		fakeBackend: newFakeBackend(
+			Device{Address: primaryHeadset, Kind: DeviceKindHeadset},
+			Device{Address: secondaryHeadset, Kind: DeviceKindHeadset},
+		),
+		fail: map[string]bool{primaryHeadset: true},
+	}
+	d := newTestDaemon(t, arbitrationOptions(backend, clock))
+
+	// First poll only tries the preferred headset, which fails.
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	// While the preferred headset backs off, the secondary takes the slot.
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	want := []string{primaryHeadset, secondaryHeadset}
+	if len(backend.connects) != len(want) || backend.connects[0] != want[0] || backend.connects[1] != want[1] {
+		t.Fatalf("unexpected connect order: %v", backend.connects)
+	}
+}
+
+func TestArbitrationIgnoresUnlimitedKinds(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(
+		Device{Address: "33:33:33:33:33:33", Kind: DeviceKindKeyboard, Connected: true, Trusted: true},
+		Device{Address: "44:44:44:44:44:44", Kind: DeviceKindKeyboard, Connected: true, Trusted: true},
+	)
+	d := newTestDaemon(t, arbitrationOptions(backend, clock))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if decisions := d.Status().Decisions; len(decisions) != 0 {
+		t.Fatalf("expected no decisions for unlimited kinds, got %+v", decisions)
+	}
+}
+
+func TestStatusControlCommand(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: primaryHeadset, Name: "Studio", Kind: DeviceKindHeadset, Connected: true})
+	d := newTestDaemon(t, arbitrationOptions(backend, clock))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	resp := d.HandleControl(ctx, control.Request{Command: "status"})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+
+	var status Status
+	if err := resp.Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+
+	if len(status.Devices) != 1 {
+		t.Fatalf("expected one device, got %+v", status.Devices)
+	}
+	dev := status.Devices[0]
+	if dev.Name != "Studio" || dev.Priority != 10 || !dev.Connected || dev.Kind != DeviceKindHeadset {
+		t.Fatalf("unexpected device status: %+v", dev)
+	}
+	if status.Limits[DeviceKindHeadset] != 1 {
+		t.Fatalf("unexpected limits: %v", status.Limits)
+	}
+}
+
+// failingBackend wraps fakeBackend and fails Connect for selected devices.
+type failingBackend struct {
+	*fakeBackend
+	fail map[string]bool
+}
+
+func (b *failingBackend) Connect(ctx context.Context, adapter, address string) error {
+	if b.fail[address] {
+		b.mu.Lock()
+		b.connects = append(b.connects, address)
+		b.mu.Unlock()
+		return errPageTimeout
+	}
+	return b.fakeBackend.Connect(ctx, adapter, address)
+}
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		class      uint32
+		appearance uint16
+		uuids      []string
+		icon       string
+		want       DeviceKind
+	}{
+		{"class", 0x002508, 0, nil, "input-keyboard", DeviceKindGamepad},
+		{"appearance", 0, 0x00c2, nil, "", DeviceKindWatch},
+		{"services", 0, 0, []string{"0000111f-0000-1000-8000-00805f9b34fb"}, "", DeviceKindPhone},
+		{"icon fallback", 0x00010c, 0, nil, "input-mouse", DeviceKindMouse},
+		{"nothing", 0, 0, nil, "", DeviceKindUnknown},
+	}
+	for _, tt := range tests {
+		if got := DetectKind(tt.class, tt.appearance, tt.uuids, tt.icon); got != tt.want {
+			t.Errorf("%s: DetectKind = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+// AudioProfile selects how an audio device is used once connected.
+type AudioProfile = policy.AudioProfile
+
+const (
+	AudioProfileA2DP    = policy.AudioProfileA2DP
+	AudioProfileHeadset = policy.AudioProfileHeadset
+	AudioProfileOff     = policy.AudioProfileOff
+)
+
+// AudioSettings are the audio preferences applied when a device connects.
+type AudioSettings = policy.AudioSettings
+
+// AudioBackend applies audio preferences to a connected device through the
+// sound server.
+type AudioBackend interface {
+	ApplyAudio(ctx context.Context, address string, settings AudioSettings) error
+}
@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/peared/peared/internal/bluetoothctl"
+)
+
+// NewBluetoothctlBackend returns a DeviceBackend that drives devices through
+// the supplied bluetoothctl runner.
+func NewBluetoothctlBackend(runner *bluetoothctl.Runner) DeviceBackend {
+	return &bluetoothctlBackend{runner: runner}
+}
+
+// NewBluetoothctlAdapterBackend returns an AdapterBackend that reads and
+// changes adapter properties through the supplied bluetoothctl runner.
+func NewBluetoothctlAdapterBackend(runner *bluetoothctl.Runner) AdapterBackend {
+	return &bluetoothctlBackend{runner: runner}
+}
+
+type bluetoothctlBackend struct {
+	runner *bluetoothctl.Runner
+}
+
+func (b *bluetoothctlBackend) ListDevices(ctx context.Context, adapter string) ([]Device, error) {
+	if b.runner == nil {
+		return nil, errors.New("bluetoothctl runner not configured")
+	}
+
+	runner := b.runnerFor(adapter)
+	entries, err := runner.Devices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+
+	devices := make([]Device, 0, len(entries))
+	for _, entry := range entries {
+		info, err := runner.Info(ctx, entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("inspect device %s: %w", entry.Address, err)
+		}
+
+		name := info.Name
+		if name == "" {
+			name = entry.Name
+		}
+
+		devices = append(devices, Device{
+			Address:    NormalizeAddress(entry.Address),
+			Name:       name,
+			Alias:      info.Alias,
+			Paired:     info.Paired,
+			Trusted:    info.Trusted,
+			Connected:  info.Connected,
+			InRange:    info.HasRSSI,
+			RSSI:       info.RSSI,
+			Battery:    info.Battery,
+			HasBattery: info.HasBattery,
+			Kind:       DetectKind(info.Class, info.Appearance, info.UUIDs, info.Icon),
+			Adapter:    adapter,
+		})
+	}
+
+	return devices, nil
+}
+
+func (b *bluetoothctlBackend) Connect(ctx context.Context, adapter, address string) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).Connect(ctx, address)
+	return err
+}
+
+func (b *bluetoothctlBackend) Disconnect(ctx context.Context, adapter, address string) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).Disconnect(ctx, address)
+	return err
+}
+
+func (b *bluetoothctlBackend) DescribeAdapter(ctx context.Context, adapter Adapter) (Adapter, error) {
+	if b.runner == nil {
+		return adapter, errors.New("bluetoothctl runner not configured")
+	}
+
+	info, err := b.runnerFor(adapter.ID).Show(ctx)
+	if err != nil {
+		return adapter, fmt.Errorf("show adapter %s: %w", adapter.ID, err)
+	}
+
+	if adapter.Address == "" {
+		adapter.Address = info.Address
+	}
+	if info.Alias != "" {
+		adapter.Alias = info.Alias
+	}
+	adapter.Powered = info.Powered
+	adapter.Discoverable = info.Discoverable
+	adapter.Pairable = info.Pairable
+	adapter.DiscoverableTimeout = info.DiscoverableTimeout
+	adapter.PairableTimeout = info.PairableTimeout
+	adapter.Class = info.Class
+	adapter.Manufacturer = info.Manufacturer
+	adapter.Version = info.Version
+	adapter.Roles = info.Roles
+	adapter.UUIDs = info.UUIDs
+
+	return adapter, nil
+}
+
+func (b *bluetoothctlBackend) SetPowered(ctx context.Context, adapter string, on bool) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).SetPowered(ctx, on)
+	return err
+}
+
+func (b *bluetoothctlBackend) SetAlias(ctx context.Context, adapter, alias string) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).SetAlias(ctx, alias)
+	return err
+}
+
+func (b *bluetoothctlBackend) SetDiscoverable(ctx context.Context, adapter string, on bool, timeout time.Duration) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).SetDiscoverable(ctx, on, timeout)
+	return err
+}
+
+func (b *bluetoothctlBackend) SetPairable(ctx context.Context, adapter string, on bool, timeout time.Duration) error {
+	if b.runner == nil {
+		return errors.New("bluetoothctl runner not configured")
+	}
+
+	_, err := b.runnerFor(adapter).SetPairable(ctx, on, timeout)
+	return err
+}
+
+func (b *bluetoothctlBackend) runnerFor(adapter string) *bluetoothctl.Runner {
+	if adapter == "" {
+		return b.runner
+	}
+	return b.runner.ForAdapter(adapter)
+}
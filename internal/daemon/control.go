@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/peared/peared/internal/control"
+)
+
+// HandleControl implements control.Handler so the daemon can serve requests
+// from the peared CLI.
+func (d *Daemon) HandleControl(ctx context.Context, req control.Request) control.Response {
+	switch req.Command {
+	case "status":
+		return control.OK(d.Status())
+	case "device.hold":
+		address := req.Arg("address")
+		if address == "" {
+			return control.Errorf("device.hold requires an address")
+		}
+		d.HoldDevice(address)
+		return control.OK(nil)
+	case "device.release":
+		address := req.Arg("address")
+		if address == "" {
+			return control.Errorf("device.release requires an address")
+		}
+		d.ReleaseDevice(address)
+		return control.OK(nil)
+	case "pairing.prompts":
+		if !d.pairingAgentRunning() {
+			return control.Errorf("pairing agent is not running")
+		}
+		return control.OK(d.PairingPrompts())
+	case "pairing.reply":
+		id := req.Arg("id")
+		if id == "" {
+			return control.Errorf("pairing.reply requires an id")
+		}
+		accept, err := strconv.ParseBool(req.Arg("accept"))
+		if err != nil {
+			return control.Errorf("pairing.reply: invalid accept value %q", req.Arg("accept"))
+		}
+		if err := d.AnswerPrompt(id, accept, req.Arg("value")); err != nil {
+			return control.Errorf("%v", err)
+		}
+		return control.OK(nil)
+	default:
+		return control.Errorf("unknown command %q", req.Command)
+	}
+}
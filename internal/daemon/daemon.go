@@ -3,10 +3,19 @@ package daemon
 import (
 	"context"
 	"errors"
-	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatteryLow   = 20
 )
 
 // Options configures the behavior of the daemon when constructed.
@@ -28,19 +37,126 @@ type Options struct {
 
 	// ConfigLoaded indicates whether a configuration file was found on disk.
 	ConfigLoaded bool
+
+	// DeviceBackend performs device operations such as reconnecting dropped
+	// devices. Device management is disabled when nil.
+	DeviceBackend DeviceBackend
+
+	// Devices holds per-device settings keyed by MAC address.
+	Devices map[string]DeviceSettings
+
+	// DefaultReconnect applies to trusted devices without explicit settings.
+	DefaultReconnect ReconnectPolicy
+
+	// ConnectionLimits caps how many devices of each kind may be connected at
+	// once. Kinds without an entry are unlimited.
+	ConnectionLimits map[DeviceKind]int
+
+	// PollInterval controls how often device state is refreshed. Zero selects
+	// a sensible default.
+	PollInterval time.Duration
+
+	// BatteryLow is the charge in percent at or below which a device's
+	// battery is reported as low. Zero selects a sensible default.
+	BatteryLow int
+
+	// FailoverReconnect reconnects devices that were connected through an
+	// adapter that disappeared when they are also bonded with the adapter the
+	// daemon fails over to.
+	FailoverReconnect bool
+
+	// SelectionPolicy ranks adapters when choosing the active one. The zero
+	// value behaves like SelectAdapter.
+	SelectionPolicy SelectionPolicy
+
+	// AdapterBackend changes adapter properties. Leaving it nil disables
+	// AdapterSettings.
+	AdapterBackend AdapterBackend
+
+	// AdapterSettings maps adapter identifiers (ID, address or alias) to the
+	// properties applied whenever a matching adapter appears.
+	AdapterSettings map[string]AdapterSettings
+
+	// Watchdog probes the active adapter and resets it when it stops
+	// working. It is disabled while Watchdog.Recoverer is nil.
+	Watchdog WatchdogSettings
+
+	// SleepMonitor delivers suspend and resume notifications. Suspend
+	// handling is disabled when nil.
+	SleepMonitor SleepMonitor
+
+	// SuspendAction applies to connected devices without a per-device
+	// suspend action. The zero value leaves them connected.
+	SuspendAction SuspendAction
+
+	// AudioBackend applies the devices' audio settings when they connect.
+	// Audio settings are ignored while it is nil.
+	AudioBackend AudioBackend
+
+	// HookRunner runs the devices' connect and disconnect hooks. Nil
+	// selects ShellHooks.
+	HookRunner HookRunner
+
+	// Pairing registers a BlueZ pairing agent and routes its prompts to
+	// control clients. It is disabled while Pairing.Agent is nil.
+	Pairing PairingSettings
+
+	// ControlSocket is the Unix socket path the daemon listens on for CLI
+	// requests. Leaving it empty disables the control API.
+	ControlSocket string
+
+	// Clock overrides time.Now, primarily for tests.
+	Clock func() time.Time
 }
 
 // Daemon represents the long-running coordination process that will manage
 // Bluetooth adapters and connections.
 type Daemon struct {
-	preferredAdapter string
-	log              *slog.Logger
-	configSource     string
-	configLoaded     bool
+	log          *slog.Logger
+	configSource string
+	configLoaded bool
+
+	live            atomic.Pointer[runtimeSettings]
+	reloaded        chan struct{}
+	reapplyAdapters atomic.Bool
 
 	mu            sync.RWMutex
 	adapterProv   AdapterProvider
 	activeAdapter *Adapter
+	adapters      []Adapter
+	adaptersSeen  bool
+
+	deviceBackend DeviceBackend
+	actions       DeviceActions
+	actionsWG     sync.WaitGroup
+	controlSocket string
+	now           func() time.Time
+
+	adapterBackend AdapterBackend
+
+	events eventBus
+
+	watchdog      WatchdogSettings
+	watchdogState watchdogState
+
+	sleepMonitor SleepMonitor
+	sleepMu      sync.Mutex
+	asleep       bool
+	sleepLock    io.Closer
+	beforeSleep  sleepSnapshot
+
+	pairing      PairingSettings
+	promptMu     sync.Mutex
+	prompts      map[string]*pendingPrompt
+	promptSeq    int
+	agentRunning bool
+
+	devMu        sync.Mutex
+	devStates    map[string]*reconnectState
+	knownDevices []Device
+
+	decisionMu sync.Mutex
+	decisions  []ArbitrationDecision
 }
 
 // New constructs a Daemon from the provided options.
@@ -58,13 +174,47 @@ func New(opts Options) (*Daemon, error) {
 		provider = DefaultAdapterProvider()
 	}
 
-	return &Daemon{
-		preferredAdapter: opts.PreferredAdapter,
-		log:              logger,
-		configSource:     opts.ConfigSource,
-		configLoaded:     opts.ConfigLoaded,
-		adapterProv:      provider,
-	}, nil
+	hooks := opts.HookRunner
+	if hooks == nil {
+		hooks = ShellHooks{}
+	}
+
+	clock := opts.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	d := &Daemon{
+		log:            logger,
+		configSource:   opts.ConfigSource,
+		configLoaded:   opts.ConfigLoaded,
+		reloaded:       make(chan struct{}, 1),
+		adapterProv:    provider,
+		deviceBackend:  opts.DeviceBackend,
+		actions:        DeviceActions{Audio: opts.AudioBackend, Hooks: hooks},
+		adapterBackend: opts.AdapterBackend,
+		watchdog:       opts.Watchdog.withDefaults(),
+		sleepMonitor:   opts.SleepMonitor,
+		pairing:        opts.Pairing,
+		prompts:        make(map[string]*pendingPrompt),
+		controlSocket:  opts.ControlSocket,
+		now:            clock,
+		devStates:      make(map[string]*reconnectState),
+	}
+	d.live.Store(newRuntimeSettings(Settings{
+		PreferredAdapter:  opts.PreferredAdapter,
+		Devices:           opts.Devices,
+		DefaultReconnect:  opts.DefaultReconnect,
+		ConnectionLimits:  opts.ConnectionLimits,
+		PollInterval:      opts.PollInterval,
+		FailoverReconnect: opts.FailoverReconnect,
+		SelectionPolicy:   opts.SelectionPolicy,
+		AdapterSettings:   opts.AdapterSettings,
+		SuspendAction:     opts.SuspendAction,
+		PairingTimeout:    opts.Pairing.Timeout,
+		BatteryLow:        opts.BatteryLow,
+	}))
+	return d, nil
 }
 
 // Run starts the daemon loop and blocks until the context is cancelled or an
@@ -75,7 +225,11 @@ func (d *Daemon) Run(ctx context.Context) error {
 	}
 
 	if d.adapterProv != nil {
-		if err := d.refreshAdapters(ctx); err != nil {
+		// Without a controller the daemon keeps polling, so one plugged in
+		// after boot is picked up like any other adapter that appears.
+		if err := d.refreshAdapters(ctx); errors.Is(err, errNoAdapters) {
+			d.log.Warn("no adapters found; waiting for one to appear")
+		} else if err != nil {
 			return err
 		}
 	}
@@ -88,8 +242,65 @@ func (d *Daemon) Run(ctx context.Context) error {
 		}
 	}
 
-	d.log.Info("daemon started", "preferred_adapter", d.preferredAdapter, "config_source", d.configSource, "config_loaded", d.configLoaded, "active_adapter", activeAdapter)
+	d.log.Info("daemon started", "preferred_adapter", d.settings().preferredAdapter, "config_source", d.configSource, "config_loaded", d.configLoaded, "active_adapter", activeAdapter)
+
+	var wg sync.WaitGroup
+	if d.controlSocket != "" {
+		ln, err := control.Listen(d.controlSocket)
+		if err != nil {
+			d.log.Warn("control API disabled", "socket", d.controlSocket, "error", err)
+		} else {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := control.Serve(ctx, ln, d); err != nil {
+					d.log.Error("control API stopped", "error", err)
+				}
+			}()
+		}
+	}
+
+	// Reconcile once immediately so devices are handled without waiting a
+	// full poll interval after startup.
+	if err := d.reconcileDevices(ctx); err != nil && ctx.Err() == nil {
+		d.log.Warn("device poll failed", "error", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.poll(ctx)
+	}()
+
+	if d.sleepMonitor != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.watchSleep(ctx)
+		}()
+	}
+
+	if d.pairing.Agent != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.servePairing(ctx)
+		}()
+	}
+
+	if d.watchdog.Recoverer != nil && d.adapterProv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.watch(ctx)
+		}()
+	}
+
 	<-ctx.Done()
+	wg.Wait()
+	// Device actions are only started by the loops above, so none start
+	// once they have stopped.
+	d.actionsWG.Wait()
 
 	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		d.log.Error("daemon exiting due to context error", "error", err)
@@ -113,34 +324,33 @@ func (d *Daemon) ActiveAdapter() (Adapter, bool) {
 	return adapter, true
 }
 
-func (d *Daemon) refreshAdapters(ctx context.Context) error {
-	if d.adapterProv == nil {
-		return errors.New("adapter provider not configured")
-	}
+// poll periodically refreshes adapters, failing over when the active one
+// disappears, and reconciles device state. A reload triggers an immediate
+// pass so new settings apply without waiting for the ticker.
+func (d *Daemon) poll(ctx context.Context) {
+	interval := d.settings().pollInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	adapters, err := d.adapterProv.ListAdapters(ctx)
-	if err != nil {
-		return fmt.Errorf("list adapters: %w", err)
-	}
-
-	if len(adapters) == 0 {
-		return errors.New("no adapters discovered")
-	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.reloaded:
+			if next := d.settings().pollInterval; next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+			d.applyReload(ctx)
+		}
 
-	chosen, err := SelectAdapter(d.preferredAdapter, adapters)
-	if err != nil {
-		return err
-	}
+		if err := d.refreshAdapters(ctx); err != nil && !errors.Is(err, errNoAdapters) && ctx.Err() == nil {
+			d.log.Warn("adapter refresh failed", "error", err)
+		}
 
-	d.mu.Lock()
-	d.activeAdapter = &Adapter{
-		ID:        chosen.ID,
-		Address:   chosen.Address,
-		Alias:     chosen.Alias,
-		Powered:   chosen.Powered,
-		Transport: chosen.Transport,
+		if err := d.reconcileDevices(ctx); err != nil && ctx.Err() == nil {
+			d.log.Warn("device poll failed", "error", err)
+		}
 	}
-	d.mu.Unlock()
-
-	return nil
 }
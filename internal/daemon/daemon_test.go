@@ -19,15 +19,11 @@ func TestNewUsesDefaultLogger(t *testing.T) {
 }
 
 func TestRunRespectsCancellation(t *testing.T) {
-	d, err := New(Options{
-		Logger: slog.New(slog.NewTextHandler(testWriter{t}, nil)),
+	d := newTestDaemon(t, Options{
 		AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
 			return []Adapter{{ID: "test"}}, nil
 		}),
 	})
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -40,20 +36,16 @@ func TestRunRespectsCancellation(t *testing.T) {
 }
 
 func TestRunPropagatesContextError(t *testing.T) {
-	d, err := New(Options{
-		Logger: slog.New(slog.NewTextHandler(testWriter{t}, nil)),
+	d := newTestDaemon(t, Options{
 		AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
 			return []Adapter{{ID: "test"}}, nil
 		}),
 	})
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
 
 	ctx, cancel := context.WithCancelCause(context.Background())
 	cancel(errors.New("boom"))
 
-	err = d.Run(ctx)
+	err := d.Run(ctx)
 	if err == nil || err.Error() != "boom" {
 		t.Fatalf("expected boom error, got %v", err)
 	}
@@ -71,6 +63,23 @@ func (w testWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// newTestDaemon constructs a Daemon that logs through t. Tests that leave
+// Clock unset get a clock stopped at a fixed time.
+func newTestDaemon(t *testing.T, opts Options) *Daemon {
+	t.Helper()
+
+	opts.Logger = slog.New(slog.NewTextHandler(testWriter{t}, nil))
+	if opts.Clock == nil {
+		opts.Clock = (&fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}).Now
+	}
+
+	d, err := New(opts)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return d
+}
+
 func TestRunNilContext(t *testing.T) {
 	d, err := New(Options{AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
 		return []Adapter{{ID: "test"}}, nil
@@ -86,15 +95,11 @@ func TestRunNilContext(t *testing.T) {
 }
 
 func TestRunBlocksUntilCancelled(t *testing.T) {
-	d, err := New(Options{
-		Logger: slog.New(slog.NewTextHandler(testWriter{t}, nil)),
+	d := newTestDaemon(t, Options{
 		AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
 			return []Adapter{{ID: "test"}}, nil
 		}),
 	})
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -128,14 +133,10 @@ func TestRunSelectsPreferredAdapter(t *testing.T) {
 		return adapters, nil
 	})
 
-	d, err := New(Options{
-		Logger:           slog.New(slog.NewTextHandler(testWriter{t}, nil)),
+	d := newTestDaemon(t, Options{
 		AdapterProvider:  provider,
 		PreferredAdapter: "hci1",
 	})
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -173,13 +174,9 @@ func TestRunFallsBackToFirstAdapter(t *testing.T) {
 		return adapters, nil
 	})
 
-	d, err := New(Options{
-		Logger:          slog.New(slog.NewTextHandler(testWriter{t}, nil)),
+	d := newTestDaemon(t, Options{
 		AdapterProvider: provider,
 	})
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -211,6 +208,47 @@ func TestRunFallsBackToFirstAdapter(t *testing.T) {
 	<-done
 }
 
+func TestRunWaitsForFirstAdapter(t *testing.T) {
+	provider := &adapterSet{}
+	d := newTestDaemon(t, Options{
+		AdapterProvider:  provider,
+		PreferredAdapter: "hci1",
+		PollInterval:     5 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		if err := d.Run(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-done:
+		t.Fatal("daemon exited without adapters")
+	}
+
+	provider.set(onboard, dongle)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if adapter, ok := d.ActiveAdapter(); ok && adapter.ID == "hci1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("adapter plugged in after startup was not selected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
 func TestSelectAdapterHonoursPreference(t *testing.T) {
 	adapters := []Adapter{
 		{ID: "hci0", Transport: AdapterTransportUSB},
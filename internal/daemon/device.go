@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+
+	"github.com/peared/peared/internal/btaddr"
+)
+
+// Device describes a Bluetooth peripheral known to the active adapter.
+type Device struct {
+	// Address is the device MAC address in canonical upper-case form.
+	Address string
+
+	// Name is the name advertised by the device.
+	Name string
+
+	// Alias is the user-facing label stored by BlueZ.
+	Alias string
+
+	Paired    bool
+	Trusted   bool
+	Connected bool
+
+	// InRange reports whether the device was recently seen advertising. BlueZ
+	// only exposes RSSI for devices discovered during an active or recent scan,
+	// so this is a best-effort signal.
+	InRange bool
+
+	// RSSI is the last observed signal strength when InRange is true.
+	RSSI int
+
+	// Battery is the charge in percent the device reports. HasBattery is
+	// false for devices that do not report one.
+	Battery    int
+	HasBattery bool
+
+	// Kind is the detected device category. Configured settings override it.
+	Kind DeviceKind
+
+	// Adapter is the ID of the controller the device was observed through.
+	Adapter string
+}
+
+// DeviceBackend performs device operations on behalf of the daemon. The
+// default implementation shells out to bluetoothctl; tests provide fakes.
+// Every call names the adapter to operate through; an empty adapter defers to
+// the backend's default controller.
+type DeviceBackend interface {
+	ListDevices(ctx context.Context, adapter string) ([]Device, error)
+	Connect(ctx context.Context, adapter, address string) error
+	Disconnect(ctx context.Context, adapter, address string) error
+}
+
+// DeviceSettings carries the per-device configuration the daemon applies.
+type DeviceSettings struct {
+	// Reconnect controls whether and how the daemon reconnects the device
+	// after it drops.
+	Reconnect ReconnectPolicy
+
+	// Priority ranks the device against others of the same kind. Higher
+	// values win when a connection limit forces a choice.
+	Priority int
+
+	// Kind overrides the detected device kind when non-empty.
+	Kind DeviceKind
+
+	// Adapter pins the device to a controller, matched by ID, address or
+	// alias. Pinned devices are only observed and connected through that
+	// controller and are skipped while it is absent.
+	Adapter string
+
+	// Suspend overrides the daemon-wide suspend action when non-empty.
+	Suspend SuspendAction
+
+	// AutoAccept answers the pairing agent's confirmation and authorization
+	// requests for the device without prompting.
+	AutoAccept bool
+
+	// Nickname is the configured short name, passed to hooks.
+	Nickname string
+
+	// Audio is applied through the AudioBackend when the device connects.
+	Audio AudioSettings
+
+	// Hooks run when the device connects or disconnects.
+	Hooks DeviceHooks
+}
+
+// Matches returns true when the device corresponds to the provided
+// identifier. Like Adapter.Matches it compares the address, name and alias
+// case-insensitively.
+func (d Device) Matches(identifier string) bool {
+	id := strings.TrimSpace(identifier)
+	if id == "" {
+		return false
+	}
+
+	return strings.EqualFold(d.Address, id) || strings.EqualFold(d.Name, id) || strings.EqualFold(d.Alias, id)
+}
+
+// label names the device in event messages: its alias or name followed by
+// the address, or the address alone.
+func (d Device) label() string {
+	name := d.Alias
+	if name == "" {
+		name = d.Name
+	}
+	if name == "" || name == strings.ReplaceAll(d.Address, ":", "-") {
+		return d.Address
+	}
+	return name + " (" + d.Address + ")"
+}
+
+// IsAddress reports whether value is a MAC address written as six
+// colon-separated pairs of hex digits.
+func IsAddress(value string) bool {
+	return btaddr.Valid(value)
+}
+
+// NormalizeAddress upper-cases and trims a MAC address so lookups are
+// insensitive to how users typed it in configuration or on the command line.
+func NormalizeAddress(address string) string {
+	return btaddr.Normalize(address)
+}
@@ -0,0 +1,17 @@
+package daemon
+
+import "testing"
+
+func TestDeviceMatches(t *testing.T) {
+	device := Device{Address: headset, Name: "WH-1000XM4", Alias: "Work headphones"}
+	for _, id := range []string{"aa:bb:cc:dd:ee:ff", "wh-1000xm4", " Work Headphones "} {
+		if !device.Matches(id) {
+			t.Errorf("expected %q to match", id)
+		}
+	}
+	for _, id := range []string{"", "WH", "Work"} {
+		if device.Matches(id) {
+			t.Errorf("expected %q not to match", id)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+)
+
+// EventType identifies the kind of state transition an Event describes.
+type EventType string
+
+const (
+	EventAdapterAdded    EventType = "adapter.added"
+	EventAdapterRemoved  EventType = "adapter.removed"
+	EventAdapterBlocked  EventType = "adapter.blocked"
+	EventAdapterSwitched EventType = "adapter.switched"
+	EventAdapterLost     EventType = "adapter.lost"
+
+	EventDeviceMigrated     EventType = "device.migrated"
+	EventDeviceConnected    EventType = "device.connected"
+	EventDeviceDisconnected EventType = "device.disconnected"
+	EventBatteryLow         EventType = "device.battery_low"
+
+	EventAdapterUnhealthy   EventType = "adapter.unhealthy"
+	EventAdapterRecovered   EventType = "adapter.recovered"
+	EventRecoveryFailed     EventType = "adapter.recovery_failed"
+	EventRecoverySuppressed EventType = "adapter.recovery_suppressed"
+
+	EventSystemSleep  EventType = "system.sleep"
+	EventSystemResume EventType = "system.resume"
+
+	EventPairingRequest  EventType = "pairing.request"
+	EventPairingDisplay  EventType = "pairing.display"
+	EventPairingResolved EventType = "pairing.resolved"
+
+	EventConfigReloaded EventType = "config.reloaded"
+)
+
+// Event describes a state transition observed by the daemon. Events are
+// logged and fanned out to subscribers such as `peared events`.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	Adapter  string    `json:"adapter,omitempty"`
+	Previous string    `json:"previous,omitempty"`
+	Address  string    `json:"address,omitempty"`
+	Message  string    `json:"message"`
+
+	// Prompt carries the pairing prompt for pairing events.
+	Prompt *PairingPrompt `json:"prompt,omitempty"`
+}
+
+const eventBufferSize = 64
+
+type eventBus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]chan Event
+}
+
+// Subscribe registers for daemon events. Slow subscribers miss events rather
+// than stalling the daemon. The returned function cancels the subscription and
+// closes the channel.
+func (d *Daemon) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	d.events.mu.Lock()
+	if d.events.subs == nil {
+		d.events.subs = make(map[int]chan Event)
+	}
+	id := d.events.nextID
+	d.events.nextID++
+	d.events.subs[id] = ch
+	d.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			d.events.mu.Lock()
+			delete(d.events.subs, id)
+			d.events.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// emit logs the event and delivers it to every subscriber.
+func (d *Daemon) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = d.now()
+	}
+
+	d.log.Info("event", "type", ev.Type, "adapter", ev.Adapter, "previous", ev.Previous, "address", ev.Address, "message", ev.Message)
+
+	d.events.mu.Lock()
+	defer d.events.mu.Unlock()
+
+	for _, ch := range d.events.subs {
+		select {
+		case ch <- ev:
+		default:
+			d.log.Debug("dropping event for slow subscriber", "type", ev.Type)
+		}
+	}
+}
+
+// HandleStream implements control.StreamHandler by forwarding daemon events
+// to the subscriber until it disconnects.
+func (d *Daemon) HandleStream(ctx context.Context, _ control.Request, send func(any) error) error {
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-events:
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
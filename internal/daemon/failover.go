@@ -0,0 +1,253 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errNoAdapters is returned by refreshAdapters when discovery succeeds but
+// finds no controllers. The poll loop reports this through an event instead
+// of logging it on every tick.
+var errNoAdapters = errors.New("no adapters discovered")
+
+// refreshAdapters rediscovers adapters and keeps the active adapter pointed at
+// the best usable controller. The first call performs the initial selection;
+// later calls fail over when the active adapter vanishes or becomes
+// hard-blocked and switch back once the preferred adapter returns. Each
+// transition is emitted as an event.
+func (d *Daemon) refreshAdapters(ctx context.Context) error {
+	if d.adapterProv == nil {
+		return errors.New("adapter provider not configured")
+	}
+
+	adapters, err := d.adapterProv.ListAdapters(ctx)
+	if err != nil {
+		return fmt.Errorf("list adapters: %w", err)
+	}
+
+	d.mu.Lock()
+	initial := !d.adaptersSeen
+	previousAdapters := d.adapters
+	var previous *Adapter
+	if d.activeAdapter != nil {
+		copied := *d.activeAdapter
+		previous = &copied
+	}
+
+	next, reason := d.chooseActive(previous, adapters)
+
+	d.adaptersSeen = true
+	d.adapters = append([]Adapter(nil), adapters...)
+	d.activeAdapter = next
+	d.mu.Unlock()
+
+	for _, adapter := range appeared(previousAdapters, adapters, initial) {
+		d.configureAdapter(ctx, adapter)
+	}
+
+	if !initial {
+		d.emitAdapterChanges(previousAdapters, adapters)
+		if d.emitActiveChange(previous, next, reason) && d.settings().failoverDevices && previous != nil && next != nil {
+			d.migrateDevices(ctx, previous.ID, next.ID)
+		}
+	}
+
+	if len(adapters) == 0 {
+		return errNoAdapters
+	}
+
+	return nil
+}
+
+// chooseActive decides which adapter should be active given the previous
+// choice and the freshly discovered adapters. It returns nil when no usable
+// adapter remains, along with a human-readable reason whenever the choice
+// changes.
+func (d *Daemon) chooseActive(previous *Adapter, adapters []Adapter) (*Adapter, string) {
+	var usable []Adapter
+	for _, adapter := range adapters {
+		if adapter.Usable() {
+			usable = append(usable, adapter)
+		}
+	}
+
+	settings := d.settings()
+	ranked := settings.selection.Explain(settings.preferredAdapter, usable)
+
+	var reason string
+	switch current, found := findAdapter(adapters, previous); {
+	case previous == nil:
+		reason = "initial selection"
+	case !found:
+		reason = fmt.Sprintf("active adapter %s disappeared", previous.ID)
+	case !current.Usable():
+		reason = fmt.Sprintf("active adapter %s is hard-blocked", previous.ID)
+	default:
+		currentScore := settings.selection.score(settings.preferredAdapter, current)
+		if currentScore.Excluded {
+			reason = fmt.Sprintf("active adapter %s is excluded by the selection policy", previous.ID)
+			break
+		}
+		// Only switch away from a working adapter when another one strictly
+		// outranks it, e.g. the preferred adapter returned. Tiebreaks alone
+		// never cause a switch.
+		if best := ranked[0]; !best.Excluded && best.Score > currentScore.Score {
+			chosen := best.Adapter
+			return &chosen, fmt.Sprintf("adapter %s ranks higher: %s", chosen.ID, strings.Join(best.Reasons, "; "))
+		}
+		return &current, ""
+	}
+
+	if len(ranked) == 0 || ranked[0].Excluded {
+		return nil, reason
+	}
+
+	chosen := ranked[0].Adapter
+	return &chosen, reason
+}
+
+// appeared returns the adapters in current that were not in previous. On the
+// initial refresh every adapter counts as new.
+func appeared(previous, current []Adapter, initial bool) []Adapter {
+	if initial {
+		return current
+	}
+
+	known := make(map[string]bool, len(previous))
+	for _, adapter := range previous {
+		known[adapter.ID] = true
+	}
+
+	var added []Adapter
+	for _, adapter := range current {
+		if !known[adapter.ID] {
+			added = append(added, adapter)
+		}
+	}
+	return added
+}
+
+func findAdapter(adapters []Adapter, target *Adapter) (Adapter, bool) {
+	if target == nil {
+		return Adapter{}, false
+	}
+	for _, adapter := range adapters {
+		if adapter.ID == target.ID {
+			return adapter, true
+		}
+	}
+	return Adapter{}, false
+}
+
+func (d *Daemon) emitAdapterChanges(previous, current []Adapter) {
+	before := make(map[string]Adapter, len(previous))
+	for _, adapter := range previous {
+		before[adapter.ID] = adapter
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, adapter := range current {
+		seen[adapter.ID] = true
+
+		old, existed := before[adapter.ID]
+		if !existed {
+			d.emit(Event{Type: EventAdapterAdded, Adapter: adapter.ID, Message: fmt.Sprintf("adapter %s appeared", adapter.ID)})
+			continue
+		}
+		if adapter.HardBlocked && !old.HardBlocked {
+			d.emit(Event{Type: EventAdapterBlocked, Adapter: adapter.ID, Message: fmt.Sprintf("adapter %s is hard-blocked", adapter.ID)})
+		}
+	}
+
+	for _, adapter := range previous {
+		if !seen[adapter.ID] {
+			d.emit(Event{Type: EventAdapterRemoved, Adapter: adapter.ID, Message: fmt.Sprintf("adapter %s disappeared", adapter.ID)})
+		}
+	}
+}
+
+// emitActiveChange reports a change of active adapter and returns whether one
+// happened.
+func (d *Daemon) emitActiveChange(previous, next *Adapter, reason string) bool {
+	previousID, nextID := "", ""
+	if previous != nil {
+		previousID = previous.ID
+	}
+	if next != nil {
+		nextID = next.ID
+	}
+
+	if previousID == nextID {
+		return false
+	}
+
+	if next == nil {
+		d.emit(Event{Type: EventAdapterLost, Previous: previousID, Message: fmt.Sprintf("no usable adapter left: %s", reason)})
+		return true
+	}
+
+	message := reason
+	if message == "" {
+		message = "adapter selection changed"
+	}
+	d.emit(Event{Type: EventAdapterSwitched, Adapter: nextID, Previous: previousID, Message: message})
+	return true
+}
+
+// migrateDevices reconnects devices that were connected through from via to,
+// provided they are bonded with both controllers. Devices pinned to an
+// adapter are left alone.
+func (d *Daemon) migrateDevices(ctx context.Context, from, to string) {
+	if d.deviceBackend == nil {
+		return
+	}
+
+	d.devMu.Lock()
+	var wanted []string
+	for _, dev := range d.knownDevices {
+		if dev.Adapter != from || !dev.Connected {
+			continue
+		}
+		if d.settings().devices[dev.Address].Adapter != "" {
+			continue
+		}
+		if d.deviceState(dev.Address).held {
+			continue
+		}
+		wanted = append(wanted, dev.Address)
+	}
+	d.devMu.Unlock()
+
+	if len(wanted) == 0 {
+		return
+	}
+
+	listed, err := d.deviceBackend.ListDevices(ctx, to)
+	if err != nil {
+		d.log.Warn("failover device migration skipped", "adapter", to, "error", err)
+		return
+	}
+
+	bonded := make(map[string]bool, len(listed))
+	for _, dev := range listed {
+		if dev.Paired {
+			bonded[NormalizeAddress(dev.Address)] = true
+		}
+	}
+
+	for _, address := range wanted {
+		if !bonded[address] {
+			d.log.Info("device not bonded with failover adapter", "address", address, "adapter", to)
+			continue
+		}
+
+		if err := d.deviceBackend.Connect(ctx, to, address); err != nil {
+			d.log.Warn("failover reconnect failed", "address", address, "adapter", to, "error", err)
+			continue
+		}
+
+		d.emit(Event{Type: EventDeviceMigrated, Adapter: to, Previous: from, Address: address, Message: fmt.Sprintf("reconnected %s through %s", address, to)})
+	}
+}
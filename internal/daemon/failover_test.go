@@ -0,0 +1,214 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// adapterSet is a mutable AdapterProvider used to simulate controllers being
+// unplugged, blocked and returning between polls.
+type adapterSet struct {
+	mu       sync.Mutex
+	adapters []Adapter
+}
+
+func (s *adapterSet) set(adapters ...Adapter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adapters = adapters
+}
+
+func (s *adapterSet) ListAdapters(context.Context) ([]Adapter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Adapter(nil), s.adapters...), nil
+}
+
+var (
+	onboard = Adapter{ID: "hci0", Address: "00:00:00:00:00:01", Transport: AdapterTransportPCI}
+	dongle  = Adapter{ID: "hci1", Address: "00:00:00:00:00:02", Transport: AdapterTransportUSB}
+)
+
+func drainEvents(ch <-chan Event) []Event {
+	var out []Event
+	for {
+		select {
+		case ev := <-ch:
+			out = append(out, ev)
+		default:
+			return out
+		}
+	}
+}
+
+func activeID(t *testing.T, d *Daemon) string {
+	t.Helper()
+	adapter, ok := d.ActiveAdapter()
+	if !ok {
+		return ""
+	}
+	return adapter.ID
+}
+
+func TestFailoverWhenActiveAdapterDisappears(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard, dongle)
+
+	d := newTestDaemon(t, Options{AdapterProvider: provider, PreferredAdapter: "hci0"})
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci0" {
+		t.Fatalf("expected hci0 to be active, got %q", got)
+	}
+	if evs := drainEvents(events); len(evs) != 0 {
+		t.Fatalf("initial selection should not emit events, got %+v", evs)
+	}
+
+	provider.set(dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci1" {
+		t.Fatalf("expected failover to hci1, got %q", got)
+	}
+
+	evs := drainEvents(events)
+	if len(evs) != 2 || evs[0].Type != EventAdapterRemoved || evs[1].Type != EventAdapterSwitched {
+		t.Fatalf("unexpected events: %+v", evs)
+	}
+	if evs[1].Adapter != "hci1" || evs[1].Previous != "hci0" {
+		t.Fatalf("unexpected switch event: %+v", evs[1])
+	}
+
+	// The preferred adapter returns and the daemon switches back to it.
+	provider.set(onboard, dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci0" {
+		t.Fatalf("expected switch back to hci0, got %q", got)
+	}
+
+	evs = drainEvents(events)
+	if len(evs) != 2 || evs[0].Type != EventAdapterAdded || evs[1].Type != EventAdapterSwitched || evs[1].Adapter != "hci0" {
+		t.Fatalf("unexpected events: %+v", evs)
+	}
+}
+
+func TestFailoverWhenActiveAdapterIsHardBlocked(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard, dongle)
+
+	d := newTestDaemon(t, Options{AdapterProvider: provider})
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci1" {
+		t.Fatalf("expected USB adapter to be active, got %q", got)
+	}
+
+	blocked := dongle
+	blocked.HardBlocked = true
+	provider.set(onboard, blocked)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci0" {
+		t.Fatalf("expected failover to hci0, got %q", got)
+	}
+
+	evs := drainEvents(events)
+	if len(evs) != 2 || evs[0].Type != EventAdapterBlocked || evs[1].Type != EventAdapterSwitched {
+		t.Fatalf("unexpected events: %+v", evs)
+	}
+
+	// Losing the last usable adapter leaves the daemon without one.
+	provider.set(blocked)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if _, ok := d.ActiveAdapter(); ok {
+		t.Fatalf("expected no active adapter")
+	}
+
+	evs = drainEvents(events)
+	if len(evs) != 2 || evs[1].Type != EventAdapterLost || evs[1].Previous != "hci0" {
+		t.Fatalf("unexpected events: %+v", evs)
+	}
+}
+
+func TestFailoverReconnectsBondedDevices(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard, dongle)
+
+	backend := newFakeBackend(Device{Address: headset, Paired: true, Connected: true})
+	d := newTestDaemon(t, Options{
+		AdapterProvider:   provider,
+		PreferredAdapter:  "hci0",
+		DeviceBackend:     backend,
+		FailoverReconnect: true,
+	})
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	// Unplugging the controller drops its connections.
+	backend.setConnected(headset, false)
+	provider.set(dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	if len(backend.connects) != 1 || backend.connects[0] != headset || backend.via[0] != "hci1" {
+		t.Fatalf("expected headset to reconnect through hci1, got %v via %v", backend.connects, backend.via)
+	}
+
+	evs := drainEvents(events)
+	last := evs[len(evs)-1]
+	if last.Type != EventDeviceMigrated || last.Address != headset || last.Adapter != "hci1" {
+		t.Fatalf("unexpected events: %+v", evs)
+	}
+}
+
+func TestFailoverLeavesDevicesAloneByDefault(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard, dongle)
+
+	backend := newFakeBackend(Device{Address: headset, Paired: true, Connected: true})
+	d := newTestDaemon(t, Options{AdapterProvider: provider, PreferredAdapter: "hci0", DeviceBackend: backend})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	backend.setConnected(headset, false)
+	provider.set(dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no reconnect attempts, got %v", backend.connects)
+	}
+}
@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hookTimeout bounds how long a device hook may run.
+const hookTimeout = 30 * time.Second
+
+// DeviceHooks are shell commands run when a device connects or disconnects.
+// Empty commands are skipped.
+type DeviceHooks struct {
+	Connect    string
+	Disconnect string
+}
+
+// HookRunner runs a device hook command with extra environment variables.
+type HookRunner interface {
+	RunHook(ctx context.Context, command string, env []string) error
+}
+
+// ShellHooks runs hooks with /bin/sh -c, inheriting the caller's
+// environment.
+type ShellHooks struct{}
+
+// RunHook implements HookRunner. Output is only reported when the command
+// fails.
+func (ShellHooks) RunHook(ctx context.Context, command string, env []string) error {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(out)); trimmed != "" {
+			return fmt.Errorf("%w: %s", err, trimmed)
+		}
+		return err
+	}
+	return nil
+}
+
+// HookEnv returns the variables a hook for event ("connect" or
+// "disconnect") receives: PEARED_HOOK, PEARED_DEVICE with the address,
+// PEARED_DEVICE_NAME, PEARED_NICKNAME and PEARED_HOOK_ADAPTER. The adapter
+// variable is not PEARED_ADAPTER, which peared commands run from a hook would
+// take as an --adapter override.
+func HookEnv(event string, dev Device, settings DeviceSettings) []string {
+	name := dev.Alias
+	if name == "" {
+		name = dev.Name
+	}
+	return []string{
+		"PEARED_HOOK=" + event,
+		"PEARED_DEVICE=" + dev.Address,
+		"PEARED_DEVICE_NAME=" + name,
+		"PEARED_NICKNAME=" + settings.Nickname,
+		"PEARED_HOOK_ADAPTER=" + dev.Adapter,
+	}
+}
+
+// DeviceActions apply what a device is configured to do when it connects or
+// disconnects. pearedd runs them on the transitions it observes and the CLI
+// runs them itself when the daemon is not running. Nil backends skip their
+// part.
+type DeviceActions struct {
+	Audio AudioBackend
+	Hooks HookRunner
+}
+
+// Connected applies the device's audio preferences and then runs its
+// connect hook.
+func (a DeviceActions) Connected(ctx context.Context, dev Device, settings DeviceSettings) error {
+	var errs []error
+	if a.Audio != nil && !settings.Audio.IsZero() {
+		if err := a.Audio.ApplyAudio(ctx, dev.Address, settings.Audio); err != nil {
+			errs = append(errs, fmt.Errorf("apply audio settings: %w", err))
+		}
+	}
+	if err := a.runHook(ctx, "connect", settings.Hooks.Connect, dev, settings); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Disconnected runs the device's disconnect hook.
+func (a DeviceActions) Disconnected(ctx context.Context, dev Device, settings DeviceSettings) error {
+	return a.runHook(ctx, "disconnect", settings.Hooks.Disconnect, dev, settings)
+}
+
+func (a DeviceActions) runHook(ctx context.Context, event, command string, dev Device, settings DeviceSettings) error {
+	if a.Hooks == nil || strings.TrimSpace(command) == "" {
+		return nil
+	}
+
+	// A hook that has started runs until it exits or times out, even when
+	// ctx is cancelled on shutdown, so it is never killed halfway.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hookTimeout)
+	defer cancel()
+	if err := a.Hooks.RunHook(ctx, command, HookEnv(event, dev, settings)); err != nil {
+		return fmt.Errorf("%s hook: %w", event, err)
+	}
+	return nil
+}
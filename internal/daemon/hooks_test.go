@@ -0,0 +1,183 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type hookCall struct {
+	command string
+	env     []string
+}
+
+type fakeHooks struct {
+	calls chan hookCall
+}
+
+func (h fakeHooks) RunHook(_ context.Context, command string, env []string) error {
+	h.calls <- hookCall{command: command, env: env}
+	return nil
+}
+
+type fakeAudio struct {
+	applied chan AudioSettings
+}
+
+func (a fakeAudio) ApplyAudio(_ context.Context, _ string, settings AudioSettings) error {
+	a.applied <- settings
+	return nil
+}
+
+func TestDeviceTransitionsRunActions(t *testing.T) {
+	ctx := context.Background()
+	backend := newFakeBackend(Device{Address: headset, Alias: "Cans", Paired: true})
+	hooks := fakeHooks{calls: make(chan hookCall, 4)}
+	audio := fakeAudio{applied: make(chan AudioSettings, 4)}
+
+	volume := 40
+	d := newTestDaemon(t, Options{
+		DeviceBackend: backend,
+		AudioBackend:  audio,
+		HookRunner:    hooks,
+		Devices: map[string]DeviceSettings{headset: {
+			Nickname: "cans",
+			Audio:    AudioSettings{Codec: "aac", Volume: &volume},
+			Hooks:    DeviceHooks{Connect: "notify connect", Disconnect: "notify disconnect"},
+		}},
+	})
+
+	reconcile := func() {
+		t.Helper()
+		if err := d.reconcileDevices(ctx); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+	expectHook := func(command string) hookCall {
+		t.Helper()
+		select {
+		case call := <-hooks.calls:
+			if call.command != command {
+				t.Fatalf("expected hook %q, got %q", command, call.command)
+			}
+			return call
+		case <-time.After(time.Second):
+			t.Fatalf("hook %q did not run", command)
+		}
+		return hookCall{}
+	}
+
+	reconcile()
+	backend.setConnected(headset, true)
+	reconcile()
+
+	select {
+	case settings := <-audio.applied:
+		if settings.Codec != "aac" || *settings.Volume != 40 {
+			t.Fatalf("unexpected audio settings %+v", settings)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("audio settings were not applied")
+	}
+	call := expectHook("notify connect")
+	want := "PEARED_HOOK=connect PEARED_DEVICE=" + headset + " PEARED_DEVICE_NAME=Cans PEARED_NICKNAME=cans PEARED_HOOK_ADAPTER="
+	if strings.Join(call.env, " ") != want {
+		t.Fatalf("unexpected hook environment %v", call.env)
+	}
+
+	reconcile()
+	backend.setConnected(headset, false)
+	reconcile()
+	expectHook("notify disconnect")
+
+	select {
+	case call := <-hooks.calls:
+		t.Fatalf("unexpected hook %q", call.command)
+	case <-audio.applied:
+		t.Fatal("audio settings applied on disconnect")
+	default:
+	}
+}
+
+func TestDevicesConnectedAtStartupRunNoActions(t *testing.T) {
+	backend := newFakeBackend(Device{Address: headset, Paired: true, Connected: true})
+	hooks := fakeHooks{calls: make(chan hookCall, 1)}
+
+	d := newTestDaemon(t, Options{
+		DeviceBackend: backend,
+		HookRunner:    hooks,
+		Devices:       map[string]DeviceSettings{headset: {Hooks: DeviceHooks{Connect: "notify connect"}}},
+	})
+
+	if err := d.reconcileDevices(context.Background()); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	select {
+	case call := <-hooks.calls:
+		t.Fatalf("unexpected hook %q for a device connected before the first poll", call.command)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// slowHooks holds every hook until release is closed and reports how it
+// ended on done.
+type slowHooks struct {
+	started chan struct{}
+	release chan struct{}
+	done    chan error
+}
+
+func (h slowHooks) RunHook(ctx context.Context, _ string, _ []string) error {
+	h.started <- struct{}{}
+	select {
+	case <-h.release:
+		h.done <- nil
+	case <-ctx.Done():
+		h.done <- ctx.Err()
+	}
+	return nil
+}
+
+func TestRunWaitsForRunningHooks(t *testing.T) {
+	backend := newFakeBackend(Device{Address: headset, Paired: true})
+	hooks := slowHooks{started: make(chan struct{}, 1), release: make(chan struct{}), done: make(chan error, 1)}
+
+	d := newTestDaemon(t, Options{
+		DeviceBackend: backend,
+		HookRunner:    hooks,
+		AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
+			return []Adapter{{ID: "hci0", Powered: true}}, nil
+		}),
+		PollInterval: 10 * time.Millisecond,
+		Devices:      map[string]DeviceSettings{headset: {Hooks: DeviceHooks{Connect: "sleep 5"}}},
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	time.Sleep(30 * time.Millisecond)
+	backend.setConnected(headset, true)
+	select {
+	case <-hooks.started:
+	case <-time.After(5 * time.Second):
+		stop()
+		t.Fatal("connect hook did not start")
+	}
+
+	stop()
+	select {
+	case err := <-done:
+		t.Fatalf("Run returned while a hook was running: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(hooks.release)
+	if err := <-hooks.done; err != nil {
+		t.Fatalf("hook was cancelled on shutdown: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+}
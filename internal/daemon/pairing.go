@@ -0,0 +1,343 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/peared/peared/internal/agent"
+)
+
+// defaultPromptTimeout is how long a pairing prompt waits for an answer.
+// BlueZ abandons agent requests after 60 seconds, so it must stay below.
+const defaultPromptTimeout = 30 * time.Second
+
+// PairingAgent registers a BlueZ pairing agent and routes its requests to
+// handler until ctx is cancelled. *agent.Agent implements it.
+type PairingAgent interface {
+	Serve(ctx context.Context, handler agent.Handler) error
+}
+
+// PinStore looks up PINs and passkeys saved for devices. *agent.PinStore
+// implements it.
+type PinStore interface {
+	Get(address string) (string, bool, error)
+}
+
+// PairingNotifier surfaces pairing prompts outside the CLI, for example as
+// desktop notifications.
+type PairingNotifier interface {
+	// NotifyPairing shows prompt. For prompts that can be accepted or
+	// rejected it blocks until the user chooses or ctx is cancelled;
+	// answered is false when no choice was made.
+	NotifyPairing(ctx context.Context, prompt PairingPrompt) (accept, answered bool, err error)
+}
+
+// PairingSettings configures the pairing agent.
+type PairingSettings struct {
+	// Agent is served while the daemon runs. Pairing prompts are disabled
+	// when nil.
+	Agent PairingAgent
+
+	// Pins answers PIN and passkey requests for devices with a stored
+	// secret.
+	Pins PinStore
+
+	// Notifier is offered every prompt alongside control clients.
+	Notifier PairingNotifier
+
+	// Timeout bounds how long a prompt waits for an answer before it is
+	// rejected. Zero selects a default.
+	Timeout time.Duration
+}
+
+// PairingPrompt is a pairing request waiting for, or shown to, the user.
+type PairingPrompt struct {
+	ID      string     `json:"id,omitempty"`
+	Kind    agent.Kind `json:"kind"`
+	Address string     `json:"address"`
+	Adapter string     `json:"adapter,omitempty"`
+	Name    string     `json:"name,omitempty"`
+
+	// Passkey is zero-padded to six digits.
+	Passkey string `json:"passkey,omitempty"`
+	PinCode string `json:"pin_code,omitempty"`
+	Entered int    `json:"entered,omitempty"`
+	UUID    string `json:"uuid,omitempty"`
+
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// NeedsValue reports whether answering the prompt requires a PIN or passkey
+// rather than a yes or no.
+func (p PairingPrompt) NeedsValue() bool {
+	return p.Kind == agent.KindPinCode || p.Kind == agent.KindPasskey
+}
+
+// Display reports whether the prompt only shows a PIN or passkey.
+func (p PairingPrompt) Display() bool {
+	return p.Kind == agent.KindDisplayPinCode || p.Kind == agent.KindDisplayPasskey
+}
+
+// Describe returns a one-line instruction for the user.
+func (p PairingPrompt) Describe() string {
+	device := p.Address
+	if p.Name != "" {
+		device = fmt.Sprintf("%s (%s)", p.Name, p.Address)
+	}
+
+	switch p.Kind {
+	case agent.KindPinCode:
+		return fmt.Sprintf("Enter the PIN for %s", device)
+	case agent.KindPasskey:
+		return fmt.Sprintf("Enter the passkey shown by %s", device)
+	case agent.KindConfirmation:
+		return fmt.Sprintf("Confirm that %s shows passkey %s", device, p.Passkey)
+	case agent.KindAuthorization:
+		return fmt.Sprintf("Allow %s to pair", device)
+	case agent.KindService:
+		return fmt.Sprintf("Allow %s to use service %s", device, p.UUID)
+	case agent.KindDisplayPinCode:
+		return fmt.Sprintf("Type PIN %s on %s", p.PinCode, device)
+	case agent.KindDisplayPasskey:
+		if p.Entered > 0 {
+			return fmt.Sprintf("Type passkey %s on %s and press Enter (%d typed)", p.Passkey, device, p.Entered)
+		}
+		return fmt.Sprintf("Type passkey %s on %s and press Enter", p.Passkey, device)
+	default:
+		return fmt.Sprintf("Pairing request %s from %s", p.Kind, device)
+	}
+}
+
+type pendingPrompt struct {
+	prompt PairingPrompt
+	answer chan agent.Response
+}
+
+// HandlePairing implements agent.Handler. Display requests are published as
+// events; other requests are answered from stored PINs or auto-accept rules
+// when possible and otherwise wait for a control client or the notifier.
+func (d *Daemon) HandlePairing(ctx context.Context, req agent.Request) (agent.Response, error) {
+	prompt := d.newPrompt(req)
+
+	if req.Display() {
+		d.emit(Event{Type: EventPairingDisplay, Adapter: prompt.Adapter, Address: prompt.Address, Message: prompt.Describe(), Prompt: &prompt})
+		if d.pairing.Notifier != nil {
+			go d.notifyPairing(context.Background(), prompt)
+		}
+		return agent.Response{Accept: true}, nil
+	}
+
+	if resp, reason, ok := d.answerAutomatically(prompt); ok {
+		d.emit(Event{Type: EventPairingResolved, Adapter: prompt.Adapter, Address: prompt.Address, Message: reason, Prompt: &prompt})
+		return resp, nil
+	}
+
+	return d.ask(ctx, prompt)
+}
+
+func (d *Daemon) newPrompt(req agent.Request) PairingPrompt {
+	prompt := PairingPrompt{
+		Kind:    req.Kind,
+		Address: NormalizeAddress(req.Address),
+		Adapter: req.Adapter,
+		PinCode: req.PinCode,
+		Entered: int(req.Entered),
+		UUID:    req.UUID,
+	}
+	if req.Kind == agent.KindConfirmation || req.Kind == agent.KindDisplayPasskey {
+		prompt.Passkey = fmt.Sprintf("%06d", req.Passkey)
+	}
+
+	d.devMu.Lock()
+	for _, dev := range d.knownDevices {
+		if dev.Address == prompt.Address {
+			prompt.Name = dev.Alias
+			if prompt.Name == "" {
+				prompt.Name = dev.Name
+			}
+			break
+		}
+	}
+	d.devMu.Unlock()
+
+	return prompt
+}
+
+// answerAutomatically answers PIN and passkey requests from the PIN store
+// and yes-or-no requests for devices configured to auto-accept.
+func (d *Daemon) answerAutomatically(prompt PairingPrompt) (agent.Response, string, bool) {
+	if prompt.NeedsValue() {
+		if d.pairing.Pins == nil {
+			return agent.Response{}, "", false
+		}
+		secret, ok, err := d.pairing.Pins.Get(prompt.Address)
+		if err != nil {
+			d.log.Warn("stored PIN unavailable", "address", prompt.Address, "error", err)
+			return agent.Response{}, "", false
+		}
+		if !ok {
+			return agent.Response{}, "", false
+		}
+
+		if prompt.Kind == agent.KindPinCode {
+			return agent.Response{Accept: true, PinCode: secret}, "answered with the stored PIN", true
+		}
+		passkey, err := agent.ParsePasskey(secret)
+		if err != nil {
+			d.log.Warn("stored PIN is not a valid passkey", "address", prompt.Address, "error", err)
+			return agent.Response{}, "", false
+		}
+		return agent.Response{Accept: true, Passkey: passkey}, "answered with the stored passkey", true
+	}
+
+	if settings, ok := d.settings().devices[prompt.Address]; ok && settings.AutoAccept {
+		return agent.Response{Accept: true}, "auto-accepted", true
+	}
+	return agent.Response{}, "", false
+}
+
+// ask publishes prompt and waits for AnswerPrompt, the notifier, the timeout
+// or BlueZ cancelling the request, whichever comes first.
+func (d *Daemon) ask(ctx context.Context, prompt PairingPrompt) (agent.Response, error) {
+	timeout := d.settings().promptTimeout
+	askCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	prompt.Expires = d.now().Add(timeout)
+	pending := &pendingPrompt{answer: make(chan agent.Response, 1)}
+
+	d.promptMu.Lock()
+	d.promptSeq++
+	prompt.ID = strconv.Itoa(d.promptSeq)
+	pending.prompt = prompt
+	d.prompts[prompt.ID] = pending
+	d.promptMu.Unlock()
+
+	defer func() {
+		d.promptMu.Lock()
+		delete(d.prompts, prompt.ID)
+		d.promptMu.Unlock()
+	}()
+
+	d.emit(Event{Type: EventPairingRequest, Adapter: prompt.Adapter, Address: prompt.Address, Message: prompt.Describe(), Prompt: &prompt})
+
+	if d.pairing.Notifier != nil {
+		go d.notifyPairing(askCtx, prompt)
+	}
+
+	var resp agent.Response
+	var outcome string
+	var err error
+	select {
+	case resp = <-pending.answer:
+		outcome = "rejected"
+		if resp.Accept {
+			outcome = "accepted"
+		}
+	case <-askCtx.Done():
+		if ctx.Err() != nil {
+			outcome, err = "cancelled", ctx.Err()
+		} else {
+			outcome = "timed out"
+		}
+	}
+
+	d.emit(Event{Type: EventPairingResolved, Adapter: prompt.Adapter, Address: prompt.Address, Message: outcome, Prompt: &prompt})
+	return resp, err
+}
+
+// notifyPairing offers prompt to the notifier and applies its answer.
+func (d *Daemon) notifyPairing(ctx context.Context, prompt PairingPrompt) {
+	accept, answered, err := d.pairing.Notifier.NotifyPairing(ctx, prompt)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Warn("pairing notification failed", "address", prompt.Address, "error", err)
+		}
+		return
+	}
+	if answered && prompt.ID != "" && !prompt.NeedsValue() {
+		// The prompt may have been answered through the CLI meanwhile.
+		_ = d.AnswerPrompt(prompt.ID, accept, "")
+	}
+}
+
+// AnswerPrompt answers the pending prompt with the given ID. PIN and passkey
+// prompts take the value to send when accepted.
+func (d *Daemon) AnswerPrompt(id string, accept bool, value string) error {
+	d.promptMu.Lock()
+	pending, ok := d.prompts[id]
+	d.promptMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no pending pairing prompt %q", id)
+	}
+
+	resp := agent.Response{Accept: accept}
+	if accept {
+		switch pending.prompt.Kind {
+		case agent.KindPinCode:
+			if err := agent.ValidatePinCode(value); err != nil {
+				return err
+			}
+			resp.PinCode = value
+		case agent.KindPasskey:
+			passkey, err := agent.ParsePasskey(value)
+			if err != nil {
+				return err
+			}
+			resp.Passkey = passkey
+		}
+	}
+
+	select {
+	case pending.answer <- resp:
+		return nil
+	default:
+		return errors.New("pairing prompt already answered")
+	}
+}
+
+// PairingPrompts returns the prompts waiting for an answer, oldest first.
+func (d *Daemon) PairingPrompts() []PairingPrompt {
+	d.promptMu.Lock()
+	defer d.promptMu.Unlock()
+
+	prompts := make([]PairingPrompt, 0, len(d.prompts))
+	for _, pending := range d.prompts {
+		prompts = append(prompts, pending.prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		a, _ := strconv.Atoi(prompts[i].ID)
+		b, _ := strconv.Atoi(prompts[j].ID)
+		return a < b
+	})
+	return prompts
+}
+
+// servePairing runs the pairing agent until ctx is cancelled. Failing to
+// register only disables pairing prompts.
+func (d *Daemon) servePairing(ctx context.Context) {
+	d.promptMu.Lock()
+	d.agentRunning = true
+	d.promptMu.Unlock()
+
+	err := d.pairing.Agent.Serve(ctx, d)
+
+	d.promptMu.Lock()
+	d.agentRunning = false
+	d.promptMu.Unlock()
+
+	if err != nil && ctx.Err() == nil {
+		d.log.Warn("pairing agent stopped", "error", err)
+	}
+}
+
+// pairingAgentRunning reports whether the pairing agent is being served.
+func (d *Daemon) pairingAgentRunning() bool {
+	d.promptMu.Lock()
+	defer d.promptMu.Unlock()
+	return d.agentRunning
+}
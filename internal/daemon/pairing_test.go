@@ -0,0 +1,173 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/agent"
+	"github.com/peared/peared/internal/control"
+)
+
+type pinMap map[string]string
+
+func (m pinMap) Get(address string) (string, bool, error) {
+	pin, ok := m[address]
+	return pin, ok, nil
+}
+
+// fakeNotifier accepts or rejects every yes-or-no prompt it is shown.
+type fakeNotifier struct {
+	accept bool
+	shown  chan PairingPrompt
+}
+
+func (n *fakeNotifier) NotifyPairing(ctx context.Context, prompt PairingPrompt) (bool, bool, error) {
+	n.shown <- prompt
+	if prompt.Display() || prompt.NeedsValue() {
+		return false, false, nil
+	}
+	return n.accept, true, nil
+}
+
+// nextPrompt waits for a pairing.request event and returns its prompt.
+func nextPrompt(t *testing.T, events <-chan Event) PairingPrompt {
+	t.Helper()
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == EventPairingRequest {
+				return *ev.Prompt
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a pairing prompt")
+		}
+	}
+}
+
+func TestPairingPromptAnsweredThroughControl(t *testing.T) {
+	backend := newFakeBackend(Device{Address: headset, Alias: "Headset"})
+	d := newTestDaemon(t, Options{AdapterProvider: &adapterSet{}, DeviceBackend: backend, Pairing: PairingSettings{Timeout: 5 * time.Second}})
+	if err := d.reconcileDevices(context.Background()); err != nil {
+		t.Fatalf("reconcileDevices: %v", err)
+	}
+
+	events, unsubscribe := d.Subscribe()
+	defer unsubscribe()
+
+	type result struct {
+		resp agent.Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := d.HandlePairing(context.Background(), agent.Request{Kind: agent.KindPasskey, Adapter: "hci0", Address: "aa:bb:cc:dd:ee:ff"})
+		results <- result{resp, err}
+	}()
+
+	prompt := nextPrompt(t, events)
+	if prompt.Address != headset || prompt.Name != "Headset" || !prompt.NeedsValue() {
+		t.Fatalf("unexpected prompt %+v", prompt)
+	}
+	if pending := d.PairingPrompts(); len(pending) != 1 || pending[0].ID != prompt.ID {
+		t.Fatalf("unexpected pending prompts %+v", pending)
+	}
+
+	reply := func(accept, value string) control.Response {
+		return d.HandleControl(context.Background(), control.Request{Command: "pairing.reply", Args: map[string]string{"id": prompt.ID, "accept": accept, "value": value}})
+	}
+	if resp := reply("true", "12ab"); resp.OK {
+		t.Fatal("expected an invalid passkey to be refused")
+	}
+	if resp := reply("true", "004711"); !resp.OK {
+		t.Fatalf("pairing.reply failed: %s", resp.Error)
+	}
+
+	res := <-results
+	if res.err != nil || !res.resp.Accept || res.resp.Passkey != 4711 {
+		t.Fatalf("HandlePairing = %+v, %v", res.resp, res.err)
+	}
+	if pending := d.PairingPrompts(); len(pending) != 0 {
+		t.Fatalf("expected no pending prompts, got %+v", pending)
+	}
+	if resp := reply("false", ""); resp.OK {
+		t.Fatal("expected a resolved prompt to be refused")
+	}
+
+	var resolved []string
+	for _, ev := range drainEvents(events) {
+		if ev.Type == EventPairingResolved {
+			resolved = append(resolved, ev.Message)
+		}
+	}
+	if len(resolved) != 1 || resolved[0] != "accepted" {
+		t.Fatalf("unexpected resolved events %v", resolved)
+	}
+}
+
+func TestPairingAnswersFromPinsAndRules(t *testing.T) {
+	const keyboard = "33:33:33:33:33:33"
+	d := newTestDaemon(t, Options{
+		AdapterProvider: &adapterSet{},
+		Devices:         map[string]DeviceSettings{headset: {AutoAccept: true}},
+		Pairing:         PairingSettings{Pins: pinMap{keyboard: "0042", headset: "not-a-passkey"}, Timeout: 20 * time.Millisecond},
+	})
+	ctx := context.Background()
+
+	resp, err := d.HandlePairing(ctx, agent.Request{Kind: agent.KindPinCode, Address: keyboard})
+	if err != nil || !resp.Accept || resp.PinCode != "0042" {
+		t.Fatalf("PIN request = %+v, %v", resp, err)
+	}
+	resp, err = d.HandlePairing(ctx, agent.Request{Kind: agent.KindPasskey, Address: keyboard})
+	if err != nil || !resp.Accept || resp.Passkey != 42 {
+		t.Fatalf("passkey request = %+v, %v", resp, err)
+	}
+
+	resp, err = d.HandlePairing(ctx, agent.Request{Kind: agent.KindConfirmation, Address: headset, Passkey: 7})
+	if err != nil || !resp.Accept {
+		t.Fatalf("auto-accepted confirmation = %+v, %v", resp, err)
+	}
+
+	// Neither rule applies, so the prompt waits and times out.
+	resp, err = d.HandlePairing(ctx, agent.Request{Kind: agent.KindConfirmation, Address: keyboard, Passkey: 7})
+	if err != nil || resp.Accept {
+		t.Fatalf("unanswered confirmation = %+v, %v", resp, err)
+	}
+	resp, err = d.HandlePairing(ctx, agent.Request{Kind: agent.KindPasskey, Address: headset})
+	if err != nil || resp.Accept {
+		t.Fatalf("passkey with an unusable stored value = %+v, %v", resp, err)
+	}
+}
+
+func TestPairingNotifierAndCancellation(t *testing.T) {
+	notifier := &fakeNotifier{accept: true, shown: make(chan PairingPrompt, 4)}
+	d := newTestDaemon(t, Options{AdapterProvider: &adapterSet{}, Pairing: PairingSettings{Notifier: notifier, Timeout: 5 * time.Second}})
+
+	resp, err := d.HandlePairing(context.Background(), agent.Request{Kind: agent.KindAuthorization, Address: headset})
+	if err != nil || !resp.Accept {
+		t.Fatalf("notifier answer = %+v, %v", resp, err)
+	}
+
+	if _, err := d.HandlePairing(context.Background(), agent.Request{Kind: agent.KindDisplayPasskey, Address: headset, Passkey: 1234, Entered: 2}); err != nil {
+		t.Fatalf("display request: %v", err)
+	}
+	<-notifier.shown
+	if shown := <-notifier.shown; shown.Passkey != "001234" || shown.Describe() != "Type passkey 001234 on "+headset+" and press Enter (2 typed)" {
+		t.Fatalf("unexpected display prompt %+v", shown)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, unsubscribe := d.Subscribe()
+	defer unsubscribe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.HandlePairing(ctx, agent.Request{Kind: agent.KindPinCode, Address: headset})
+		done <- err
+	}()
+	nextPrompt(t, events)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Adapters returns the adapters discovered during the last refresh.
+func (d *Daemon) Adapters() []Adapter {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]Adapter(nil), d.adapters...)
+}
+
+// resolveAdapter finds the discovered adapter matching identifier.
+func (d *Daemon) resolveAdapter(identifier string) (Adapter, bool) {
+	for _, adapter := range d.Adapters() {
+		if adapter.Matches(identifier) {
+			return adapter, true
+		}
+	}
+	return Adapter{}, false
+}
+
+// pinnedAdapters resolves the adapter pins from device settings. It returns
+// the adapter ID for each pinned device whose controller is present, and the
+// set of pinned devices whose controller is missing.
+func (d *Daemon) pinnedAdapters() (map[string]string, map[string]string) {
+	pins := make(map[string]string)
+	missing := make(map[string]string)
+
+	for address, settings := range d.settings().devices {
+		if settings.Adapter == "" {
+			continue
+		}
+
+		adapter, ok := d.resolveAdapter(settings.Adapter)
+		if !ok {
+			missing[address] = settings.Adapter
+			continue
+		}
+		pins[address] = adapter.ID
+	}
+
+	return pins, missing
+}
+
+// collectDevices lists devices through every adapter the daemon cares about:
+// the active adapter plus any adapter a device is pinned to. Pinned devices
+// are only taken from their pinned adapter, everything else from the active
+// one, so a device bonded with several controllers is reported once.
+func (d *Daemon) collectDevices(ctx context.Context) ([]Device, error) {
+	active := ""
+	if adapter, ok := d.ActiveAdapter(); ok {
+		active = adapter.ID
+	}
+
+	pins, missing := d.pinnedAdapters()
+	for address, identifier := range missing {
+		d.log.Debug("pinned adapter not present; skipping device", "address", address, "adapter", identifier)
+	}
+
+	targets := map[string]bool{active: true}
+	for _, id := range pins {
+		targets[id] = true
+	}
+
+	ordered := make([]string, 0, len(targets))
+	for id := range targets {
+		ordered = append(ordered, id)
+	}
+	sort.Strings(ordered)
+
+	var devices []Device
+	for _, target := range ordered {
+		listed, err := d.deviceBackend.ListDevices(ctx, target)
+		if err != nil {
+			if target == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("adapter %s: %w", target, err)
+		}
+
+		for _, dev := range listed {
+			dev.Address = NormalizeAddress(dev.Address)
+			if _, skip := missing[dev.Address]; skip {
+				continue
+			}
+
+			want := active
+			if pin, ok := pins[dev.Address]; ok {
+				want = pin
+			}
+			if target != want {
+				continue
+			}
+
+			dev.Adapter = target
+			devices = append(devices, dev)
+		}
+	}
+
+	return devices, nil
+}
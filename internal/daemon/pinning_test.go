@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReconnectRoutesThroughPinnedAdapter(t *testing.T) {
+	ctx := context.Background()
+	const (
+		controller = "55:55:55:55:55:55"
+		orphan     = "66:66:66:66:66:66"
+	)
+
+	backend := newFakeBackend(
+		Device{Address: headset, Adapter: "hci0"},
+		Device{Address: controller, Adapter: "hci1"},
+		Device{Address: orphan, Adapter: "hci0"},
+	)
+
+	always := ReconnectPolicy{Mode: ReconnectAlways}
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	d := newTestDaemon(t, Options{
+		AdapterProvider: AdapterProviderFunc(func(context.Context) ([]Adapter, error) {
+			return []Adapter{
+				{ID: "hci0", Address: "00:00:00:00:00:01", Alias: "Onboard"},
+				{ID: "hci1", Address: "00:00:00:00:00:02", Alias: "Gaming Dongle"},
+			}, nil
+		}),
+		PreferredAdapter: "hci0",
+		DeviceBackend:    backend,
+		Devices: map[string]DeviceSettings{
+			headset:    {Reconnect: always},
+			controller: {Reconnect: always, Adapter: "gaming dongle"},
+			orphan:     {Reconnect: always, Adapter: "hci7"},
+		},
+		Clock: clock.Now,
+	})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := make(map[string]string)
+	for i, address := range backend.connects {
+		got[address] = backend.via[i]
+	}
+
+	if got[headset] != "hci0" {
+		t.Errorf("expected unpinned device to use the active adapter, got %q", got[headset])
+	}
+	if got[controller] != "hci1" {
+		t.Errorf("expected pinned device to use hci1, got %q", got[controller])
+	}
+	if _, ok := got[orphan]; ok {
+		t.Errorf("expected device pinned to a missing adapter to be skipped")
+	}
+
+	for _, dev := range d.Status().Devices {
+		if dev.Address == controller && dev.Adapter != "hci1" {
+			t.Errorf("expected status to report hci1 for the pinned device, got %q", dev.Adapter)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+const (
+	defaultReconnectInitialBackoff = 2 * time.Second
+	defaultReconnectMaxBackoff     = 2 * time.Minute
+	defaultReconnectMaxAttempts    = 10
+)
+
+// ReconnectMode determines when the daemon tries to bring a dropped device
+// back.
+type ReconnectMode = policy.ReconnectMode
+
+const (
+	ReconnectNever       = policy.ReconnectNever
+	ReconnectAlways      = policy.ReconnectAlways
+	ReconnectWhenInRange = policy.ReconnectWhenInRange
+)
+
+// TimeWindow is a recurring daily time range, optionally limited to specific
+// weekdays.
+type TimeWindow = policy.TimeWindow
+
+// ReconnectPolicy describes how aggressively a device is reconnected.
+type ReconnectPolicy struct {
+	Mode ReconnectMode
+
+	// Windows restricts reconnection to specific times of day. An empty list
+	// allows reconnection at any time.
+	Windows []TimeWindow
+
+	// InitialBackoff is the delay after the first attempt that did not leave
+	// the device connected. Subsequent attempts double the delay up to
+	// MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxAttempts is the number of consecutive attempts that did not leave
+	// the device connected after which the daemon gives up until the device
+	// is seen connected again or a user asks for it.
+	MaxAttempts int
+}
+
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if p.Mode == "" {
+		p.Mode = ReconnectNever
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultReconnectInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultReconnectMaxBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultReconnectMaxAttempts
+	}
+	return p
+}
+
+// Allows reports whether the policy's time windows permit reconnecting at t.
+func (p ReconnectPolicy) Allows(t time.Time) bool {
+	if len(p.Windows) == 0 {
+		return true
+	}
+
+	for _, window := range p.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the delay to wait after the given number of attempts that
+// did not leave the device connected.
+func (p ReconnectPolicy) backoff(attempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// reconnectState tracks what the daemon knows about a single device between
+// polls.
+type reconnectState struct {
+	observed    bool
+	connected   bool
+	connectedAt time.Time
+	held        bool
+	gaveUp      bool
+	attempts    int
+	next        time.Time
+	batteryLow  bool
+}
+
+// HoldDevice marks a device as intentionally disconnected so the daemon stops
+// reconnecting it until ReleaseDevice is called.
+func (d *Daemon) HoldDevice(address string) {
+	addr := NormalizeAddress(address)
+
+	d.devMu.Lock()
+	defer d.devMu.Unlock()
+
+	st := d.deviceState(addr)
+	st.held = true
+	d.log.Info("device held disconnected", "address", addr)
+}
+
+// ReleaseDevice clears a hold placed by HoldDevice and resets any backoff so
+// the device becomes eligible for reconnection immediately.
+func (d *Daemon) ReleaseDevice(address string) {
+	addr := NormalizeAddress(address)
+
+	d.devMu.Lock()
+	defer d.devMu.Unlock()
+
+	st := d.deviceState(addr)
+	st.held = false
+	st.gaveUp = false
+	st.attempts = 0
+	st.next = time.Time{}
+	d.log.Info("device released for reconnection", "address", addr)
+}
+
+// deviceState returns the mutable state for addr. Callers must hold devMu.
+func (d *Daemon) deviceState(addr string) *reconnectState {
+	st, ok := d.devStates[addr]
+	if !ok {
+		st = &reconnectState{}
+		d.devStates[addr] = st
+	}
+	return st
+}
+
+// reconnectPolicyFor returns the effective policy for dev. Devices without
+// explicit settings inherit the daemon default only when they are trusted.
+func (d *Daemon) reconnectPolicyFor(dev Device) ReconnectPolicy {
+	current := d.settings()
+	if settings, ok := current.devices[dev.Address]; ok {
+		return settings.Reconnect.withDefaults()
+	}
+
+	if dev.Trusted {
+		return current.defaultReconnect.withDefaults()
+	}
+
+	return ReconnectPolicy{Mode: ReconnectNever}.withDefaults()
+}
+
+// reconnectEligible reports whether a disconnected device may be reconnected
+// right now according to its policy, hold and backoff state. Callers must
+// hold devMu.
+func (d *Daemon) reconnectEligible(dev Device, now time.Time) bool {
+	st := d.deviceState(dev.Address)
+	policy := d.reconnectPolicyFor(dev)
+
+	switch {
+	case st.held, st.gaveUp:
+		return false
+	case policy.Mode == ReconnectNever:
+		return false
+	case policy.Mode == ReconnectWhenInRange && !dev.InRange:
+		return false
+	case !policy.Allows(now):
+		return false
+	case now.Before(st.next):
+		return false
+	}
+
+	return true
+}
+
+// observeDevice updates the tracked state for dev and emits its connection
+// and battery changes. It reports whether dev connected or disconnected
+// since the previous poll; the first observation of a device is not a
+// transition. Callers must hold devMu.
+func (d *Daemon) observeDevice(dev Device, now time.Time) bool {
+	st := d.deviceState(dev.Address)
+	first := !st.observed
+	st.observed = true
+	d.observeBattery(dev, st)
+
+	if dev.Connected {
+		changed := !st.connected
+		if changed {
+			st.connectedAt = now
+			if first {
+				d.log.Info("device connected", "address", dev.Address)
+			} else {
+				d.emit(Event{Type: EventDeviceConnected, Adapter: dev.Adapter, Address: dev.Address, Message: dev.label() + " connected"})
+			}
+		}
+		st.connected = true
+		st.gaveUp = false
+		st.attempts = 0
+		st.next = time.Time{}
+		return changed && !first
+	}
+
+	if st.connected {
+		d.emit(Event{Type: EventDeviceDisconnected, Adapter: dev.Adapter, Address: dev.Address, Message: dev.label() + " disconnected"})
+		st.connected = false
+		st.attempts = 0
+		st.next = time.Time{}
+		return true
+	}
+	return false
+}
+
+// observeBattery reports a device's battery as low when its charge falls to
+// the configured threshold, and again only once it has risen above it in
+// between. Callers must hold devMu.
+func (d *Daemon) observeBattery(dev Device, st *reconnectState) {
+	if !dev.HasBattery {
+		return
+	}
+
+	low := dev.Battery <= d.settings().batteryLow
+	if low && !st.batteryLow {
+		d.emit(Event{Type: EventBatteryLow, Adapter: dev.Adapter, Address: dev.Address, Message: fmt.Sprintf("%s battery low: %d%%", dev.label(), dev.Battery)})
+	}
+	st.batteryLow = low
+}
+
+// deviceTransitioned runs the actions configured for dev connecting or
+// disconnecting. Applying audio settings waits for the sound server to pick
+// the device up, so the actions run in the background; Run waits for them
+// before it returns.
+func (d *Daemon) deviceTransitioned(ctx context.Context, dev Device) {
+	settings, ok := d.settings().devices[dev.Address]
+	if !ok {
+		return
+	}
+
+	d.actionsWG.Add(1)
+	go func() {
+		defer d.actionsWG.Done()
+		var err error
+		if dev.Connected {
+			err = d.actions.Connected(ctx, dev, settings)
+		} else {
+			err = d.actions.Disconnected(ctx, dev, settings)
+		}
+		if err != nil && ctx.Err() == nil {
+			d.log.Warn("device action failed", "address", dev.Address, "connected", dev.Connected, "error", err)
+		}
+	}()
+}
+
+// reconcileDevices observes current device state, arbitrates between devices
+// competing for the same kind of connection and issues reconnect attempts for
+// dropped devices whose policy allows it. It is called on every poll tick and
+// is safe to drive directly from tests.
+func (d *Daemon) reconcileDevices(ctx context.Context) error {
+	if d.deviceBackend == nil || d.sleeping() {
+		return nil
+	}
+
+	devices, err := d.collectDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+
+	now := d.now()
+
+	d.devMu.Lock()
+	var candidates, transitioned []Device
+	for i := range devices {
+		devices[i].Kind = d.kindFor(devices[i])
+
+		if d.observeDevice(devices[i], now) {
+			transitioned = append(transitioned, devices[i])
+		}
+		if !devices[i].Connected && d.reconnectEligible(devices[i], now) {
+			candidates = append(candidates, devices[i])
+		}
+	}
+	d.knownDevices = devices
+	plan := d.planArbitration(devices, candidates, now)
+	d.devMu.Unlock()
+
+	for _, dev := range transitioned {
+		d.deviceTransitioned(ctx, dev)
+	}
+
+	for _, decision := range plan.evict {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := d.deviceBackend.Disconnect(ctx, decision.Adapter, decision.Address)
+		d.recordDecision(decision, err)
+	}
+
+	for _, dev := range plan.connect {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := d.deviceBackend.Connect(ctx, dev.Adapter, dev.Address)
+		d.recordReconnectAttempt(dev.Address, err)
+		d.recordConnectResult(dev.Adapter, err)
+	}
+
+	return nil
+}
+
+// recordReconnectAttempt counts a reconnect attempt and schedules the next
+// one. A successful Connect backs off like a failed one: the attempt only
+// counts as done once a poll sees the device connected, at which point
+// observeDevice resets the counter, so a link that drops straight away is
+// not retried on every poll.
+func (d *Daemon) recordReconnectAttempt(addr string, err error) {
+	d.devMu.Lock()
+	defer d.devMu.Unlock()
+
+	st := d.deviceState(addr)
+	st.attempts++
+
+	// Only configured or trusted devices become candidates, so resolving the
+	// policy as if the device were trusted yields the one that was applied.
+	policy := d.reconnectPolicyFor(Device{Address: addr, Trusted: true})
+	if st.attempts >= policy.MaxAttempts {
+		st.gaveUp = true
+		d.log.Warn("giving up on device reconnection", "address", addr, "attempts", st.attempts, "error", err)
+		return
+	}
+
+	delay := policy.backoff(st.attempts)
+	st.next = d.now().Add(delay)
+	if err == nil {
+		d.log.Info("device reconnect succeeded", "address", addr, "attempt", st.attempts)
+		return
+	}
+	d.log.Warn("device reconnect failed", "address", addr, "attempt", st.attempts, "retry_in", delay, "error", err)
+}
@@ -0,0 +1,396 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/control"
+	"github.com/peared/peared/internal/policy"
+)
+
+// fakeBackend is an in-memory DeviceBackend. Connect succeeds only when
+// connectErr is nil, in which case the device flips to connected unless
+// connectDrops is set. Devices with an empty Adapter are visible through every
+// adapter.
+type fakeBackend struct {
+	mu           sync.Mutex
+	devices      map[string]*Device
+	connectErr   error
+	connectDrops bool
+	connects     []string
+	via          []string
+}
+
+func newFakeBackend(devices ...Device) *fakeBackend {
+	b := &fakeBackend{devices: make(map[string]*Device)}
+	for i := range devices {
+		dev := devices[i]
+		b.devices[dev.Address] = &dev
+	}
+	return b
+}
+
+func (b *fakeBackend) ListDevices(_ context.Context, adapter string) ([]Device, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []Device
+	for _, dev := range b.devices {
+		if dev.Adapter == "" || dev.Adapter == adapter {
+			out = append(out, *dev)
+		}
+	}
+	return out, nil
+}
+
+func (b *fakeBackend) Connect(_ context.Context, adapter, address string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.connects = append(b.connects, address)
+	b.via = append(b.via, adapter)
+	if b.connectErr != nil {
+		return b.connectErr
+	}
+	b.devices[address].Connected = !b.connectDrops
+	return nil
+}
+
+func (b *fakeBackend) Disconnect(_ context.Context, _, address string) error {
+	b.setConnected(address, false)
+	return nil
+}
+
+func (b *fakeBackend) setConnected(address string, connected bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.devices[address].Connected = connected
+}
+
+func (b *fakeBackend) setBattery(address string, percent int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.devices[address].Battery = percent
+	b.devices[address].HasBattery = true
+}
+
+func (b *fakeBackend) attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.connects)
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+const headset = "AA:BB:CC:DD:EE:FF"
+
+var errPageTimeout = errors.New("page timeout")
+
+// reconnectOptions configures the headset with policy.
+func reconnectOptions(backend DeviceBackend, clock *fakeClock, policy ReconnectPolicy) Options {
+	return Options{
+		DeviceBackend: backend,
+		Devices:       map[string]DeviceSettings{headset: {Reconnect: policy}},
+		Clock:         clock.Now,
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Paired: true, Connected: true})
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{Mode: ReconnectAlways}))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no attempts while connected")
+	}
+
+	backend.setConnected(headset, false)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 1 {
+		t.Fatalf("expected one reconnect attempt, got %d", backend.attempts())
+	}
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 1 {
+		t.Fatalf("expected device to stay connected, got %d attempts", backend.attempts())
+	}
+}
+
+func TestReconnectBacksOffAndGivesUp(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Paired: true})
+	backend.connectErr = errPageTimeout
+
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{
+		Mode:           ReconnectAlways,
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+		MaxAttempts:    3,
+	}))
+
+	steps := []struct {
+		advance time.Duration
+		want    int
+	}{
+		{0, 1},                      // immediate first attempt
+		{500 * time.Millisecond, 1}, // still inside the 1s backoff
+		{500 * time.Millisecond, 2}, // backoff elapsed
+		{time.Second, 2},            // second backoff is 2s
+		{time.Second, 3},            // third attempt reaches the limit
+		{time.Hour, 3},              // gave up
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if err := d.reconcileDevices(ctx); err != nil {
+			t.Fatalf("step %d: reconcile: %v", i, err)
+		}
+		if got := backend.attempts(); got != step.want {
+			t.Fatalf("step %d: expected %d attempts, got %d", i, step.want, got)
+		}
+	}
+
+	// A manual connect request resets the give-up state.
+	backend.connectErr = nil
+	d.ReleaseDevice(headset)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 4 {
+		t.Fatalf("expected attempt after release, got %d", backend.attempts())
+	}
+}
+
+func TestReconnectBacksOffWhenConnectionDoesNotHold(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Paired: true})
+	backend.connectDrops = true
+
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{
+		Mode:           ReconnectAlways,
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+		MaxAttempts:    3,
+	}))
+
+	steps := []struct {
+		advance time.Duration
+		want    int
+	}{
+		{0, 1},                      // Connect succeeds but the link drops
+		{500 * time.Millisecond, 1}, // backs off like a failure
+		{500 * time.Millisecond, 2},
+		{time.Second, 2},
+		{time.Second, 3}, // third attempt reaches the limit
+		{time.Hour, 3},   // gave up
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if err := d.reconcileDevices(ctx); err != nil {
+			t.Fatalf("step %d: reconcile: %v", i, err)
+		}
+		if got := backend.attempts(); got != step.want {
+			t.Fatalf("step %d: expected %d attempts, got %d", i, step.want, got)
+		}
+	}
+
+	// Seeing the device connected resets the count.
+	backend.setConnected(headset, true)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	backend.setConnected(headset, false)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 4 {
+		t.Fatalf("expected an attempt once the device was seen connected, got %d", backend.attempts())
+	}
+}
+
+func TestReconnectRespectsManualDisconnect(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Paired: true, Connected: true})
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{Mode: ReconnectAlways}))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	resp := d.HandleControl(ctx, control.Request{Command: "device.hold", Args: map[string]string{"address": "aa:bb:cc:dd:ee:ff"}})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	backend.setConnected(headset, false)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Minute)
+		if err := d.reconcileDevices(ctx); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected held device to stay disconnected, got %d attempts", backend.attempts())
+	}
+
+	resp = d.HandleControl(ctx, control.Request{Command: "device.release", Args: map[string]string{"address": headset}})
+	if err := resp.Err(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 1 {
+		t.Fatalf("expected reconnect after release, got %d attempts", backend.attempts())
+	}
+}
+
+func TestReconnectWhenInRange(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Paired: true})
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{Mode: ReconnectWhenInRange}))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no attempt while out of range")
+	}
+
+	backend.mu.Lock()
+	backend.devices[headset].InRange = true
+	backend.mu.Unlock()
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 1 {
+		t.Fatalf("expected attempt once in range, got %d", backend.attempts())
+	}
+}
+
+func TestReconnectHonoursTimeWindows(t *testing.T) {
+	ctx := context.Background()
+	// Monday 07:00, before the window opens.
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)}
+	window, err := policy.ParseTimeWindow("mon-fri 08:00-18:00")
+	if err != nil {
+		t.Fatalf("ParseTimeWindow: %v", err)
+	}
+
+	backend := newFakeBackend(Device{Address: headset, Paired: true})
+	d := newTestDaemon(t, reconnectOptions(backend, clock, ReconnectPolicy{Mode: ReconnectAlways, Windows: []TimeWindow{window}}))
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no attempt outside the window")
+	}
+
+	clock.Advance(90 * time.Minute)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if backend.attempts() != 1 {
+		t.Fatalf("expected attempt inside the window, got %d", backend.attempts())
+	}
+}
+
+func TestReconnectDefaultPolicyAppliesToTrustedDevices(t *testing.T) {
+	ctx := context.Background()
+	backend := newFakeBackend(
+		Device{Address: "11:11:11:11:11:11", Trusted: true},
+		Device{Address: "22:22:22:22:22:22"},
+	)
+
+	d := newTestDaemon(t, Options{
+		DeviceBackend:    backend,
+		DefaultReconnect: ReconnectPolicy{Mode: ReconnectAlways},
+	})
+
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if len(backend.connects) != 1 || backend.connects[0] != "11:11:11:11:11:11" {
+		t.Fatalf("expected only the trusted device to reconnect, got %v", backend.connects)
+	}
+}
+
+func TestDeviceEvents(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	backend := newFakeBackend(Device{Address: headset, Alias: "WH-1000XM4", Paired: true, Connected: true, Battery: 80, HasBattery: true})
+	opts := reconnectOptions(backend, clock, ReconnectPolicy{Mode: ReconnectNever})
+	opts.BatteryLow = 15
+	d := newTestDaemon(t, opts)
+
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	// The first observation only records the device.
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	backend.setConnected(headset, false)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	backend.setConnected(headset, true)
+	backend.setBattery(headset, 15)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	// A battery that stays low is reported once.
+	backend.setBattery(headset, 10)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := drainEvents(events)
+	want := []EventType{EventDeviceDisconnected, EventBatteryLow, EventDeviceConnected}
+	if !slices.Equal(eventTypes(got), want) {
+		t.Fatalf("events = %v, want %v", eventTypes(got), want)
+	}
+	if got[1].Message != "WH-1000XM4 (AA:BB:CC:DD:EE:FF) battery low: 15%" {
+		t.Fatalf("battery event message = %q", got[1].Message)
+	}
+
+	// Charging above the threshold re-arms the warning.
+	backend.setBattery(headset, 50)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	backend.setBattery(headset, 12)
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := eventTypes(drainEvents(events)); !slices.Equal(got, []EventType{EventBatteryLow}) {
+		t.Fatalf("events after recharge = %v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Settings are the options that can change while the daemon runs. Reload
+// swaps them in as a whole, so no goroutine ever sees half an update. The
+// fields mirror the Options of the same names.
+type Settings struct {
+	PreferredAdapter  string
+	Devices           map[string]DeviceSettings
+	DefaultReconnect  ReconnectPolicy
+	ConnectionLimits  map[DeviceKind]int
+	PollInterval      time.Duration
+	FailoverReconnect bool
+	SelectionPolicy   SelectionPolicy
+	AdapterSettings   map[string]AdapterSettings
+	SuspendAction     SuspendAction
+
+	// PairingTimeout bounds how long a pairing prompt waits for an answer.
+	PairingTimeout time.Duration
+
+	// BatteryLow is the charge in percent at or below which a device's
+	// battery is reported as low. Zero selects a default of 20.
+	BatteryLow int
+}
+
+// runtimeSettings is the normalised form of Settings the daemon reads. A
+// value is never modified once stored; Reload replaces it.
+type runtimeSettings struct {
+	preferredAdapter string
+	devices          map[string]DeviceSettings
+	defaultReconnect ReconnectPolicy
+	limits           map[DeviceKind]int
+	pollInterval     time.Duration
+	failoverDevices  bool
+	selection        SelectionPolicy
+	adapterSettings  map[string]AdapterSettings
+	suspendAction    SuspendAction
+	promptTimeout    time.Duration
+	batteryLow       int
+}
+
+func newRuntimeSettings(s Settings) *runtimeSettings {
+	devices := make(map[string]DeviceSettings, len(s.Devices))
+	for address, settings := range s.Devices {
+		devices[NormalizeAddress(address)] = settings
+	}
+
+	limits := make(map[DeviceKind]int, len(s.ConnectionLimits))
+	for kind, limit := range s.ConnectionLimits {
+		limits[kind] = limit
+	}
+
+	adapterSettings := make(map[string]AdapterSettings, len(s.AdapterSettings))
+	for identifier, settings := range s.AdapterSettings {
+		adapterSettings[identifier] = settings
+	}
+
+	pollInterval := s.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	promptTimeout := s.PairingTimeout
+	if promptTimeout <= 0 {
+		promptTimeout = defaultPromptTimeout
+	}
+
+	batteryLow := s.BatteryLow
+	if batteryLow <= 0 {
+		batteryLow = defaultBatteryLow
+	}
+
+	return &runtimeSettings{
+		preferredAdapter: s.PreferredAdapter,
+		devices:          devices,
+		defaultReconnect: s.DefaultReconnect,
+		limits:           limits,
+		pollInterval:     pollInterval,
+		failoverDevices:  s.FailoverReconnect,
+		selection:        s.SelectionPolicy,
+		adapterSettings:  adapterSettings,
+		suspendAction:    s.SuspendAction,
+		promptTimeout:    promptTimeout,
+		batteryLow:       batteryLow,
+	}
+}
+
+// changes names the settings that differ between s and next.
+func (s *runtimeSettings) changes(next *runtimeSettings) []string {
+	var changed []string
+	for _, setting := range []struct {
+		name          string
+		before, after any
+	}{
+		{"preferred adapter", s.preferredAdapter, next.preferredAdapter},
+		{"adapter selection", s.selection, next.selection},
+		{"adapter settings", s.adapterSettings, next.adapterSettings},
+		{"device settings", s.devices, next.devices},
+		{"default reconnect policy", s.defaultReconnect, next.defaultReconnect},
+		{"connection limits", s.limits, next.limits},
+		{"poll interval", s.pollInterval, next.pollInterval},
+		{"failover", s.failoverDevices, next.failoverDevices},
+		{"suspend action", s.suspendAction, next.suspendAction},
+		{"pairing timeout", s.promptTimeout, next.promptTimeout},
+		{"battery threshold", s.batteryLow, next.batteryLow},
+	} {
+		if !reflect.DeepEqual(setting.before, setting.after) {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
+
+// settings returns the settings in effect.
+func (d *Daemon) settings() *runtimeSettings {
+	return d.live.Load()
+}
+
+// Reload replaces the daemon's settings while it runs and reports what
+// changed. The poll loop then picks the active adapter again, so a new
+// preferred adapter or selection policy takes effect, applies changed adapter
+// settings to the adapters present, and reconciles devices against the new
+// device settings and limits without waiting for the next poll.
+func (d *Daemon) Reload(s Settings) []string {
+	next := newRuntimeSettings(s)
+	previous := d.live.Swap(next)
+
+	changed := previous.changes(next)
+	if len(changed) == 0 {
+		d.log.Info("configuration reloaded without changes")
+		return nil
+	}
+
+	if !reflect.DeepEqual(previous.adapterSettings, next.adapterSettings) {
+		d.reapplyAdapters.Store(true)
+	}
+	select {
+	case d.reloaded <- struct{}{}:
+	default:
+	}
+
+	d.emit(Event{Type: EventConfigReloaded, Message: "configuration reloaded: " + strings.Join(changed, ", ") + " changed"})
+	return changed
+}
+
+// applyReload brings the daemon in line with settings swapped in by Reload.
+// It runs on the poll loop, which refreshes adapters and devices right after.
+func (d *Daemon) applyReload(ctx context.Context) {
+	if !d.reapplyAdapters.Swap(false) {
+		return
+	}
+
+	d.mu.RLock()
+	adapters := append([]Adapter(nil), d.adapters...)
+	d.mu.RUnlock()
+
+	for _, adapter := range adapters {
+		d.configureAdapter(ctx, adapter)
+	}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadSwitchesToNewPreferredAdapter(t *testing.T) {
+	provider := &adapterSet{}
+	provider.set(onboard, dongle)
+
+	d := newTestDaemon(t, Options{AdapterProvider: provider, PreferredAdapter: "hci0", PollInterval: time.Hour})
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	defer func() {
+		stop()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned error: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for activeID(t, d) != "hci0" {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon never selected hci0")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	changed := d.Reload(Settings{PreferredAdapter: "hci1", PollInterval: time.Hour})
+	if strings.Join(changed, ",") != "preferred adapter" {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+
+	var types []EventType
+	timeout := time.After(5 * time.Second)
+	for len(types) < 2 {
+		select {
+		case ev := <-events:
+			types = append(types, ev.Type)
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got %v", types)
+		}
+	}
+	if types[0] != EventConfigReloaded || types[1] != EventAdapterSwitched {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if got := activeID(t, d); got != "hci1" {
+		t.Fatalf("expected the reload to switch to hci1, got %q", got)
+	}
+}
+
+func TestReloadWithoutChanges(t *testing.T) {
+	d := newTestDaemon(t, Options{
+		AdapterProvider:  &adapterSet{},
+		PreferredAdapter: "hci0",
+		Devices:          map[string]DeviceSettings{"aa:bb:cc:dd:ee:ff": {Priority: 1}},
+	})
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	if changed := d.Reload(Settings{
+		PreferredAdapter: "hci0",
+		Devices:          map[string]DeviceSettings{"AA:BB:CC:DD:EE:FF": {Priority: 1}},
+	}); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+	if evs := drainEvents(events); len(evs) != 0 {
+		t.Fatalf("expected no events, got %+v", evs)
+	}
+}
+
+func TestReloadReappliesChangedAdapterSettings(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard)
+
+	backend := &recordingAdapterBackend{}
+	d := newTestDaemon(t, Options{AdapterProvider: provider, AdapterBackend: backend})
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	// Settings that leave adapters alone do not touch them again.
+	d.Reload(Settings{PreferredAdapter: "hci0"})
+	d.applyReload(ctx)
+	if len(backend.calls) != 0 {
+		t.Fatalf("unexpected calls %v", backend.calls)
+	}
+
+	d.Reload(Settings{PreferredAdapter: "hci0", AdapterSettings: map[string]AdapterSettings{"hci0": {Alias: "Desk"}}})
+	d.applyReload(ctx)
+	if strings.Join(backend.calls, "; ") != "hci0 alias Desk" {
+		t.Fatalf("unexpected calls %v", backend.calls)
+	}
+}
@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+// AdapterRule matches adapters by hardware and identity.
+type AdapterRule = policy.AdapterRule
+
+// ruleMatches reports whether adapter satisfies every field of r.
+func ruleMatches(r AdapterRule, adapter Adapter) bool {
+	return globField(r.ID, adapter.ID) &&
+		exactField(r.Address, adapter.Address) &&
+		globField(r.Alias, adapter.Alias) &&
+		exactField(r.Transport, string(adapter.Transport)) &&
+		exactField(r.VendorID, adapter.VendorID) &&
+		globField(r.Driver, adapter.Driver)
+}
+
+// Tiebreak orders adapters that score the same.
+type Tiebreak = policy.Tiebreak
+
+const (
+	TiebreakUSB     = policy.TiebreakUSB
+	TiebreakFirst   = policy.TiebreakFirst
+	TiebreakPowered = policy.TiebreakPowered
+	TiebreakID      = policy.TiebreakID
+)
+
+func globField(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, strings.ToLower(value))
+	return ok
+}
+
+func exactField(want, value string) bool {
+	return want == "" || strings.EqualFold(want, value)
+}
+
+// SelectionPolicy ranks adapters. An explicitly preferred adapter wins,
+// followed by adapters matching earlier Prefer rules; excluded adapters are
+// never chosen.
+type SelectionPolicy struct {
+	Prefer   []AdapterRule
+	Exclude  []AdapterRule
+	Tiebreak Tiebreak
+}
+
+// NewSelectionPolicy builds a policy from the configuration form of its
+// rules. The daemon and the CLI both use it so they always agree on which
+// adapter to pick.
+func NewSelectionPolicy(prefer, exclude []map[string]string, tiebreak string) (SelectionPolicy, error) {
+	selection, err := policy.ParseSelection(prefer, exclude, tiebreak)
+	if err != nil {
+		return SelectionPolicy{}, err
+	}
+	return SelectionPolicy(selection), nil
+}
+
+// AdapterScore explains how a policy ranked one adapter.
+type AdapterScore struct {
+	Adapter  Adapter  `json:"adapter"`
+	Score    int      `json:"score"`
+	Excluded bool     `json:"excluded"`
+	Reasons  []string `json:"reasons"`
+}
+
+// score rates a single adapter. The preferred adapter outranks every rule and
+// earlier rules outrank later ones.
+func (p SelectionPolicy) score(preferred string, adapter Adapter) AdapterScore {
+	result := AdapterScore{Adapter: adapter}
+
+	for _, rule := range p.Exclude {
+		if ruleMatches(rule, adapter) {
+			result.Excluded = true
+			result.Reasons = append(result.Reasons, "excluded by rule "+rule.String())
+			return result
+		}
+	}
+
+	if adapter.Matches(preferred) {
+		result.Score = len(p.Prefer) + 1
+		result.Reasons = append(result.Reasons, fmt.Sprintf("matches preferred adapter %q", strings.TrimSpace(preferred)))
+		return result
+	}
+
+	for i, rule := range p.Prefer {
+		if ruleMatches(rule, adapter) {
+			result.Score = len(p.Prefer) - i
+			result.Reasons = append(result.Reasons, fmt.Sprintf("matches prefer rule %d (%s)", i+1, rule.String()))
+			return result
+		}
+	}
+
+	result.Reasons = append(result.Reasons, "matches no prefer rule")
+	return result
+}
+
+// Explain scores every adapter and returns them best first. Excluded
+// adapters sort last.
+func (p SelectionPolicy) Explain(preferred string, adapters []Adapter) []AdapterScore {
+	scores := make([]AdapterScore, 0, len(adapters))
+	for _, adapter := range adapters {
+		scores = append(scores, p.score(preferred, adapter))
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.Excluded != b.Excluded {
+			return !a.Excluded
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return p.tiebreakLess(a.Adapter, b.Adapter)
+	})
+
+	return scores
+}
+
+func (p SelectionPolicy) tiebreakLess(a, b Adapter) bool {
+	switch p.Tiebreak {
+	case TiebreakFirst:
+		return false
+	case TiebreakPowered:
+		return a.Powered && !b.Powered
+	case TiebreakID:
+		return a.ID < b.ID
+	default:
+		return a.Transport == AdapterTransportUSB && b.Transport != AdapterTransportUSB
+	}
+}
+
+// Select returns the best adapter according to the policy.
+func (p SelectionPolicy) Select(preferred string, adapters []Adapter) (Adapter, error) {
+	if len(adapters) == 0 {
+		return Adapter{}, fmt.Errorf("select adapter: no adapters supplied")
+	}
+
+	ranked := p.Explain(preferred, adapters)
+	if ranked[0].Excluded {
+		return Adapter{}, errors.New("select adapter: every adapter is excluded by the selection policy")
+	}
+
+	return ranked[0].Adapter, nil
+}
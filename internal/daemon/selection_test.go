@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+)
+
+var selectionAdapters = []Adapter{
+	{ID: "hci0", Address: "00:00:00:00:00:01", Alias: "Onboard", Transport: AdapterTransportUSB, VendorID: "8087", Driver: "btusb"},
+	{ID: "hci1", Address: "00:00:00:00:00:02", Alias: "Gaming Dongle", Transport: AdapterTransportUSB, VendorID: "0bda", Driver: "btusb", Powered: true},
+	{ID: "hci2", Address: "00:00:00:00:00:03", Alias: "Serial", Transport: AdapterTransportPlatform, Driver: "hci_uart", Powered: true},
+}
+
+func TestSelectionPolicyPreferRules(t *testing.T) {
+	policy, err := NewSelectionPolicy(
+		[]map[string]string{{"driver": "hci_*"}, {"alias": "gaming*"}},
+		nil,
+		"",
+	)
+	if err != nil {
+		t.Fatalf("NewSelectionPolicy returned error: %v", err)
+	}
+
+	chosen, err := policy.Select("", selectionAdapters)
+	if err != nil {
+		t.Fatalf("Select returned error: %v", err)
+	}
+	if chosen.ID != "hci2" {
+		t.Fatalf("expected first rule to win, got %s", chosen.ID)
+	}
+
+	// An explicitly preferred adapter outranks every rule.
+	chosen, err = policy.Select("Gaming Dongle", selectionAdapters)
+	if err != nil {
+		t.Fatalf("Select returned error: %v", err)
+	}
+	if chosen.ID != "hci1" {
+		t.Fatalf("expected preferred adapter, got %s", chosen.ID)
+	}
+}
+
+func TestSelectionPolicyExclusions(t *testing.T) {
+	policy, err := NewSelectionPolicy(nil, []map[string]string{{"vendor_id": "0x8087"}}, "first")
+	if err != nil {
+		t.Fatalf("NewSelectionPolicy returned error: %v", err)
+	}
+
+	ranked := policy.Explain("hci0", selectionAdapters)
+	if ranked[0].Adapter.ID != "hci1" {
+		t.Fatalf("expected hci1 first, got %+v", ranked[0])
+	}
+	last := ranked[len(ranked)-1]
+	if last.Adapter.ID != "hci0" || !last.Excluded {
+		t.Fatalf("expected excluded onboard adapter last, got %+v", last)
+	}
+
+	if _, err := policy.Select("", selectionAdapters[:1]); err == nil {
+		t.Fatal("expected error when every adapter is excluded")
+	}
+}
+
+func TestSelectionPolicyTiebreaks(t *testing.T) {
+	tests := []struct {
+		tiebreak string
+		want     string
+	}{
+		{"usb", "hci0"},
+		{"first", "hci0"},
+		{"powered", "hci1"},
+		{"id", "hci0"},
+	}
+
+	reversed := []Adapter{selectionAdapters[2], selectionAdapters[1], selectionAdapters[0]}
+	for _, tt := range tests {
+		policy, err := NewSelectionPolicy(nil, nil, tt.tiebreak)
+		if err != nil {
+			t.Fatalf("NewSelectionPolicy(%q) returned error: %v", tt.tiebreak, err)
+		}
+
+		adapters := selectionAdapters
+		if tt.tiebreak == "id" {
+			adapters = reversed
+		}
+
+		chosen, err := policy.Select("", adapters)
+		if err != nil {
+			t.Fatalf("Select returned error: %v", err)
+		}
+		if chosen.ID != tt.want {
+			t.Errorf("tiebreak %s: expected %s, got %s", tt.tiebreak, tt.want, chosen.ID)
+		}
+	}
+}
+
+func TestNewSelectionPolicyRejectsInvalidInput(t *testing.T) {
+	if _, err := NewSelectionPolicy([]map[string]string{{"colour": "blue"}}, nil, ""); err == nil {
+		t.Error("expected error for unknown rule field")
+	}
+	if _, err := NewSelectionPolicy([]map[string]string{{"alias": "[oops"}}, nil, ""); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+	if _, err := NewSelectionPolicy(nil, nil, "random"); err == nil {
+		t.Error("expected error for unknown tiebreak")
+	}
+}
+
+func TestDaemonSwitchesToHigherRankedAdapter(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(onboard)
+
+	policy, err := NewSelectionPolicy([]map[string]string{{"transport": "usb"}}, nil, "")
+	if err != nil {
+		t.Fatalf("NewSelectionPolicy returned error: %v", err)
+	}
+	d := newTestDaemon(t, Options{AdapterProvider: provider, SelectionPolicy: policy})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci0" {
+		t.Fatalf("expected hci0, got %q", got)
+	}
+
+	provider.set(onboard, dongle)
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if got := activeID(t, d); got != "hci1" {
+		t.Fatalf("expected switch to the USB adapter, got %q", got)
+	}
+}
@@ -0,0 +1,241 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+// sleepPrepareTimeout bounds the work done before suspend. logind only waits
+// InhibitDelayMaxSec (5s by default) for delay locks to be released.
+const sleepPrepareTimeout = 4 * time.Second
+
+// SleepMonitor reports system suspend and resume and lets the daemon delay
+// suspend while it prepares. *logind.Manager implements it.
+type SleepMonitor interface {
+	// InhibitSleep takes a delay lock that postpones suspend until closed.
+	InhibitSleep(ctx context.Context, who, why string) (io.Closer, error)
+
+	// WatchSleep calls fn with true before suspend and false after resume
+	// until ctx is cancelled.
+	WatchSleep(ctx context.Context, fn func(sleeping bool)) error
+}
+
+// SuspendAction decides what happens to a connected device before suspend.
+type SuspendAction = policy.SuspendAction
+
+const (
+	SuspendLeave      = policy.SuspendLeave
+	SuspendDisconnect = policy.SuspendDisconnect
+)
+
+// sleepSnapshot records what was connected and powered before suspend so it
+// can be restored on resume.
+type sleepSnapshot struct {
+	devices  []Device
+	adapters []Adapter
+}
+
+// watchSleep holds a delay lock and handles suspend and resume until the
+// context is cancelled. Failing to reach logind only disables the feature.
+func (d *Daemon) watchSleep(ctx context.Context) {
+	d.takeSleepLock(ctx)
+	defer d.releaseSleepLock()
+
+	err := d.sleepMonitor.WatchSleep(ctx, func(sleeping bool) {
+		if sleeping {
+			d.prepareForSleep(ctx)
+		} else {
+			d.resumeFromSleep(ctx)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		d.log.Warn("suspend handling disabled", "error", err)
+	}
+}
+
+func (d *Daemon) takeSleepLock(ctx context.Context) {
+	lock, err := d.sleepMonitor.InhibitSleep(ctx, "pearedd", "Prepare Bluetooth devices for suspend")
+	if err != nil {
+		d.log.Warn("cannot delay suspend; devices will not be prepared", "error", err)
+		return
+	}
+
+	d.sleepMu.Lock()
+	d.sleepLock = lock
+	d.sleepMu.Unlock()
+}
+
+func (d *Daemon) releaseSleepLock() {
+	d.sleepMu.Lock()
+	lock := d.sleepLock
+	d.sleepLock = nil
+	d.sleepMu.Unlock()
+
+	if lock != nil {
+		lock.Close()
+	}
+}
+
+// sleeping reports whether the system is between PrepareForSleep and resume.
+// Device reconciliation pauses meanwhile so it does not undo the
+// preparation.
+func (d *Daemon) sleeping() bool {
+	d.sleepMu.Lock()
+	defer d.sleepMu.Unlock()
+	return d.asleep
+}
+
+// prepareForSleep remembers connected devices and powered adapters,
+// disconnects devices whose suspend action asks for it and then releases the
+// delay lock so suspend can proceed.
+func (d *Daemon) prepareForSleep(ctx context.Context) {
+	defer d.releaseSleepLock()
+
+	d.sleepMu.Lock()
+	d.asleep = true
+	d.sleepMu.Unlock()
+
+	var snapshot sleepSnapshot
+	for _, adapter := range d.Adapters() {
+		if adapter.Powered {
+			snapshot.adapters = append(snapshot.adapters, adapter)
+		}
+	}
+
+	var disconnect []Device
+	d.devMu.Lock()
+	for _, dev := range d.knownDevices {
+		if !dev.Connected {
+			continue
+		}
+		snapshot.devices = append(snapshot.devices, dev)
+		if d.suspendActionFor(dev) == SuspendDisconnect {
+			disconnect = append(disconnect, dev)
+		}
+	}
+	d.devMu.Unlock()
+
+	d.sleepMu.Lock()
+	d.beforeSleep = snapshot
+	d.sleepMu.Unlock()
+
+	if d.deviceBackend != nil && len(disconnect) > 0 {
+		prepareCtx, cancel := context.WithTimeout(ctx, sleepPrepareTimeout)
+		for _, dev := range disconnect {
+			if err := d.deviceBackend.Disconnect(prepareCtx, dev.Adapter, dev.Address); err != nil {
+				d.log.Warn("disconnect before suspend failed", "address", dev.Address, "error", err)
+			}
+		}
+		cancel()
+	}
+
+	d.emit(Event{Type: EventSystemSleep, Message: fmt.Sprintf("preparing for suspend: %d devices connected, %d disconnected", len(snapshot.devices), len(disconnect))})
+}
+
+// resumeFromSleep re-arms the delay lock, powers adapters that were on before
+// suspend back up and reconnects the devices that were connected.
+func (d *Daemon) resumeFromSleep(ctx context.Context) {
+	d.takeSleepLock(ctx)
+
+	d.sleepMu.Lock()
+	snapshot := d.beforeSleep
+	d.beforeSleep = sleepSnapshot{}
+	d.sleepMu.Unlock()
+
+	if d.adapterProv != nil {
+		if err := d.refreshAdapters(ctx); err != nil && ctx.Err() == nil {
+			d.log.Warn("adapter refresh after resume failed", "error", err)
+		}
+	}
+
+	powered := 0
+	if d.adapterBackend != nil {
+		for _, before := range snapshot.adapters {
+			current, ok := d.resolveAdapter(stableIdentifier(before))
+			if !ok || current.Powered {
+				continue
+			}
+			if err := d.adapterBackend.SetPowered(ctx, current.ID, true); err != nil {
+				d.log.Warn("re-powering adapter after resume failed", "adapter", current.ID, "error", err)
+				continue
+			}
+			powered++
+		}
+	}
+
+	d.sleepMu.Lock()
+	d.asleep = false
+	d.sleepMu.Unlock()
+
+	reconnected := d.reconnectAfterResume(ctx, snapshot.devices)
+
+	d.emit(Event{Type: EventSystemResume, Message: fmt.Sprintf("resumed: %d adapters re-powered, %d of %d devices reconnected", powered, reconnected, len(snapshot.devices))})
+}
+
+// reconnectAfterResume connects devices that were connected before suspend,
+// skipping held ones. Backoff is reset first so devices that fail here are
+// retried promptly by the regular reconnect loop.
+func (d *Daemon) reconnectAfterResume(ctx context.Context, devices []Device) int {
+	if d.deviceBackend == nil {
+		return 0
+	}
+
+	active := ""
+	if adapter, ok := d.ActiveAdapter(); ok {
+		active = adapter.ID
+	}
+	pins, _ := d.pinnedAdapters()
+
+	var wanted []Device
+	d.devMu.Lock()
+	for _, dev := range devices {
+		st := d.deviceState(dev.Address)
+		if st.held {
+			continue
+		}
+		st.gaveUp = false
+		st.attempts = 0
+		st.next = time.Time{}
+
+		// Adapters may be renumbered across suspend, so route through the
+		// pinned or active adapter rather than the one recorded before.
+		if pinned, ok := pins[dev.Address]; ok {
+			dev.Adapter = pinned
+		} else {
+			dev.Adapter = active
+		}
+		wanted = append(wanted, dev)
+	}
+	d.devMu.Unlock()
+
+	reconnected := 0
+	for _, dev := range wanted {
+		if ctx.Err() != nil {
+			break
+		}
+		err := d.deviceBackend.Connect(ctx, dev.Adapter, dev.Address)
+		d.recordReconnectAttempt(dev.Address, err)
+		d.recordConnectResult(dev.Adapter, err)
+		if err == nil {
+			reconnected++
+		}
+	}
+
+	return reconnected
+}
+
+// suspendActionFor returns the configured suspend action for dev.
+func (d *Daemon) suspendActionFor(dev Device) SuspendAction {
+	current := d.settings()
+	if settings, ok := current.devices[dev.Address]; ok && settings.Suspend != "" {
+		return settings.Suspend
+	}
+	if current.suspendAction != "" {
+		return current.suspendAction
+	}
+	return SuspendLeave
+}
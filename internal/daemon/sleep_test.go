@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSleepMonitor delivers sleep transitions sent on transitions and
+// acknowledges each once the daemon has handled it.
+type fakeSleepMonitor struct {
+	transitions chan bool
+	handled     chan struct{}
+
+	mu       sync.Mutex
+	taken    int
+	released int
+}
+
+func newFakeSleepMonitor() *fakeSleepMonitor {
+	return &fakeSleepMonitor{transitions: make(chan bool), handled: make(chan struct{})}
+}
+
+func (m *fakeSleepMonitor) InhibitSleep(context.Context, string, string) (io.Closer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.taken++
+	return closerFunc(func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.released++
+		return nil
+	}), nil
+}
+
+func (m *fakeSleepMonitor) WatchSleep(ctx context.Context, fn func(bool)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sleeping := <-m.transitions:
+			fn(sleeping)
+			m.handled <- struct{}{}
+		}
+	}
+}
+
+func (m *fakeSleepMonitor) send(sleeping bool) {
+	m.transitions <- sleeping
+	<-m.handled
+}
+
+func (m *fakeSleepMonitor) locks() (taken, released int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.taken, m.released
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestSuspendAndResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const keyboard = "33:33:33:33:33:33"
+	provider := &adapterSet{}
+	powered := dongle
+	powered.Powered = true
+	provider.set(powered)
+
+	backend := newFakeBackend(
+		Device{Address: headset, Kind: DeviceKindHeadset, Connected: true},
+		Device{Address: keyboard, Kind: DeviceKindKeyboard, Connected: true},
+	)
+	adapterBackend := &recordingAdapterBackend{}
+	monitor := newFakeSleepMonitor()
+
+	d := newTestDaemon(t, Options{
+		AdapterProvider: provider,
+		DeviceBackend:   backend,
+		AdapterBackend:  adapterBackend,
+		SleepMonitor:    monitor,
+		Devices: map[string]DeviceSettings{
+			headset: {Reconnect: ReconnectPolicy{Mode: ReconnectAlways}, Suspend: SuspendDisconnect},
+		},
+	})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcileDevices: %v", err)
+	}
+	events, unsubscribe := d.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		d.watchSleep(ctx)
+		close(done)
+	}()
+
+	monitor.send(true)
+
+	backend.mu.Lock()
+	headsetConnected := backend.devices[headset].Connected
+	keyboardConnected := backend.devices[keyboard].Connected
+	backend.mu.Unlock()
+	if headsetConnected || !keyboardConnected {
+		t.Fatalf("expected only the headset to be disconnected before suspend (headset=%v keyboard=%v)", headsetConnected, keyboardConnected)
+	}
+	if taken, released := monitor.locks(); taken != 1 || released != 1 {
+		t.Fatalf("expected the delay lock to be released before suspend, taken=%d released=%d", taken, released)
+	}
+
+	// Reconciliation must not reconnect the headset while suspending.
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcileDevices: %v", err)
+	}
+	if backend.attempts() != 0 {
+		t.Fatalf("expected no reconnects while asleep, got %v", backend.connects)
+	}
+
+	// The adapter comes back unpowered and the keyboard dropped.
+	powered.Powered = false
+	provider.set(powered)
+	backend.setConnected(keyboard, false)
+
+	monitor.send(false)
+
+	if len(adapterBackend.calls) != 1 || adapterBackend.calls[0] != "hci1 power true" {
+		t.Fatalf("expected the adapter to be re-powered, got %v", adapterBackend.calls)
+	}
+
+	connects := append([]string(nil), backend.connects...)
+	sort.Strings(connects)
+	if len(connects) != 2 || connects[0] != keyboard || connects[1] != headset {
+		t.Fatalf("expected both devices to be reconnected, got %v", backend.connects)
+	}
+	for _, via := range backend.via {
+		if via != dongle.ID {
+			t.Fatalf("expected reconnects through the active adapter, got %v", backend.via)
+		}
+	}
+
+	if taken, _ := monitor.locks(); taken != 2 {
+		t.Fatalf("expected the delay lock to be re-taken after resume, taken=%d", taken)
+	}
+
+	var types []EventType
+	for _, ev := range drainEvents(events) {
+		if ev.Type == EventSystemSleep || ev.Type == EventSystemResume {
+			types = append(types, ev.Type)
+		}
+	}
+	if len(types) != 2 || types[0] != EventSystemSleep || types[1] != EventSystemResume {
+		t.Fatalf("unexpected events: %v", types)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watchSleep did not stop")
+	}
+	if taken, released := monitor.locks(); taken != released {
+		t.Fatalf("expected every lock to be released on exit, taken=%d released=%d", taken, released)
+	}
+}
+
+func TestResumeSkipsHeldDevices(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	provider.set(dongle)
+	backend := newFakeBackend(Device{Address: headset, Kind: DeviceKindHeadset, Connected: true})
+	d := newTestDaemon(t, Options{AdapterProvider: provider, DeviceBackend: backend, SleepMonitor: newFakeSleepMonitor()})
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	if err := d.reconcileDevices(ctx); err != nil {
+		t.Fatalf("reconcileDevices: %v", err)
+	}
+
+	d.prepareForSleep(ctx)
+	backend.setConnected(headset, false)
+	d.HoldDevice(headset)
+	d.resumeFromSleep(ctx)
+
+	if backend.attempts() != 0 {
+		t.Fatalf("expected held device to stay disconnected, got %v", backend.connects)
+	}
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import "sort"
+
+// Status is a point-in-time snapshot of the daemon's view of the system. It is
+// served to the CLI over the control socket.
+type Status struct {
+	ActiveAdapter *Adapter              `json:"active_adapter,omitempty"`
+	Devices       []DeviceStatus        `json:"devices"`
+	Limits        map[DeviceKind]int    `json:"limits,omitempty"`
+	Decisions     []ArbitrationDecision `json:"decisions"`
+}
+
+// DeviceStatus describes a single device in a Status snapshot.
+type DeviceStatus struct {
+	Address   string     `json:"address"`
+	Name      string     `json:"name,omitempty"`
+	Kind      DeviceKind `json:"kind"`
+	Priority  int        `json:"priority"`
+	Adapter   string     `json:"adapter,omitempty"`
+	Connected bool       `json:"connected"`
+	Held      bool       `json:"held"`
+	GaveUp    bool       `json:"gave_up"`
+	Attempts  int        `json:"attempts"`
+}
+
+// Status returns the current daemon snapshot.
+func (d *Daemon) Status() Status {
+	var status Status
+
+	if adapter, ok := d.ActiveAdapter(); ok {
+		status.ActiveAdapter = &adapter
+	}
+
+	d.devMu.Lock()
+	for _, dev := range d.knownDevices {
+		st := d.deviceState(dev.Address)
+		name := dev.Alias
+		if name == "" {
+			name = dev.Name
+		}
+
+		status.Devices = append(status.Devices, DeviceStatus{
+			Address:   dev.Address,
+			Name:      name,
+			Kind:      dev.Kind,
+			Priority:  d.priorityFor(dev.Address),
+			Adapter:   dev.Adapter,
+			Connected: dev.Connected,
+			Held:      st.held,
+			GaveUp:    st.gaveUp,
+			Attempts:  st.attempts,
+		})
+	}
+	d.devMu.Unlock()
+
+	sort.Slice(status.Devices, func(i, j int) bool {
+		return status.Devices[i].Address < status.Devices[j].Address
+	})
+
+	if limits := d.settings().limits; len(limits) > 0 {
+		status.Limits = make(map[DeviceKind]int, len(limits))
+		for kind, limit := range limits {
+			status.Limits[kind] = limit
+		}
+	}
+
+	d.decisionMu.Lock()
+	status.Decisions = append([]ArbitrationDecision(nil), d.decisions...)
+	d.decisionMu.Unlock()
+
+	return status
+}
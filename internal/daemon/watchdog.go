@@ -0,0 +1,364 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peared/peared/internal/recovery"
+)
+
+const (
+	defaultWatchdogInterval        = 30 * time.Second
+	defaultWatchdogProbeTimeout    = 5 * time.Second
+	defaultWatchdogThreshold       = 2
+	defaultWatchdogConnectTimeouts = 3
+	defaultWatchdogMaxRecoveries   = 3
+	defaultWatchdogWindow          = time.Hour
+)
+
+// Recoverer resets an adapter through an escalating sequence of levels.
+// *recovery.Resetter implements it.
+type Recoverer interface {
+	ResetLevels(ctx context.Context, target recovery.Target, levels []recovery.Level) (recovery.Result, error)
+}
+
+// WatchdogSettings configures the adapter health watchdog. The watchdog is
+// disabled while Recoverer is nil; zero values select the defaults noted on
+// each field.
+type WatchdogSettings struct {
+	// Recoverer performs the reset once the active adapter is judged
+	// unhealthy.
+	Recoverer Recoverer
+
+	// Interval is the time between probes. Defaults to 30s.
+	Interval time.Duration
+
+	// ProbeTimeout bounds the backend call used to check that BlueZ still
+	// answers for the adapter. Defaults to 5s.
+	ProbeTimeout time.Duration
+
+	// Threshold is how many consecutive failed probes trigger a recovery,
+	// so a single slow answer does not reset the controller. Defaults to 2.
+	Threshold int
+
+	// ConnectTimeouts is how many consecutive device connects through the
+	// adapter may time out before it is considered wedged. Defaults to 3.
+	ConnectTimeouts int
+
+	// Levels is the escalation sequence handed to the Recoverer. Defaults
+	// to soft followed by service.
+	Levels []recovery.Level
+
+	// MaxRecoveries caps how many recoveries may start within Window so a
+	// controller that never comes back is not reset in an endless loop.
+	// Defaults to 3 per hour.
+	MaxRecoveries int
+	Window        time.Duration
+
+	// SysfsPath locates adapters when building the reset target. Defaults to
+	// DefaultSysfsPath.
+	SysfsPath string
+}
+
+func (s WatchdogSettings) withDefaults() WatchdogSettings {
+	if s.Interval <= 0 {
+		s.Interval = defaultWatchdogInterval
+	}
+	if s.ProbeTimeout <= 0 {
+		s.ProbeTimeout = defaultWatchdogProbeTimeout
+	}
+	if s.Threshold <= 0 {
+		s.Threshold = defaultWatchdogThreshold
+	}
+	if s.ConnectTimeouts <= 0 {
+		s.ConnectTimeouts = defaultWatchdogConnectTimeouts
+	}
+	if len(s.Levels) == 0 {
+		s.Levels = []recovery.Level{recovery.LevelSoft, recovery.LevelService}
+	} else {
+		s.Levels = append([]recovery.Level(nil), s.Levels...)
+	}
+	if s.MaxRecoveries <= 0 {
+		s.MaxRecoveries = defaultWatchdogMaxRecoveries
+	}
+	if s.Window <= 0 {
+		s.Window = defaultWatchdogWindow
+	}
+	if s.SysfsPath == "" {
+		s.SysfsPath = DefaultSysfsPath()
+	}
+	return s
+}
+
+// watchdogState tracks probe failures and recent recoveries between ticks.
+type watchdogState struct {
+	mu              sync.Mutex
+	watched         *Adapter
+	failures        int
+	connectTimeouts map[string]int
+	recoveries      []time.Time
+	suppressed      bool
+}
+
+// RecoveryTarget describes adapter for the recovery package, resolving its
+// USB device under sysfsRoot and the kernel modules its driver needs.
+func RecoveryTarget(adapter Adapter, sysfsRoot string) recovery.Target {
+	target := recovery.Target{
+		Adapter: adapter.ID,
+		Address: adapter.Address,
+		Modules: recovery.DefaultModules(adapter.Driver, adapter.VendorID),
+	}
+
+	if adapter.Transport == AdapterTransportUSB {
+		if device, err := recovery.USBDevice(filepath.Join(sysfsRoot, adapter.ID)); err == nil {
+			target.USBDevice = device
+		}
+	}
+
+	return target
+}
+
+// AdapterHealthCheck considers a reset target healthy when it is present
+// (found by address, as resets may renumber it), powered, and BlueZ answers
+// for it.
+func AdapterHealthCheck(provider AdapterProvider, backend AdapterBackend) recovery.HealthCheck {
+	return func(ctx context.Context, target recovery.Target) error {
+		adapters, err := provider.ListAdapters(ctx)
+		if err != nil {
+			return err
+		}
+
+		identifier := target.Address
+		if identifier == "" {
+			identifier = target.Adapter
+		}
+
+		for _, adapter := range adapters {
+			if !adapter.Matches(identifier) {
+				continue
+			}
+			if !adapter.Powered {
+				return fmt.Errorf("adapter %s is not powered", adapter.ID)
+			}
+			if backend != nil {
+				if _, err := backend.DescribeAdapter(ctx, adapter); err != nil {
+					return fmt.Errorf("bluez does not answer for %s: %w", adapter.ID, err)
+				}
+			}
+			return nil
+		}
+
+		return fmt.Errorf("adapter %s is not present", identifier)
+	}
+}
+
+// watch probes the active adapter every watchdog interval until the context
+// is cancelled.
+func (d *Daemon) watch(ctx context.Context) {
+	ticker := time.NewTicker(d.watchdog.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		d.checkAdapterHealth(ctx)
+	}
+}
+
+// checkAdapterHealth runs one probe and starts a recovery once the adapter
+// has failed Threshold probes in a row. The watchdog keeps watching the last
+// active adapter after it vanishes, because a wedged controller often drops
+// off the bus and a module reload or USB rebind is what brings it back.
+func (d *Daemon) checkAdapterHealth(ctx context.Context) {
+	w := &d.watchdogState
+
+	w.mu.Lock()
+	if active, ok := d.ActiveAdapter(); ok {
+		if w.watched == nil || !w.watched.Matches(stableIdentifier(active)) {
+			w.failures = 0
+		}
+		w.watched = &active
+	}
+	if w.watched == nil {
+		w.mu.Unlock()
+		return
+	}
+	watched := *w.watched
+	w.mu.Unlock()
+
+	problem := d.probeAdapter(ctx, watched)
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	if problem == nil {
+		w.failures = 0
+		w.mu.Unlock()
+		return
+	}
+	w.failures++
+	failures := w.failures
+	w.mu.Unlock()
+
+	d.log.Warn("adapter health probe failed", "adapter", watched.ID, "failures", failures, "error", problem)
+	if failures < d.watchdog.Threshold {
+		return
+	}
+
+	d.emit(Event{Type: EventAdapterUnhealthy, Adapter: watched.ID, Message: fmt.Sprintf("adapter %s is unhealthy: %v", watched.ID, problem)})
+	d.recoverAdapter(ctx, watched)
+}
+
+// probeAdapter returns why adapter looks unhealthy, or nil when every probe
+// passes: it must still be present in sysfs, powered when configuration asks
+// for it, answering backend calls, and not timing out every connection.
+func (d *Daemon) probeAdapter(ctx context.Context, adapter Adapter) error {
+	adapters, err := d.adapterProv.ListAdapters(ctx)
+	if err != nil {
+		// A sysfs read failure says nothing about the controller itself.
+		d.log.Debug("adapter health probe skipped", "error", err)
+		return nil
+	}
+
+	identifier := stableIdentifier(adapter)
+
+	var current *Adapter
+	for i := range adapters {
+		if adapters[i].Matches(identifier) {
+			current = &adapters[i]
+			break
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("%s is no longer present", adapter.ID)
+	}
+
+	// Blocked radios are unpowered on purpose, and resets cannot lift a
+	// hard block anyway.
+	if current.SoftBlocked || current.HardBlocked {
+		return nil
+	}
+
+	if settings, ok := d.adapterSettingsFor(*current); ok && settings.Powered != nil && *settings.Powered && !current.Powered {
+		return errors.New("adapter is configured to be powered but is off")
+	}
+
+	if d.adapterBackend != nil {
+		probeCtx, cancel := context.WithTimeout(ctx, d.watchdog.ProbeTimeout)
+		_, err := d.adapterBackend.DescribeAdapter(probeCtx, *current)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("bluez does not answer: %w", err)
+		}
+	}
+
+	d.watchdogState.mu.Lock()
+	timeouts := d.watchdogState.connectTimeouts[current.ID]
+	d.watchdogState.mu.Unlock()
+	if timeouts >= d.watchdog.ConnectTimeouts {
+		return fmt.Errorf("%d consecutive connection attempts timed out", timeouts)
+	}
+
+	return nil
+}
+
+// recoverAdapter runs the configured reset sequence unless MaxRecoveries
+// have already started within the rate-limit window.
+func (d *Daemon) recoverAdapter(ctx context.Context, adapter Adapter) {
+	w := &d.watchdogState
+	now := d.now()
+
+	w.mu.Lock()
+	recent := w.recoveries[:0]
+	for _, started := range w.recoveries {
+		if now.Sub(started) < d.watchdog.Window {
+			recent = append(recent, started)
+		}
+	}
+	w.recoveries = recent
+
+	if len(w.recoveries) >= d.watchdog.MaxRecoveries {
+		notify := !w.suppressed
+		w.suppressed = true
+		w.mu.Unlock()
+
+		if notify {
+			d.emit(Event{Type: EventRecoverySuppressed, Adapter: adapter.ID, Message: fmt.Sprintf("not resetting %s again: %d recoveries within %s", adapter.ID, len(recent), d.watchdog.Window)})
+		}
+		return
+	}
+
+	w.recoveries = append(w.recoveries, now)
+	w.suppressed = false
+	w.mu.Unlock()
+
+	target := RecoveryTarget(adapter, d.watchdog.SysfsPath)
+	result, err := d.watchdog.Recoverer.ResetLevels(ctx, target, d.watchdog.Levels)
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	w.failures = 0
+	delete(w.connectTimeouts, adapter.ID)
+	w.mu.Unlock()
+
+	if err != nil {
+		d.emit(Event{Type: EventRecoveryFailed, Adapter: adapter.ID, Message: fmt.Sprintf("reset of %s failed after %d steps: %v", adapter.ID, len(result.Steps), err)})
+		return
+	}
+
+	d.emit(Event{Type: EventAdapterRecovered, Adapter: adapter.ID, Message: fmt.Sprintf("adapter %s recovered after the %s level", adapter.ID, result.Level)})
+}
+
+// stableIdentifier prefers the adapter address, which survives the
+// renumbering a reset may cause, over its ID.
+func stableIdentifier(adapter Adapter) string {
+	if adapter.Address != "" {
+		return adapter.Address
+	}
+	return adapter.ID
+}
+
+// recordConnectResult counts consecutive connection timeouts per adapter for
+// the watchdog. Other failures say more about the device than the adapter,
+// so they leave the count untouched.
+func (d *Daemon) recordConnectResult(adapter string, err error) {
+	if d.watchdog.Recoverer == nil {
+		return
+	}
+
+	w := &d.watchdogState
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch {
+	case err == nil:
+		delete(w.connectTimeouts, adapter)
+	case isTimeout(err):
+		if w.connectTimeouts == nil {
+			w.connectTimeouts = make(map[string]int)
+		}
+		w.connectTimeouts[adapter]++
+	}
+}
+
+// isTimeout recognises deadline errors and the page or connection timeouts
+// BlueZ reports through bluetoothctl.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "timeout") || strings.Contains(message, "timed out")
+}
@@ -0,0 +1,251 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/recovery"
+)
+
+// fakeRecoverer records reset requests and reports a fixed outcome.
+type fakeRecoverer struct {
+	targets []recovery.Target
+	levels  [][]recovery.Level
+	err     error
+}
+
+func (r *fakeRecoverer) ResetLevels(_ context.Context, target recovery.Target, levels []recovery.Level) (recovery.Result, error) {
+	r.targets = append(r.targets, target)
+	r.levels = append(r.levels, levels)
+	if r.err != nil {
+		return recovery.Result{Steps: []recovery.Step{{Level: levels[0], Attempt: 1, Error: r.err.Error()}}}, r.err
+	}
+	return recovery.Result{Recovered: true, Level: levels[0], Steps: []recovery.Step{{Level: levels[0], Attempt: 1, Healthy: true}}}, nil
+}
+
+// unresponsiveBackend fails DescribeAdapter while down is set.
+type unresponsiveBackend struct {
+	recordingAdapterBackend
+	down bool
+}
+
+func (b *unresponsiveBackend) DescribeAdapter(_ context.Context, adapter Adapter) (Adapter, error) {
+	if b.down {
+		return adapter, errors.New("org.bluez.Error.NotReady")
+	}
+	return adapter, nil
+}
+
+// watchdogOptions enables the watchdog with a soft reset followed by a module
+// reload and room for two recoveries an hour.
+func watchdogOptions(t *testing.T, provider AdapterProvider, backend AdapterBackend, recoverer Recoverer, clock *fakeClock) Options {
+	return Options{
+		AdapterProvider: provider,
+		AdapterBackend:  backend,
+		Clock:           clock.Now,
+		Watchdog: WatchdogSettings{
+			Recoverer:     recoverer,
+			Levels:        []recovery.Level{recovery.LevelSoft, recovery.LevelModule},
+			MaxRecoveries: 2,
+			Window:        time.Hour,
+			SysfsPath:     t.TempDir(),
+		},
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, 0, len(events))
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func TestWatchdogRecoversUnresponsiveAdapter(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	provider := &adapterSet{}
+	provider.set(dongle)
+	backend := &unresponsiveBackend{down: true}
+	recoverer := &fakeRecoverer{}
+	d := newTestDaemon(t, watchdogOptions(t, provider, backend, recoverer, clock))
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	// A single failed probe is tolerated.
+	d.checkAdapterHealth(ctx)
+	if len(recoverer.targets) != 0 {
+		t.Fatalf("expected no recovery after one failed probe")
+	}
+
+	d.checkAdapterHealth(ctx)
+	if len(recoverer.targets) != 1 {
+		t.Fatalf("expected one recovery, got %d", len(recoverer.targets))
+	}
+	if target := recoverer.targets[0]; target.Adapter != dongle.ID || target.Address != dongle.Address {
+		t.Fatalf("unexpected target: %+v", target)
+	}
+	if levels := recoverer.levels[0]; len(levels) != 2 || levels[1] != recovery.LevelModule {
+		t.Fatalf("unexpected levels: %v", levels)
+	}
+
+	got := eventTypes(drainEvents(events))
+	if len(got) != 2 || got[0] != EventAdapterUnhealthy || got[1] != EventAdapterRecovered {
+		t.Fatalf("unexpected events: %v", got)
+	}
+
+	// The failure count restarts after a recovery.
+	d.checkAdapterHealth(ctx)
+	if len(recoverer.targets) != 1 {
+		t.Fatalf("expected the threshold to apply again after recovery")
+	}
+}
+
+func TestWatchdogRateLimitsRecoveries(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	provider := &adapterSet{}
+	provider.set(dongle)
+	recoverer := &fakeRecoverer{err: recovery.ErrNotRecovered}
+	d := newTestDaemon(t, watchdogOptions(t, provider, &unresponsiveBackend{down: true}, recoverer, clock))
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	events, cancel := d.Subscribe()
+	defer cancel()
+
+	for i := 0; i < 8; i++ {
+		d.checkAdapterHealth(ctx)
+		clock.Advance(time.Minute)
+	}
+	if len(recoverer.targets) != 2 {
+		t.Fatalf("expected recoveries capped at 2, got %d", len(recoverer.targets))
+	}
+
+	suppressed := 0
+	for _, ev := range drainEvents(events) {
+		if ev.Type == EventRecoverySuppressed {
+			suppressed++
+		}
+	}
+	if suppressed != 1 {
+		t.Fatalf("expected a single suppression event, got %d", suppressed)
+	}
+
+	// Once the window has passed the watchdog may try again.
+	clock.Advance(time.Hour)
+	d.checkAdapterHealth(ctx)
+	d.checkAdapterHealth(ctx)
+	if len(recoverer.targets) != 3 {
+		t.Fatalf("expected a recovery after the window, got %d", len(recoverer.targets))
+	}
+}
+
+func TestWatchdogKeepsWatchingVanishedAdapter(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	provider := &adapterSet{}
+	provider.set(dongle)
+	recoverer := &fakeRecoverer{}
+	d := newTestDaemon(t, watchdogOptions(t, provider, &unresponsiveBackend{}, recoverer, clock))
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+	d.checkAdapterHealth(ctx)
+
+	provider.set()
+	if err := d.refreshAdapters(ctx); !errors.Is(err, errNoAdapters) {
+		t.Fatalf("expected errNoAdapters, got %v", err)
+	}
+
+	d.checkAdapterHealth(ctx)
+	d.checkAdapterHealth(ctx)
+	if len(recoverer.targets) != 1 || recoverer.targets[0].Address != dongle.Address {
+		t.Fatalf("expected the vanished adapter to be reset, got %+v", recoverer.targets)
+	}
+}
+
+func TestWatchdogCountsConnectTimeouts(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)}
+	provider := &adapterSet{}
+	provider.set(dongle)
+	recoverer := &fakeRecoverer{}
+	d := newTestDaemon(t, watchdogOptions(t, provider, &unresponsiveBackend{}, recoverer, clock))
+
+	if err := d.refreshAdapters(ctx); err != nil {
+		t.Fatalf("refreshAdapters: %v", err)
+	}
+
+	d.recordConnectResult(dongle.ID, errPageTimeout)
+	d.recordConnectResult(dongle.ID, errors.New("org.bluez.Error.AlreadyConnected"))
+	d.recordConnectResult(dongle.ID, errPageTimeout)
+	if err := d.probeAdapter(ctx, dongle); err != nil {
+		t.Fatalf("expected healthy adapter below the timeout limit, got %v", err)
+	}
+
+	d.recordConnectResult(dongle.ID, context.DeadlineExceeded)
+	if err := d.probeAdapter(ctx, dongle); err == nil {
+		t.Fatal("expected repeated timeouts to mark the adapter unhealthy")
+	}
+
+	d.recordConnectResult(dongle.ID, nil)
+	if err := d.probeAdapter(ctx, dongle); err != nil {
+		t.Fatalf("expected a successful connect to clear timeouts, got %v", err)
+	}
+}
+
+func TestWatchdogRequiresConfiguredPower(t *testing.T) {
+	ctx := context.Background()
+	provider := &adapterSet{}
+	unpowered := dongle
+	unpowered.Powered = false
+	provider.set(unpowered)
+
+	d := newTestDaemon(t, watchdogOptions(t, provider, &unresponsiveBackend{}, &fakeRecoverer{}, &fakeClock{}))
+	if err := d.probeAdapter(ctx, unpowered); err != nil {
+		t.Fatalf("expected an unpowered adapter without settings to be healthy, got %v", err)
+	}
+
+	on := true
+	d.Reload(Settings{AdapterSettings: map[string]AdapterSettings{dongle.ID: {Powered: &on}}})
+	if err := d.probeAdapter(ctx, unpowered); err == nil {
+		t.Fatal("expected an adapter configured as powered to be unhealthy while off")
+	}
+
+	unpowered.SoftBlocked = true
+	provider.set(unpowered)
+	if err := d.probeAdapter(ctx, unpowered); err != nil {
+		t.Fatalf("expected a blocked adapter to be left alone, got %v", err)
+	}
+}
+
+func TestAdapterHealthCheck(t *testing.T) {
+	adapters := []Adapter{{ID: "hci1", Address: "AA:BB:CC:DD:EE:FF", Powered: true}}
+	provider := AdapterProviderFunc(func(context.Context) ([]Adapter, error) { return adapters, nil })
+
+	check := AdapterHealthCheck(provider, &unresponsiveBackend{})
+	ctx := context.Background()
+
+	// The adapter was renumbered from hci0 to hci1 but is found by address.
+	if err := check(ctx, recovery.Target{Adapter: "hci0", Address: "aa:bb:cc:dd:ee:ff"}); err != nil {
+		t.Fatalf("expected healthy adapter, got %v", err)
+	}
+
+	adapters[0].Powered = false
+	if err := check(ctx, recovery.Target{Address: "AA:BB:CC:DD:EE:FF"}); err == nil {
+		t.Fatal("expected unpowered adapter to be unhealthy")
+	}
+
+	if err := check(ctx, recovery.Target{Address: "11:22:33:44:55:66"}); err == nil {
+		t.Fatal("expected missing adapter to be unhealthy")
+	}
+}
@@ -0,0 +1,617 @@
+// Package dbus is a minimal D-Bus client. It implements just enough of the
+// wire protocol for pearedd to talk to system services such as logind: method
+// calls, signals, exporting simple methods and passing Unix file descriptors.
+// Only basic types, string arrays and string-keyed variant dictionaries are
+// supported.
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	busName      = "org.freedesktop.DBus"
+	busPath      = ObjectPath("/org/freedesktop/DBus")
+	busInterface = "org.freedesktop.DBus"
+
+	defaultSystemBusAddress = "unix:path=/run/dbus/system_bus_socket"
+
+	// maxFDsPerRead bounds the control message buffer for received files.
+	maxFDsPerRead = 16
+
+	signalBufferSize = 64
+)
+
+// ErrClosed is returned for calls on a closed connection.
+var ErrClosed = errors.New("dbus: connection closed")
+
+// Conn is a connection to a message bus.
+type Conn struct {
+	sock *net.UnixConn
+	in   *bufio.Reader
+	fds  *fdReader
+	name string
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	serial  uint32
+	pending map[uint32]chan *Message
+	subs    map[int]chan *Message
+	nextSub int
+	handler func(*Message) ([]any, error)
+	err     error
+
+	done chan struct{}
+}
+
+// SystemBusAddress returns the system bus address, honouring
+// DBUS_SYSTEM_BUS_ADDRESS.
+func SystemBusAddress() string {
+	if address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); address != "" {
+		return address
+	}
+	return defaultSystemBusAddress
+}
+
+// SessionBusAddress returns the session bus address from
+// DBUS_SESSION_BUS_ADDRESS, falling back to the socket systemd creates in
+// $XDG_RUNTIME_DIR.
+func SessionBusAddress() (string, error) {
+	if address := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); address != "" {
+		return address, nil
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return "unix:path=" + runtimeDir + "/bus", nil
+	}
+	return "", errors.New("dbus: no session bus address (DBUS_SESSION_BUS_ADDRESS and XDG_RUNTIME_DIR are unset)")
+}
+
+// SystemBus connects to the system message bus.
+func SystemBus() (*Conn, error) {
+	return Dial(SystemBusAddress())
+}
+
+// Dial connects to the bus at address, authenticates and registers with it.
+// Only unix:path= and unix:abstract= addresses are supported; alternatives
+// separated by semicolons are tried in order.
+func Dial(address string) (*Conn, error) {
+	var errs []error
+	for _, candidate := range strings.Split(address, ";") {
+		if strings.TrimSpace(candidate) == "" {
+			continue
+		}
+		socket, err := parseAddress(candidate)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		raw, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socket, Net: "unix"})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dbus: connect %s: %w", candidate, err))
+			continue
+		}
+		conn, err := newConn(raw)
+		if err != nil {
+			raw.Close()
+			errs = append(errs, err)
+			continue
+		}
+		return conn, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("dbus: no usable address in %q", address)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// parseAddress resolves a single unix transport address to a socket name.
+// Abstract sockets are returned with a leading NUL.
+func parseAddress(address string) (string, error) {
+	transport, params, ok := strings.Cut(strings.TrimSpace(address), ":")
+	if !ok || transport != "unix" {
+		return "", fmt.Errorf("dbus: unsupported address %q", address)
+	}
+
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(param, "=")
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			return "", fmt.Errorf("dbus: invalid address %q: %w", address, err)
+		}
+		switch key {
+		case "path":
+			return unescaped, nil
+		case "abstract":
+			return "\x00" + unescaped, nil
+		}
+	}
+
+	return "", fmt.Errorf("dbus: address %q has no path", address)
+}
+
+func newConn(sock *net.UnixConn) (*Conn, error) {
+	fds := &fdReader{conn: sock}
+	c := &Conn{
+		sock:    sock,
+		fds:     fds,
+		in:      bufio.NewReader(fds),
+		pending: make(map[uint32]chan *Message),
+		subs:    make(map[int]chan *Message),
+		done:    make(chan struct{}),
+	}
+
+	if err := c.authenticate(); err != nil {
+		return nil, err
+	}
+
+	go c.readLoop()
+
+	body, err := c.Call(context.Background(), busName, busPath, busInterface, "Hello")
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("dbus: hello: %w", err)
+	}
+	if len(body) == 1 {
+		c.name, _ = body[0].(string)
+	}
+
+	return c, nil
+}
+
+// authenticate runs the SASL EXTERNAL handshake and negotiates file
+// descriptor passing.
+func (c *Conn) authenticate() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.sock.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return fmt.Errorf("dbus: authenticate: %w", err)
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("dbus: authenticate: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus: authentication rejected: %s", line)
+	}
+
+	if _, err := c.sock.Write([]byte("NEGOTIATE_UNIX_FD\r\n")); err != nil {
+		return fmt.Errorf("dbus: authenticate: %w", err)
+	}
+	line, err = c.readLine()
+	if err != nil {
+		return fmt.Errorf("dbus: authenticate: %w", err)
+	}
+	if line != "AGREE_UNIX_FD" {
+		return fmt.Errorf("dbus: bus refused file descriptor passing: %s", line)
+	}
+
+	if _, err := c.sock.Write([]byte("BEGIN\r\n")); err != nil {
+		return fmt.Errorf("dbus: authenticate: %w", err)
+	}
+	return nil
+}
+
+func (c *Conn) readLine() (string, error) {
+	line, err := c.in.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// UniqueName returns the name the bus assigned to this connection.
+func (c *Conn) UniqueName() string {
+	return c.name
+}
+
+// Close closes the connection. Pending calls fail with ErrClosed.
+func (c *Conn) Close() error {
+	err := c.sock.Close()
+	<-c.done
+	return err
+}
+
+// Done is closed once the connection has shut down.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Call invokes a method and waits for its reply body.
+func (c *Conn) Call(ctx context.Context, dest string, path ObjectPath, iface, member string, args ...any) ([]any, error) {
+	reply := make(chan *Message, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	serial := c.nextSerial()
+	c.pending[serial] = reply
+	c.mu.Unlock()
+
+	err := c.send(&Message{
+		Type:        TypeMethodCall,
+		Serial:      serial,
+		Destination: dest,
+		Path:        path,
+		Interface:   iface,
+		Member:      member,
+		Body:        args,
+	})
+	if err != nil {
+		c.forget(serial)
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.forget(serial)
+		return nil, ctx.Err()
+	case msg, ok := <-reply:
+		if !ok {
+			return nil, c.closedErr()
+		}
+		if msg.Type == TypeError {
+			e := &Error{Name: msg.ErrorName}
+			if len(msg.Body) > 0 {
+				e.Message, _ = msg.Body[0].(string)
+			}
+			return nil, e
+		}
+		if msg.bodyErr != nil {
+			return nil, msg.bodyErr
+		}
+		return msg.Body, nil
+	}
+}
+
+// Emit broadcasts a signal.
+func (c *Conn) Emit(path ObjectPath, iface, member string, args ...any) error {
+	c.mu.Lock()
+	serial := c.nextSerial()
+	c.mu.Unlock()
+
+	return c.send(&Message{
+		Type:      TypeSignal,
+		Serial:    serial,
+		Path:      path,
+		Interface: iface,
+		Member:    member,
+		Body:      args,
+	})
+}
+
+// AddMatch asks the bus to route signals matching rule to this connection.
+func (c *Conn) AddMatch(ctx context.Context, rule string) error {
+	_, err := c.Call(ctx, busName, busPath, busInterface, "AddMatch", rule)
+	return err
+}
+
+// RequestName claims a well-known name, failing if another connection
+// already owns it.
+func (c *Conn) RequestName(ctx context.Context, name string) error {
+	const doNotQueue = uint32(4)
+	const primaryOwner = uint32(1)
+
+	body, err := c.Call(ctx, busName, busPath, busInterface, "RequestName", name, doNotQueue)
+	if err != nil {
+		return err
+	}
+	if len(body) != 1 || body[0] != primaryOwner {
+		return fmt.Errorf("dbus: name %s is already owned", name)
+	}
+	return nil
+}
+
+// Signals subscribes to the signals routed to this connection. Signals are
+// dropped for subscribers that fall too far behind. The returned function
+// cancels the subscription.
+func (c *Conn) Signals() (<-chan *Message, func()) {
+	ch := make(chan *Message, signalBufferSize)
+
+	c.mu.Lock()
+	id := c.nextSub
+	c.nextSub++
+	if c.err == nil {
+		c.subs[id] = ch
+	} else {
+		close(ch)
+	}
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if sub, ok := c.subs[id]; ok {
+			delete(c.subs, id)
+			close(sub)
+		}
+	}
+}
+
+// HandleCalls installs fn to answer incoming method calls. The values it
+// returns form the reply body; an *Error becomes an error reply with that
+// name. Calls are rejected while no handler is installed. Files in the
+// reply stay owned by the handler: the connection sends duplicates of
+// their descriptors, so the handler may close them once fn returns.
+func (c *Conn) HandleCalls(fn func(call *Message) ([]any, error)) {
+	c.mu.Lock()
+	c.handler = fn
+	c.mu.Unlock()
+}
+
+func (c *Conn) nextSerial() uint32 {
+	c.serial++
+	if c.serial == 0 {
+		c.serial++
+	}
+	return c.serial
+}
+
+func (c *Conn) forget(serial uint32) {
+	c.mu.Lock()
+	delete(c.pending, serial)
+	c.mu.Unlock()
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return ErrClosed
+}
+
+func (c *Conn) send(m *Message) error {
+	data, files, err := m.encode(binary.LittleEndian)
+	if err != nil {
+		return err
+	}
+
+	var oob []byte
+	if len(files) > 0 {
+		fds := make([]int, 0, len(files))
+		defer func() {
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+		}()
+		for _, file := range files {
+			fd, err := dupFile(file)
+			if err != nil {
+				return fmt.Errorf("dbus: send: %w", err)
+			}
+			fds = append(fds, fd)
+		}
+		oob = syscall.UnixRights(fds...)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	n, _, err := c.sock.WriteMsgUnix(data, oob, nil)
+	if err != nil {
+		return fmt.Errorf("dbus: send: %w", err)
+	}
+	if n < len(data) {
+		if _, err := c.sock.Write(data[n:]); err != nil {
+			return fmt.Errorf("dbus: send: %w", err)
+		}
+	}
+	return nil
+}
+
+// dupFile duplicates the descriptor of file for sending. Unlike Fd it
+// neither races with a concurrent Close nor switches the file to blocking
+// mode, and the copy stays valid however long the write takes.
+func dupFile(file *os.File) (int, error) {
+	raw, err := file.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+
+	fd := -1
+	var dupErr error
+	err = raw.Control(func(orig uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+		if fd, dupErr = syscall.Dup(int(orig)); dupErr == nil {
+			syscall.CloseOnExec(fd)
+		}
+	})
+	if err != nil {
+		return -1, err
+	}
+	return fd, dupErr
+}
+
+func (c *Conn) readLoop() {
+	var err error
+	for {
+		var msg *Message
+		msg, err = c.readMessage()
+		if err != nil {
+			break
+		}
+		c.dispatch(msg)
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		err = ErrClosed
+	}
+
+	c.mu.Lock()
+	c.err = err
+	for serial, ch := range c.pending {
+		close(ch)
+		delete(c.pending, serial)
+	}
+	for id, ch := range c.subs {
+		close(ch)
+		delete(c.subs, id)
+	}
+	c.mu.Unlock()
+
+	c.fds.closeAll()
+	close(c.done)
+}
+
+func (c *Conn) readMessage() (*Message, error) {
+	fixed := make([]byte, fixedHeaderSize)
+	if _, err := io.ReadFull(c.in, fixed); err != nil {
+		return nil, err
+	}
+
+	total, _, err := messageLength(fixed)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, total)
+	copy(data, fixed)
+	if _, err := io.ReadFull(c.in, data[fixedHeaderSize:]); err != nil {
+		return nil, err
+	}
+
+	return decodeMessage(data, c.fds.take)
+}
+
+func (c *Conn) dispatch(msg *Message) {
+	switch msg.Type {
+	case TypeMethodReturn, TypeError:
+		c.mu.Lock()
+		ch, ok := c.pending[msg.ReplySerial]
+		delete(c.pending, msg.ReplySerial)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+
+	case TypeSignal:
+		if msg.bodyErr != nil {
+			return
+		}
+		c.mu.Lock()
+		for _, ch := range c.subs {
+			select {
+			case ch <- msg:
+			default:
+			}
+		}
+		c.mu.Unlock()
+
+	case TypeMethodCall:
+		c.mu.Lock()
+		handler := c.handler
+		c.mu.Unlock()
+		go c.answer(msg, handler)
+	}
+}
+
+// answer runs handler for an incoming call and sends the reply.
+func (c *Conn) answer(call *Message, handler func(*Message) ([]any, error)) {
+	var body []any
+	var err error
+	switch {
+	case call.bodyErr != nil:
+		err = &Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Message: call.bodyErr.Error()}
+	case handler == nil:
+		err = &Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: "no methods are exported"}
+	default:
+		body, err = handler(call)
+	}
+
+	if call.Flags&flagNoReplyExpected != 0 {
+		return
+	}
+
+	c.mu.Lock()
+	serial := c.nextSerial()
+	c.mu.Unlock()
+
+	reply := &Message{Type: TypeMethodReturn, Serial: serial, ReplySerial: call.Serial, Destination: call.Sender, Body: body}
+	if err != nil {
+		var dbusErr *Error
+		if !errors.As(err, &dbusErr) {
+			dbusErr = &Error{Name: "org.freedesktop.DBus.Error.Failed", Message: err.Error()}
+		}
+		reply = &Message{Type: TypeError, Serial: serial, ReplySerial: call.Serial, Destination: call.Sender, ErrorName: dbusErr.Name, Body: []any{dbusErr.Message}}
+	}
+
+	_ = c.send(reply)
+}
+
+// fdReader reads from the socket while collecting file descriptors passed
+// alongside the data. Messages claim them in the order they arrived.
+type fdReader struct {
+	conn *net.UnixConn
+
+	mu    sync.Mutex
+	files []*os.File
+}
+
+func (r *fdReader) Read(p []byte) (int, error) {
+	oob := make([]byte, syscall.CmsgSpace(maxFDsPerRead*4))
+	n, oobn, _, _, err := r.conn.ReadMsgUnix(p, oob)
+	if oobn > 0 {
+		r.collect(oob[:oobn])
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+func (r *fdReader) collect(oob []byte) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range messages {
+		fds, err := syscall.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			r.files = append(r.files, os.NewFile(uintptr(fd), "dbus-fd"))
+		}
+	}
+}
+
+func (r *fdReader) take(n int) ([]*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n > len(r.files) {
+		return nil, fmt.Errorf("dbus: message announced %d file descriptors but %d arrived", n, len(r.files))
+	}
+	files := r.files[:n:n]
+	r.files = r.files[n:]
+	return files, nil
+}
+
+func (r *fdReader) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, file := range r.files {
+		file.Close()
+	}
+	r.files = nil
+}
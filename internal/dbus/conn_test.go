@@ -0,0 +1,127 @@
+package dbus_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+	"github.com/peared/peared/internal/dbus/dbustest"
+)
+
+func dial(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCallsSignalsAndFiles(t *testing.T) {
+	address := dbustest.StartBus(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	service := dial(t, address)
+	client := dial(t, address)
+
+	if service.UniqueName() == "" || service.UniqueName() == client.UniqueName() {
+		t.Fatalf("unexpected unique names %q and %q", service.UniqueName(), client.UniqueName())
+	}
+
+	if err := service.RequestName(ctx, "org.example.Test"); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+	if err := client.RequestName(ctx, "org.example.Test"); err == nil {
+		t.Fatal("expected second RequestName to fail")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer r.Close()
+
+	service.HandleCalls(func(call *dbus.Message) ([]any, error) {
+		switch call.Member {
+		case "Echo":
+			return call.Body, nil
+		case "Open":
+			return []any{w}, nil
+		default:
+			return nil, &dbus.Error{Name: "org.example.Error.Unknown", Message: call.Member}
+		}
+	})
+
+	body, err := client.Call(ctx, "org.example.Test", "/org/example", "org.example.Test", "Echo", "hello", true, uint32(7))
+	if err != nil {
+		t.Fatalf("Echo: %v", err)
+	}
+	if len(body) != 3 || body[0] != "hello" || body[1] != true || body[2] != uint32(7) {
+		t.Fatalf("unexpected echo: %v", body)
+	}
+
+	_, err = client.Call(ctx, "org.example.Test", "/org/example", "org.example.Test", "Nope")
+	var dbusErr *dbus.Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.example.Error.Unknown" {
+		t.Fatalf("expected D-Bus error, got %v", err)
+	}
+
+	body, err = client.Call(ctx, "org.example.Test", "/org/example", "org.example.Test", "Open")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	file, ok := body[0].(*os.File)
+	if !ok {
+		t.Fatalf("expected a file, got %#v", body)
+	}
+
+	// The received descriptor is the write end of the pipe: closing both
+	// copies ends the stream, which is how logind notices a released lock.
+	file.Write([]byte("x"))
+	file.Close()
+	w.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "x" {
+		t.Fatalf("unexpected pipe data %q", data)
+	}
+
+	signals, stop := client.Signals()
+	defer stop()
+	if err := client.AddMatch(ctx, "type='signal',interface='org.example.Test'"); err != nil {
+		t.Fatalf("AddMatch: %v", err)
+	}
+	if err := service.Emit("/org/example", "org.example.Test", "Changed", false); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Member != "Changed" {
+				continue
+			}
+			if len(sig.Body) != 1 || sig.Body[0] != false || sig.Sender != service.UniqueName() {
+				t.Fatalf("unexpected signal: %+v", sig)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for signal")
+		}
+	}
+}
+
+func TestCloseFailsPendingCalls(t *testing.T) {
+	address := dbustest.StartBus(t)
+	conn := dial(t, address)
+
+	conn.Close()
+	if _, err := conn.Call(context.Background(), "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetId"); !errors.Is(err, dbus.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
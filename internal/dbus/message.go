@@ -0,0 +1,643 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MessageType is the kind of a D-Bus message.
+type MessageType byte
+
+const (
+	TypeMethodCall   MessageType = 1
+	TypeMethodReturn MessageType = 2
+	TypeError        MessageType = 3
+	TypeSignal       MessageType = 4
+)
+
+const (
+	flagNoReplyExpected byte = 0x1
+
+	protocolVersion = 1
+
+	// maxMessageSize is the limit the specification places on a message.
+	maxMessageSize = 128 << 20
+)
+
+// Header field codes.
+const (
+	fieldPath        byte = 1
+	fieldInterface   byte = 2
+	fieldMember      byte = 3
+	fieldErrorName   byte = 4
+	fieldReplySerial byte = 5
+	fieldDestination byte = 6
+	fieldSender      byte = 7
+	fieldSignature   byte = 8
+	fieldUnixFDs     byte = 9
+)
+
+// ObjectPath is a D-Bus object path such as /org/freedesktop/login1.
+type ObjectPath string
+
+// signature is a D-Bus type signature. It is only used in headers and
+// variants; bodies derive their signature from the Go values.
+type signature string
+
+// Message is a single D-Bus message. Body values map to D-Bus types as
+// follows: byte (y), bool (b), uint16 (q), int32 (i), uint32 (u), string
+// (s), ObjectPath (o), *os.File (h), []string (as) and map[string]any
+// (a{sv}). Other containers are not supported.
+type Message struct {
+	Type        MessageType
+	Flags       byte
+	Serial      uint32
+	ReplySerial uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	Destination string
+	Sender      string
+	Body        []any
+
+	// bodyErr records why Body could not be decoded. The header is still
+	// valid, so the connection survives messages with unsupported types.
+	bodyErr error
+}
+
+// Error is a D-Bus error reply.
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// bodySignature derives the signature of body.
+func bodySignature(body []any) (signature, error) {
+	var sig []byte
+	for _, value := range body {
+		code, err := typeSignature(value)
+		if err != nil {
+			return "", err
+		}
+		sig = append(sig, code...)
+	}
+	return signature(sig), nil
+}
+
+func typeSignature(value any) (string, error) {
+	switch value.(type) {
+	case byte:
+		return "y", nil
+	case bool:
+		return "b", nil
+	case uint16:
+		return "q", nil
+	case int32:
+		return "i", nil
+	case uint32:
+		return "u", nil
+	case string:
+		return "s", nil
+	case ObjectPath:
+		return "o", nil
+	case signature:
+		return "g", nil
+	case *os.File:
+		return "h", nil
+	case []string:
+		return "as", nil
+	case map[string]any:
+		return "a{sv}", nil
+	default:
+		return "", fmt.Errorf("dbus: unsupported value of type %T", value)
+	}
+}
+
+// encoder appends D-Bus wire data to buf. Alignment is relative to the start
+// of buf, so header and body are encoded with separate encoders.
+type encoder struct {
+	buf   []byte
+	order binary.ByteOrder
+	files []*os.File
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	var b [4]byte
+	e.order.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.align(2)
+	var b [2]byte
+	e.order.PutUint16(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) signature(s signature) {
+	e.buf = append(e.buf, byte(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) value(value any) error {
+	switch v := value.(type) {
+	case byte:
+		e.buf = append(e.buf, v)
+	case bool:
+		if v {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case uint16:
+		e.uint16(v)
+	case int32:
+		e.uint32(uint32(v))
+	case uint32:
+		e.uint32(v)
+	case string:
+		e.string(v)
+	case ObjectPath:
+		e.string(string(v))
+	case signature:
+		e.signature(v)
+	case *os.File:
+		e.uint32(uint32(len(e.files)))
+		e.files = append(e.files, v)
+	case []string:
+		return e.array(4, func() error {
+			for _, s := range v {
+				e.string(s)
+			}
+			return nil
+		})
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return e.array(8, func() error {
+			for _, key := range keys {
+				e.align(8)
+				e.string(key)
+				if err := e.variant(v[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("dbus: unsupported value of type %T", value)
+	}
+	return nil
+}
+
+// array writes an array whose elements have the given alignment. The length
+// excludes the padding before the first element, even when it is empty.
+func (e *encoder) array(alignment int, elements func() error) error {
+	e.uint32(0)
+	lengthAt := len(e.buf) - 4
+	e.align(alignment)
+	start := len(e.buf)
+	if err := elements(); err != nil {
+		return err
+	}
+	e.order.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-start))
+	return nil
+}
+
+func (e *encoder) variant(value any) error {
+	sig, err := typeSignature(value)
+	if err != nil {
+		return err
+	}
+	e.signature(signature(sig))
+	return e.value(value)
+}
+
+// encode serialises m, returning the wire bytes and the files to pass
+// alongside them.
+func (m *Message) encode(order binary.ByteOrder) ([]byte, []*os.File, error) {
+	body := &encoder{order: order}
+	for _, value := range m.Body {
+		if err := body.value(value); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sig, err := bodySignature(m.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type field struct {
+		code  byte
+		value any
+	}
+	var fields []field
+	if m.Path != "" {
+		fields = append(fields, field{fieldPath, m.Path})
+	}
+	if m.Interface != "" {
+		fields = append(fields, field{fieldInterface, m.Interface})
+	}
+	if m.Member != "" {
+		fields = append(fields, field{fieldMember, m.Member})
+	}
+	if m.ErrorName != "" {
+		fields = append(fields, field{fieldErrorName, m.ErrorName})
+	}
+	if m.ReplySerial != 0 {
+		fields = append(fields, field{fieldReplySerial, m.ReplySerial})
+	}
+	if m.Destination != "" {
+		fields = append(fields, field{fieldDestination, m.Destination})
+	}
+	if m.Sender != "" {
+		fields = append(fields, field{fieldSender, m.Sender})
+	}
+	if sig != "" {
+		fields = append(fields, field{fieldSignature, sig})
+	}
+	if len(body.files) > 0 {
+		fields = append(fields, field{fieldUnixFDs, uint32(len(body.files))})
+	}
+
+	endian := byte('l')
+	if order == binary.BigEndian {
+		endian = 'B'
+	}
+
+	header := &encoder{order: order}
+	header.buf = append(header.buf, endian, byte(m.Type), m.Flags, protocolVersion)
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(m.Serial)
+
+	// The field array length excludes the padding before its first element.
+	lengthAt := len(header.buf)
+	header.uint32(0)
+	header.align(8)
+	start := len(header.buf)
+	for _, f := range fields {
+		header.align(8)
+		header.buf = append(header.buf, f.code)
+		if err := header.variant(f.value); err != nil {
+			return nil, nil, err
+		}
+	}
+	order.PutUint32(header.buf[lengthAt:], uint32(len(header.buf)-start))
+	header.align(8)
+
+	return append(header.buf, body.buf...), body.files, nil
+}
+
+// decoder reads D-Bus wire data. Like encoder, alignment is relative to the
+// start of data.
+type decoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+	files []*os.File
+}
+
+var errTruncated = errors.New("dbus: truncated message")
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.data) {
+		return errTruncated
+	}
+	return nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) uint16() (uint16, error) {
+	if err := d.align(2); err != nil {
+		return 0, err
+	}
+	if d.pos+2 > len(d.data) {
+		return 0, errTruncated
+	}
+	v := d.order.Uint16(d.data[d.pos:])
+	d.pos += 2
+	return v, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	if d.pos+4 > len(d.data) {
+		return 0, errTruncated
+	}
+	v := d.order.Uint32(d.data[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	// Strings are followed by a NUL that is not part of their length.
+	if n < 0 || d.pos+n+1 > len(d.data) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n + 1
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.bytes(int(n))
+	return string(b), err
+}
+
+func (d *decoder) signature() (signature, error) {
+	n, err := d.byte()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.bytes(int(n))
+	return signature(b), err
+}
+
+func (d *decoder) value(code byte) (any, error) {
+	switch code {
+	case 'y':
+		return d.byte()
+	case 'b':
+		v, err := d.uint32()
+		return v != 0, err
+	case 'q':
+		return d.uint16()
+	case 'i':
+		v, err := d.uint32()
+		return int32(v), err
+	case 'u':
+		return d.uint32()
+	case 's':
+		return d.string()
+	case 'o':
+		s, err := d.string()
+		return ObjectPath(s), err
+	case 'g':
+		return d.signature()
+	case 'h':
+		index, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if int(index) >= len(d.files) {
+			return nil, fmt.Errorf("dbus: file descriptor index %d out of range", index)
+		}
+		return d.files[index], nil
+	default:
+		return nil, fmt.Errorf("dbus: unsupported type %q", code)
+	}
+}
+
+func (d *decoder) variant() (any, error) {
+	sig, err := d.signature()
+	if err != nil {
+		return nil, err
+	}
+	value, rest, err := d.typed(sig)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("dbus: unsupported variant signature %q", sig)
+	}
+	return value, nil
+}
+
+// typed decodes the first complete type in sig and returns the remainder of
+// the signature. Besides basic types only as and a{sv} are understood.
+func (d *decoder) typed(sig signature) (any, signature, error) {
+	switch {
+	case strings.HasPrefix(string(sig), "as"):
+		var values []string
+		err := d.array(4, func() error {
+			s, err := d.string()
+			values = append(values, s)
+			return err
+		})
+		return values, sig[2:], err
+	case strings.HasPrefix(string(sig), "a{sv}"):
+		values := make(map[string]any)
+		err := d.array(8, func() error {
+			if err := d.align(8); err != nil {
+				return err
+			}
+			key, err := d.string()
+			if err != nil {
+				return err
+			}
+			values[key], err = d.variant()
+			return err
+		})
+		return values, sig[5:], err
+	case sig == "":
+		return nil, sig, errTruncated
+	default:
+		value, err := d.value(sig[0])
+		return value, sig[1:], err
+	}
+}
+
+// array reads an array whose elements have the given alignment, calling
+// element until its contents are consumed.
+func (d *decoder) array(alignment int, element func() error) error {
+	n, err := d.uint32()
+	if err != nil {
+		return err
+	}
+	if err := d.align(alignment); err != nil {
+		return err
+	}
+	end := d.pos + int(n)
+	if n > maxMessageSize || end > len(d.data) {
+		return errTruncated
+	}
+	for d.pos < end {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	if d.pos != end {
+		return errors.New("dbus: array elements overrun its length")
+	}
+	return nil
+}
+
+// fixedHeaderSize covers the endianness flag through the length of the
+// header field array.
+const fixedHeaderSize = 16
+
+// messageLength returns the total size of the message whose first
+// fixedHeaderSize bytes are given.
+func messageLength(fixed []byte) (int, binary.ByteOrder, error) {
+	order, err := byteOrder(fixed[0])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	bodyLen := int(order.Uint32(fixed[4:]))
+	fieldsLen := int(order.Uint32(fixed[12:]))
+	headerLen := fixedHeaderSize + fieldsLen
+	if pad := headerLen % 8; pad != 0 {
+		headerLen += 8 - pad
+	}
+
+	total := headerLen + bodyLen
+	if bodyLen > maxMessageSize || fieldsLen > maxMessageSize || total > maxMessageSize {
+		return 0, nil, fmt.Errorf("dbus: message of %d bytes exceeds the limit", total)
+	}
+	return total, order, nil
+}
+
+func byteOrder(flag byte) (binary.ByteOrder, error) {
+	switch flag {
+	case 'l':
+		return binary.LittleEndian, nil
+	case 'B':
+		return binary.BigEndian, nil
+	default:
+		return nil, fmt.Errorf("dbus: invalid endianness flag %q", flag)
+	}
+}
+
+// decodeMessage parses a complete message. take supplies the file
+// descriptors announced in the header, in the order they were received.
+func decodeMessage(data []byte, take func(n int) ([]*os.File, error)) (*Message, error) {
+	if len(data) < fixedHeaderSize {
+		return nil, errTruncated
+	}
+	order, err := byteOrder(data[0])
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{Type: MessageType(data[1]), Flags: data[2]}
+	if data[3] != protocolVersion {
+		return nil, fmt.Errorf("dbus: unsupported protocol version %d", data[3])
+	}
+
+	d := &decoder{data: data, pos: 4, order: order}
+	bodyLen, _ := d.uint32()
+	m.Serial, _ = d.uint32()
+	fieldsLen, _ := d.uint32()
+
+	if err := d.align(8); err != nil {
+		return nil, err
+	}
+	end := d.pos + int(fieldsLen)
+	if end > len(data) {
+		return nil, errTruncated
+	}
+
+	var sig signature
+	var unixFDs uint32
+	for d.pos < end {
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		code, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.variant()
+		if err != nil {
+			return nil, err
+		}
+
+		switch code {
+		case fieldPath:
+			m.Path, _ = value.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = value.(string)
+		case fieldMember:
+			m.Member, _ = value.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = value.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = value.(uint32)
+		case fieldDestination:
+			m.Destination, _ = value.(string)
+		case fieldSender:
+			m.Sender, _ = value.(string)
+		case fieldSignature:
+			sig, _ = value.(signature)
+		case fieldUnixFDs:
+			unixFDs, _ = value.(uint32)
+		}
+	}
+
+	if err := d.align(8); err != nil {
+		return nil, err
+	}
+
+	if unixFDs > 0 {
+		files, err := take(int(unixFDs))
+		if err != nil {
+			return nil, err
+		}
+		d.files = files
+	}
+
+	body := &decoder{data: data[d.pos:], order: order, files: d.files}
+	if len(body.data) != int(bodyLen) {
+		return nil, errTruncated
+	}
+	for rest := sig; rest != ""; {
+		var value any
+		value, rest, err = body.typed(rest)
+		if err != nil {
+			m.Body = nil
+			m.bodyErr = err
+			break
+		}
+		m.Body = append(m.Body, value)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,161 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		in := &Message{
+			Type:        TypeMethodCall,
+			Serial:      7,
+			Path:        "/org/freedesktop/login1",
+			Interface:   "org.freedesktop.login1.Manager",
+			Member:      "Inhibit",
+			Destination: "org.freedesktop.login1",
+			Body:        []any{"sleep", "pearedd", "why", "delay", true, uint32(42), int32(-3), byte(9), uint16(513), ObjectPath("/x")},
+		}
+
+		data, files, err := in.encode(order)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if len(files) != 0 {
+			t.Fatalf("unexpected files: %v", files)
+		}
+		total, _, err := messageLength(data[:fixedHeaderSize])
+		if err != nil || total != len(data) {
+			t.Fatalf("messageLength = %d, %v; want %d", total, err, len(data))
+		}
+
+		out, err := decodeMessage(data, func(int) ([]*os.File, error) { return nil, nil })
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+
+		if out.Serial != in.Serial || out.Path != in.Path || out.Interface != in.Interface || out.Member != in.Member || out.Destination != in.Destination {
+			t.Fatalf("header mismatch: %+v", out)
+		}
+		if len(out.Body) != len(in.Body) {
+			t.Fatalf("body mismatch: %v", out.Body)
+		}
+		for i := range in.Body {
+			if out.Body[i] != in.Body[i] {
+				t.Fatalf("body[%d] = %#v, want %#v", i, out.Body[i], in.Body[i])
+			}
+		}
+	}
+}
+
+func TestMessageContainers(t *testing.T) {
+	in := &Message{
+		Type:   TypeMethodCall,
+		Serial: 4,
+		Member: "Notify",
+		Body: []any{
+			[]string{"accept", "Accept", "reject", "Reject"},
+			map[string]any{"urgency": byte(2), "resident": true, "nested": map[string]any{}},
+			[]string{},
+			uint32(5),
+		},
+	}
+
+	data, _, err := in.encode(binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, err := decodeMessage(data, nil)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.bodyErr != nil || len(out.Body) != 4 {
+		t.Fatalf("unexpected body %v (%v)", out.Body, out.bodyErr)
+	}
+
+	actions, _ := out.Body[0].([]string)
+	if len(actions) != 4 || actions[3] != "Reject" {
+		t.Fatalf("actions = %#v", out.Body[0])
+	}
+	hints, _ := out.Body[1].(map[string]any)
+	if hints["urgency"] != byte(2) || hints["resident"] != true || len(hints) != 3 {
+		t.Fatalf("hints = %#v", out.Body[1])
+	}
+	if empty, ok := out.Body[2].([]string); !ok || len(empty) != 0 {
+		t.Fatalf("empty array = %#v", out.Body[2])
+	}
+	if out.Body[3] != uint32(5) {
+		t.Fatalf("trailing value = %#v", out.Body[3])
+	}
+}
+
+func TestMessageCarriesFiles(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	in := &Message{Type: TypeMethodReturn, Serial: 2, ReplySerial: 1, Body: []any{w}}
+	data, files, err := in.encode(binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(files) != 1 || files[0] != w {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	out, err := decodeMessage(data, func(n int) ([]*os.File, error) { return []*os.File{r}[:n], nil })
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.ReplySerial != 1 || len(out.Body) != 1 || out.Body[0] != r {
+		t.Fatalf("unexpected message: %+v", out)
+	}
+}
+
+func TestUnsupportedBodyKeepsHeader(t *testing.T) {
+	in := &Message{Type: TypeSignal, Serial: 3, Member: "Changed", Body: []any{"x"}}
+	data, _, err := in.encode(binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	// Rewrite the body signature from "s" to "a", an unsupported array.
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] == fieldSignature && data[i+1] == 1 && data[i+2] == 'g' {
+			data[i+5] = 'a'
+			break
+		}
+	}
+
+	out, err := decodeMessage(data, nil)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Member != "Changed" || out.bodyErr == nil {
+		t.Fatalf("expected header with body error, got %+v", out)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	cases := map[string]string{
+		"unix:path=/run/dbus/system_bus_socket": "/run/dbus/system_bus_socket",
+		"unix:abstract=/tmp/dbus-x,guid=abc":    "\x00/tmp/dbus-x",
+		"unix:guid=abc,path=/tmp/with%20space":  "/tmp/with space",
+	}
+	for address, want := range cases {
+		got, err := parseAddress(address)
+		if err != nil || got != want {
+			t.Errorf("parseAddress(%q) = %q, %v; want %q", address, got, err, want)
+		}
+	}
+
+	for _, address := range []string{"tcp:host=localhost", "unix:guid=abc"} {
+		if _, err := parseAddress(address); err == nil {
+			t.Errorf("expected error for %q", address)
+		}
+	}
+}
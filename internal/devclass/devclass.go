@@ -0,0 +1,353 @@
+// Package devclass decodes the Class of Device, GAP appearance and service
+// UUIDs Bluetooth devices report into readable names and a coarse Kind. The
+// names come from tables embedded from the Bluetooth Assigned Numbers
+// document.
+package devclass
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is a coarse device category.
+type Kind string
+
+const (
+	Unknown  Kind = "unknown"
+	Headset  Kind = "headset"
+	Speaker  Kind = "speaker"
+	Keyboard Kind = "keyboard"
+	Mouse    Kind = "mouse"
+	Gamepad  Kind = "gamepad"
+	Phone    Kind = "phone"
+	Watch    Kind = "watch"
+)
+
+// Kinds lists every kind other than Unknown.
+var Kinds = []Kind{Headset, Speaker, Keyboard, Mouse, Gamepad, Phone, Watch}
+
+// ParseKind validates a device kind from configuration. An empty value maps
+// to Unknown so detection can fill it in.
+func ParseKind(value string) (Kind, error) {
+	kind := Kind(strings.ToLower(strings.TrimSpace(value)))
+	switch kind {
+	case "", Unknown:
+		return Unknown, nil
+	}
+	for _, known := range Kinds {
+		if kind == known {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown device kind %q (want headset, speaker, keyboard, mouse, gamepad, phone or watch)", value)
+}
+
+// audioProfileOrder is the order AudioProfiles lists profiles in.
+var audioProfileOrder = []string{"A2DP", "HFP", "HSP", "LE Audio"}
+
+// serviceKindOrder decides between the kinds suggested by several services:
+// a headset also offers an audio sink and a phone an audio source, so the
+// more specific kind wins.
+var serviceKindOrder = []Kind{Phone, Headset, Watch, Gamepad, Keyboard, Mouse, Speaker}
+
+// baseUUIDSuffix is the tail of the Bluetooth base UUID that 16- and 32-bit
+// UUIDs expand into.
+const baseUUIDSuffix = "-0000-1000-8000-00805f9b34fb"
+
+// Major class numbers with minor classes that are decoded in parts.
+const (
+	majorPeripheral = 0x05
+	majorImaging    = 0x06
+)
+
+var (
+	//go:embed classes.txt
+	classTable string
+	//go:embed appearances.txt
+	appearanceTable string
+	//go:embed uuids.txt
+	uuidTable string
+
+	tablesOnce sync.Once
+	classes    map[string]entry
+	appears    map[string]entry
+	services   map[string]entry
+)
+
+type entry struct {
+	name    string
+	kind    Kind
+	profile string
+}
+
+// Class is a decoded Class of Device.
+type Class struct {
+	Major string
+	// Minor is empty when the minor class is unassigned or unknown.
+	Minor string
+	// Services names the service class bits that are set.
+	Services []string
+	Kind     Kind
+}
+
+// String returns "Major: Minor", or only the major class without a minor.
+func (c Class) String() string {
+	if c.Minor == "" {
+		return c.Major
+	}
+	return c.Major + ": " + c.Minor
+}
+
+// DecodeClass decodes a 24-bit Class of Device value.
+func DecodeClass(cod uint32) Class {
+	loadTables()
+
+	major := (cod >> 8) & 0x1f
+	minor := (cod >> 2) & 0x3f
+
+	majorEntry, ok := classes[fmt.Sprintf("major %02x", major)]
+	if !ok {
+		majorEntry = entry{name: fmt.Sprintf("Reserved (0x%02x)", major)}
+	}
+	class := Class{Major: majorEntry.name, Kind: majorEntry.kind}
+
+	var parts []entry
+	switch major {
+	case majorPeripheral:
+		// The upper bits say keyboard or pointing device, the lower bits
+		// name the device type; either may be unset.
+		for _, part := range []uint32{minor & 0x30, minor & 0x0f} {
+			if e, ok := classes[fmt.Sprintf("minor %02x %02x", major, part)]; ok && part != 0 {
+				parts = append(parts, e)
+			}
+		}
+		// The device type is the more specific of the two.
+		for i := len(parts) - 1; i >= 0; i-- {
+			if parts[i].kind != "" {
+				class.Kind = parts[i].kind
+				break
+			}
+		}
+	case majorImaging:
+		for bit := uint32(0x04); bit <= 0x20; bit <<= 1 {
+			if minor&bit != 0 {
+				if e, ok := classes[fmt.Sprintf("minor %02x %02x", major, bit)]; ok {
+					parts = append(parts, e)
+				}
+			}
+		}
+	default:
+		if e, ok := classes[fmt.Sprintf("minor %02x %02x", major, minor)]; ok {
+			parts = append(parts, e)
+			if e.kind != "" {
+				class.Kind = e.kind
+			}
+		}
+	}
+
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		names = append(names, part.name)
+	}
+	separator := " "
+	if major == majorImaging {
+		separator = "/"
+	}
+	class.Minor = strings.Join(names, separator)
+
+	for bit := 13; bit <= 23; bit++ {
+		if cod&(1<<bit) == 0 {
+			continue
+		}
+		if e, ok := classes[fmt.Sprintf("service %d", bit)]; ok {
+			class.Services = append(class.Services, e.name)
+		}
+	}
+
+	if class.Kind == "" {
+		class.Kind = Unknown
+	}
+	return class
+}
+
+// Appearance is a decoded GAP appearance value.
+type Appearance struct {
+	Category string
+	// Subcategory is empty for generic values and unknown subcategories.
+	Subcategory string
+	Kind        Kind
+}
+
+// String returns the subcategory when known, otherwise the category.
+func (a Appearance) String() string {
+	if a.Subcategory != "" {
+		return a.Subcategory
+	}
+	return a.Category
+}
+
+// DecodeAppearance decodes a 16-bit GAP appearance value.
+func DecodeAppearance(value uint16) Appearance {
+	loadTables()
+
+	category := value >> 6
+	sub := value & 0x3f
+
+	cat, ok := appears[fmt.Sprintf("category %03x", category)]
+	if !ok {
+		cat = entry{name: fmt.Sprintf("Reserved (0x%03x)", category)}
+	}
+	appearance := Appearance{Category: cat.name, Kind: cat.kind}
+
+	if e, ok := appears[fmt.Sprintf("sub %03x %02x", category, sub)]; ok && sub != 0 {
+		appearance.Subcategory = e.name
+		if e.kind != "" {
+			appearance.Kind = e.kind
+		}
+	}
+
+	if appearance.Kind == "" {
+		appearance.Kind = Unknown
+	}
+	return appearance
+}
+
+// ServiceName returns the assigned name of a service UUID, given as a 16- or
+// 32-bit value in hex (with or without 0x) or as a full UUID built on the
+// Bluetooth base UUID.
+func ServiceName(uuid string) (string, bool) {
+	e, ok := lookupService(uuid)
+	return e.name, ok
+}
+
+// ServiceKind returns the kind implied by a set of service UUIDs, or Unknown
+// when none implies one.
+func ServiceKind(uuids []string) Kind {
+	found := make(map[Kind]bool)
+	for _, uuid := range uuids {
+		if e, ok := lookupService(uuid); ok && e.kind != "" {
+			found[e.kind] = true
+		}
+	}
+	for _, kind := range serviceKindOrder {
+		if found[kind] {
+			return kind
+		}
+	}
+	return Unknown
+}
+
+// AudioProfiles names the audio profiles (A2DP, HFP, HSP, LE Audio) among a
+// set of service UUIDs, in that order.
+func AudioProfiles(uuids []string) []string {
+	offered := make(map[string]bool)
+	for _, uuid := range uuids {
+		if e, ok := lookupService(uuid); ok && e.profile != "" {
+			offered[e.profile] = true
+		}
+	}
+
+	var profiles []string
+	for _, profile := range audioProfileOrder {
+		if offered[profile] {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// Detect returns the kind suggested by the class, then the appearance and
+// then the services, whichever first gives one. Zero class and appearance
+// values are treated as absent.
+func Detect(cod uint32, appearance uint16, uuids []string) Kind {
+	if cod != 0 {
+		if kind := DecodeClass(cod).Kind; kind != Unknown {
+			return kind
+		}
+	}
+	if appearance != 0 {
+		if kind := DecodeAppearance(appearance).Kind; kind != Unknown {
+			return kind
+		}
+	}
+	return ServiceKind(uuids)
+}
+
+// Describe returns a short description of the device type from the class
+// or, without one, the appearance. It is empty when neither is known.
+func Describe(cod uint32, appearance uint16) string {
+	if cod != 0 {
+		return DecodeClass(cod).String()
+	}
+	if appearance != 0 {
+		return DecodeAppearance(appearance).String()
+	}
+	return ""
+}
+
+func lookupService(uuid string) (entry, bool) {
+	loadTables()
+
+	short, ok := shortUUID(uuid)
+	if !ok {
+		return entry{}, false
+	}
+	e, ok := services[short]
+	return e, ok
+}
+
+// shortUUID reduces uuid to the lower-case hex value it abbreviates.
+func shortUUID(uuid string) (string, bool) {
+	value := strings.ToLower(strings.TrimSpace(uuid))
+	if strings.HasSuffix(value, baseUUIDSuffix) && len(value) == 8+len(baseUUIDSuffix) {
+		value = value[:8]
+	}
+	value = strings.TrimPrefix(value, "0x")
+
+	n, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%04x", n), true
+}
+
+func loadTables() {
+	tablesOnce.Do(func() {
+		classes = parseTable(classTable)
+		appears = parseTable(appearanceTable)
+		services = parseTable(uuidTable)
+	})
+}
+
+// parseTable reads "key<TAB>name[<TAB>kind[<TAB>profile]]" rows, skipping
+// blank lines and comments.
+func parseTable(table string) map[string]entry {
+	entries := make(map[string]entry)
+
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		e := entry{name: strings.TrimSpace(fields[1])}
+		if len(fields) > 2 {
+			e.kind = Kind(strings.TrimSpace(fields[2]))
+		}
+		if len(fields) > 3 {
+			e.profile = strings.TrimSpace(fields[3])
+		}
+		entries[strings.ToLower(fields[0])] = e
+	}
+
+	return entries
+}
@@ -0,0 +1,184 @@
+package devclass
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeClass(t *testing.T) {
+	tests := []struct {
+		cod      uint32
+		want     string
+		kind     Kind
+		services []string
+	}{
+		{0x240418, "Audio/Video: Headphones", Headset, []string{"Rendering", "Audio"}},
+		{0x240404, "Audio/Video: Wearable headset", Headset, []string{"Rendering", "Audio"}},
+		{0x240414, "Audio/Video: Loudspeaker", Speaker, []string{"Rendering", "Audio"}},
+		{0x5a020c, "Phone: Smartphone", Phone, []string{"Networking", "Capturing", "Object transfer", "Telephony"}},
+		{0x00010c, "Computer: Laptop", Unknown, nil},
+		{0x002540, "Peripheral: Keyboard", Keyboard, []string{"Limited discoverable mode"}},
+		{0x000580, "Peripheral: Pointing device", Mouse, nil},
+		{0x0005c0, "Peripheral: Combo keyboard/pointing device", Keyboard, nil},
+		{0x002508, "Peripheral: Gamepad", Gamepad, []string{"Limited discoverable mode"}},
+		{0x000504, "Peripheral: Joystick", Gamepad, nil},
+		{0x000500, "Peripheral", Unknown, nil},
+		{0x000704, "Wearable: Wristwatch", Watch, nil},
+		{0x000810, "Toy: Controller", Gamepad, nil},
+		{0x000680, "Imaging: Printer", Unknown, nil},
+		{0x0006a0, "Imaging: Camera/Printer", Unknown, nil},
+		{0x001f00, "Uncategorized", Unknown, nil},
+		{0x000b00, "Reserved (0x0b)", Unknown, nil},
+	}
+
+	for _, tt := range tests {
+		class := DecodeClass(tt.cod)
+		if got := class.String(); got != tt.want {
+			t.Errorf("DecodeClass(%#06x) = %q, want %q", tt.cod, got, tt.want)
+		}
+		if class.Kind != tt.kind {
+			t.Errorf("DecodeClass(%#06x).Kind = %q, want %q", tt.cod, class.Kind, tt.kind)
+		}
+		if !slices.Equal(class.Services, tt.services) {
+			t.Errorf("DecodeClass(%#06x).Services = %v, want %v", tt.cod, class.Services, tt.services)
+		}
+	}
+}
+
+func TestDecodeAppearance(t *testing.T) {
+	tests := []struct {
+		value uint16
+		want  string
+		kind  Kind
+	}{
+		{0x03c1, "Keyboard", Keyboard},
+		{0x03c2, "Mouse", Mouse},
+		{0x03c4, "Gamepad", Gamepad},
+		{0x03c0, "Human interface device", Unknown},
+		{0x00c2, "Smartwatch", Watch},
+		{0x0941, "Earbud", Headset},
+		{0x0843, "Bookshelf speaker", Speaker},
+		{0x0040, "Phone", Phone},
+		{0x0080, "Computer", Unknown},
+		{0x0083, "Laptop", Unknown},
+		{0x003f, "Unknown", Unknown},
+		{0xffc0, "Reserved (0x3ff)", Unknown},
+	}
+
+	for _, tt := range tests {
+		appearance := DecodeAppearance(tt.value)
+		if got := appearance.String(); got != tt.want {
+			t.Errorf("DecodeAppearance(%#04x) = %q, want %q", tt.value, got, tt.want)
+		}
+		if appearance.Kind != tt.kind {
+			t.Errorf("DecodeAppearance(%#04x).Kind = %q, want %q", tt.value, appearance.Kind, tt.kind)
+		}
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		uuid string
+		want string
+		ok   bool
+	}{
+		{"0000110b-0000-1000-8000-00805f9b34fb", "Audio sink", true},
+		{"0000111E-0000-1000-8000-00805F9B34FB", "Handsfree", true},
+		{"0x180f", "Battery", true},
+		{"1812", "Human interface device (HID over GATT)", true},
+		{"0000fe2c-0000-1000-8000-00805f9b34fb", "", false},
+		{"6e400001-b5a3-f393-e0a9-e50e24dcca9e", "", false},
+		{"not-a-uuid", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ServiceName(tt.uuid)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ServiceName(%q) = %q, %v; want %q, %v", tt.uuid, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAudioProfiles(t *testing.T) {
+	profiles := AudioProfiles([]string{
+		"0000111E-0000-1000-8000-00805F9B34FB",
+		"0000110b-0000-1000-8000-00805f9b34fb",
+		"0000110a-0000-1000-8000-00805f9b34fb",
+		"00001812-0000-1000-8000-00805f9b34fb",
+		"0x184e",
+	})
+	if !slices.Equal(profiles, []string{"A2DP", "HFP", "LE Audio"}) {
+		t.Fatalf("unexpected profiles: %v", profiles)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	const (
+		audioSink = "0000110b-0000-1000-8000-00805f9b34fb"
+		handsfree = "0000111e-0000-1000-8000-00805f9b34fb"
+		hfpGate   = "0000111f-0000-1000-8000-00805f9b34fb"
+		battery   = "0000180f-0000-1000-8000-00805f9b34fb"
+	)
+
+	tests := []struct {
+		name       string
+		cod        uint32
+		appearance uint16
+		uuids      []string
+		want       Kind
+	}{
+		{"class wins", 0x240418, 0x03c1, nil, Headset},
+		{"appearance without class", 0, 0x03c2, []string{audioSink}, Mouse},
+		{"appearance when class is vague", 0x00010c, 0x00c1, nil, Watch},
+		{"headset services beat audio sink", 0, 0, []string{audioSink, handsfree}, Headset},
+		{"audio sink alone", 0, 0, []string{audioSink, battery}, Speaker},
+		{"phone services", 0, 0, []string{hfpGate, audioSink}, Phone},
+		{"nothing known", 0, 0, []string{battery}, Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.cod, tt.appearance, tt.uuids); got != tt.want {
+			t.Errorf("%s: Detect = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Describe(0, 0x0941); got != "Earbud" {
+		t.Errorf("Describe(appearance) = %q", got)
+	}
+	if got := Describe(0, 0); got != "" {
+		t.Errorf("Describe(nothing) = %q", got)
+	}
+}
+
+func TestTablesUseKnownKinds(t *testing.T) {
+	loadTables()
+
+	for name, table := range map[string]map[string]entry{"classes": classes, "appearances": appears, "uuids": services} {
+		if len(table) == 0 {
+			t.Fatalf("%s table is empty", name)
+		}
+		for key, e := range table {
+			if e.name == "" {
+				t.Errorf("%s: %q has no name", name, key)
+			}
+			if e.kind != "" && !slices.Contains(Kinds, e.kind) {
+				t.Errorf("%s: %q has unknown kind %q", name, key, e.kind)
+			}
+		}
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for _, kind := range Kinds {
+		if parsed, err := ParseKind(strings.ToUpper(string(kind))); err != nil || parsed != kind {
+			t.Errorf("ParseKind(%q) = %q, %v", kind, parsed, err)
+		}
+	}
+	if kind, err := ParseKind(""); err != nil || kind != Unknown {
+		t.Errorf("ParseKind(\"\") = %q, %v", kind, err)
+	}
+	if _, err := ParseKind("fridge"); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
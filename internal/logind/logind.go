@@ -0,0 +1,97 @@
+// Package logind talks to systemd-logind over D-Bus to take sleep inhibitor
+// locks and follow suspend and resume.
+package logind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peared/peared/internal/dbus"
+)
+
+const (
+	// Service, Path and ManagerInterface address the logind manager object.
+	Service          = "org.freedesktop.login1"
+	Path             = dbus.ObjectPath("/org/freedesktop/login1")
+	ManagerInterface = "org.freedesktop.login1.Manager"
+
+	// PrepareForSleep is emitted with true before suspend and false after
+	// resume.
+	PrepareForSleep = "PrepareForSleep"
+)
+
+// Manager is a client for the logind manager.
+type Manager struct {
+	conn *dbus.Conn
+}
+
+// Connect opens a connection to logind on the bus at address. An empty
+// address selects the system bus.
+func Connect(address string) (*Manager, error) {
+	if address == "" {
+		address = dbus.SystemBusAddress()
+	}
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{conn: conn}, nil
+}
+
+// Close closes the underlying bus connection, which also releases any lock
+// still held through it.
+func (m *Manager) Close() error {
+	return m.conn.Close()
+}
+
+// InhibitSleep takes a delay lock on sleep: logind postpones suspend until
+// the returned lock is closed or InhibitDelayMaxSec elapses.
+func (m *Manager) InhibitSleep(ctx context.Context, who, why string) (io.Closer, error) {
+	body, err := m.conn.Call(ctx, Service, Path, ManagerInterface, "Inhibit", "sleep", who, why, "delay")
+	if err != nil {
+		return nil, fmt.Errorf("take sleep inhibitor: %w", err)
+	}
+
+	if len(body) != 1 {
+		return nil, errors.New("take sleep inhibitor: unexpected reply")
+	}
+	lock, ok := body[0].(*os.File)
+	if !ok {
+		return nil, errors.New("take sleep inhibitor: reply carries no file descriptor")
+	}
+	return lock, nil
+}
+
+// WatchSleep calls fn with true when the system is about to suspend and with
+// false once it has resumed. It blocks until ctx is cancelled or the bus
+// connection fails.
+func (m *Manager) WatchSleep(ctx context.Context, fn func(sleeping bool)) error {
+	signals, stop := m.conn.Signals()
+	defer stop()
+
+	rule := fmt.Sprintf("type='signal',sender='%s',path='%s',interface='%s',member='%s'", Service, Path, ManagerInterface, PrepareForSleep)
+	if err := m.conn.AddMatch(ctx, rule); err != nil {
+		return fmt.Errorf("subscribe to %s: %w", PrepareForSleep, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return dbus.ErrClosed
+			}
+			if sig.Path != Path || sig.Interface != ManagerInterface || sig.Member != PrepareForSleep || len(sig.Body) != 1 {
+				continue
+			}
+			if sleeping, ok := sig.Body[0].(bool); ok {
+				fn(sleeping)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package logind
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+	"github.com/peared/peared/internal/dbus/dbustest"
+)
+
+// fakeLogind owns the logind name on a private bus, hands out inhibitor
+// locks as pipes and emits PrepareForSleep on demand.
+type fakeLogind struct {
+	conn    *dbus.Conn
+	locks   chan *os.File
+	writers chan *os.File
+	calls   chan []any
+}
+
+func startFakeLogind(t *testing.T, address string) *fakeLogind {
+	t.Helper()
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.RequestName(context.Background(), Service); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+
+	fake := &fakeLogind{conn: conn, locks: make(chan *os.File, 4), writers: make(chan *os.File, 4), calls: make(chan []any, 4)}
+	conn.HandleCalls(func(call *dbus.Message) ([]any, error) {
+		if call.Interface != ManagerInterface || call.Member != "Inhibit" {
+			return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: call.Member}
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		fake.calls <- call.Body
+		fake.locks <- r
+		fake.writers <- w
+		return []any{w}, nil
+	})
+
+	return fake
+}
+
+func (f *fakeLogind) prepareForSleep(t *testing.T, sleeping bool) {
+	t.Helper()
+	if err := f.conn.Emit(Path, ManagerInterface, PrepareForSleep, sleeping); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+}
+
+func TestInhibitSleepHoldsLockUntilClosed(t *testing.T) {
+	address := dbustest.StartBus(t)
+	fake := startFakeLogind(t, address)
+
+	manager, err := Connect(address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer manager.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lock, err := manager.InhibitSleep(ctx, "pearedd", "Disconnect Bluetooth devices")
+	if err != nil {
+		t.Fatalf("InhibitSleep: %v", err)
+	}
+
+	args := <-fake.calls
+	if len(args) != 4 || args[0] != "sleep" || args[1] != "pearedd" || args[3] != "delay" {
+		t.Fatalf("unexpected Inhibit arguments: %v", args)
+	}
+
+	// Like logind, drop our copy of the write end once it has been handed
+	// out; the lock is released when the read end reports EOF.
+	(<-fake.writers).Close()
+	held := <-fake.locks
+	defer held.Close()
+	released := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, held)
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("lock released before Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lock.Close()
+	select {
+	case <-released:
+	case <-ctx.Done():
+		t.Fatal("lock not released after Close")
+	}
+}
+
+func TestWatchSleepReportsTransitions(t *testing.T) {
+	address := dbustest.StartBus(t)
+	fake := startFakeLogind(t, address)
+
+	manager, err := Connect(address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer manager.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	seen := make(chan bool, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.WatchSleep(ctx, func(sleeping bool) { seen <- sleeping })
+	}()
+
+	// Keep emitting until the match rule is in place and the first signal
+	// arrives; AddMatch races with the first Emit.
+	var first bool
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case first = <-seen:
+			break wait
+		case <-ticker.C:
+			fake.prepareForSleep(t, true)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for PrepareForSleep")
+		}
+	}
+	if !first {
+		t.Fatal("expected sleeping=true first")
+	}
+
+	// Drain duplicates from the retry loop, then signal resume.
+	time.Sleep(50 * time.Millisecond)
+	for len(seen) > 0 {
+		<-seen
+	}
+	fake.prepareForSleep(t, false)
+	select {
+	case sleeping := <-seen:
+		if sleeping {
+			t.Fatal("expected sleeping=false on resume")
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for resume")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchSleep returned %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package notify shows desktop notifications through the
+// org.freedesktop.Notifications service and reports which action the user
+// picked.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+)
+
+const (
+	// Service, Path and Interface address the notification server.
+	Service   = "org.freedesktop.Notifications"
+	Path      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	Interface = "org.freedesktop.Notifications"
+
+	appName = "peared"
+
+	urgencyNormal   = byte(1)
+	urgencyCritical = byte(2)
+)
+
+// callTimeout bounds the calls Ask makes independently of its context: a
+// notification must be closed again even if the question was settled while
+// it was being shown.
+const callTimeout = 2 * time.Second
+
+// Action is a button offered on a notification.
+type Action struct {
+	Key   string
+	Label string
+}
+
+// Notification describes what to show.
+type Notification struct {
+	Summary string
+	Body    string
+	Icon    string
+
+	// Actions are offered as buttons; servers without action support show
+	// the notification without them.
+	Actions []Action
+
+	// Timeout is how long the notification stays up. Zero leaves it to the
+	// server.
+	Timeout time.Duration
+}
+
+// Notifier sends notifications over a session bus connection.
+type Notifier struct {
+	conn *dbus.Conn
+}
+
+// Connect opens a connection to the bus at address. An empty address
+// selects the session bus.
+func Connect(address string) (*Notifier, error) {
+	if address == "" {
+		resolved, err := dbus.SessionBusAddress()
+		if err != nil {
+			return nil, err
+		}
+		address = resolved
+	}
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := fmt.Sprintf("type='signal',interface='%s',path='%s'", Interface, Path)
+	if err := conn.AddMatch(context.Background(), rule); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("subscribe to notification signals: %w", err)
+	}
+
+	return &Notifier{conn: conn}, nil
+}
+
+// Close closes the bus connection.
+func (n *Notifier) Close() error {
+	return n.conn.Close()
+}
+
+// Show displays a notification and returns its ID without waiting for the
+// user.
+func (n *Notifier) Show(ctx context.Context, note Notification) (uint32, error) {
+	return n.notify(ctx, note, urgencyNormal)
+}
+
+// Ask displays a notification with actions and waits until the user picks
+// one, returning its key. An empty key means the notification was dismissed
+// without a choice. When ctx is cancelled first the notification is closed
+// and the context error returned.
+func (n *Notifier) Ask(ctx context.Context, note Notification) (string, error) {
+	if len(note.Actions) == 0 {
+		return "", errors.New("notification offers no actions to choose from")
+	}
+
+	// Subscribe before sending so a quick answer is not missed.
+	signals, stop := n.conn.Signals()
+	defer stop()
+
+	showCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), callTimeout)
+	id, err := n.notify(showCtx, note, urgencyCritical)
+	cancel()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			closeCtx, cancel := context.WithTimeout(context.Background(), callTimeout)
+			_, _ = n.conn.Call(closeCtx, Service, Path, Interface, "CloseNotification", id)
+			cancel()
+			return "", ctx.Err()
+		case sig, ok := <-signals:
+			if !ok {
+				return "", dbus.ErrClosed
+			}
+			if sig.Interface != Interface || len(sig.Body) < 2 || sig.Body[0] != id {
+				continue
+			}
+			switch sig.Member {
+			case "ActionInvoked":
+				key, _ := sig.Body[1].(string)
+				return key, nil
+			case "NotificationClosed":
+				return "", nil
+			}
+		}
+	}
+}
+
+func (n *Notifier) notify(ctx context.Context, note Notification, urgency byte) (uint32, error) {
+	actions := make([]string, 0, 2*len(note.Actions))
+	for _, action := range note.Actions {
+		actions = append(actions, action.Key, action.Label)
+	}
+
+	// -1 lets the server pick the timeout.
+	timeout := int32(-1)
+	if note.Timeout > 0 {
+		timeout = int32(note.Timeout / time.Millisecond)
+	}
+
+	hints := map[string]any{"urgency": urgency}
+	body, err := n.conn.Call(ctx, Service, Path, Interface, "Notify", appName, uint32(0), note.Icon, note.Summary, note.Body, actions, hints, timeout)
+	if err != nil {
+		return 0, fmt.Errorf("show notification: %w", err)
+	}
+	if len(body) != 1 {
+		return 0, errors.New("show notification: unexpected reply")
+	}
+	id, ok := body[0].(uint32)
+	if !ok {
+		return 0, errors.New("show notification: reply carries no notification ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/dbus"
+	"github.com/peared/peared/internal/dbus/dbustest"
+)
+
+// fakeServer owns the notification service on a private bus and records the
+// notifications it is asked to show.
+type fakeServer struct {
+	conn   *dbus.Conn
+	shown  chan []any
+	closed chan uint32
+}
+
+func startFakeServer(t *testing.T, address string) *fakeServer {
+	t.Helper()
+
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.RequestName(context.Background(), Service); err != nil {
+		t.Fatalf("RequestName: %v", err)
+	}
+
+	fake := &fakeServer{conn: conn, shown: make(chan []any, 4), closed: make(chan uint32, 4)}
+	next := uint32(0)
+	conn.HandleCalls(func(call *dbus.Message) ([]any, error) {
+		switch call.Member {
+		case "Notify":
+			next++
+			fake.shown <- call.Body
+			return []any{next}, nil
+		case "CloseNotification":
+			fake.closed <- call.Body[0].(uint32)
+			return nil, nil
+		default:
+			return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: call.Member}
+		}
+	})
+
+	return fake
+}
+
+func TestAskReturnsInvokedAction(t *testing.T) {
+	address := dbustest.StartBus(t)
+	server := startFakeServer(t, address)
+
+	notifier, err := Connect(address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer notifier.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	answer := make(chan string, 1)
+	go func() {
+		key, err := notifier.Ask(ctx, Notification{
+			Summary: "Pair keyboard?",
+			Body:    "Confirm passkey 123456",
+			Actions: []Action{{Key: "accept", Label: "Accept"}, {Key: "reject", Label: "Reject"}},
+		})
+		if err != nil {
+			t.Errorf("Ask: %v", err)
+		}
+		answer <- key
+	}()
+
+	args := <-server.shown
+	if args[0] != appName || args[3] != "Pair keyboard?" {
+		t.Fatalf("unexpected Notify arguments %v", args)
+	}
+	if actions, _ := args[5].([]string); len(actions) != 4 || actions[0] != "accept" || actions[3] != "Reject" {
+		t.Fatalf("unexpected actions %#v", args[5])
+	}
+	if hints, _ := args[6].(map[string]any); hints["urgency"] != urgencyCritical {
+		t.Fatalf("unexpected hints %#v", args[6])
+	}
+
+	// A signal for another notification must be ignored.
+	if err := server.conn.Emit(Path, Interface, "ActionInvoked", uint32(99), "reject"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if err := server.conn.Emit(Path, Interface, "ActionInvoked", uint32(1), "accept"); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	if key := <-answer; key != "accept" {
+		t.Fatalf("Ask = %q, want accept", key)
+	}
+}
+
+func TestAskClosesNotificationWhenCancelled(t *testing.T) {
+	address := dbustest.StartBus(t)
+	server := startFakeServer(t, address)
+
+	notifier, err := Connect(address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer notifier.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := notifier.Ask(ctx, Notification{Summary: "Allow?", Actions: []Action{{Key: "accept", Label: "Accept"}}})
+		result <- err
+	}()
+
+	<-server.shown
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	select {
+	case id := <-server.closed:
+		if id != 1 {
+			t.Fatalf("closed notification %d, want 1", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not closed")
+	}
+}
@@ -0,0 +1,342 @@
+// Package oui names the manufacturer of a Bluetooth device from the
+// organizationally unique identifier (OUI) in its public address, and tells
+// public addresses apart from the random ones LE devices use for privacy.
+//
+// The embedded oui.tsv.gz holds every MA-L assignment from the IEEE
+// registry's oui.csv. `peared oui update` builds a newer table from a fresh
+// oui.csv and installs it where Default finds it; run it with --output
+// internal/oui/oui.tsv.gz to refresh the embedded copy.
+package oui
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AddressKind classifies a device address.
+type AddressKind string
+
+const (
+	// Public addresses carry an IEEE-assigned OUI.
+	Public AddressKind = "public"
+	// StaticRandom addresses are random but stay fixed for a power cycle or
+	// longer.
+	StaticRandom AddressKind = "static random"
+	// ResolvablePrivate addresses rotate and can only be linked to the
+	// device by a bonded peer holding its identity resolving key.
+	ResolvablePrivate AddressKind = "resolvable private"
+	// NonResolvablePrivate addresses rotate and cannot be linked at all.
+	NonResolvablePrivate AddressKind = "non-resolvable private"
+	// Random addresses use the reserved sub-type.
+	Random AddressKind = "random"
+	// Unknown is returned for addresses that do not parse.
+	Unknown AddressKind = "unknown"
+)
+
+var (
+	//go:embed oui.tsv.gz
+	embeddedTable []byte
+
+	defaultOnce  sync.Once
+	defaultTable *Table
+)
+
+// Table maps OUIs to organization names.
+type Table struct {
+	vendors map[uint32]string
+}
+
+// NewTable returns an empty table.
+func NewTable() *Table {
+	return &Table{vendors: make(map[uint32]string)}
+}
+
+// Default returns the table installed at DefaultPath when it exists and
+// reads cleanly, and the embedded table otherwise. It is loaded once.
+func Default() *Table {
+	defaultOnce.Do(func() {
+		if path, err := DefaultPath(); err == nil {
+			if table, err := Open(path); err == nil {
+				defaultTable = table
+				return
+			}
+		}
+
+		table, err := Read(bytes.NewReader(embeddedTable))
+		if err != nil {
+			// The embedded table is checked by the tests; an empty table
+			// only loses vendor names.
+			table = NewTable()
+		}
+		defaultTable = table
+	})
+	return defaultTable
+}
+
+// DefaultPath returns $XDG_DATA_HOME/peared/oui.tsv.gz, falling back to
+// ~/.local/share when XDG_DATA_HOME is unset.
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "peared", "oui.tsv.gz"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".local", "share", "peared", "oui.tsv.gz"), nil
+}
+
+// Open reads a compressed table from path.
+func Open(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Read decodes a gzip-compressed table of "OUI<TAB>name" lines.
+func Read(r io.Reader) (*Table, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	table := NewTable()
+	scanner := bufio.NewScanner(zr)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, name, ok := strings.Cut(text, "\t")
+		prefix, err := parsePrefix(key)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("line %d: malformed entry %q", line, text)
+		}
+		table.vendors[prefix] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// ReadCSV builds a table from the IEEE registry's oui.csv, which has the
+// columns Registry, Assignment, Organization Name and Organization Address.
+func ReadCSV(r io.Reader) (*Table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	assignment, organization := -1, -1
+	for i, column := range header {
+		switch strings.TrimSpace(column) {
+		case "Assignment":
+			assignment = i
+		case "Organization Name":
+			organization = i
+		}
+	}
+	if assignment < 0 || organization < 0 {
+		return nil, errors.New("missing Assignment or Organization Name column")
+	}
+
+	table := NewTable()
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) <= assignment || len(record) <= organization {
+			continue
+		}
+		// Only 24-bit MA-L assignments are OUIs; longer MA-M and MA-S
+		// blocks live in separate registry files.
+		prefix, err := parsePrefix(record[assignment])
+		if err != nil {
+			continue
+		}
+		name := strings.Join(strings.Fields(record[organization]), " ")
+		if name != "" {
+			table.vendors[prefix] = name
+		}
+	}
+
+	if len(table.vendors) == 0 {
+		return nil, errors.New("no OUI assignments found")
+	}
+	return table, nil
+}
+
+// Write encodes the table as gzip-compressed "OUI<TAB>name" lines sorted by
+// OUI.
+func (t *Table) Write(w io.Writer) error {
+	prefixes := make([]uint32, 0, len(t.vendors))
+	for prefix := range t.vendors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+
+	zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(zw)
+	for _, prefix := range prefixes {
+		fmt.Fprintf(buffered, "%06X\t%s\n", prefix, t.vendors[prefix])
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Install writes the table to path atomically, creating its directory.
+func (t *Table) Install(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".oui-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := t.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Len returns the number of OUIs in the table.
+func (t *Table) Len() int {
+	return len(t.vendors)
+}
+
+// Vendor returns the organization the OUI of address is assigned to. It does
+// not check whether the address is public; see Describe.
+func (t *Table) Vendor(address string) (string, bool) {
+	octets, err := parseAddress(address)
+	if err != nil {
+		return "", false
+	}
+	name, ok := t.vendors[uint32(octets[0])<<16|uint32(octets[1])<<8|uint32(octets[2])]
+	return name, ok
+}
+
+// Describe names the manufacturer of a public address, or says which kind of
+// random address it is. addressType is the type BlueZ reports ("public" or
+// "random"). When it is empty the result is empty too: the address alone
+// cannot tell a public address from a random one, and an OUI looked up in a
+// random address would name an unrelated vendor.
+func (t *Table) Describe(address, addressType string) string {
+	if strings.TrimSpace(addressType) == "" {
+		return ""
+	}
+
+	kind := Classify(address, addressType)
+	switch kind {
+	case Unknown:
+		return ""
+	case Public:
+		name, _ := t.Vendor(address)
+		return name
+	default:
+		return string(kind) + " address"
+	}
+}
+
+// Classify determines the kind of address. Only random addresses are
+// divided further, by their two most significant bits; an empty
+// addressType is taken as public because the bits of a public address are
+// arbitrary.
+func Classify(address, addressType string) AddressKind {
+	octets, err := parseAddress(address)
+	if err != nil {
+		return Unknown
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(addressType), "random") {
+		return Public
+	}
+
+	switch octets[0] >> 6 {
+	case 0b11:
+		return StaticRandom
+	case 0b01:
+		return ResolvablePrivate
+	case 0b00:
+		return NonResolvablePrivate
+	default:
+		return Random
+	}
+}
+
+func parseAddress(address string) ([6]byte, error) {
+	var octets [6]byte
+	parts := strings.Split(strings.TrimSpace(address), ":")
+	if len(parts) != 6 {
+		return octets, fmt.Errorf("invalid address %q", address)
+	}
+	for i, part := range parts {
+		if len(part) != 2 {
+			return octets, fmt.Errorf("invalid address %q", address)
+		}
+		value, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return octets, fmt.Errorf("invalid address %q", address)
+		}
+		octets[i] = byte(value)
+	}
+	return octets, nil
+}
+
+// parsePrefix parses a 24-bit OUI written as six hex digits, optionally
+// separated by dashes or colons.
+func parsePrefix(value string) (uint32, error) {
+	digits := strings.NewReplacer("-", "", ":", "").Replace(strings.TrimSpace(value))
+	if len(digits) != 6 {
+		return 0, fmt.Errorf("invalid OUI %q", value)
+	}
+	prefix, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid OUI %q", value)
+	}
+	return uint32(prefix), nil
+}
@@ -0,0 +1,116 @@
+package oui
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleCSV = `Registry,Assignment,Organization Name,Organization Address
+MA-L,B827EB,Raspberry Pi Foundation,Mitchell Wood House Caldecote Cambridgeshire GB CB23 7NU
+MA-L,001B66,"Sennheiser electronic GmbH & Co. KG","Am Labor 1 Wedemark  DE 30900 "
+MA-L,70B3D5,IEEE Registration Authority,"445 Hoes Lane Piscataway NJ US 08554 "
+MA-L,zzzzzz,Broken Row,Nowhere
+MA-L,4C0001,"Example  Vendor,  Inc.",Somewhere
+`
+
+func TestReadCSVAndRoundTrip(t *testing.T) {
+	table, err := ReadCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if table.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", table.Len())
+	}
+	if name, ok := table.Vendor("4c:00:01:12:34:56"); !ok || name != "Example Vendor, Inc." {
+		t.Fatalf("Vendor = %q, %v", name, ok)
+	}
+
+	path := filepath.Join(t.TempDir(), "data", "oui.tsv.gz")
+	if err := table.Install(path); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	reread, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if reread.Len() != table.Len() {
+		t.Fatalf("round trip lost entries: %d != %d", reread.Len(), table.Len())
+	}
+	if name, _ := reread.Vendor("00:1B:66:AA:BB:CC"); name != "Sennheiser electronic GmbH & Co. KG" {
+		t.Fatalf("unexpected vendor after round trip %q", name)
+	}
+
+	if _, err := ReadCSV(strings.NewReader("Registry,Assignment\nMA-L,001B66\n")); err == nil {
+		t.Fatal("expected a CSV without organization names to be refused")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	table, err := ReadCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+
+	tests := []struct {
+		address     string
+		addressType string
+		kind        AddressKind
+		want        string
+	}{
+		{"B8:27:EB:01:02:03", "public", Public, "Raspberry Pi Foundation"},
+		// Without a type nothing is said, as the address could be random.
+		{"B8:27:EB:01:02:03", "", Public, ""},
+		{"4C:00:01:12:34:56", "", Public, ""},
+		{"00:11:22:33:44:55", "public", Public, ""},
+		// Random addresses are never looked up, even when their first
+		// bytes happen to match an assignment.
+		{"4C:00:01:12:34:56", "random", ResolvablePrivate, "resolvable private address"},
+		{"4C:00:01:12:34:56", "public", Public, "Example Vendor, Inc."},
+		{"F3:12:34:56:78:9A", "random", StaticRandom, "static random address"},
+		{"12:34:56:78:9A:BC", "random", NonResolvablePrivate, "non-resolvable private address"},
+		{"9A:34:56:78:9A:BC", "random", Random, "random address"},
+		{"not-an-address", "public", Unknown, ""},
+	}
+
+	for _, tt := range tests {
+		if kind := Classify(tt.address, tt.addressType); kind != tt.kind {
+			t.Errorf("Classify(%q, %q) = %q, want %q", tt.address, tt.addressType, kind, tt.kind)
+		}
+		if got := table.Describe(tt.address, tt.addressType); got != tt.want {
+			t.Errorf("Describe(%q, %q) = %q, want %q", tt.address, tt.addressType, got, tt.want)
+		}
+	}
+}
+
+func TestEmbeddedTable(t *testing.T) {
+	table, err := Read(bytes.NewReader(embeddedTable))
+	if err != nil {
+		t.Fatalf("Read embedded table: %v", err)
+	}
+	if name, ok := table.Vendor("DC:A6:32:00:00:01"); !ok || name != "Raspberry Pi Trading Ltd" {
+		t.Fatalf("Vendor = %q, %v", name, ok)
+	}
+	// The full MA-L registry has well over twenty thousand assignments.
+	if table.Len() < 20000 {
+		t.Fatalf("embedded table has only %d vendors", table.Len())
+	}
+}
+
+func TestDefaultPrefersInstalledTable(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+
+	table, err := ReadCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	if err := table.Install(filepath.Join(dir, "peared", "oui.tsv.gz")); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if name, _ := Default().Vendor("4C:00:01:00:00:00"); name != "Example Vendor, Inc." {
+		t.Fatalf("expected the installed table to be used, got %q", name)
+	}
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AudioProfile selects how an audio device is used once connected.
+type AudioProfile string
+
+const (
+	// AudioProfileA2DP plays high-quality stereo audio without a
+	// microphone.
+	AudioProfileA2DP AudioProfile = "a2dp"
+
+	// AudioProfileHeadset enables the microphone at a lower audio quality.
+	AudioProfileHeadset AudioProfile = "headset"
+
+	// AudioProfileOff keeps the device connected without an audio stream.
+	AudioProfileOff AudioProfile = "off"
+)
+
+// ParseAudioProfile validates an audio profile from configuration. An empty
+// value leaves the profile to the sound server.
+func ParseAudioProfile(value string) (AudioProfile, error) {
+	switch profile := AudioProfile(strings.ToLower(strings.TrimSpace(value))); profile {
+	case "", AudioProfileA2DP, AudioProfileHeadset, AudioProfileOff:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("unknown audio profile %q (want a2dp, headset or off)", value)
+	}
+}
+
+// audioCodecs lists the codecs the sound servers can select, in the
+// spelling PipeWire uses.
+var audioCodecs = map[string]AudioProfile{
+	"sbc":        AudioProfileA2DP,
+	"sbc_xq":     AudioProfileA2DP,
+	"aac":        AudioProfileA2DP,
+	"aptx":       AudioProfileA2DP,
+	"aptx_hd":    AudioProfileA2DP,
+	"aptx_ll":    AudioProfileA2DP,
+	"ldac":       AudioProfileA2DP,
+	"faststream": AudioProfileA2DP,
+	"opus_05":    AudioProfileA2DP,
+	"lc3":        AudioProfileA2DP,
+	"cvsd":       AudioProfileHeadset,
+	"msbc":       AudioProfileHeadset,
+}
+
+// ParseAudioCodec validates a codec name from configuration, such as aac or
+// ldac. An empty value leaves the codec to the sound server.
+func ParseAudioCodec(value string) (string, error) {
+	codec := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(value, "-", "_")))
+	if codec == "" {
+		return "", nil
+	}
+	if _, ok := audioCodecs[codec]; !ok {
+		return "", fmt.Errorf("unknown audio codec %q (want sbc, sbc_xq, aac, aptx, aptx_hd, aptx_ll, ldac, faststream, opus_05, lc3, cvsd or msbc)", value)
+	}
+	return codec, nil
+}
+
+// AudioSettings are the audio preferences applied when a device connects.
+// Zero fields leave the sound server's choice alone.
+type AudioSettings struct {
+	Profile AudioProfile
+	Codec   string
+
+	// Volume is the output volume in percent.
+	Volume *int
+}
+
+// NewAudioSettings parses the audio preferences of a device from
+// configuration, checking that the codec is available with the profile and
+// that the volume is a percentage.
+func NewAudioSettings(profile, codec string, volume *int) (AudioSettings, error) {
+	var settings AudioSettings
+	var err error
+	if settings.Profile, err = ParseAudioProfile(profile); err != nil {
+		return AudioSettings{}, err
+	}
+	if settings.Codec, err = ParseAudioCodec(codec); err != nil {
+		return AudioSettings{}, err
+	}
+	if settings.Codec != "" && settings.Profile != "" && audioCodecs[settings.Codec] != settings.Profile {
+		return AudioSettings{}, fmt.Errorf("codec %s is not available with the %s profile", settings.Codec, settings.Profile)
+	}
+	if volume != nil {
+		if *volume < 0 || *volume > 100 {
+			return AudioSettings{}, fmt.Errorf("volume %d is out of range (want 0 to 100)", *volume)
+		}
+		v := *volume
+		settings.Volume = &v
+	}
+	return settings, nil
+}
+
+// IsZero reports whether no audio preference is set.
+func (a AudioSettings) IsZero() bool {
+	return a.Profile == "" && a.Codec == "" && a.Volume == nil
+}
+
+// EffectiveProfile returns the profile to select: the configured one, or
+// the one the codec belongs to when only a codec is set.
+func (a AudioSettings) EffectiveProfile() AudioProfile {
+	if a.Profile != "" {
+		return a.Profile
+	}
+	return audioCodecs[a.Codec]
+}
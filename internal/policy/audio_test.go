@@ -0,0 +1,24 @@
+package policy
+
+import "testing"
+
+func TestNewAudioSettings(t *testing.T) {
+	volume := 70
+	settings, err := NewAudioSettings("", "LDAC", &volume)
+	if err != nil || settings.Codec != "ldac" || settings.EffectiveProfile() != AudioProfileA2DP {
+		t.Fatalf("unexpected settings %+v (%v)", settings, err)
+	}
+	if settings, _ := NewAudioSettings("", "msbc", nil); settings.EffectiveProfile() != AudioProfileHeadset {
+		t.Fatalf("expected msbc to select the headset profile, got %q", settings.EffectiveProfile())
+	}
+
+	for _, bad := range [][2]string{{"stereo", ""}, {"", "opus"}, {"headset", "aac"}, {"off", "sbc"}} {
+		if _, err := NewAudioSettings(bad[0], bad[1], nil); err == nil {
+			t.Errorf("expected an error for profile %q codec %q", bad[0], bad[1])
+		}
+	}
+	volume = 101
+	if _, err := NewAudioSettings("a2dp", "", &volume); err == nil {
+		t.Error("expected an error for a volume above 100")
+	}
+}
@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability is the input and output capability the pairing agent
+// advertises. It decides which pairing method BlueZ negotiates with a device.
+type Capability string
+
+const (
+	DisplayOnly     Capability = "DisplayOnly"
+	DisplayYesNo    Capability = "DisplayYesNo"
+	KeyboardOnly    Capability = "KeyboardOnly"
+	NoInputNoOutput Capability = "NoInputNoOutput"
+	KeyboardDisplay Capability = "KeyboardDisplay"
+)
+
+// ParseCapability validates a capability name, ignoring case. An empty value
+// selects KeyboardDisplay, which supports every pairing method.
+func ParseCapability(value string) (Capability, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return KeyboardDisplay, nil
+	}
+
+	for _, capability := range []Capability{DisplayOnly, DisplayYesNo, KeyboardOnly, NoInputNoOutput, KeyboardDisplay} {
+		if strings.EqualFold(trimmed, string(capability)) {
+			return capability, nil
+		}
+	}
+	return "", fmt.Errorf("unknown agent capability %q (want DisplayOnly, DisplayYesNo, KeyboardOnly, NoInputNoOutput or KeyboardDisplay)", value)
+}
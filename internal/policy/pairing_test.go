@@ -0,0 +1,15 @@
+package policy
+
+import "testing"
+
+func TestParseCapability(t *testing.T) {
+	if capability, err := ParseCapability(""); err != nil || capability != KeyboardDisplay {
+		t.Fatalf("ParseCapability(\"\") = %q, %v", capability, err)
+	}
+	if capability, err := ParseCapability("noinputnooutput"); err != nil || capability != NoInputNoOutput {
+		t.Fatalf("ParseCapability = %q, %v", capability, err)
+	}
+	if _, err := ParseCapability("telepathy"); err == nil {
+		t.Fatal("expected error for unknown capability")
+	}
+}
@@ -0,0 +1,186 @@
+// Package policy defines the values that steer how peared treats devices
+// and adapters, such as reconnect modes, time windows and audio presets, and
+// parses them from their configuration form. It depends on nothing else in
+// peared, so the configuration can be checked without loading the daemon,
+// the pairing agent or the recovery workflows.
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReconnectMode determines when the daemon tries to bring a dropped device
+// back.
+type ReconnectMode string
+
+const (
+	// ReconnectNever leaves the device alone.
+	ReconnectNever ReconnectMode = "never"
+
+	// ReconnectAlways retries regardless of whether the device was recently
+	// seen advertising.
+	ReconnectAlways ReconnectMode = "always"
+
+	// ReconnectWhenInRange only retries while the device is reported in range.
+	ReconnectWhenInRange ReconnectMode = "when-in-range"
+)
+
+// ParseReconnectMode validates a reconnect mode string from configuration. An
+// empty value maps to ReconnectNever.
+func ParseReconnectMode(value string) (ReconnectMode, error) {
+	switch mode := ReconnectMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return ReconnectNever, nil
+	case ReconnectNever, ReconnectAlways, ReconnectWhenInRange:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown reconnect policy %q (want always, when-in-range or never)", value)
+	}
+}
+
+// TimeWindow is a recurring daily time range, optionally limited to specific
+// weekdays. Windows whose end precedes their start wrap past midnight.
+type TimeWindow struct {
+	// Days selects the weekdays on which the window opens. A zero value means
+	// every day.
+	Days [7]bool
+
+	Start time.Duration
+	End   time.Duration
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseTimeWindow parses windows such as "08:00-18:00", "mon-fri 08:00-18:00"
+// or "sat,sun 22:00-02:00".
+func ParseTimeWindow(value string) (TimeWindow, error) {
+	fields := strings.Fields(strings.ToLower(value))
+
+	var window TimeWindow
+	var span string
+	switch len(fields) {
+	case 1:
+		span = fields[0]
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("time window %q: %w", value, err)
+		}
+		window.Days = days
+		span = fields[1]
+	default:
+		return TimeWindow{}, fmt.Errorf("time window %q: expected [days] HH:MM-HH:MM", value)
+	}
+
+	startText, endText, ok := strings.Cut(span, "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("time window %q: expected HH:MM-HH:MM", value)
+	}
+
+	start, err := parseClock(startText)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("time window %q: %w", value, err)
+	}
+
+	end, err := parseClock(endText)
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("time window %q: %w", value, err)
+	}
+
+	if start == end {
+		return TimeWindow{}, fmt.Errorf("time window %q: start and end are identical", value)
+	}
+
+	window.Start = start
+	window.End = end
+	return window, nil
+}
+
+func parseWeekdays(value string) ([7]bool, error) {
+	var days [7]bool
+
+	for _, part := range strings.Split(value, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		first, ok := weekdayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown weekday %q", from)
+		}
+
+		if !isRange {
+			days[first] = true
+			continue
+		}
+
+		last, ok := weekdayNames[to]
+		if !ok {
+			return days, fmt.Errorf("unknown weekday %q", to)
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	hoursText, minutesText, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	hours, err := strconv.Atoi(hoursText)
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", value)
+	}
+
+	minutes, err := strconv.Atoi(minutesText)
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", value)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	if w.Start < w.End {
+		return w.dayAllowed(t.Weekday()) && offset >= w.Start && offset < w.End
+	}
+
+	// The window wraps past midnight: the late part belongs to today's
+	// window, the early part to the one that opened yesterday.
+	if offset >= w.Start {
+		return w.dayAllowed(t.Weekday())
+	}
+	if offset < w.End {
+		return w.dayAllowed((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+func (w TimeWindow) dayAllowed(day time.Weekday) bool {
+	if w.Days == [7]bool{} {
+		return true
+	}
+	return w.Days[day]
+}
@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		at     time.Time
+		want   bool
+	}{
+		{"08:00-18:00", time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC), true},
+		{"08:00-18:00", time.Date(2024, 5, 6, 18, 0, 0, 0, time.UTC), false},
+		{"mon-fri 08:00-18:00", time.Date(2024, 5, 5, 9, 0, 0, 0, time.UTC), false}, // Sunday
+		{"sat,sun 22:00-02:00", time.Date(2024, 5, 5, 23, 0, 0, 0, time.UTC), true}, // Sunday late
+		{"sat,sun 22:00-02:00", time.Date(2024, 5, 6, 1, 0, 0, 0, time.UTC), true},  // Monday early, opened Sunday
+		{"sat,sun 22:00-02:00", time.Date(2024, 5, 7, 1, 0, 0, 0, time.UTC), false}, // Tuesday early
+		{"fri-mon 00:00-24:00", time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC), true}, // wraps the week
+	}
+
+	for _, tt := range tests {
+		window, err := ParseTimeWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseTimeWindow(%q): %v", tt.window, err)
+		}
+		if got := window.Contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s: want %v got %v", tt.window, tt.at.Format(time.RFC1123), tt.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "8-18", "mon 08:00", "funday 08:00-09:00", "10:00-10:00", "25:00-26:00"} {
+		if _, err := ParseTimeWindow(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseReconnectMode(t *testing.T) {
+	if mode, err := ParseReconnectMode(""); err != nil || mode != ReconnectNever {
+		t.Fatalf("expected empty mode to map to never, got %q (%v)", mode, err)
+	}
+	if mode, err := ParseReconnectMode("When-In-Range"); err != nil || mode != ReconnectWhenInRange {
+		t.Fatalf("unexpected mode %q (%v)", mode, err)
+	}
+	if _, err := ParseReconnectMode("sometimes"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
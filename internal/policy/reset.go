@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResetLevel is a step of an adapter reset. Levels are ordered from least to
+// most disruptive.
+type ResetLevel string
+
+const (
+	// ResetSoft power-cycles the adapter through BlueZ.
+	ResetSoft ResetLevel = "soft"
+	// ResetService restarts bluetooth.service.
+	ResetService ResetLevel = "service"
+	// ResetModule unloads and reloads the Bluetooth kernel modules.
+	ResetModule ResetLevel = "module"
+	// ResetUSB unbinds and rebinds the controller's USB device.
+	ResetUSB ResetLevel = "usb"
+)
+
+// ResetLevels lists every level in escalation order.
+var ResetLevels = []ResetLevel{ResetSoft, ResetService, ResetModule, ResetUSB}
+
+// ParseResetLevel converts a string such as "service" into a ResetLevel.
+func ParseResetLevel(value string) (ResetLevel, error) {
+	level := ResetLevel(strings.ToLower(strings.TrimSpace(value)))
+	for _, known := range ResetLevels {
+		if level == known {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown reset level %q (expected soft, service, module or usb)", value)
+}
+
+// ParseResetLevels parses a list of level names, rejecting duplicates.
+func ParseResetLevels(values []string) ([]ResetLevel, error) {
+	levels := make([]ResetLevel, 0, len(values))
+	seen := make(map[ResetLevel]bool, len(values))
+	for _, value := range values {
+		level, err := ParseResetLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		if seen[level] {
+			return nil, fmt.Errorf("reset level %q listed twice", level)
+		}
+		seen[level] = true
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
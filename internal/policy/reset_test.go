@@ -0,0 +1,22 @@
+package policy
+
+import "testing"
+
+func TestParseResetLevel(t *testing.T) {
+	if level, err := ParseResetLevel(" Module "); err != nil || level != ResetModule {
+		t.Fatalf("ParseResetLevel returned %q, %v", level, err)
+	}
+	if _, err := ParseResetLevel("nuclear"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
+
+func TestParseResetLevels(t *testing.T) {
+	levels, err := ParseResetLevels([]string{"soft", "usb"})
+	if err != nil || len(levels) != 2 || levels[1] != ResetUSB {
+		t.Fatalf("ParseResetLevels returned %v, %v", levels, err)
+	}
+	if _, err := ParseResetLevels([]string{"soft", "Soft"}); err == nil {
+		t.Fatal("expected error for duplicate level")
+	}
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// AdapterRule matches adapters by hardware and identity. Every non-empty field
+// must match. ID, Alias and Driver accept shell-style globs; all comparisons
+// are case-insensitive.
+type AdapterRule struct {
+	ID        string `json:"id,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Alias     string `json:"alias,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	VendorID  string `json:"vendor_id,omitempty"`
+	Driver    string `json:"driver,omitempty"`
+}
+
+// ParseAdapterRule builds a rule from the key/value form used in the
+// configuration file, e.g. {"alias": "Gaming*", "transport": "usb"}.
+func ParseAdapterRule(fields map[string]string) (AdapterRule, error) {
+	var rule AdapterRule
+	if len(fields) == 0 {
+		return rule, errors.New("empty adapter rule")
+	}
+
+	for key, value := range fields {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			return rule, fmt.Errorf("adapter rule field %q is empty", key)
+		}
+
+		switch strings.ToLower(key) {
+		case "id":
+			rule.ID = value
+		case "address":
+			rule.Address = value
+		case "alias":
+			rule.Alias = value
+		case "transport":
+			rule.Transport = value
+		case "vendor_id":
+			rule.VendorID = strings.TrimPrefix(value, "0x")
+		case "driver":
+			rule.Driver = value
+		default:
+			return rule, fmt.Errorf("unknown adapter rule field %q (expected id, address, alias, transport, vendor_id or driver)", key)
+		}
+	}
+
+	for _, pattern := range []string{rule.ID, rule.Alias, rule.Driver} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return rule, nil
+}
+
+// String renders the rule in its configuration form for explanations.
+func (r AdapterRule) String() string {
+	var parts []string
+	for _, field := range []struct{ key, value string }{
+		{"id", r.ID}, {"address", r.Address}, {"alias", r.Alias},
+		{"transport", r.Transport}, {"vendor_id", r.VendorID}, {"driver", r.Driver},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.key+"="+field.value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Tiebreak orders adapters that score the same.
+type Tiebreak string
+
+const (
+	// TiebreakUSB prefers USB adapters, then discovery order. It reproduces
+	// the historical SelectAdapter behaviour and is the default.
+	TiebreakUSB Tiebreak = "usb"
+	// TiebreakFirst keeps discovery order.
+	TiebreakFirst Tiebreak = "first"
+	// TiebreakPowered prefers adapters whose radio is on, then discovery
+	// order.
+	TiebreakPowered Tiebreak = "powered"
+	// TiebreakID orders by adapter ID.
+	TiebreakID Tiebreak = "id"
+)
+
+// Selection holds the rules that rank adapters: adapters matching earlier
+// Prefer rules win and adapters matching an Exclude rule are never chosen.
+type Selection struct {
+	Prefer   []AdapterRule
+	Exclude  []AdapterRule
+	Tiebreak Tiebreak
+}
+
+// ParseSelection parses the configuration form of the adapter selection
+// rules.
+func ParseSelection(prefer, exclude []map[string]string, tiebreak string) (Selection, error) {
+	var selection Selection
+
+	for i, fields := range prefer {
+		rule, err := ParseAdapterRule(fields)
+		if err != nil {
+			return Selection{}, fmt.Errorf("prefer[%d]: %w", i, err)
+		}
+		selection.Prefer = append(selection.Prefer, rule)
+	}
+
+	for i, fields := range exclude {
+		rule, err := ParseAdapterRule(fields)
+		if err != nil {
+			return Selection{}, fmt.Errorf("exclude[%d]: %w", i, err)
+		}
+		selection.Exclude = append(selection.Exclude, rule)
+	}
+
+	switch mode := Tiebreak(strings.ToLower(strings.TrimSpace(tiebreak))); mode {
+	case "":
+		selection.Tiebreak = TiebreakUSB
+	case TiebreakUSB, TiebreakFirst, TiebreakPowered, TiebreakID:
+		selection.Tiebreak = mode
+	default:
+		return Selection{}, fmt.Errorf("unknown tiebreak %q (expected usb, first, powered or id)", tiebreak)
+	}
+
+	return selection, nil
+}
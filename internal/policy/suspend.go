@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SuspendAction decides what happens to a connected device before suspend.
+type SuspendAction string
+
+const (
+	// SuspendLeave leaves the device connected and lets the controller drop
+	// it.
+	SuspendLeave SuspendAction = "leave"
+
+	// SuspendDisconnect disconnects the device cleanly before suspend.
+	SuspendDisconnect SuspendAction = "disconnect"
+)
+
+// ParseSuspendAction validates a suspend action from configuration. An empty
+// value maps to SuspendLeave.
+func ParseSuspendAction(value string) (SuspendAction, error) {
+	switch action := SuspendAction(strings.ToLower(strings.TrimSpace(value))); action {
+	case "":
+		return SuspendLeave, nil
+	case SuspendLeave, SuspendDisconnect:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown suspend action %q (want leave or disconnect)", value)
+	}
+}
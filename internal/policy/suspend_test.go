@@ -0,0 +1,15 @@
+package policy
+
+import "testing"
+
+func TestParseSuspendAction(t *testing.T) {
+	if action, err := ParseSuspendAction(""); err != nil || action != SuspendLeave {
+		t.Fatalf("ParseSuspendAction(\"\") = %q, %v", action, err)
+	}
+	if action, err := ParseSuspendAction(" Disconnect "); err != nil || action != SuspendDisconnect {
+		t.Fatalf("ParseSuspendAction = %q, %v", action, err)
+	}
+	if _, err := ParseSuspendAction("hibernate"); err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+}
@@ -0,0 +1,76 @@
+package recovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is one line in the recovery audit log.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Level   Level     `json:"level,omitempty"`
+	Attempt int       `json:"attempt,omitempty"`
+	Adapter string    `json:"adapter,omitempty"`
+	Address string    `json:"address,omitempty"`
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// AuditLog records recovery actions.
+type AuditLog interface {
+	Record(entry Entry) error
+}
+
+// DefaultAuditPath returns $XDG_STATE_HOME/peared/audit.log, falling back to
+// ~/.local/state when XDG_STATE_HOME is unset.
+func DefaultAuditPath() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "peared", "audit.log"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".local", "state", "peared", "audit.log"), nil
+}
+
+// FileAuditLog appends entries as JSON lines to a file.
+type FileAuditLog struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileAuditLog returns an audit log writing to path.
+func NewFileAuditLog(path string) *FileAuditLog {
+	return &FileAuditLog{Path: path}
+}
+
+// Record implements AuditLog.
+func (l *FileAuditLog) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o700); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package recovery
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileAuditLogAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "audit.log")
+	audit := NewFileAuditLog(path)
+
+	for i := 1; i <= 2; i++ {
+		if err := audit.Record(Entry{Time: time.Unix(0, 0).UTC(), Action: "reset", Level: LevelSoft, Attempt: i, Result: "failed"}); err != nil {
+			t.Fatalf("Record returned error: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 || entries[1].Attempt != 2 || entries[1].Level != LevelSoft {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat audit log: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestDefaultAuditPathUsesStateHome(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+
+	path, err := DefaultAuditPath()
+	if err != nil {
+		t.Fatalf("DefaultAuditPath returned error: %v", err)
+	}
+	if path != "/tmp/state/peared/audit.log" {
+		t.Fatalf("unexpected path: %q", path)
+	}
+}
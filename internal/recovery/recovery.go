@@ -0,0 +1,337 @@
+// Package recovery implements escalating reset workflows for Bluetooth
+// controllers: power-cycling the adapter, restarting bluetoothd, reloading
+// kernel modules and re-binding the USB device. Every actuator is an
+// interface so callers (and tests) decide what actually touches the system.
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/policy"
+)
+
+// Level is a reset step. Levels are ordered from least to most disruptive.
+type Level = policy.ResetLevel
+
+const (
+	LevelSoft    = policy.ResetSoft
+	LevelService = policy.ResetService
+	LevelModule  = policy.ResetModule
+	LevelUSB     = policy.ResetUSB
+)
+
+// Levels lists every level in escalation order.
+var Levels = policy.ResetLevels
+
+// LevelsUpTo returns the levels from soft up to and including max.
+func LevelsUpTo(max Level) []Level {
+	for i, level := range Levels {
+		if level == max {
+			return append([]Level(nil), Levels[:i+1]...)
+		}
+	}
+	return nil
+}
+
+// Target identifies the controller being reset.
+type Target struct {
+	// Adapter is the adapter ID (hci0, ...) used for power operations.
+	Adapter string
+
+	// Address is the controller MAC address. Health checks use it to find
+	// the adapter again after a reset renumbers it.
+	Address string
+
+	// USBDevice is the sysfs USB device name (e.g. 1-4) for LevelUSB.
+	USBDevice string
+
+	// Modules lists the kernel modules reloaded by LevelModule.
+	Modules []string
+}
+
+// PowerSwitch turns an adapter's radio on or off.
+type PowerSwitch interface {
+	SetPowered(ctx context.Context, adapter string, on bool) error
+}
+
+// ServiceManager restarts system services.
+type ServiceManager interface {
+	RestartUnit(ctx context.Context, unit string) error
+}
+
+// ModuleLoader unloads and loads kernel modules.
+type ModuleLoader interface {
+	Unload(ctx context.Context, modules ...string) error
+	Load(ctx context.Context, modules ...string) error
+}
+
+// USBBinder detaches and re-attaches a USB device from its driver.
+type USBBinder interface {
+	Unbind(ctx context.Context, device string) error
+	Bind(ctx context.Context, device string) error
+}
+
+// HealthCheck reports whether the target works after a reset.
+type HealthCheck func(ctx context.Context, target Target) error
+
+// ServiceUnit is the systemd unit restarted by LevelService.
+const ServiceUnit = "bluetooth.service"
+
+// ErrNotRecovered is returned when every permitted level ran without the
+// health check passing.
+var ErrNotRecovered = errors.New("adapter did not recover")
+
+// Options configures a Resetter. Actuators left nil make their level fail
+// with an explanatory error, which counts as a failed attempt.
+type Options struct {
+	Power    PowerSwitch
+	Services ServiceManager
+	Modules  ModuleLoader
+	USB      USBBinder
+	Health   HealthCheck
+	Audit    AuditLog
+
+	// Attempts is how often each level is tried before escalating. Defaults
+	// to 2.
+	Attempts int
+
+	// InitialBackoff and MaxBackoff bound the exponential delay between
+	// attempts. Defaults are 1s and 30s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Settle is how long to wait after an action before checking health.
+	// Defaults to 2s.
+	Settle time.Duration
+
+	// Sleep waits for d or until ctx is done. Tests replace it to avoid real
+	// delays.
+	Sleep func(ctx context.Context, d time.Duration) error
+
+	// Clock provides timestamps for audit entries.
+	Clock func() time.Time
+}
+
+// Resetter runs escalating reset workflows.
+type Resetter struct {
+	opts Options
+}
+
+// NewResetter returns a Resetter with defaults applied to opts.
+func NewResetter(opts Options) *Resetter {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 2
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = opts.InitialBackoff
+	}
+	if opts.Settle <= 0 {
+		opts.Settle = 2 * time.Second
+	}
+	if opts.Sleep == nil {
+		opts.Sleep = sleep
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	return &Resetter{opts: opts}
+}
+
+// Step records one attempt of one level.
+type Step struct {
+	Level   Level  `json:"level"`
+	Attempt int    `json:"attempt"`
+	Error   string `json:"error,omitempty"`
+	Healthy bool   `json:"healthy"`
+}
+
+// Result summarises a reset run.
+type Result struct {
+	Recovered bool   `json:"recovered"`
+	Level     Level  `json:"level,omitempty"`
+	Steps     []Step `json:"steps"`
+}
+
+// Reset escalates through the levels up to max until the health check
+// passes. Each level is retried with exponential backoff before moving on.
+func (r *Resetter) Reset(ctx context.Context, target Target, max Level) (Result, error) {
+	levels := LevelsUpTo(max)
+	if len(levels) == 0 {
+		return Result{}, fmt.Errorf("unknown reset level %q", max)
+	}
+
+	return r.ResetLevels(ctx, target, levels)
+}
+
+// ResetLevels is like Reset but runs exactly the given levels, in order. It
+// lets callers skip levels that are pointless or unsafe on their hardware.
+func (r *Resetter) ResetLevels(ctx context.Context, target Target, levels []Level) (Result, error) {
+	if ctx == nil {
+		return Result{}, errors.New("nil context passed to Reset")
+	}
+	if len(levels) == 0 {
+		return Result{}, errors.New("no reset levels given")
+	}
+
+	var result Result
+	delay := r.opts.InitialBackoff
+	for _, level := range levels {
+		for attempt := 1; attempt <= r.opts.Attempts; attempt++ {
+			step := Step{Level: level, Attempt: attempt}
+
+			err := r.perform(ctx, level, target)
+			if err == nil {
+				if err = r.opts.Sleep(ctx, r.opts.Settle); err == nil {
+					err = r.checkHealth(ctx, target)
+					step.Healthy = err == nil
+				}
+			}
+			if err != nil {
+				step.Error = err.Error()
+			}
+
+			result.Steps = append(result.Steps, step)
+			r.record(level, attempt, target, err)
+
+			if step.Healthy {
+				result.Recovered = true
+				result.Level = level
+				return result, nil
+			}
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+
+			if err := r.opts.Sleep(ctx, delay); err != nil {
+				return result, err
+			}
+			delay *= 2
+			if delay > r.opts.MaxBackoff {
+				delay = r.opts.MaxBackoff
+			}
+		}
+	}
+
+	return result, ErrNotRecovered
+}
+
+func (r *Resetter) perform(ctx context.Context, level Level, target Target) error {
+	switch level {
+	case LevelSoft:
+		if r.opts.Power == nil {
+			return errors.New("no power switch configured")
+		}
+		if err := r.opts.Power.SetPowered(ctx, target.Adapter, false); err != nil {
+			return fmt.Errorf("power off %s: %w", target.Adapter, err)
+		}
+		if err := r.opts.Power.SetPowered(ctx, target.Adapter, true); err != nil {
+			return fmt.Errorf("power on %s: %w", target.Adapter, err)
+		}
+		return nil
+
+	case LevelService:
+		if r.opts.Services == nil {
+			return errors.New("no service manager configured")
+		}
+		if err := r.opts.Services.RestartUnit(ctx, ServiceUnit); err != nil {
+			return fmt.Errorf("restart %s: %w", ServiceUnit, err)
+		}
+
+	case LevelModule:
+		if r.opts.Modules == nil {
+			return errors.New("no module loader configured")
+		}
+		if len(target.Modules) == 0 {
+			return errors.New("no kernel modules known for this adapter")
+		}
+		if err := r.opts.Modules.Unload(ctx, target.Modules...); err != nil {
+			return fmt.Errorf("unload %s: %w", strings.Join(target.Modules, ", "), err)
+		}
+		if err := r.opts.Modules.Load(ctx, target.Modules...); err != nil {
+			return fmt.Errorf("load %s: %w", strings.Join(target.Modules, ", "), err)
+		}
+
+	case LevelUSB:
+		if r.opts.USB == nil {
+			return errors.New("no USB binder configured")
+		}
+		if target.USBDevice == "" {
+			return errors.New("adapter is not attached over USB")
+		}
+		if err := r.opts.USB.Unbind(ctx, target.USBDevice); err != nil {
+			return fmt.Errorf("unbind USB device %s: %w", target.USBDevice, err)
+		}
+		if err := r.opts.USB.Bind(ctx, target.USBDevice); err != nil {
+			return fmt.Errorf("bind USB device %s: %w", target.USBDevice, err)
+		}
+
+	default:
+		return fmt.Errorf("unknown reset level %q", level)
+	}
+
+	// Heavier resets bring the adapter back unpowered unless BlueZ is set to
+	// auto-enable it, so switch it on before judging its health.
+	if r.opts.Power != nil {
+		if err := r.opts.Sleep(ctx, r.opts.Settle); err != nil {
+			return err
+		}
+		_ = r.opts.Power.SetPowered(ctx, target.Adapter, true)
+	}
+
+	return nil
+}
+
+func (r *Resetter) checkHealth(ctx context.Context, target Target) error {
+	if r.opts.Health == nil {
+		return nil
+	}
+	if err := r.opts.Health(ctx, target); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	return nil
+}
+
+func (r *Resetter) record(level Level, attempt int, target Target, err error) {
+	if r.opts.Audit == nil {
+		return
+	}
+
+	entry := Entry{
+		Time:    r.opts.Clock(),
+		Action:  "reset",
+		Level:   level,
+		Attempt: attempt,
+		Adapter: target.Adapter,
+		Address: target.Address,
+		Result:  "recovered",
+	}
+	if err != nil {
+		entry.Result = "failed"
+		entry.Error = err.Error()
+	}
+
+	// Auditing must never block recovery.
+	_ = r.opts.Audit.Record(entry)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder implements every actuator and records the calls it receives.
+type recorder struct {
+	calls []string
+	fail  map[string]bool
+}
+
+func (r *recorder) do(call string) error {
+	r.calls = append(r.calls, call)
+	if r.fail[call] {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (r *recorder) SetPowered(_ context.Context, adapter string, on bool) error {
+	return r.do(fmt.Sprintf("power %s %v", adapter, on))
+}
+
+func (r *recorder) RestartUnit(_ context.Context, unit string) error {
+	return r.do("restart " + unit)
+}
+
+func (r *recorder) Unload(_ context.Context, modules ...string) error {
+	return r.do("unload " + strings.Join(modules, ","))
+}
+
+func (r *recorder) Load(_ context.Context, modules ...string) error {
+	return r.do("load " + strings.Join(modules, ","))
+}
+
+func (r *recorder) Unbind(_ context.Context, device string) error {
+	return r.do("unbind " + device)
+}
+
+func (r *recorder) Bind(_ context.Context, device string) error {
+	return r.do("bind " + device)
+}
+
+type memoryAudit struct {
+	entries []Entry
+}
+
+func (m *memoryAudit) Record(entry Entry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func newTestResetter(rec *recorder, audit *memoryAudit, health HealthCheck, sleeps *[]time.Duration) *Resetter {
+	return NewResetter(Options{
+		Power:          rec,
+		Services:       rec,
+		Modules:        rec,
+		USB:            rec,
+		Health:         health,
+		Audit:          audit,
+		InitialBackoff: time.Second,
+		MaxBackoff:     3 * time.Second,
+		Settle:         time.Millisecond,
+		Sleep: func(_ context.Context, d time.Duration) error {
+			if d > time.Millisecond {
+				*sleeps = append(*sleeps, d)
+			}
+			return nil
+		},
+		Clock: func() time.Time { return time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC) },
+	})
+}
+
+var testTarget = Target{Adapter: "hci0", Address: "00:11:22:33:44:55", USBDevice: "1-4", Modules: []string{"btusb", "btintel"}}
+
+func TestResetStopsAtFirstHealthyLevel(t *testing.T) {
+	rec := &recorder{}
+	audit := &memoryAudit{}
+	var sleeps []time.Duration
+
+	checks := 0
+	health := func(context.Context, Target) error {
+		checks++
+		if checks < 3 {
+			return errors.New("adapter missing")
+		}
+		return nil
+	}
+
+	result, err := newTestResetter(rec, audit, health, &sleeps).Reset(context.Background(), testTarget, LevelUSB)
+	if err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+
+	if !result.Recovered || result.Level != LevelService {
+		t.Fatalf("expected recovery at service level, got %+v", result)
+	}
+	if len(result.Steps) != 3 {
+		t.Fatalf("expected three attempts, got %+v", result.Steps)
+	}
+
+	want := []string{
+		"power hci0 false", "power hci0 true",
+		"power hci0 false", "power hci0 true",
+		"restart bluetooth.service", "power hci0 true",
+	}
+	if strings.Join(rec.calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected calls:\n%v\nwant\n%v", rec.calls, want)
+	}
+
+	if fmt.Sprint(sleeps) != "[1s 2s]" {
+		t.Fatalf("unexpected backoff: %v", sleeps)
+	}
+
+	if len(audit.entries) != 3 || audit.entries[0].Result != "failed" || audit.entries[2].Result != "recovered" {
+		t.Fatalf("unexpected audit entries: %+v", audit.entries)
+	}
+	if audit.entries[2].Level != LevelService || audit.entries[2].Address != testTarget.Address {
+		t.Fatalf("unexpected final audit entry: %+v", audit.entries[2])
+	}
+}
+
+func TestResetEscalatesThroughEveryLevel(t *testing.T) {
+	rec := &recorder{}
+	audit := &memoryAudit{}
+	var sleeps []time.Duration
+	unhealthy := func(context.Context, Target) error { return errors.New("still wedged") }
+
+	result, err := newTestResetter(rec, audit, unhealthy, &sleeps).Reset(context.Background(), testTarget, LevelUSB)
+	if !errors.Is(err, ErrNotRecovered) {
+		t.Fatalf("expected ErrNotRecovered, got %v", err)
+	}
+	if result.Recovered || len(result.Steps) != 8 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	joined := strings.Join(rec.calls, "|")
+	for _, want := range []string{"unload btusb,btintel", "load btusb,btintel", "unbind 1-4", "bind 1-4"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q in calls %v", want, rec.calls)
+		}
+	}
+
+	// Backoff doubles until it reaches the cap.
+	if sleeps[len(sleeps)-1] != 3*time.Second {
+		t.Fatalf("expected backoff capped at 3s, got %v", sleeps)
+	}
+}
+
+func TestResetHonoursMaxLevel(t *testing.T) {
+	rec := &recorder{fail: map[string]bool{"power hci0 false": true}}
+	audit := &memoryAudit{}
+	var sleeps []time.Duration
+
+	result, err := newTestResetter(rec, audit, nil, &sleeps).Reset(context.Background(), testTarget, LevelSoft)
+	if !errors.Is(err, ErrNotRecovered) {
+		t.Fatalf("expected ErrNotRecovered, got %v", err)
+	}
+
+	for _, step := range result.Steps {
+		if step.Level != LevelSoft {
+			t.Fatalf("unexpected escalation: %+v", result.Steps)
+		}
+		if !strings.Contains(step.Error, "power off hci0") {
+			t.Fatalf("unexpected step error: %q", step.Error)
+		}
+	}
+}
+
+func TestResetSkipsUnavailableLevels(t *testing.T) {
+	rec := &recorder{}
+	resetter := NewResetter(Options{
+		Power:  rec,
+		USB:    rec,
+		Health: func(context.Context, Target) error { return errors.New("down") },
+		Sleep:  func(context.Context, time.Duration) error { return nil },
+	})
+
+	target := testTarget
+	target.USBDevice = ""
+	result, _ := resetter.Reset(context.Background(), target, LevelUSB)
+
+	var errs []string
+	for _, step := range result.Steps {
+		if step.Level != LevelSoft {
+			errs = append(errs, step.Error)
+		}
+	}
+	joined := strings.Join(errs, "|")
+	for _, want := range []string{"no service manager configured", "no module loader configured", "not attached over USB"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q in %v", want, errs)
+		}
+	}
+}
+
+func TestLevelsUpTo(t *testing.T) {
+	if got := LevelsUpTo(LevelService); len(got) != 2 || got[1] != LevelService {
+		t.Fatalf("unexpected levels: %v", got)
+	}
+}
+
+func TestResetLevelsRunsOnlyGivenLevels(t *testing.T) {
+	rec := &recorder{}
+	audit := &memoryAudit{}
+	var sleeps []time.Duration
+	down := func(context.Context, Target) error { return errors.New("down") }
+
+	result, err := newTestResetter(rec, audit, down, &sleeps).ResetLevels(context.Background(), testTarget, []Level{LevelSoft, LevelUSB})
+	if !errors.Is(err, ErrNotRecovered) {
+		t.Fatalf("expected ErrNotRecovered, got %v", err)
+	}
+
+	for _, step := range result.Steps {
+		if step.Level != LevelSoft && step.Level != LevelUSB {
+			t.Fatalf("unexpected level in %+v", result.Steps)
+		}
+	}
+	for _, call := range rec.calls {
+		if strings.HasPrefix(call, "restart") || strings.HasPrefix(call, "unload") {
+			t.Fatalf("skipped level ran: %v", rec.calls)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type commandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func defaultCommandRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+func runCommand(ctx context.Context, run commandRunner, name string, args ...string) error {
+	if run == nil {
+		run = defaultCommandRunner
+	}
+
+	out, err := run(ctx, name, args...)
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(out)); trimmed != "" {
+			return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, trimmed)
+		}
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// SystemdServices restarts units through systemd's D-Bus API using busctl.
+type SystemdServices struct {
+	run commandRunner
+}
+
+// NewSystemdServices returns a ServiceManager backed by systemd.
+func NewSystemdServices() *SystemdServices {
+	return &SystemdServices{}
+}
+
+// RestartUnit implements ServiceManager. The call waits for systemd to
+// queue the job; the health check that follows covers the restart itself.
+func (s *SystemdServices) RestartUnit(ctx context.Context, unit string) error {
+	return runCommand(ctx, s.run, "busctl", "--system", "call",
+		"org.freedesktop.systemd1", "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager",
+		"RestartUnit", "ss", unit, "replace")
+}
+
+// Modprobe loads and unloads kernel modules with modprobe.
+type Modprobe struct {
+	run commandRunner
+}
+
+// NewModprobe returns a ModuleLoader backed by modprobe.
+func NewModprobe() *Modprobe {
+	return &Modprobe{}
+}
+
+// Unload implements ModuleLoader.
+func (m *Modprobe) Unload(ctx context.Context, modules ...string) error {
+	return runCommand(ctx, m.run, "modprobe", append([]string{"-r"}, modules...)...)
+}
+
+// Load implements ModuleLoader.
+func (m *Modprobe) Load(ctx context.Context, modules ...string) error {
+	return runCommand(ctx, m.run, "modprobe", append([]string{"-a"}, modules...)...)
+}
+
+// DefaultModules returns the kernel modules to reload for an adapter bound to
+// driver. USB controllers from Intel, Realtek and Broadcom need their vendor
+// helper module reloaded alongside btusb to re-run firmware setup.
+func DefaultModules(driver, vendorID string) []string {
+	if driver == "" {
+		return nil
+	}
+
+	modules := []string{driver}
+	if driver == "btusb" {
+		switch strings.ToLower(vendorID) {
+		case "8087":
+			modules = append(modules, "btintel")
+		case "0bda":
+			modules = append(modules, "btrtl")
+		case "0a5c":
+			modules = append(modules, "btbcm")
+		}
+	}
+	return modules
+}
+
+const defaultUSBDriverPath = "/sys/bus/usb/drivers/usb"
+
+// SysfsUSB unbinds and binds USB devices by writing to the USB core driver's
+// sysfs attributes.
+type SysfsUSB struct {
+	driverPath string
+}
+
+// NewSysfsUSB returns a USBBinder operating on the driver directory at path.
+// A zero path uses /sys/bus/usb/drivers/usb.
+func NewSysfsUSB(path string) *SysfsUSB {
+	if path == "" {
+		path = defaultUSBDriverPath
+	}
+	return &SysfsUSB{driverPath: path}
+}
+
+// Unbind implements USBBinder.
+func (u *SysfsUSB) Unbind(_ context.Context, device string) error {
+	return u.write("unbind", device)
+}
+
+// Bind implements USBBinder.
+func (u *SysfsUSB) Bind(_ context.Context, device string) error {
+	return u.write("bind", device)
+}
+
+func (u *SysfsUSB) write(attribute, device string) error {
+	if device == "" {
+		return errors.New("USB device name required")
+	}
+
+	path := filepath.Join(u.driverPath, attribute)
+	if err := os.WriteFile(path, []byte(device), 0o200); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// USBDevice resolves the USB device name (e.g. 1-4) backing the adapter at
+// adapterPath, such as /sys/class/bluetooth/hci0. Adapters that are not
+// attached over USB return an empty name.
+func USBDevice(adapterPath string) (string, error) {
+	target, err := filepath.EvalSymlinks(filepath.Join(adapterPath, "device"))
+	if err != nil {
+		return "", fmt.Errorf("resolve adapter device: %w", err)
+	}
+
+	// The adapter links to a USB interface such as .../1-4/1-4:1.0; the
+	// device that can be rebound is its parent.
+	name := filepath.Base(target)
+	if !strings.Contains(name, ":") {
+		return "", nil
+	}
+
+	parent := filepath.Base(filepath.Dir(target))
+	if !strings.HasPrefix(name, parent+":") {
+		return "", nil
+	}
+
+	return parent, nil
+}
@@ -0,0 +1,126 @@
+package recovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSystemdServicesRestartUnit(t *testing.T) {
+	var got []string
+	services := &SystemdServices{run: func(_ context.Context, name string, args ...string) ([]byte, error) {
+		got = append([]string{name}, args...)
+		return nil, nil
+	}}
+
+	if err := services.RestartUnit(context.Background(), ServiceUnit); err != nil {
+		t.Fatalf("RestartUnit returned error: %v", err)
+	}
+
+	want := "busctl --system call org.freedesktop.systemd1 /org/freedesktop/systemd1 org.freedesktop.systemd1.Manager RestartUnit ss bluetooth.service replace"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected command: %q", strings.Join(got, " "))
+	}
+}
+
+func TestModprobeCommands(t *testing.T) {
+	var got []string
+	loader := &Modprobe{run: func(_ context.Context, name string, args ...string) ([]byte, error) {
+		got = append(got, name+" "+strings.Join(args, " "))
+		return nil, nil
+	}}
+
+	ctx := context.Background()
+	if err := loader.Unload(ctx, "btusb", "btintel"); err != nil {
+		t.Fatalf("Unload returned error: %v", err)
+	}
+	if err := loader.Load(ctx, "btusb", "btintel"); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if strings.Join(got, "|") != "modprobe -r btusb btintel|modprobe -a btusb btintel" {
+		t.Fatalf("unexpected commands: %v", got)
+	}
+}
+
+func TestDefaultModules(t *testing.T) {
+	if got := DefaultModules("btusb", "8087"); strings.Join(got, ",") != "btusb,btintel" {
+		t.Errorf("unexpected Intel modules: %v", got)
+	}
+	if got := DefaultModules("hci_uart", ""); strings.Join(got, ",") != "hci_uart" {
+		t.Errorf("unexpected UART modules: %v", got)
+	}
+	if got := DefaultModules("", "8087"); got != nil {
+		t.Errorf("expected no modules without a driver, got %v", got)
+	}
+}
+
+func TestSysfsUSBWritesDeviceName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"bind", "unbind"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	binder := NewSysfsUSB(dir)
+	ctx := context.Background()
+	if err := binder.Unbind(ctx, "1-4"); err != nil {
+		t.Fatalf("Unbind returned error: %v", err)
+	}
+	if err := binder.Bind(ctx, "1-4"); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+
+	for _, name := range []string{"bind", "unbind"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(data) != "1-4" {
+			t.Errorf("unexpected %s content: %q", name, data)
+		}
+	}
+}
+
+func TestUSBDeviceResolvesParentOfInterface(t *testing.T) {
+	dir := t.TempDir()
+
+	iface := filepath.Join(dir, "devices", "pci0000:00", "usb1", "1-4", "1-4:1.0")
+	if err := os.MkdirAll(iface, 0o755); err != nil {
+		t.Fatalf("failed to create interface dir: %v", err)
+	}
+	adapter := filepath.Join(dir, "class", "hci0")
+	if err := os.MkdirAll(adapter, 0o755); err != nil {
+		t.Fatalf("failed to create adapter dir: %v", err)
+	}
+	if err := os.Symlink(iface, filepath.Join(adapter, "device")); err != nil {
+		t.Fatalf("failed to link device: %v", err)
+	}
+
+	device, err := USBDevice(adapter)
+	if err != nil {
+		t.Fatalf("USBDevice returned error: %v", err)
+	}
+	if device != "1-4" {
+		t.Fatalf("expected 1-4, got %q", device)
+	}
+
+	serial := filepath.Join(dir, "devices", "serial0", "serial0-0")
+	if err := os.MkdirAll(serial, 0o755); err != nil {
+		t.Fatalf("failed to create serial dir: %v", err)
+	}
+	uart := filepath.Join(dir, "class", "hci1")
+	if err := os.MkdirAll(uart, 0o755); err != nil {
+		t.Fatalf("failed to create adapter dir: %v", err)
+	}
+	if err := os.Symlink(serial, filepath.Join(uart, "device")); err != nil {
+		t.Fatalf("failed to link device: %v", err)
+	}
+
+	if device, err := USBDevice(uart); err != nil || device != "" {
+		t.Fatalf("expected no USB device for UART adapter, got %q, %v", device, err)
+	}
+}
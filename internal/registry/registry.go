@@ -0,0 +1,191 @@
+// Package registry keeps peared's record of the devices it knows about, so
+// the CLI can name and describe devices that no adapter currently lists.
+// Entries describe devices only; pairing keys stay with BlueZ.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+// fileVersion is written to the registry file so later formats can tell it
+// apart.
+const fileVersion = 1
+
+// Device is a known device.
+type Device struct {
+	Address string `json:"address"`
+
+	// AddressType is public or random for LE devices.
+	AddressType string `json:"address_type,omitempty"`
+
+	Name  string `json:"name,omitempty"`
+	Alias string `json:"alias,omitempty"`
+
+	Class      uint32   `json:"class,omitempty"`
+	Appearance uint16   `json:"appearance,omitempty"`
+	Services   []string `json:"services,omitempty"`
+
+	// Adapters are the addresses of the adapters that know the device.
+	Adapters []string `json:"adapters,omitempty"`
+
+	Trusted bool `json:"trusted,omitempty"`
+	Blocked bool `json:"blocked,omitempty"`
+
+	// LinkKey and LongTermKey record whether a BR/EDR or LE bond exists,
+	// never the key.
+	LinkKey     bool `json:"link_key,omitempty"`
+	LongTermKey bool `json:"long_term_key,omitempty"`
+
+	LastSeen time.Time `json:"last_seen"`
+
+	// Source names where the entry came from, such as "bluez".
+	Source string `json:"source"`
+}
+
+// Registry is the set of known devices stored in one file.
+type Registry struct {
+	path    string
+	devices map[string]Device
+}
+
+type file struct {
+	Version int      `json:"version"`
+	Devices []Device `json:"devices"`
+}
+
+// DefaultPath returns $XDG_STATE_HOME/peared/devices.json, falling back to
+// ~/.local/state when XDG_STATE_HOME is unset.
+func DefaultPath() (string, error) {
+	if os.Getenv("XDG_STATE_HOME") != "" {
+		return PathFor(""), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+
+	return PathFor(home), nil
+}
+
+// PathFor returns the registry path DefaultPath resolves for the user whose
+// home directory is home. Commands run through sudo use it to find the
+// registry of the user who ran them.
+func PathFor(home string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "peared", "devices.json")
+}
+
+// Open loads the registry stored at path. A missing file is an empty
+// registry; Save creates it. An empty path resolves to DefaultPath.
+func Open(path string) (*Registry, error) {
+	if path == "" {
+		resolved, err := DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = resolved
+	}
+
+	r := &Registry{path: path, devices: make(map[string]Device)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read device registry: %w", err)
+	}
+
+	var stored file
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parse device registry %s: %w", path, err)
+	}
+	if stored.Version != fileVersion {
+		return nil, fmt.Errorf("device registry %s has unsupported version %d", path, stored.Version)
+	}
+	for _, device := range stored.Devices {
+		device.Address = daemon.NormalizeAddress(device.Address)
+		r.devices[device.Address] = device
+	}
+	return r, nil
+}
+
+// Path returns the file the registry is stored in.
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Devices returns the known devices sorted by address.
+func (r *Registry) Devices() []Device {
+	devices := make([]Device, 0, len(r.devices))
+	for _, device := range r.devices {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices
+}
+
+// Device returns the entry for address.
+func (r *Registry) Device(address string) (Device, bool) {
+	device, ok := r.devices[daemon.NormalizeAddress(address)]
+	return device, ok
+}
+
+// Put adds device or replaces the entry with its address, reporting
+// whether it was new.
+func (r *Registry) Put(device Device) bool {
+	device.Address = daemon.NormalizeAddress(device.Address)
+	_, existed := r.devices[device.Address]
+	r.devices[device.Address] = device
+	return !existed
+}
+
+// Save writes the registry to its file, replacing it atomically. The file
+// is private to the user.
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(file{Version: fileVersion, Devices: r.Devices()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode device registry: %w", err)
+	}
+	data = append(data, '\n')
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create registry directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".devices-*")
+	if err != nil {
+		return fmt.Errorf("write device registry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write device registry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write device registry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write device registry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("write device registry: %w", err)
+	}
+	return nil
+}
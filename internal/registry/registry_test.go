@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "devices.json")
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open missing file: %v", err)
+	}
+	if len(r.Devices()) != 0 {
+		t.Fatalf("expected an empty registry, got %v", r.Devices())
+	}
+
+	seen := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	if !r.Put(Device{Address: "aa:bb:cc:dd:ee:ff", Name: "WH-1000XM4", Class: 0x240404, LinkKey: true, LastSeen: seen, Source: "bluez"}) {
+		t.Fatal("expected a new entry")
+	}
+	r.Put(Device{Address: "11:22:33:44:55:66", Name: "Kitchen Speaker", Source: "bluez"})
+	if r.Put(Device{Address: "AA:BB:CC:DD:EE:FF", Name: "WH-1000XM4", Alias: "Cans", LinkKey: true, LastSeen: seen, Source: "bluez"}) {
+		t.Fatal("expected the entry to be replaced")
+	}
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private registry file, got %v (%v)", info, err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	devices := reopened.Devices()
+	if len(devices) != 2 || devices[0].Address != "11:22:33:44:55:66" {
+		t.Fatalf("unexpected devices %+v", devices)
+	}
+	device, ok := reopened.Device("aa:bb:cc:dd:ee:ff")
+	if !ok || device.Alias != "Cans" || device.Class != 0 || !device.LinkKey || !device.LastSeen.Equal(seen) {
+		t.Fatalf("unexpected device %+v", device)
+	}
+}
+
+func TestOpenRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	if err := os.WriteFile(path, []byte(`{"version": 7, "devices": []}`), 0o600); err != nil {
+		t.Fatalf("write registry: %v", err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "unsupported version 7") {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+}
+
+func TestPathForMatchesDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+
+	want := filepath.Join(home, ".local", "state", "peared", "devices.json")
+	if got, err := DefaultPath(); err != nil || got != want {
+		t.Fatalf("DefaultPath = %q, %v; want %q", got, err, want)
+	}
+	if got := PathFor(home); got != want {
+		t.Fatalf("PathFor = %q, want %q", got, want)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "/srv/state")
+	if got := PathFor(home); got != "/srv/state/peared/devices.json" {
+		t.Fatalf("PathFor ignored XDG_STATE_HOME: %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package term
+
+import "bufio"
+
+// KeyCode identifies a key decoded by ReadKey.
+type KeyCode int
+
+const (
+	// KeyRune is a character, control characters and a lone Escape
+	// included; Key.Rune holds it.
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyDelete
+
+	// KeyUnknown is an escape sequence ReadKey does not decode, such as
+	// a function key or a modified arrow.
+	KeyUnknown
+)
+
+// Key is one key press.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// ReadKey reads one key from a terminal in raw mode, decoding the CSI and
+// SS3 escape sequences terminals send for arrows, Home, End and Delete.
+func ReadKey(reader *bufio.Reader) (Key, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return Key{}, err
+	}
+	// A lone Escape arrives without anything buffered behind it.
+	if r != 0x1b || reader.Buffered() == 0 {
+		return Key{Code: KeyRune, Rune: r}, nil
+	}
+
+	intro, err := reader.ReadByte()
+	if err != nil {
+		return Key{}, err
+	}
+	if intro != '[' && intro != 'O' {
+		return Key{Code: KeyUnknown}, nil
+	}
+
+	var seq []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return Key{Code: KeyUp}, nil
+	case "B":
+		return Key{Code: KeyDown}, nil
+	case "C":
+		return Key{Code: KeyRight}, nil
+	case "D":
+		return Key{Code: KeyLeft}, nil
+	case "H", "1~", "7~":
+		return Key{Code: KeyHome}, nil
+	case "F", "4~", "8~":
+		return Key{Code: KeyEnd}, nil
+	case "3~":
+		return Key{Code: KeyDelete}, nil
+	default:
+		return Key{Code: KeyUnknown}, nil
+	}
+}
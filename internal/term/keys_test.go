@@ -0,0 +1,25 @@
+package term
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadKeyDecodesSequences(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("\x1b[A\x1b[B\x1bOH\x1b[4~j\x1b[1;5C\x1b[3~\x1bOD\x1b"))
+
+	want := []Key{
+		{Code: KeyUp}, {Code: KeyDown}, {Code: KeyHome}, {Code: KeyEnd}, {Code: KeyRune, Rune: 'j'},
+		{Code: KeyUnknown}, {Code: KeyDelete}, {Code: KeyLeft}, {Code: KeyRune, Rune: 0x1b},
+	}
+	for _, w := range want {
+		got, err := ReadKey(reader)
+		if err != nil {
+			t.Fatalf("ReadKey returned error: %v", err)
+		}
+		if got != w {
+			t.Fatalf("got %+v, want %+v", got, w)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package term switches terminals in and out of raw mode, reports their size
+// and decodes the keys they send, which is all the interactive parts of the
+// CLI need from them.
+package term
+
+import "os"
+
+// IsDumb reports whether $TERM names a terminal that cannot move the cursor
+// or clear the screen, so full-screen output would come out garbled.
+func IsDumb() bool {
+	switch os.Getenv("TERM") {
+	case "", "dumb":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package term
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("terminal control is only supported on Linux")
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(*os.File) bool {
+	return false
+}
+
+// MakeRaw is not supported outside Linux.
+func MakeRaw(*os.File) (func() error, error) {
+	return nil, errUnsupported
+}
+
+// Size is not supported outside Linux.
+func Size(*os.File) (int, int, error) {
+	return 0, 0, errUnsupported
+}
+
+// NotifyResize does nothing outside Linux.
+func NotifyResize(chan<- os.Signal) {}
@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/peared/peared/internal/daemon"
+)
+
+const helpLine = "↑/↓ select  c connect  d disconnect  p pair  t trust  x remove  o power  s scan  r refresh  q quit"
+
+// row is a selectable line: an adapter or a device.
+type row struct {
+	adapter *daemon.Adapter
+	device  *Device
+}
+
+func (r row) key() string {
+	if r.adapter != nil {
+		return "adapter " + r.adapter.ID
+	}
+	if r.device != nil {
+		return "device " + r.device.Address
+	}
+	return ""
+}
+
+// rows lists adapters, then paired devices by name, then the other devices,
+// strongest signal first.
+func (d *Dashboard) rows() []row {
+	adapters := append([]daemon.Adapter(nil), d.snapshot.Adapters...)
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i].ID < adapters[j].ID })
+
+	var paired, nearby []Device
+	for _, device := range d.snapshot.Devices {
+		if device.Paired {
+			paired = append(paired, device)
+		} else {
+			nearby = append(nearby, device)
+		}
+	}
+	sort.SliceStable(paired, func(i, j int) bool {
+		return strings.ToLower(deviceLabel(paired[i])) < strings.ToLower(deviceLabel(paired[j]))
+	})
+	sort.SliceStable(nearby, func(i, j int) bool {
+		a, b := nearby[i], nearby[j]
+		if a.HasRSSI != b.HasRSSI {
+			return a.HasRSSI
+		}
+		if a.RSSI != b.RSSI {
+			return a.RSSI > b.RSSI
+		}
+		return a.Address < b.Address
+	})
+
+	rows := make([]row, 0, len(adapters)+len(paired)+len(nearby))
+	for i := range adapters {
+		rows = append(rows, row{adapter: &adapters[i]})
+	}
+	for i := range paired {
+		rows = append(rows, row{device: &paired[i]})
+	}
+	for i := range nearby {
+		rows = append(rows, row{device: &nearby[i]})
+	}
+	return rows
+}
+
+// render lays the dashboard out in lines no wider than width, and returns
+// the index of the selected line, or -1. Interactive layouts fill height,
+// scrolling the lists around the selection when they do not fit and giving
+// the remaining space to the event log; otherwise width and height are
+// ignored and nothing is selected.
+func (d *Dashboard) render(width, height int, interactive bool) ([]string, int) {
+	body := []string{"Adapters"}
+	selected := -1
+	section := ""
+	for i, r := range d.rows() {
+		if r.device != nil {
+			title := "Nearby devices"
+			if r.device.Paired {
+				title = "Paired devices"
+			}
+			if title != section {
+				section = title
+				body = append(body, "", title)
+			}
+		}
+		if interactive && i == d.selected {
+			selected = len(body)
+		}
+		if r.adapter != nil {
+			body = append(body, "  "+adapterLine(*r.adapter))
+		} else {
+			body = append(body, "  "+deviceLine(*r.device))
+		}
+	}
+	if d.loaded && len(d.snapshot.Adapters) == 0 {
+		body = append(body, "  No adapters found.")
+	}
+	if d.loaded && len(d.snapshot.Devices) == 0 {
+		body = append(body, "", "  No devices known. Press s to scan.")
+	}
+
+	if !interactive {
+		return body, -1
+	}
+
+	lines := []string{"peared dashboard"}
+	available := height - 3 // title, status and help
+	if available < 1 {
+		available = 1
+	}
+
+	if len(body) > available {
+		start := 0
+		if selected >= 0 {
+			start = min(max(selected-available/2, 0), len(body)-available)
+		}
+		body = body[start : start+available]
+		if selected >= 0 {
+			selected -= start
+		}
+	} else if room := available - len(body) - 2; room > 0 && len(d.log) > 0 {
+		log := d.log
+		if len(log) > room {
+			log = log[len(log)-room:]
+		}
+		body = append(body, "", "Events")
+		for _, line := range log {
+			body = append(body, "  "+line)
+		}
+	}
+	if selected >= 0 {
+		selected += len(lines)
+	}
+
+	lines = append(lines, body...)
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+	// Errors from bluetoothctl can span lines; the status line shows the
+	// first.
+	status, _, _ := strings.Cut(d.status, "\n")
+	lines = append(lines, status, helpLine)
+
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines, selected
+}
+
+func adapterLine(adapter daemon.Adapter) string {
+	power := "off"
+	if adapter.Powered {
+		power = "powered"
+	}
+	return fmt.Sprintf("%s %s %s %s", pad(adapter.ID, 6), pad(adapter.Address, 17), pad(valueOr(adapter.Alias, "-"), 24), power)
+}
+
+func deviceLine(device Device) string {
+	var state []string
+	if device.Connected {
+		state = append(state, "connected")
+	}
+	if device.Trusted {
+		state = append(state, "trusted")
+	}
+
+	battery := "    "
+	if device.HasBattery {
+		battery = fmt.Sprintf("%3d%%", device.Battery)
+	}
+
+	line := fmt.Sprintf("%s %s %s %s %s %s %s",
+		pad(device.Address, 17),
+		pad(deviceLabel(device), 24),
+		pad(valueOr(string(device.Kind), "-"), 8),
+		pad(strings.Join(state, " "), 17),
+		signalBars(device.RSSI, device.HasRSSI),
+		battery,
+		audioColumn(device),
+	)
+	return strings.TrimRight(line, " ")
+}
+
+// audioColumn shows the active audio route, marked with an arrow, or else
+// the profiles the device offers.
+func audioColumn(device Device) string {
+	if device.Route != "" {
+		return "▶ " + device.Route
+	}
+	return strings.Join(device.Audio, ",")
+}
+
+func deviceLabel(device Device) string {
+	return valueOr(device.Name, device.Address)
+}
+
+// signalBars draws RSSI as four bars followed by the value in dBm, or blanks
+// when the device has not been seen recently. -90 dBm and below shows no
+// bars, -60 dBm and above shows all four.
+func signalBars(rssi int, known bool) string {
+	if !known {
+		return strings.Repeat(" ", 8)
+	}
+
+	level := min(max((rssi+90)*4/30+1, 0), 4)
+	if rssi <= -90 {
+		level = 0
+	}
+
+	bars := []rune("▂▄▆█")
+	var b strings.Builder
+	for i, bar := range bars {
+		if i < level {
+			b.WriteRune(bar)
+		} else {
+			b.WriteRune('·')
+		}
+	}
+	return fmt.Sprintf("%s %3d", b.String(), rssi)
+}
+
+// pad truncates or space-pads s to width runes.
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis. A
+// width of zero or less leaves s alone.
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,478 @@
+// Package tui implements `peared tui`, a full-screen dashboard of adapters and
+// devices that refreshes on daemon events and binds device actions to keys.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/term"
+)
+
+// Backend reads the state the dashboard shows and performs the actions bound
+// to its keys. Device actions name the adapter the device was seen through.
+type Backend interface {
+	Snapshot(ctx context.Context) (Snapshot, error)
+	Connect(ctx context.Context, adapter, address string) error
+	Disconnect(ctx context.Context, adapter, address string) error
+	Pair(ctx context.Context, adapter, address string) error
+	Trust(ctx context.Context, adapter, address string) error
+	Remove(ctx context.Context, adapter, address string) error
+	SetPowered(ctx context.Context, adapter string, on bool) error
+	Scan(ctx context.Context, adapter string, duration time.Duration) error
+}
+
+// Snapshot is the state shown on one screen.
+type Snapshot struct {
+	Adapters []daemon.Adapter
+	Devices  []Device
+
+	// Warnings describe what the snapshot leaves out, such as the devices
+	// of an adapter that could not be listed. They are shown on the status
+	// line.
+	Warnings []string
+}
+
+// Device is a device as the dashboard lists it.
+type Device struct {
+	Address string
+	Name    string
+	Adapter string
+	Kind    daemon.DeviceKind
+
+	Paired    bool
+	Trusted   bool
+	Connected bool
+
+	// RSSI is only known for devices seen during a recent scan.
+	RSSI    int
+	HasRSSI bool
+
+	Battery    int
+	HasBattery bool
+
+	// Audio lists the audio profiles the device offers, as returned by
+	// devclass.AudioProfiles.
+	Audio []string
+
+	// Route is the profile the sound server routes the device's audio
+	// through, such as a2dp-sink-aac, or empty when none is active.
+	Route string
+}
+
+const (
+	defaultRefresh = 5 * time.Second
+	scanDuration   = 10 * time.Second
+	logLines       = 50
+)
+
+// Option configures a Dashboard.
+type Option func(*Dashboard)
+
+// WithRefresh sets how often the dashboard polls the backend between daemon
+// events.
+func WithRefresh(interval time.Duration) Option {
+	return func(d *Dashboard) {
+		if interval > 0 {
+			d.refresh = interval
+		}
+	}
+}
+
+// WithEvents supplies daemon event lines. Each one is added to the event log
+// and triggers a refresh.
+func WithEvents(events <-chan string) Option {
+	return func(d *Dashboard) {
+		d.events = events
+	}
+}
+
+// Dashboard is the state of a running `peared tui`. All fields are owned by
+// the goroutine in Run; actions report back through a channel.
+type Dashboard struct {
+	backend Backend
+	refresh time.Duration
+	events  <-chan string
+
+	snapshot Snapshot
+	loaded   bool
+	selected int
+	status   string
+	warning  string
+	log      []string
+	busy     int
+	confirm  string
+	width    int
+	height   int
+}
+
+// New creates a dashboard showing the state reported by backend.
+func New(backend Backend, opts ...Option) *Dashboard {
+	d := &Dashboard{backend: backend, refresh: defaultRefresh}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(d)
+		}
+	}
+	return d
+}
+
+// Run shows the dashboard on out and reads keys from in until q is pressed or
+// ctx is cancelled. When in or out is not a terminal, or $TERM cannot
+// position the cursor, it prints the state once instead.
+func (d *Dashboard) Run(ctx context.Context, in, out *os.File) error {
+	if ctx == nil {
+		return errors.New("nil context passed to Dashboard.Run")
+	}
+	if term.IsDumb() || !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return d.Print(ctx, out)
+	}
+
+	restore, err := term.MakeRaw(in)
+	if err != nil {
+		return d.Print(ctx, out)
+	}
+	defer restore()
+
+	// Switch to the alternate screen and hide the cursor, undoing both on
+	// the way out.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	return d.loop(ctx, in, out)
+}
+
+// Print writes the current state as plain text, for dumb terminals and pipes.
+func (d *Dashboard) Print(ctx context.Context, out io.Writer) error {
+	snapshot, err := d.backend.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	d.snapshot, d.loaded = snapshot, true
+
+	lines, _ := d.render(0, 0, false)
+	if len(snapshot.Warnings) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, snapshot.Warnings...)
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outcome is what a background refresh or action reports back to the loop.
+type outcome struct {
+	snapshot *Snapshot
+	message  string
+	err      error
+}
+
+func (d *Dashboard) loop(ctx context.Context, in io.Reader, out *os.File) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := readKeys(in)
+	resize := make(chan os.Signal, 1)
+	term.NotifyResize(resize)
+	defer signal.Stop(resize)
+
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+
+	results := make(chan outcome)
+	refreshing, again := false, false
+	refresh := func() {
+		if refreshing {
+			again = true
+			return
+		}
+		refreshing = true
+		go func() {
+			snapshot, err := d.backend.Snapshot(ctx)
+			select {
+			case results <- outcome{snapshot: &snapshot, err: err}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	d.status = "Loading…"
+	d.resize(out)
+	refresh()
+
+	for {
+		d.draw(out)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			if d.handleKey(ctx, k, results, refresh) {
+				return nil
+			}
+		case result := <-results:
+			if result.snapshot != nil {
+				refreshing = false
+				if result.err != nil {
+					d.status = fmt.Sprintf("Refresh failed: %v", result.err)
+				} else {
+					d.update(*result.snapshot)
+				}
+				if again {
+					again = false
+					refresh()
+				}
+				continue
+			}
+			d.busy--
+			if result.err != nil {
+				d.status = result.err.Error()
+			} else {
+				d.status = result.message
+			}
+			refresh()
+		case line, ok := <-d.events:
+			if !ok {
+				d.events = nil
+				continue
+			}
+			d.addLog(line)
+			refresh()
+		case <-ticker.C:
+			refresh()
+		case <-resize:
+			d.resize(out)
+		}
+	}
+}
+
+// update replaces the snapshot, keeping the same row selected when it is
+// still listed.
+func (d *Dashboard) update(snapshot Snapshot) {
+	var selectedKey string
+	if rows := d.rows(); d.selected < len(rows) {
+		selectedKey = rows[d.selected].key()
+	}
+
+	// Warnings replace the previous ones on the status line but never the
+	// outcome of an action.
+	d.snapshot, d.loaded = snapshot, true
+	warning := strings.Join(snapshot.Warnings, "; ")
+	if d.busy == 0 && (d.status == "Loading…" || d.status == d.warning) {
+		d.status = warning
+	}
+	d.warning = warning
+
+	rows := d.rows()
+	for i, r := range rows {
+		if r.key() == selectedKey {
+			d.selected = i
+			return
+		}
+	}
+	if d.selected >= len(rows) {
+		d.selected = max(len(rows)-1, 0)
+	}
+}
+
+func (d *Dashboard) addLog(line string) {
+	d.log = append(d.log, line)
+	if len(d.log) > logLines {
+		d.log = d.log[len(d.log)-logLines:]
+	}
+}
+
+func (d *Dashboard) resize(out *os.File) {
+	width, height, err := term.Size(out)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	d.width, d.height = width, height
+}
+
+// draw repaints the screen in place, highlighting the selected row.
+func (d *Dashboard) draw(out io.Writer) {
+	lines, selected := d.render(d.width, d.height, true)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		if i == selected {
+			b.WriteString("\x1b[7m" + line + "\x1b[m")
+		} else {
+			b.WriteString(line)
+		}
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(out, b.String())
+}
+
+// handleKey acts on a key press and reports whether the dashboard should
+// exit. Actions run in the background and report to results.
+func (d *Dashboard) handleKey(ctx context.Context, k term.Key, results chan<- outcome, refresh func()) bool {
+	confirm := d.confirm
+	d.confirm = ""
+
+	rows := d.rows()
+	var current row
+	if d.selected < len(rows) {
+		current = rows[d.selected]
+	}
+
+	switch {
+	case k.Code == term.KeyUp || k.Rune == 'k':
+		d.selected = max(d.selected-1, 0)
+	case k.Code == term.KeyDown || k.Rune == 'j':
+		d.selected = min(d.selected+1, max(len(rows)-1, 0))
+	case k.Code == term.KeyHome || k.Rune == 'g':
+		d.selected = 0
+	case k.Code == term.KeyEnd || k.Rune == 'G':
+		d.selected = max(len(rows)-1, 0)
+	case k.Rune == 'q' || k.Rune == ctrl('c') || k.Rune == ctrl('d'):
+		return true
+	case k.Rune == 'r' || k.Rune == ctrl('l'):
+		refresh()
+	case k.Rune == 'c':
+		d.deviceAction(ctx, current, results, "Connecting to", "Connected", "connect", d.backend.Connect)
+	case k.Rune == 'd':
+		d.deviceAction(ctx, current, results, "Disconnecting from", "Disconnected", "disconnect", d.backend.Disconnect)
+	case k.Rune == 'p':
+		d.deviceAction(ctx, current, results, "Pairing with", "Paired", "pair", d.backend.Pair)
+	case k.Rune == 't':
+		d.deviceAction(ctx, current, results, "Trusting", "Trusted", "trust", d.backend.Trust)
+	case k.Rune == 'x':
+		if current.device == nil {
+			d.status = "Select a device to remove."
+			break
+		}
+		// Removing forgets the pairing, so it takes a second press.
+		if confirm != current.device.Address {
+			d.confirm = current.device.Address
+			d.status = fmt.Sprintf("Press x again to remove %s.", deviceLabel(*current.device))
+			break
+		}
+		d.deviceAction(ctx, current, results, "Removing", "Removed", "remove", d.backend.Remove)
+	case k.Rune == 'o':
+		adapter, ok := d.adapterFor(current)
+		if !ok {
+			d.status = "Select an adapter to power on or off."
+			break
+		}
+		on := !adapter.Powered
+		d.start(ctx, results, fmt.Sprintf("Powering %s %s…", adapter.ID, onOff(on)), fmt.Sprintf("Powered %s %s.", adapter.ID, onOff(on)), func(ctx context.Context) error {
+			if err := d.backend.SetPowered(ctx, adapter.ID, on); err != nil {
+				return fmt.Errorf("power %s %s: %w", onOff(on), adapter.ID, err)
+			}
+			return nil
+		})
+	case k.Rune == 's':
+		adapter, _ := d.adapterFor(current)
+		target := valueOr(adapter.ID, "the default adapter")
+		d.start(ctx, results, fmt.Sprintf("Scanning on %s for %s…", target, scanDuration), "Scan finished.", func(ctx context.Context) error {
+			if err := d.backend.Scan(ctx, adapter.ID, scanDuration); err != nil {
+				return fmt.Errorf("scan on %s: %w", target, err)
+			}
+			return nil
+		})
+	}
+	return false
+}
+
+func (d *Dashboard) deviceAction(ctx context.Context, current row, results chan<- outcome, doing, done, verb string, action func(ctx context.Context, adapter, address string) error) {
+	if current.device == nil {
+		d.status = fmt.Sprintf("Select a device to %s.", verb)
+		return
+	}
+
+	device := *current.device
+	label := deviceLabel(device)
+	d.start(ctx, results, fmt.Sprintf("%s %s…", doing, label), fmt.Sprintf("%s %s.", done, label), func(ctx context.Context) error {
+		if err := action(ctx, device.Adapter, device.Address); err != nil {
+			return fmt.Errorf("%s %s: %w", verb, label, err)
+		}
+		return nil
+	})
+}
+
+// start runs fn in the background, showing pending in the status line until
+// it finishes.
+func (d *Dashboard) start(ctx context.Context, results chan<- outcome, pending, done string, fn func(ctx context.Context) error) {
+	d.busy++
+	d.status = pending
+	go func() {
+		err := fn(ctx)
+		select {
+		case results <- outcome{message: done, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// adapterFor returns the selected adapter, or the adapter the selected
+// device was seen through.
+func (d *Dashboard) adapterFor(current row) (daemon.Adapter, bool) {
+	switch {
+	case current.adapter != nil:
+		return *current.adapter, true
+	case current.device != nil:
+		for _, adapter := range d.snapshot.Adapters {
+			if adapter.ID == current.device.Adapter {
+				return adapter, true
+			}
+		}
+	}
+	return daemon.Adapter{}, false
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+// readKeys decodes key presses from in until it fails.
+func readKeys(in io.Reader) <-chan term.Key {
+	keys := make(chan term.Key)
+	go func() {
+		defer close(keys)
+		reader := bufio.NewReader(in)
+		for {
+			k, err := term.ReadKey(reader)
+			if err != nil {
+				return
+			}
+			keys <- k
+		}
+	}()
+	return keys
+}
@@ -0,0 +1,359 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peared/peared/internal/daemon"
+	"github.com/peared/peared/internal/term"
+)
+
+type fakeBackend struct {
+	snapshot Snapshot
+	calls    []string
+	err      error
+}
+
+func (f *fakeBackend) Snapshot(context.Context) (Snapshot, error) {
+	return f.snapshot, nil
+}
+
+func (f *fakeBackend) record(call string) error {
+	f.calls = append(f.calls, call)
+	return f.err
+}
+
+func (f *fakeBackend) Connect(_ context.Context, adapter, address string) error {
+	return f.record("connect " + adapter + " " + address)
+}
+
+func (f *fakeBackend) Disconnect(_ context.Context, adapter, address string) error {
+	return f.record("disconnect " + adapter + " " + address)
+}
+
+func (f *fakeBackend) Pair(_ context.Context, adapter, address string) error {
+	return f.record("pair " + adapter + " " + address)
+}
+
+func (f *fakeBackend) Trust(_ context.Context, adapter, address string) error {
+	return f.record("trust " + adapter + " " + address)
+}
+
+func (f *fakeBackend) Remove(_ context.Context, adapter, address string) error {
+	return f.record("remove " + adapter + " " + address)
+}
+
+func (f *fakeBackend) SetPowered(_ context.Context, adapter string, on bool) error {
+	return f.record("power " + adapter + " " + onOff(on))
+}
+
+func (f *fakeBackend) Scan(_ context.Context, adapter string, _ time.Duration) error {
+	return f.record("scan " + adapter)
+}
+
+func sampleSnapshot() Snapshot {
+	return Snapshot{
+		Adapters: []daemon.Adapter{
+			{ID: "hci1", Address: "00:11:22:33:44:66", Alias: "Dongle"},
+			{ID: "hci0", Address: "00:11:22:33:44:55", Alias: "Laptop", Powered: true},
+		},
+		Devices: []Device{
+			{Address: "11:11:11:11:11:11", Name: "Phone", Adapter: "hci0", RSSI: -85, HasRSSI: true},
+			{Address: "AA:BB:CC:DD:EE:FF", Name: "Headphones", Adapter: "hci0", Kind: daemon.DeviceKindHeadset, Paired: true, Connected: true, Trusted: true, RSSI: -55, HasRSSI: true, Battery: 80, HasBattery: true, Audio: []string{"A2DP", "HFP"}},
+			{Address: "22:22:22:22:22:22", Name: "Speaker", Adapter: "hci1", RSSI: -60, HasRSSI: true},
+			{Address: "33:33:33:33:33:33", Name: "Keyboard", Adapter: "hci0", Kind: daemon.DeviceKindKeyboard, Paired: true},
+		},
+	}
+}
+
+// press runs one key through the dashboard and waits for the action it
+// started, if any.
+func press(t *testing.T, d *Dashboard, k term.Key) {
+	t.Helper()
+
+	results := make(chan outcome, 1)
+	busy := d.busy
+	if d.handleKey(context.Background(), k, results, func() {}) {
+		t.Fatalf("key %+v quit the dashboard", k)
+	}
+	if d.busy == busy {
+		return
+	}
+
+	select {
+	case result := <-results:
+		d.busy--
+		if result.err != nil {
+			d.status = result.err.Error()
+		} else {
+			d.status = result.message
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("action for key %+v did not finish", k)
+	}
+}
+
+func runeKey(r rune) term.Key {
+	return term.Key{Code: term.KeyRune, Rune: r}
+}
+
+func TestRowsOrderAdaptersPairedThenNearby(t *testing.T) {
+	d := New(&fakeBackend{})
+	d.update(sampleSnapshot())
+
+	var keys []string
+	for _, r := range d.rows() {
+		keys = append(keys, r.key())
+	}
+
+	want := []string{
+		"adapter hci0",
+		"adapter hci1",
+		"device AA:BB:CC:DD:EE:FF",
+		"device 33:33:33:33:33:33",
+		"device 22:22:22:22:22:22",
+		"device 11:11:11:11:11:11",
+	}
+	if strings.Join(keys, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected rows:\n got %v\nwant %v", keys, want)
+	}
+}
+
+func TestRenderShowsDeviceDetails(t *testing.T) {
+	d := New(&fakeBackend{})
+	d.update(sampleSnapshot())
+	d.selected = 2
+	d.addLog("2024-01-01 10:00:00\tadapter.switched\tswitched to hci1")
+
+	lines, selected := d.render(120, 24, true)
+	if len(lines) != 24 {
+		t.Fatalf("expected 24 lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[selected], "Headphones") {
+		t.Fatalf("selected line %q is not the headphones", lines[selected])
+	}
+
+	for _, want := range []string{"connected trusted", "▂▄▆█ -55", " 80%", "A2DP,HFP", "headset"} {
+		if !strings.Contains(lines[selected], want) {
+			t.Errorf("device line %q lacks %q", lines[selected], want)
+		}
+	}
+
+	routed := sampleSnapshot().Devices[1]
+	routed.Route = "a2dp-sink-aac"
+	if line := deviceLine(routed); !strings.HasSuffix(line, "▶ a2dp-sink-aac") {
+		t.Errorf("device line %q lacks the active route", line)
+	}
+
+	screen := strings.Join(lines, "\n")
+	for _, want := range []string{"Adapters", "Paired devices", "Nearby devices", "Events", "switched to hci1", "hci0   00:11:22:33:44:55 Laptop                   powered"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("screen lacks %q:\n%s", want, screen)
+		}
+	}
+	if lines[len(lines)-1] != helpLine {
+		t.Errorf("last line is %q, want the key help", lines[len(lines)-1])
+	}
+}
+
+func TestRenderScrollsToSelection(t *testing.T) {
+	d := New(&fakeBackend{})
+	d.update(sampleSnapshot())
+	d.selected = 5
+	d.addLog("an event")
+
+	lines, selected := d.render(40, 8, true)
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 lines, got %d", len(lines))
+	}
+	if selected < 0 || !strings.HasPrefix(lines[selected], "  11:11:11:11:11:11") {
+		t.Fatalf("selection not visible: %d in %q", selected, lines)
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 40 {
+			t.Errorf("line %q is %d runes wide", line, n)
+		}
+		if strings.Contains(line, "an event") {
+			t.Errorf("event log shown although the lists do not fit")
+		}
+	}
+}
+
+func TestPrintWritesPlainSnapshot(t *testing.T) {
+	d := New(&fakeBackend{snapshot: sampleSnapshot()})
+
+	var out bytes.Buffer
+	if err := d.Print(context.Background(), &out); err != nil {
+		t.Fatalf("Print returned error: %v", err)
+	}
+	if strings.Contains(out.String(), "\x1b") {
+		t.Fatalf("plain output contains escape sequences: %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Headphones") || strings.Contains(out.String(), helpLine) {
+		t.Fatalf("unexpected plain output:\n%s", out.String())
+	}
+}
+
+func TestUpdateKeepsSelectedRow(t *testing.T) {
+	d := New(&fakeBackend{})
+	d.update(sampleSnapshot())
+	d.selected = 4 // Speaker
+
+	snapshot := sampleSnapshot()
+	snapshot.Adapters = snapshot.Adapters[1:]
+	d.update(snapshot)
+
+	if got := d.rows()[d.selected].key(); got != "device 22:22:22:22:22:22" {
+		t.Fatalf("selection moved to %s", got)
+	}
+}
+
+func TestKeysRunDeviceActions(t *testing.T) {
+	backend := &fakeBackend{}
+	d := New(backend)
+	d.update(sampleSnapshot())
+
+	press(t, d, term.Key{Code: term.KeyDown})
+	press(t, d, runeKey('j'))
+	press(t, d, runeKey('d'))
+	press(t, d, runeKey('c'))
+	press(t, d, runeKey('t'))
+	press(t, d, term.Key{Code: term.KeyEnd})
+	press(t, d, runeKey('p'))
+
+	want := []string{
+		"disconnect hci0 AA:BB:CC:DD:EE:FF",
+		"connect hci0 AA:BB:CC:DD:EE:FF",
+		"trust hci0 AA:BB:CC:DD:EE:FF",
+		"pair hci0 11:11:11:11:11:11",
+	}
+	if strings.Join(backend.calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected calls:\n got %v\nwant %v", backend.calls, want)
+	}
+	if d.status != "Paired Phone." {
+		t.Fatalf("unexpected status %q", d.status)
+	}
+}
+
+func TestRemoveNeedsConfirmation(t *testing.T) {
+	backend := &fakeBackend{}
+	d := New(backend)
+	d.update(sampleSnapshot())
+	d.selected = 3
+
+	press(t, d, runeKey('x'))
+	if len(backend.calls) != 0 || !strings.Contains(d.status, "Press x again") {
+		t.Fatalf("remove ran without confirmation: %v (%q)", backend.calls, d.status)
+	}
+
+	press(t, d, runeKey('j'))
+	press(t, d, runeKey('k'))
+	press(t, d, runeKey('x'))
+	if len(backend.calls) != 0 {
+		t.Fatalf("confirmation survived another key: %v", backend.calls)
+	}
+
+	press(t, d, runeKey('x'))
+	if strings.Join(backend.calls, "|") != "remove hci0 33:33:33:33:33:33" {
+		t.Fatalf("unexpected calls: %v", backend.calls)
+	}
+}
+
+func TestPowerTogglesSelectedAdapter(t *testing.T) {
+	backend := &fakeBackend{}
+	d := New(backend)
+	d.update(sampleSnapshot())
+
+	press(t, d, runeKey('o'))
+	d.selected = 4 // Speaker, seen through hci1
+	press(t, d, runeKey('o'))
+	press(t, d, runeKey('s'))
+
+	want := []string{"power hci0 off", "power hci1 on", "scan hci1"}
+	if strings.Join(backend.calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected calls:\n got %v\nwant %v", backend.calls, want)
+	}
+}
+
+func TestActionErrorsShowInStatus(t *testing.T) {
+	backend := &fakeBackend{err: errors.New("bluetoothctl failed\nmore output")}
+	d := New(backend)
+	d.update(sampleSnapshot())
+	d.selected = 2
+
+	press(t, d, runeKey('c'))
+	if d.status != "connect Headphones: bluetoothctl failed\nmore output" {
+		t.Fatalf("unexpected status %q", d.status)
+	}
+
+	lines, _ := d.render(200, 24, true)
+	if status := lines[len(lines)-2]; status != "connect Headphones: bluetoothctl failed" {
+		t.Fatalf("unexpected status line %q", status)
+	}
+}
+
+func TestWarningsShowInStatus(t *testing.T) {
+	backend := &fakeBackend{}
+	d := New(backend)
+	d.status = "Loading…"
+
+	snapshot := sampleSnapshot()
+	snapshot.Warnings = []string{"list devices on hci1: org.bluez.Error.NotReady"}
+	d.update(snapshot)
+	if d.status != "list devices on hci1: org.bluez.Error.NotReady" {
+		t.Fatalf("unexpected status %q", d.status)
+	}
+
+	d.update(sampleSnapshot())
+	if d.status != "" {
+		t.Fatalf("warning outlived the refresh that cleared it: %q", d.status)
+	}
+
+	d.selected = 2
+	press(t, d, runeKey('c'))
+	d.update(snapshot)
+	if d.status != "Connected Headphones." {
+		t.Fatalf("warning replaced the outcome of an action: %q", d.status)
+	}
+}
+
+func TestDeviceActionsNeedDevice(t *testing.T) {
+	backend := &fakeBackend{}
+	d := New(backend)
+	d.update(sampleSnapshot())
+
+	press(t, d, runeKey('c'))
+	if len(backend.calls) != 0 || d.status != "Select a device to connect." {
+		t.Fatalf("unexpected result: %v (%q)", backend.calls, d.status)
+	}
+}
+
+func TestQuitKeys(t *testing.T) {
+	for _, r := range []rune{'q', ctrl('c')} {
+		d := New(&fakeBackend{})
+		if !d.handleKey(context.Background(), runeKey(r), nil, func() {}) {
+			t.Errorf("key %q did not quit", r)
+		}
+	}
+}
+
+func TestSignalBars(t *testing.T) {
+	cases := map[int]string{
+		-40: "▂▄▆█ -40",
+		-70: "▂▄▆· -70",
+		-85: "▂··· -85",
+		-95: "···· -95",
+	}
+	for rssi, want := range cases {
+		if got := signalBars(rssi, true); got != want {
+			t.Errorf("signalBars(%d) = %q, want %q", rssi, got, want)
+		}
+	}
+	if got := signalBars(0, false); strings.TrimSpace(got) != "" {
+		t.Errorf("unknown RSSI drawn as %q", got)
+	}
+}